@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+	"time"
+
+	"auth"
+	"config"
+)
+
+// Config is the configuration of the service, loaded from the environment
+// and the optional CONFIG_FILE. Tracing, metrics and logging read their
+// OTEL_* and LOG_* variables themselves.
+type Config struct {
+	Port string `env:"PORT" default:"8080"`
+
+	OrdersTable        string `env:"ORDERS_TABLE" default:"orders"`
+	OrdersTopicArn     string `env:"ORDERS_TOPIC_ARN" required:"true"`
+	ProductsServiceURL string `env:"PRODUCTS_SERVICE_URL" default:"http://products-service:8080"`
+
+	ShutdownDrainPeriod time.Duration `env:"SHUTDOWN_DRAIN_PERIOD" default:"5s"`
+	ShutdownTimeout     time.Duration `env:"SHUTDOWN_TIMEOUT" default:"20s"`
+
+	Auth auth.Config
+	AWS  config.AWS
+}
+
+// Validate checks the shutdown periods, which cannot be negative.
+func (c *Config) Validate() error {
+	var errs []error
+	if c.ShutdownDrainPeriod < 0 {
+		errs = append(errs, errors.New("SHUTDOWN_DRAIN_PERIOD cannot be negative"))
+	}
+	if c.ShutdownTimeout < 0 {
+		errs = append(errs, errors.New("SHUTDOWN_TIMEOUT cannot be negative"))
+	}
+	return errors.Join(errs...)
+}
@@ -1,90 +1,122 @@
 package main
 
 import (
+	"auth"
+	"config"
 	"context"
-	"log"
+	"flag"
+	"log/slog"
 	"os"
+	"time"
 
 	"orders-service/internal/handlers"
+	"orders-service/internal/products"
 	"orders-service/internal/publisher"
 	"orders-service/internal/repository"
-	"orders-service/internal/tracing"
+	"telemetry/health"
+	"telemetry/logging"
+	"telemetry/metrics"
+	"telemetry/tracing"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
-	"github.com/aws/aws-sdk-go-v2/service/sns"
 	"github.com/gofiber/contrib/otelfiber/v2"
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+)
+
+const (
+	// providerShutdownTimeout bounds the flush of the telemetry on exit.
+	providerShutdownTimeout = 5 * time.Second
 )
 
 func main() {
-	tempoEndpoint := getEnv("TEMPO_ENDPOINT", "tempo:4318")
-	tp := tracing.InitTracer("orders-service", tempoEndpoint)
+	logging.Init("orders-service")
+
+	printConfig := flag.Bool("print-config", false, "print the effective configuration, with secrets redacted, and exit")
+	flag.Parse()
+
+	var conf Config
+	if err := config.Load(&conf); err != nil {
+		logging.Fatal("failed to load configuration", "error", err)
+	}
+	if *printConfig {
+		if err := config.Print(os.Stdout, &conf); err != nil {
+			logging.Fatal("failed to print configuration", "error", err)
+		}
+		return
+	}
+
+	tp, err := tracing.InitTracer(context.Background(), "orders-service")
+	if err != nil {
+		slog.Warn("tracing is partially disabled", "error", err)
+	}
 	defer func() {
-		if err := tp.Shutdown(context.Background()); err != nil {
-			log.Printf("Error shutting down tracer provider: %v", err)
+		ctx, cancel := context.WithTimeout(context.Background(), providerShutdownTimeout)
+		defer cancel()
+		if err := tp.Shutdown(ctx); err != nil {
+			slog.Error("failed to shut down the tracer provider", "error", err)
 		}
 	}()
-	awsEndpoint := getEnv("AWS_ENDPOINT", "")
-	awsRegion := getEnv("AWS_REGION", "us-west-2")
-
-	customResolver := aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...interface{}) (aws.Endpoint, error) {
-		if awsEndpoint != "" {
-			return aws.Endpoint{
-				PartitionID:   "aws",
-				URL:           awsEndpoint,
-				SigningRegion: awsRegion,
-			}, nil
+	mp, metricsHandler, err := metrics.InitMeter(context.Background(), "orders-service")
+	if err != nil {
+		slog.Warn("metrics are partially disabled", "error", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), providerShutdownTimeout)
+		defer cancel()
+		if err := mp.Shutdown(ctx); err != nil {
+			slog.Error("failed to shut down the meter provider", "error", err)
 		}
+	}()
 
-		// returning EndpointNotFoundError will allow the service to fallback to it's default resolution
-		return aws.Endpoint{}, &aws.EndpointNotFoundError{}
-	})
-
-	cfg, err := config.LoadDefaultConfig(context.Background(), config.WithEndpointResolverWithOptions(customResolver))
+	cfg, err := conf.AWS.Load(context.Background())
 	if err != nil {
-		log.Fatalf("failed to load AWS config: %v", err)
+		logging.Fatal("failed to load AWS config", "error", err)
 	}
-	dynamoClient := dynamodb.NewFromConfig(cfg)
+	metrics.InstrumentAWS(&cfg)
+	dynamoClient := conf.AWS.DynamoDB(cfg)
+	orderRepo := repository.NewDynamoOrderRepository(dynamoClient, conf.OrdersTable)
 
-	tableName := getEnv("ORDERS_TABLE", "orders")
-	orderRepo := repository.NewDynamoOrderRepository(dynamoClient, tableName)
+	snsClient := conf.AWS.SNS(cfg)
+	orderPublisher := publisher.NewSnsOrderPublisher(snsClient, conf.OrdersTopicArn)
 
-	snsTopicArn := getEnv("ORDERS_TOPIC_ARN", "")
-	if snsTopicArn == "" {
-		log.Fatal("ORDERS_TOPIC_ARN environment variable is required")
-	}
+	// products-service is left out of readiness: orders are served
+	// without their allocation while it is down.
+	readiness := health.NewChecker(health.DefaultCacheTTL, health.DefaultTimeout)
+	readiness.Add("dynamodb:"+conf.OrdersTable, health.DynamoDBTable(dynamoClient, conf.OrdersTable))
+	readiness.Add("sns:"+conf.OrdersTopicArn, health.SNSTopic(snsClient, conf.OrdersTopicArn))
 
-	snsClient := sns.NewFromConfig(cfg)
-	orderPublisher := publisher.NewSnsOrderPublisher(snsClient, snsTopicArn)
+	allocationClient := products.NewHTTPAllocationClient(conf.ProductsServiceURL)
 
 	app := fiber.New()
+
+	// Probes and scrapes come before the middleware so they are neither
+	// traced nor logged. /healthz stays for the probes that still use it.
+	app.Get("/livez", adaptor.HTTPHandler(health.Live()))
+	app.Get("/healthz", adaptor.HTTPHandler(health.Live()))
+	app.Get("/readyz", adaptor.HTTPHandler(readiness))
+	if metricsHandler != nil {
+		app.Get("/metrics", adaptor.HTTPHandler(metricsHandler))
+	}
+
+	// Besides the server spans, otelfiber records the duration, and so the
+	// rate and errors, of the requests per route and status code.
 	app.Use(otelfiber.Middleware())
+	app.Use(logging.AccessLog())
+	app.Use(auth.Middleware(auth.NewVerifier(conf.Auth)))
 
-	app.Get("/healthz", func(c *fiber.Ctx) error {
-		return c.Status(fiber.StatusOK).JSON(fiber.Map{
-			"status": "ok",
-		})
-	})
-	api := app.Group("/api/orders")
+	// Every order route takes a signed-in caller. Customers are limited to
+	// their own orders by the handlers; changing the status of an order
+	// takes an admin.
+	api := app.Group("/api/orders", auth.Require())
 	api.Post("/", handlers.CreateOrderHandler(orderRepo, orderPublisher))
-	api.Get("/", handlers.ListOrdersHandler(orderRepo))
-	api.Get("/:id", handlers.ListOrdersHandler(orderRepo))
-	api.Patch("/:id", handlers.PatchOrderHandler(orderRepo, orderPublisher))
+	api.Get("/", handlers.ListOrdersHandler(orderRepo, allocationClient))
+	api.Get("/:id", handlers.ListOrdersHandler(orderRepo, allocationClient))
+	api.Patch("/:id", auth.Require(auth.RoleAdmin), handlers.PatchOrderHandler(orderRepo, orderPublisher))
 	api.Delete("/:id", handlers.DeleteOrderHandler(orderRepo, orderPublisher))
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-	}
+	slog.Info("starting orders service", "port", conf.Port)
+	health.Serve(app, conf.Port, readiness, conf.ShutdownDrainPeriod, conf.ShutdownTimeout)
 
-	log.Fatal(app.Listen(":" + port))
-}
-
-func getEnv(key, fallback string) string {
-	if value, ok := os.LookupEnv(key); ok {
-		return value
-	}
-	return fallback
+	// The telemetry is flushed by the deferred calls.
+	slog.Info("orders service stopped")
 }
@@ -1,15 +1,96 @@
 package domain
 
+import "slices"
+
 type Order struct {
-	ID        string      `json:"id" dynamodbav:"id"`
-	Status    string      `json:"status" dynamodbav:"status"`
-	CreatedAt string      `json:"createdAt" dynamodbav:"createdAt"`
-	Items     []OrderItem `json:"items" dynamodbav:"items"`
-	Deleted   bool        `json:"deleted"`
+	ID     string `json:"id" dynamodbav:"id"`
+	Status string `json:"status" dynamodbav:"status"`
+	// CustomerID is who placed the order. Orders placed before customers
+	// were recorded have none.
+	CustomerID string      `json:"customerId,omitempty" dynamodbav:"customerId,omitempty"`
+	CreatedAt  string      `json:"createdAt" dynamodbav:"createdAt"`
+	Items      []OrderItem `json:"items" dynamodbav:"items"`
+	ShipTo     *Location   `json:"shipTo,omitempty" dynamodbav:"shipTo,omitempty"`
+	Deleted    bool        `json:"deleted"`
+	// Allocation says which warehouses fulfill the items. It is owned by
+	// products-service and only attached when a single order is read.
+	Allocation []AllocationLine `json:"allocation,omitempty" dynamodbav:"-"`
 }
 
 type OrderItem struct {
 	ProductID   string `json:"productId" dynamodbav:"productId"`
+	SKU         string `json:"sku,omitempty" dynamodbav:"sku,omitempty"`
 	ProductName string `json:"productName" dynamodbav:"productName"`
 	Quantity    int    `json:"quantity" dynamodbav:"quantity"`
 }
+
+// IsOpen reports whether the order is still in progress, i.e. it was not
+// delivered, canceled or returned.
+func (o *Order) IsOpen() bool {
+	switch o.Status {
+	case "delivered", "canceled", "returned":
+		return false
+	}
+	return true
+}
+
+// transitions holds the statuses an order can go to from each status.
+// Orders only move forward; canceled and returned orders are final, and a
+// delivered order can only be returned.
+var transitions = map[string][]string{
+	"created":   {"pending", "shipped", "delivered", "canceled"},
+	"pending":   {"shipped", "delivered", "canceled"},
+	"shipped":   {"delivered", "returned", "canceled"},
+	"delivered": {"returned"},
+	"canceled":  nil,
+	"returned":  nil,
+}
+
+// IsStatus reports whether status is one an order can have.
+func IsStatus(status string) bool {
+	_, ok := transitions[status]
+	return ok
+}
+
+// CanTransition reports whether the order can go from its status to
+// status.
+func (o *Order) CanTransition(status string) bool {
+	return slices.Contains(transitions[o.Status], status)
+}
+
+// CustomerCancelable reports whether the customer who placed the order can
+// still cancel it, which is only until it is being shipped.
+func (o *Order) CustomerCancelable() bool {
+	switch o.Status {
+	case "created", "pending":
+		return true
+	}
+	return false
+}
+
+// HasProduct reports whether any item of the order is for the product.
+func (o *Order) HasProduct(productID string) bool {
+	for _, item := range o.Items {
+		if item.ProductID == productID {
+			return true
+		}
+	}
+	return false
+}
+
+// Location is the destination an order ships to, used to allocate it to the
+// nearest warehouse.
+type Location struct {
+	Latitude  float64 `json:"latitude" dynamodbav:"latitude"`
+	Longitude float64 `json:"longitude" dynamodbav:"longitude"`
+}
+
+// AllocationLine says how many units of the order item at position Item are
+// picked from a warehouse.
+type AllocationLine struct {
+	Item        int    `json:"item"`
+	ProductID   string `json:"productId"`
+	SKU         string `json:"sku,omitempty"`
+	WarehouseID string `json:"warehouseId"`
+	Quantity    int    `json:"quantity"`
+}
@@ -0,0 +1,19 @@
+package handlers
+
+import (
+	"context"
+	"telemetry/metrics"
+	"telemetry/tracing"
+
+	"go.opentelemetry.io/otel/metric"
+)
+
+var orderStatusChanges, _ = metrics.Meter("orders-service/internal/handlers").Int64Counter(
+	"orders.status_changes",
+	metric.WithUnit("{order}"),
+	metric.WithDescription("Orders that entered a status, by status; creating an order enters created"),
+)
+
+func recordStatusChange(ctx context.Context, status string) {
+	orderStatusChanges.Add(ctx, 1, metric.WithAttributes(tracing.StringAttribute("status", status)))
+}
@@ -1,25 +1,36 @@
 package handlers
 
 import (
+	"auth"
+	"errors"
+	"log/slog"
 	"orders-service/internal/domain"
+	"orders-service/internal/products"
 	"orders-service/internal/publisher"
 	"orders-service/internal/repository"
-	"orders-service/internal/tracing"
-
+	"strconv"
+	"telemetry/tracing"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 )
 
+var errOrderOfOtherCustomer = errors.New("order of another customer")
+
 // CreateOrderHandler handles POST /api/orders
+//
+// A customer places orders for itself: the customerId of the order is the
+// subject of its token. The back office can place them for any customer.
 func CreateOrderHandler(repo repository.OrderRepository, pub publisher.OrderPublisher) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		ctx, span := tracing.NewSpan(c.UserContext(), "CreateOrderHandler")
 		defer span.End()
 
 		var input struct {
-			Items []domain.OrderItem `json:"items"`
+			CustomerID string             `json:"customerId"`
+			Items      []domain.OrderItem `json:"items"`
+			ShipTo     *domain.Location   `json:"shipTo"`
 		}
 
 		if err := c.BodyParser(&input); err != nil {
@@ -29,18 +40,53 @@ func CreateOrderHandler(repo repository.OrderRepository, pub publisher.OrderPubl
 			})
 		}
 
+		principal, ok := auth.FromContext(ctx)
+		if !ok {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Authentication required",
+			})
+		}
+		if !principal.IsStaff() {
+			if input.CustomerID != "" && input.CustomerID != principal.Subject {
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+					"error": "Customers can only place their own orders",
+				})
+			}
+			input.CustomerID = principal.Subject
+		}
+
 		if len(input.Items) == 0 {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 				"error": "Order must have at least one item",
 			})
 		}
+		for _, item := range input.Items {
+			if item.ProductID == "" {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "Every item needs a productId",
+				})
+			}
+			if item.Quantity < 1 {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "Every item needs a quantity of at least 1",
+				})
+			}
+		}
+
+		if input.ShipTo != nil && (input.ShipTo.Latitude < -90 || input.ShipTo.Latitude > 90 || input.ShipTo.Longitude < -180 || input.ShipTo.Longitude > 180) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "shipTo coordinates are out of range",
+			})
+		}
 
 		order := domain.Order{
-			ID:        uuid.New().String(),
-			Status:    "created",
-			CreatedAt: time.Now().UTC().Format(time.RFC3339),
-			Items:     input.Items,
-			Deleted:   false,
+			ID:         uuid.New().String(),
+			Status:     "created",
+			CustomerID: input.CustomerID,
+			CreatedAt:  time.Now().UTC().Format(time.RFC3339),
+			Items:      input.Items,
+			ShipTo:     input.ShipTo,
+			Deleted:    false,
 		}
 
 		if err := repo.Create(ctx, &order); err != nil {
@@ -49,6 +95,7 @@ func CreateOrderHandler(repo repository.OrderRepository, pub publisher.OrderPubl
 				"error": err.Error(),
 			})
 		}
+		recordStatusChange(ctx, order.Status)
 
 		if err := pub.PublishOrderCreated(ctx, order); err != nil {
 			span.RecordError(err)
@@ -61,13 +108,29 @@ func CreateOrderHandler(repo repository.OrderRepository, pub publisher.OrderPubl
 	}
 }
 
-// ListOrdersHandler handles GET /api/orders
-func ListOrdersHandler(repo repository.OrderRepository) fiber.Handler {
+// ListOrdersHandler handles GET /api/orders and GET /api/orders/:id
+//
+// A single order is returned with its warehouse allocation when
+// products-service has one; failing to fetch it does not fail the request.
+//
+// The listing can be narrowed to the orders with an item for a product with
+// ?productId=<id>, to the orders of a customer with ?customerId=<id>, to the
+// orders in a status with ?status=<status> and to the orders still in
+// progress with ?open=true.
+//
+// Customers only see their own orders, and those without the allocation.
+func ListOrdersHandler(repo repository.OrderRepository, allocations products.AllocationClient) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		ctx, span := tracing.NewSpan(c.UserContext(), "ListOrdersHandler")
 		defer span.End()
 
 		id := c.Params("id")
+		principal, ok := auth.FromContext(ctx)
+		if !ok {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Authentication required",
+			})
+		}
 
 		span.SetAttributes(
 			tracing.StringAttribute("orderId", id),
@@ -75,12 +138,27 @@ func ListOrdersHandler(repo repository.OrderRepository) fiber.Handler {
 
 		if id != "" {
 			order, err := repo.GetByID(ctx, id)
+			// The orders of other customers are not found, rather than
+			// forbidden, so their ids cannot be probed.
+			if err == nil && !principal.IsStaff() && !ownsOrder(principal, order) {
+				err = errOrderOfOtherCustomer
+			}
 			if err != nil {
 				span.RecordError(err)
 				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 					"error": "Order not found",
 				})
 			}
+
+			if principal.IsStaff() {
+				lines, err := allocations.GetAllocation(ctx, id)
+				if err != nil {
+					span.RecordError(err)
+					slog.WarnContext(ctx, "failed to fetch allocation of order", "orderId", id, "error", err)
+				}
+				order.Allocation = lines
+			}
+
 			return c.JSON(order)
 		}
 
@@ -92,11 +170,45 @@ func ListOrdersHandler(repo repository.OrderRepository) fiber.Handler {
 			})
 		}
 
+		productID := c.Query("productId")
+		customerID := c.Query("customerId")
+		if !principal.IsStaff() {
+			customerID = principal.Subject
+		}
+		status := c.Query("status")
+		open := c.QueryBool("open")
+		if productID != "" || customerID != "" || status != "" || open {
+			span.SetAttributes(
+				tracing.StringAttribute("productId", productID),
+				tracing.StringAttribute("customerId", customerID),
+			)
+			matching := []domain.Order{}
+			for _, order := range orders {
+				if productID != "" && !order.HasProduct(productID) {
+					continue
+				}
+				if customerID != "" && order.CustomerID != customerID {
+					continue
+				}
+				if status != "" && order.Status != status {
+					continue
+				}
+				if open && !order.IsOpen() {
+					continue
+				}
+				matching = append(matching, order)
+			}
+			orders = matching
+		}
+
 		return c.JSON(orders)
 	}
 }
 
 // PatchOrderHandler handles PATCH /api/orders/:id
+//
+// A status outside the known ones is rejected, as is a move the order
+// cannot make from its current status, see domain.Order.CanTransition.
 func PatchOrderHandler(repo repository.OrderRepository, pub publisher.OrderPublisher) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		ctx, span := tracing.NewSpan(c.UserContext(), "PatchOrderHandler")
@@ -130,19 +242,14 @@ func PatchOrderHandler(repo repository.OrderRepository, pub publisher.OrderPubli
 			})
 		}
 
+		previousStatus := order.Status
 		if status, ok := patchData["status"].(string); ok {
-			validTransition := true
-
-			switch order.Status {
-			case "canceled", "returned":
-				validTransition = false
-			case "delivered":
-				validTransition = (status == "returned")
-			default:
-				validTransition = true
+			if !domain.IsStatus(status) {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "Unknown status " + strconv.Quote(status),
+				})
 			}
-
-			if !validTransition {
+			if status != order.Status && !order.CanTransition(status) {
 				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 					"error": "Invalid state transition",
 				})
@@ -157,6 +264,10 @@ func PatchOrderHandler(repo repository.OrderRepository, pub publisher.OrderPubli
 				"error": err.Error(),
 			})
 		}
+		if order.Status == previousStatus {
+			return c.JSON(order)
+		}
+		recordStatusChange(ctx, order.Status)
 
 		// Publish events if necessary
 		switch order.Status {
@@ -168,7 +279,7 @@ func PatchOrderHandler(repo repository.OrderRepository, pub publisher.OrderPubli
 				})
 			}
 		case "returned":
-			if err := pub.PublishOrderCanceled(ctx, *order); err != nil {
+			if err := pub.PublishOrderReturned(ctx, *order); err != nil {
 				span.RecordError(err)
 				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 					"error": "Order updated but failed to publish return event: " + err.Error(),
@@ -181,6 +292,9 @@ func PatchOrderHandler(repo repository.OrderRepository, pub publisher.OrderPubli
 }
 
 // DeleteOrderHandler handles DELETE /api/orders/:id
+//
+// Canceling an order changes its status, which only admins do, except for
+// customers canceling their own orders before they are shipped.
 func DeleteOrderHandler(repo repository.OrderRepository, pub publisher.OrderPublisher) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		ctx, span := tracing.NewSpan(c.UserContext(), "DeleteOrderHandler")
@@ -192,14 +306,33 @@ func DeleteOrderHandler(repo repository.OrderRepository, pub publisher.OrderPubl
 		)
 
 		order, err := repo.GetByID(ctx, id)
+		principal, _ := auth.FromContext(ctx)
+		if err == nil && !principal.IsStaff() && !ownsOrder(principal, order) {
+			err = errOrderOfOtherCustomer
+		}
 		if err != nil {
 			span.RecordError(err)
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 				"error": "Order not found",
 			})
 		}
+		if !principal.HasRole(auth.RoleAdmin) && !ownsOrder(principal, order) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Forbidden",
+			})
+		}
 
-		if order.Status != "delivered" && order.Status != "canceled" && order.Status != "returned" {
+		if order.IsOpen() {
+			if !order.CanTransition("canceled") {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "Invalid state transition",
+				})
+			}
+			if !principal.HasRole(auth.RoleAdmin) && !order.CustomerCancelable() {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+					"error": "Order is already being shipped and can no longer be canceled",
+				})
+			}
 			order.Status = "canceled"
 
 			if err := repo.Update(ctx, order); err != nil {
@@ -208,6 +341,7 @@ func DeleteOrderHandler(repo repository.OrderRepository, pub publisher.OrderPubl
 					"error": "Failed to cancel before delete: " + err.Error(),
 				})
 			}
+			recordStatusChange(ctx, order.Status)
 
 			if err := pub.PublishOrderCanceled(ctx, *order); err != nil {
 				span.RecordError(err)
@@ -228,3 +362,9 @@ func DeleteOrderHandler(repo repository.OrderRepository, pub publisher.OrderPubl
 		return c.SendStatus(fiber.StatusNoContent)
 	}
 }
+
+// ownsOrder reports whether the principal is the customer who placed the
+// order.
+func ownsOrder(principal *auth.Principal, order *domain.Order) bool {
+	return principal != nil && order.CustomerID != "" && order.CustomerID == principal.Subject
+}
@@ -0,0 +1,13 @@
+package products
+
+import (
+	"context"
+	"orders-service/internal/domain"
+)
+
+// AllocationClient reads the warehouse allocation products-service keeps for
+// an order.
+type AllocationClient interface {
+	// GetAllocation returns nil when the order has not been allocated yet.
+	GetAllocation(ctx context.Context, orderID string) ([]domain.AllocationLine, error)
+}
@@ -0,0 +1,68 @@
+package products
+
+import (
+	"auth"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"orders-service/internal/domain"
+	"telemetry/tracing"
+	"time"
+)
+
+type HTTPAllocationClient struct {
+	client  *http.Client
+	baseURL string
+}
+
+func NewHTTPAllocationClient(baseURL string) *HTTPAllocationClient {
+	return &HTTPAllocationClient{
+		client:  &http.Client{Timeout: 2 * time.Second},
+		baseURL: baseURL,
+	}
+}
+
+func (c *HTTPAllocationClient) GetAllocation(ctx context.Context, orderID string) ([]domain.AllocationLine, error) {
+	ctx, span := tracing.NewSpan(ctx, "HTTPAllocationClient#GetAllocation")
+	defer span.End()
+	span.SetAttributes(
+		tracing.StringAttribute("orderId", orderID),
+	)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/api/allocations/"+url.PathEscape(orderID), nil)
+	if err != nil {
+		return nil, err
+	}
+	if traceparent := tracing.GetTraceParent(ctx); traceparent != "" {
+		req.Header.Set("traceparent", traceparent)
+	}
+	// products-service only shows allocations to the back office.
+	auth.Forward(ctx, req)
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, nil
+	default:
+		err := fmt.Errorf("products-service answered %d", res.StatusCode)
+		span.RecordError(err)
+		return nil, err
+	}
+
+	var allocation struct {
+		Lines []domain.AllocationLine `json:"lines"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&allocation); err != nil {
+		return nil, err
+	}
+	return allocation.Lines, nil
+}
@@ -8,4 +8,5 @@ import (
 type OrderPublisher interface {
 	PublishOrderCreated(ctx context.Context, order domain.Order) error
 	PublishOrderCanceled(ctx context.Context, order domain.Order) error
+	PublishOrderReturned(ctx context.Context, order domain.Order) error
 }
@@ -3,13 +3,14 @@ package publisher
 import (
 	"context"
 	"encoding/json"
-	"log"
+	"log/slog"
 	"orders-service/internal/domain"
-	"orders-service/internal/tracing"
+	"telemetry/tracing"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sns"
 	"github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"github.com/google/uuid"
 )
 
 type SnsOrderPublisher struct {
@@ -32,41 +33,56 @@ func (p *SnsOrderPublisher) PublishOrderCanceled(ctx context.Context, order doma
 	return p.publish(ctx, "order.canceled", order)
 }
 
+func (p *SnsOrderPublisher) PublishOrderReturned(ctx context.Context, order domain.Order) error {
+	return p.publish(ctx, "order.returned", order)
+}
+
 func (p *SnsOrderPublisher) publish(ctx context.Context, eventType string, order domain.Order) error {
-	ctx, span := tracing.NewSpan(ctx, "SnsOrderPublisher#publish")
+	ctx, span := tracing.NewPublishSpan(ctx, tracing.MessagingSNS, tracing.TopicName(p.topicArn))
 	defer span.End()
 
 	span.SetAttributes(
 		tracing.StringAttribute("orderId", order.ID),
 		tracing.StringAttribute("eventType", eventType),
 	)
-	traceparent := tracing.GetTraceParent(ctx)
 
-	messageAttributes := map[string]types.MessageAttributeValue{
-		"traceparent": {
+	messageAttributes := map[string]types.MessageAttributeValue{}
+	// The trace context and baggage travel along for the consumers.
+	for key, value := range tracing.MessageHeaders(ctx) {
+		messageAttributes[key] = types.MessageAttributeValue{
 			DataType:    aws.String("String"),
-			StringValue: aws.String(traceparent),
-		},
+			StringValue: aws.String(value),
+		}
 	}
 
+	// eventId lets consumers recognize redelivered events.
 	payload := map[string]interface{}{
 		"type":     eventType,
+		"eventId":  uuid.New().String(),
 		"orderId":  order.ID,
 		"items":    order.Items,
 		"datetime": order.CreatedAt,
 	}
+	if order.ShipTo != nil {
+		payload["shipTo"] = order.ShipTo
+	}
 
 	body, err := json.Marshal(payload)
 	if err != nil {
 		return err
 	}
 
-	_, err = p.client.Publish(ctx, &sns.PublishInput{
+	output, err := p.client.Publish(ctx, &sns.PublishInput{
 		TopicArn:          aws.String(p.topicArn),
 		Message:           aws.String(string(body)),
 		MessageAttributes: messageAttributes,
 	})
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	tracing.SetMessageID(span, aws.ToString(output.MessageId))
 
-	log.Printf("Publishing attribute: %s", traceparent)
-	return err
+	slog.DebugContext(ctx, "published order event", "eventType", eventType, "orderId", order.ID)
+	return nil
 }
@@ -4,7 +4,7 @@ import (
 	"context"
 	"errors"
 	"orders-service/internal/domain"
-	"orders-service/internal/tracing"
+	"telemetry/tracing"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
@@ -0,0 +1,193 @@
+// Command catalog imports a CSV or NDJSON catalog file into the products
+// service, or exports the whole catalog from it.
+//
+//	catalog import [-url URL] [-token TOKEN] [-format csv|ndjson] [-dry-run] FILE
+//	catalog export [-url URL] [-token TOKEN] [-format csv|ndjson] [-o FILE]
+//
+// The import reads FILE, or stdin when FILE is "-", and streams it to
+// POST /api/products:import. The export writes to stdout unless -o is given.
+// The requests are authenticated with the bearer token given by -token or
+// AUTH_TOKEN: the import takes an admin, the export the back office, and the
+// subject of the token is recorded in the stock ledger.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"products-service/internal/catalog"
+)
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "import":
+		err = runImport(os.Args[2:])
+	case "export":
+		err = runExport(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: catalog import [-url URL] [-token TOKEN] [-format csv|ndjson] [-dry-run] FILE")
+	fmt.Fprintln(os.Stderr, "       catalog export [-url URL] [-token TOKEN] [-format csv|ndjson] [-o FILE]")
+	os.Exit(2)
+}
+
+func runImport(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	serviceURL := flags.String("url", getEnv("PRODUCTS_SERVICE_URL", "http://localhost:8080"), "products service base URL")
+	formatName := flags.String("format", "", "file format, csv or ndjson (default: from the file extension)")
+	dryRun := flags.Bool("dry-run", false, "validate and match the rows without writing them")
+	token := flags.String("token", getEnv("AUTH_TOKEN", ""), "bearer token of an admin")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		usage()
+	}
+	path := flags.Arg(0)
+
+	if *formatName == "" {
+		*formatName = path
+	}
+	format, err := catalog.ParseFormat(*formatName)
+	if err != nil {
+		return err
+	}
+
+	var body io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		body = file
+	}
+
+	query := url.Values{}
+	query.Set("format", string(format))
+	if *dryRun {
+		query.Set("dryRun", "true")
+	}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimRight(*serviceURL, "/")+"/api/products:import?"+query.Encode(), body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", format.ContentType())
+	setToken(req, *token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var report catalog.Report
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		return fmt.Errorf("unexpected response (%s): %w", resp.Status, err)
+	}
+
+	for _, e := range report.Errors {
+		if e.SKU != "" {
+			fmt.Fprintf(os.Stderr, "line %d (%s): %s\n", e.Line, e.SKU, e.Error)
+		} else {
+			fmt.Fprintf(os.Stderr, "line %d: %s\n", e.Line, e.Error)
+		}
+	}
+	prefix := ""
+	if report.DryRun {
+		prefix = "dry run: "
+	}
+	fmt.Printf("%s%d rows, %d created, %d updated, %d unchanged, %d failed\n",
+		prefix, report.Rows, report.Created, report.Updated, report.Unchanged, report.Failed)
+
+	if report.Error != "" {
+		return fmt.Errorf("%s (%s)", report.Error, resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("import failed: %s", resp.Status)
+	}
+	if len(report.Errors) > 0 {
+		return fmt.Errorf("%d rows reported errors", len(report.Errors))
+	}
+	return nil
+}
+
+func runExport(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	serviceURL := flags.String("url", getEnv("PRODUCTS_SERVICE_URL", "http://localhost:8080"), "products service base URL")
+	formatName := flags.String("format", "", "file format, csv or ndjson (default: from -o, otherwise ndjson)")
+	output := flags.String("o", "", "file to write to (default: stdout)")
+	token := flags.String("token", getEnv("AUTH_TOKEN", ""), "bearer token of an operator or admin")
+	flags.Parse(args)
+
+	if *formatName == "" {
+		*formatName = string(catalog.FormatNDJSON)
+		if *output != "" {
+			*formatName = *output
+		}
+	}
+	format, err := catalog.ParseFormat(*formatName)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, strings.TrimRight(*serviceURL, "/")+"/api/products:export?format="+string(format), nil)
+	if err != nil {
+		return err
+	}
+	setToken(req, *token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("export failed: %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+
+	var out io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+
+	_, err = io.Copy(out, resp.Body)
+	return err
+}
+
+func setToken(req *http.Request, token string) {
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"time"
+
+	"auth"
+	"config"
+)
+
+// Config is the configuration of the service, loaded from the environment
+// and the optional CONFIG_FILE. Tracing, metrics and logging read their
+// OTEL_* and LOG_* variables themselves.
+type Config struct {
+	Port string `env:"PORT" default:"8080"`
+
+	ProductsTable      string `env:"PRODUCTS_TABLE" default:"products"`
+	ProductFacetsTable string `env:"PRODUCT_FACETS_TABLE" default:"product_facets"`
+	CategoriesTable    string `env:"CATEGORIES_TABLE" default:"categories"`
+	MovementsTable     string `env:"STOCK_MOVEMENTS_TABLE" default:"stock_movements"`
+	WarehousesTable    string `env:"WAREHOUSES_TABLE" default:"warehouses"`
+	AllocationsTable   string `env:"ALLOCATIONS_TABLE" default:"allocations"`
+	ReservationsTable  string `env:"RESERVATIONS_TABLE" default:"reservations"`
+	PriceChangesTable  string `env:"PRICE_CHANGES_TABLE" default:"price_changes"`
+	ReviewsTable       string `env:"REVIEWS_TABLE" default:"reviews"`
+
+	ProductsTopicArn string `env:"PRODUCTS_TOPIC_ARN" required:"true"`
+	// CacheQueueURL receives the stock changes of the worker; without it
+	// cached products only expire.
+	CacheQueueURL    string `env:"CACHE_INVALIDATION_QUEUE_URL"`
+	OrdersServiceURL string `env:"ORDERS_SERVICE_URL" default:"http://orders-service:8080"`
+
+	ImagesBucket string        `env:"IMAGES_BUCKET" default:"product-images"`
+	ImagesCDNURL string        `env:"IMAGES_CDN_URL"`
+	ImageURLTTL  time.Duration `env:"IMAGE_URL_TTL" default:"1h"`
+
+	// SearchIndexPath keeps the search index on disk; it is in memory and
+	// rebuilt on every start by default.
+	SearchIndexPath string `env:"SEARCH_INDEX_PATH"`
+
+	ProductCacheTTL  time.Duration `env:"PRODUCT_CACHE_TTL" default:"30s"`
+	ProductCacheSize int           `env:"PRODUCT_CACHE_SIZE" default:"1000"`
+	RedisURL         string        `env:"REDIS_URL" secret:"true"`
+	HTTPMaxAge       time.Duration `env:"PRODUCTS_HTTP_MAX_AGE" default:"10s"`
+
+	// Replicas is how many instances of the service run. An in-process
+	// cache would miss the writes of the other instances, and each event on
+	// the cache queue reaches only one of them, so with more than one the
+	// cache has to be shared in Redis.
+	Replicas int `env:"REPLICAS" default:"1"`
+
+	ReservationTTL time.Duration `env:"RESERVATION_TTL" default:"10m"`
+	// ConfirmedReservationTTL is how long a confirmed reservation keeps its
+	// stock for the order before the products-worker takes it. Past that
+	// the order is taken to be lost and the sweeper gives the stock back.
+	ConfirmedReservationTTL time.Duration `env:"CONFIRMED_RESERVATION_TTL" default:"30m"`
+	SweepInterval           time.Duration `env:"RESERVATION_SWEEP_INTERVAL" default:"30s"`
+	PriceScheduleInterval   time.Duration `env:"PRICE_SCHEDULER_INTERVAL" default:"15s"`
+	ShutdownDrainPeriod     time.Duration `env:"SHUTDOWN_DRAIN_PERIOD" default:"5s"`
+	ShutdownTimeout         time.Duration `env:"SHUTDOWN_TIMEOUT" default:"20s"`
+
+	// MaxHeldReservations is how many reservations a caller can hold at
+	// once.
+	MaxHeldReservations int `env:"MAX_HELD_RESERVATIONS" default:"5"`
+
+	Auth auth.Config
+	AWS  config.AWS
+}
+
+// Validate checks the values that would otherwise fail, or spin, at run
+// time: tickers need positive intervals, the cache a positive size, several
+// replicas a shared cache and none of the periods can be negative.
+func (c *Config) Validate() error {
+	var errs []error
+	positive := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"IMAGE_URL_TTL", c.ImageURLTTL},
+		{"PRODUCT_CACHE_TTL", c.ProductCacheTTL},
+		{"RESERVATION_TTL", c.ReservationTTL},
+		{"CONFIRMED_RESERVATION_TTL", c.ConfirmedReservationTTL},
+		{"RESERVATION_SWEEP_INTERVAL", c.SweepInterval},
+		{"PRICE_SCHEDULER_INTERVAL", c.PriceScheduleInterval},
+	}
+	for _, p := range positive {
+		if p.value <= 0 {
+			errs = append(errs, errors.New(p.name+" must be positive"))
+		}
+	}
+	if c.ProductCacheSize <= 0 {
+		errs = append(errs, errors.New("PRODUCT_CACHE_SIZE must be positive"))
+	}
+	if c.Replicas > 1 && c.RedisURL == "" {
+		errs = append(errs, errors.New("REDIS_URL is required when REPLICAS is more than 1: an in-process cache misses the writes of the other replicas"))
+	}
+	if c.MaxHeldReservations <= 0 {
+		errs = append(errs, errors.New("MAX_HELD_RESERVATIONS must be positive"))
+	}
+	nonNegative := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"PRODUCTS_HTTP_MAX_AGE", c.HTTPMaxAge},
+		{"SHUTDOWN_DRAIN_PERIOD", c.ShutdownDrainPeriod},
+		{"SHUTDOWN_TIMEOUT", c.ShutdownTimeout},
+	}
+	for _, p := range nonNegative {
+		if p.value < 0 {
+			errs = append(errs, errors.New(p.name+" cannot be negative"))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -1,85 +1,309 @@
 package main
 
 import (
+	"auth"
+	"config"
 	"context"
-	"log"
+	"flag"
+	"log/slog"
 	"os"
+	"sync"
+	"time"
 
-	"github.com/gofiber/contrib/otelfiber"
+	"github.com/gofiber/contrib/otelfiber/v2"
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/gofiber/fiber/v2/middleware/etag"
 
+	"products-service/internal/cache"
 	"products-service/internal/handlers"
+	"products-service/internal/images"
+	"products-service/internal/orders"
+	"products-service/internal/pricing"
+	"products-service/internal/publisher"
 	"products-service/internal/repository"
-	"products-service/internal/tracing"
+	"products-service/internal/reservations"
+	"products-service/internal/search"
+	"telemetry/health"
+	"telemetry/logging"
+	"telemetry/metrics"
+	"telemetry/tracing"
+)
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+const (
+	// providerShutdownTimeout bounds the flush of the telemetry on exit.
+	providerShutdownTimeout = 5 * time.Second
+	// backgroundStopTimeout bounds the wait for the background jobs on exit.
+	backgroundStopTimeout = 5 * time.Second
 )
 
 func main() {
-	tempoEndpoint := getEnv("TEMPO_ENDPOINT", "tempo:4318")
-	tp := tracing.InitTracer("products-service", tempoEndpoint)
+	logging.Init("products-service")
+
+	printConfig := flag.Bool("print-config", false, "print the effective configuration, with secrets redacted, and exit")
+	flag.Parse()
+
+	var conf Config
+	if err := config.Load(&conf); err != nil {
+		logging.Fatal("failed to load configuration", "error", err)
+	}
+	if *printConfig {
+		if err := config.Print(os.Stdout, &conf); err != nil {
+			logging.Fatal("failed to print configuration", "error", err)
+		}
+		return
+	}
+
+	tp, err := tracing.InitTracer(context.Background(), "products-service")
+	if err != nil {
+		slog.Warn("tracing is partially disabled", "error", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), providerShutdownTimeout)
+		defer cancel()
+		if err := tp.Shutdown(ctx); err != nil {
+			slog.Error("failed to shut down the tracer provider", "error", err)
+		}
+	}()
+	mp, metricsHandler, err := metrics.InitMeter(context.Background(), "products-service")
+	if err != nil {
+		slog.Warn("metrics are partially disabled", "error", err)
+	}
 	defer func() {
-		if err := tp.Shutdown(context.Background()); err != nil {
-			log.Printf("Error shutting down tracer provider: %v", err)
+		ctx, cancel := context.WithTimeout(context.Background(), providerShutdownTimeout)
+		defer cancel()
+		if err := mp.Shutdown(ctx); err != nil {
+			slog.Error("failed to shut down the meter provider", "error", err)
 		}
 	}()
 
-	productsTable := getEnv("PRODUCTS_TABLE", "products")
+	cfg, err := conf.AWS.Load(context.Background())
+	if err != nil {
+		logging.Fatal("unable to load AWS SDK config", "error", err)
+	}
+	metrics.InstrumentAWS(&cfg)
+
+	dynamoClient := conf.AWS.DynamoDB(cfg)
+	productIndex, err := search.NewBleveProductIndex(conf.SearchIndexPath)
+	if err != nil {
+		logging.Fatal("unable to open search index", "error", err)
+	}
+	defer productIndex.Close()
 
-	awsEndpoint := getEnv("AWS_ENDPOINT", "")
-	awsRegion := getEnv("AWS_REGION", "us-west-2")
+	imageStore := images.NewS3Store(conf.AWS.S3(cfg), conf.ImagesBucket, conf.ImagesCDNURL, conf.ImageURLTTL)
 
-	customResolver := aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...interface{}) (aws.Endpoint, error) {
-		if awsEndpoint != "" {
-			return aws.Endpoint{
-				PartitionID:   "aws",
-				URL:           awsEndpoint,
-				SigningRegion: awsRegion,
-			}, nil
+	// Products are cached in process, or in Redis when REDIS_URL is set so
+	// that all replicas share the cache. Every write drops what it changed;
+	// the stock the worker moves is dropped as its events arrive.
+	// Redis also carries the products changed through one replica to the
+	// others, to refresh them in their search index.
+	var productCache cache.Cache = cache.NewLRU(conf.ProductCacheSize, conf.ProductCacheTTL)
+	var redisCache *cache.Redis
+	var peers search.Broadcaster
+	if conf.RedisURL != "" {
+		redisCache, err = cache.NewRedis(conf.RedisURL, conf.ProductCacheTTL)
+		if err != nil {
+			logging.Fatal("invalid REDIS_URL", "error", err)
 		}
+		defer redisCache.Close()
+		productCache = redisCache
+		peers = redisCache
+	}
 
-		// returning EndpointNotFoundError will allow the service to fallback to it's default resolution
-		return aws.Endpoint{}, &aws.EndpointNotFoundError{}
-	})
+	// Only the read endpoints are served from the cache; the reads that
+	// feed a write go to the table so a stale product is never written back.
+	indexedRepo := search.NewIndexedProductRepository(
+		repository.NewDynamoProductRepository(dynamoClient, conf.ProductsTable, conf.ProductFacetsTable),
+		productIndex,
+		peers,
+	)
+	uncachedRepo := cache.NewInvalidatingProductRepository(indexedRepo, productCache)
+	// Writes that change a product behind the product repository's back
+	// refresh it in the search index and drop it from the cache.
+	invalidator := cache.Invalidators{indexedRepo, uncachedRepo}
+	productRepo := images.NewURLProductRepository(uncachedRepo, imageStore)
+	cachedProductRepo := images.NewURLProductRepository(cache.NewCachedProductRepository(uncachedRepo), imageStore)
+	categoryRepo := repository.NewDynamoCategoryRepository(dynamoClient, conf.CategoriesTable)
+	movementRepo := cache.NewInvalidatingStockMovementRepository(
+		repository.NewDynamoStockMovementRepository(dynamoClient, conf.MovementsTable, conf.ProductsTable),
+		invalidator,
+	)
+	warehouseRepo := repository.NewDynamoWarehouseRepository(dynamoClient, conf.WarehousesTable)
+	allocationRepo := repository.NewDynamoAllocationRepository(dynamoClient, conf.AllocationsTable)
+	reservationRepo := cache.NewInvalidatingReservationRepository(
+		repository.NewDynamoReservationRepository(dynamoClient, conf.ReservationsTable, conf.ProductsTable, conf.AllocationsTable),
+		invalidator,
+	)
+	priceChangeRepo := repository.NewDynamoPriceChangeRepository(dynamoClient, conf.PriceChangesTable)
+	reviewRepo := cache.NewInvalidatingReviewRepository(
+		repository.NewDynamoReviewRepository(dynamoClient, conf.ReviewsTable, conf.ProductsTable),
+		invalidator,
+	)
 
-	// Cargar configuración AWS
-	cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithEndpointResolverWithOptions(customResolver))
-	if err != nil {
-		log.Fatalf("unable to load AWS SDK config: %v", err)
+	// Background jobs run until stopBackground, and are waited for before
+	// the index and the clients they use are closed.
+	background, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	var jobs sync.WaitGroup
+	runJob := func(job func(ctx context.Context)) {
+		jobs.Add(1)
+		go func() {
+			defer jobs.Done()
+			job(background)
+		}()
+	}
+
+	runJob(reservations.NewSweeper(reservationRepo, conf.SweepInterval).Run)
+
+	if redisCache != nil {
+		runJob(func(ctx context.Context) {
+			redisCache.ListenForChanges(ctx, indexedRepo.Refresh)
+		})
 	}
 
-	dynamoClient := dynamodb.NewFromConfig(cfg)
-	productRepo := repository.NewDynamoProductRepository(dynamoClient, productsTable)
+	sqsClient := conf.AWS.SQS(cfg)
+	if conf.CacheQueueURL != "" {
+		runJob(func(ctx context.Context) {
+			cache.ListenForStockEvents(ctx, sqsClient, conf.CacheQueueURL, invalidator)
+		})
+	}
 
-	app := fiber.New()
+	snsClient := conf.AWS.SNS(cfg)
+	productPublisher := publisher.NewSnsProductPublisher(snsClient, conf.ProductsTopicArn)
 
-	app.Use(otelfiber.Middleware())
+	// The service is ready when it can reach every table, the topic and
+	// the queue it works with. The images bucket is left out: products are
+	// served without image URLs when it is down.
+	readiness := health.NewChecker(health.DefaultCacheTTL, health.DefaultTimeout)
+	for _, table := range []string{conf.ProductsTable, conf.ProductFacetsTable, conf.CategoriesTable, conf.MovementsTable, conf.WarehousesTable, conf.AllocationsTable, conf.ReservationsTable, conf.PriceChangesTable, conf.ReviewsTable} {
+		readiness.Add("dynamodb:"+table, health.DynamoDBTable(dynamoClient, table))
+	}
+	readiness.Add("sns:"+conf.ProductsTopicArn, health.SNSTopic(snsClient, conf.ProductsTopicArn))
+	if conf.CacheQueueURL != "" {
+		readiness.Add("sqs:"+conf.CacheQueueURL, health.SQSQueue(sqsClient, conf.CacheQueueURL))
+	}
+
+	orderClient := orders.NewHTTPOrderClient(conf.OrdersServiceURL)
+
+	runJob(pricing.NewScheduler(priceChangeRepo, productRepo, productPublisher, conf.PriceScheduleInterval).Run)
 
-	// Healthcheck
-	app.Get("/healthz", func(c *fiber.Ctx) error {
-		return c.Status(fiber.StatusOK).JSON(fiber.Map{
-			"status": "ok",
+	// An empty index (in-memory, or a fresh directory) is filled from the
+	// table in the background so startup is not blocked by the scan.
+	if count, err := productIndex.Count(); err == nil && count == 0 {
+		runJob(func(ctx context.Context) {
+			indexed, err := search.Rebuild(ctx, productRepo, productIndex)
+			if err != nil {
+				slog.Error("failed to build search index", "error", err)
+				return
+			}
+			slog.Info("search index built", "products", indexed)
 		})
+	}
+
+	// Request bodies are streamed so catalog imports are processed while
+	// they upload instead of being buffered, and limited, in memory. The
+	// other routes keep the default limit through LimitBody.
+	app := fiber.New(fiber.Config{
+		StreamRequestBody: true,
 	})
 
+	// Probes and scrapes come before the middleware so they are neither
+	// traced nor logged. /healthz stays for the probes that still use it.
+	app.Get("/livez", adaptor.HTTPHandler(health.Live()))
+	app.Get("/healthz", adaptor.HTTPHandler(health.Live()))
+	app.Get("/readyz", adaptor.HTTPHandler(readiness))
+	if metricsHandler != nil {
+		app.Get("/metrics", adaptor.HTTPHandler(metricsHandler))
+	}
+
+	// Besides the server spans, otelfiber records the duration, and so the
+	// rate and errors, of the requests per route and status code.
+	app.Use(otelfiber.Middleware())
+	app.Use(logging.AccessLog())
+	app.Use(handlers.LimitBody(fiber.DefaultBodyLimit, "/api/products:import"))
+	app.Use(auth.Middleware(auth.NewVerifier(conf.Auth)))
+
+	// The catalog is read anonymously. Changing it and the prices takes an
+	// admin, the stock and the moderation the back office, and buying or
+	// reviewing any signed-in caller.
+	admin := auth.Require(auth.RoleAdmin)
+	staff := auth.Require(auth.RoleOperator, auth.RoleAdmin)
+	signedIn := auth.Require()
+
 	// Product Routes
+	app.Post("/api/products\\:batchGet", handlers.BatchGetProductsHandler(productRepo))
+	app.Post("/api/products\\:import", admin, handlers.ImportCatalogHandler(productRepo, categoryRepo, movementRepo, priceChangeRepo, productPublisher))
+	app.Get("/api/products\\:export", staff, handlers.ExportCatalogHandler(productRepo))
 	api := app.Group("/api/products")
-	api.Post("/", handlers.CreateProductHandler(productRepo))
-	api.Get("/:id?", handlers.ListProductsHandler(productRepo))
-	api.Put("/:id", handlers.UpdateProductHandler(productRepo))
-	api.Patch("/:id", handlers.PatchProductHandler(productRepo))
-	api.Delete("/:id", handlers.DeleteProductHandler(productRepo))
-
-	port := getEnv("PORT", "8080")
-	log.Printf("Starting Products Service on port %s...", port)
-	log.Fatal(app.Listen(":" + port))
-}
+	api.Get("/search", handlers.CacheControl(conf.HTTPMaxAge), etag.New(), handlers.SearchProductsHandler(productIndex, categoryRepo, imageStore))
+	api.Post("/search/reindex", admin, handlers.ReindexProductsHandler(productRepo, productIndex))
+	api.Get("/low-stock", staff, handlers.LowStockProductsHandler(productRepo))
+	api.Post("/", admin, handlers.CreateProductHandler(productRepo, categoryRepo, movementRepo, priceChangeRepo, productPublisher))
+	api.Get("/:id?", handlers.CacheControl(conf.HTTPMaxAge), etag.New(), handlers.ListProductsHandler(cachedProductRepo, categoryRepo))
+	api.Put("/:id", admin, handlers.UpdateProductHandler(productRepo, categoryRepo, priceChangeRepo, productPublisher))
+	api.Patch("/:id", admin, handlers.PatchProductHandler(productRepo, categoryRepo, priceChangeRepo, productPublisher))
+	api.Delete("/:id", admin, handlers.DeleteProductHandler(productRepo, orderClient, productPublisher))
+	api.Post("/:id/restore", admin, handlers.RestoreProductHandler(productRepo, productPublisher))
+	api.Post("/:id/images", admin, handlers.UploadProductImageHandler(productRepo, imageStore, productPublisher))
+	api.Put("/:id/images/order", admin, handlers.ReorderProductImagesHandler(productRepo, productPublisher))
+	api.Delete("/:id/images/:imageId", admin, handlers.DeleteProductImageHandler(productRepo, imageStore, productPublisher))
+	api.Put("/:id/variants/:sku", admin, handlers.PutVariantHandler(productRepo, categoryRepo, movementRepo, productPublisher))
+	api.Delete("/:id/variants/:sku", admin, handlers.DeleteVariantHandler(productRepo, movementRepo, productPublisher))
+	api.Post("/:id/stock-adjustments", staff, handlers.AdjustStockHandler(movementRepo, warehouseRepo, productPublisher))
+	api.Get("/:id/stock-movements", staff, handlers.ListStockMovementsHandler(movementRepo))
+	api.Get("/:id/price", handlers.GetPriceAtHandler(priceChangeRepo))
+	api.Post("/:id/price-changes", admin, handlers.SchedulePriceChangeHandler(productRepo, priceChangeRepo))
+	api.Get("/:id/price-changes", staff, handlers.ListPriceChangesHandler(priceChangeRepo))
+	api.Delete("/:id/price-changes/:changeId", admin, handlers.CancelPriceChangeHandler(priceChangeRepo))
+	api.Post("/:id/sales", admin, handlers.ScheduleSaleHandler(productRepo, priceChangeRepo))
+	api.Post("/:id/reviews", auth.Require(auth.RoleCustomer), handlers.CreateReviewHandler(productRepo, reviewRepo, orderClient))
+	api.Get("/:id/reviews", handlers.ListReviewsHandler(reviewRepo))
+	api.Put("/:id/reviews/:customerId/status", staff, handlers.ModerateReviewHandler(reviewRepo))
+	api.Delete("/:id/reviews/:customerId", signedIn, handlers.DeleteReviewHandler(reviewRepo))
+
+	// Review moderation
+	app.Get("/api/reviews", staff, handlers.ListReviewsByStatusHandler(reviewRepo))
 
-func getEnv(key, fallback string) string {
-	if value, ok := os.LookupEnv(key); ok {
-		return value
+	// Category Routes
+	categories := app.Group("/api/categories")
+	categories.Post("/", admin, handlers.CreateCategoryHandler(categoryRepo))
+	categories.Get("/:id?", handlers.ListCategoriesHandler(categoryRepo))
+	categories.Put("/:id", admin, handlers.UpdateCategoryHandler(categoryRepo))
+	categories.Delete("/:id", admin, handlers.DeleteCategoryHandler(categoryRepo, productRepo))
+
+	// Warehouse Routes
+	warehouses := app.Group("/api/warehouses")
+	warehouses.Post("/", admin, handlers.CreateWarehouseHandler(warehouseRepo))
+	warehouses.Get("/:id?", staff, handlers.ListWarehousesHandler(warehouseRepo))
+	warehouses.Put("/:id", admin, handlers.UpdateWarehouseHandler(warehouseRepo))
+	warehouses.Delete("/:id", admin, handlers.DeleteWarehouseHandler(warehouseRepo, productRepo))
+
+	// Reservation Routes
+	reservationRoutes := app.Group("/api/reservations", signedIn)
+	reservationRoutes.Post("/", handlers.CreateReservationHandler(reservationRepo, conf.ReservationTTL, conf.MaxHeldReservations))
+	reservationRoutes.Get("/:id", handlers.GetReservationHandler(reservationRepo))
+	reservationRoutes.Post("/:id/confirm", handlers.ConfirmReservationHandler(reservationRepo, orderClient, conf.ConfirmedReservationTTL))
+	reservationRoutes.Post("/:id/release", handlers.ReleaseReservationHandler(reservationRepo))
+
+	// Allocation Routes
+	app.Get("/api/allocations/:orderId", staff, handlers.GetAllocationHandler(allocationRepo))
+
+	slog.Info("starting products service", "port", conf.Port)
+	health.Serve(app, conf.Port, readiness, conf.ShutdownDrainPeriod, conf.ShutdownTimeout)
+
+	// The background jobs stop once no request can use them anymore; the
+	// clients are closed and the telemetry flushed by the deferred calls
+	// once they have returned, or gave up to.
+	stopBackground()
+	stopped := make(chan struct{})
+	go func() {
+		jobs.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(backgroundStopTimeout):
+		slog.Error("background jobs did not stop in time", "timeout", backgroundStopTimeout.String())
 	}
-	return fallback
+	slog.Info("products service stopped")
 }
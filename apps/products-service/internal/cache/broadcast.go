@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"telemetry/tracing"
+)
+
+// changesChannel carries the ids of the products a replica changed, so that
+// the other replicas bring their own search index up to date.
+const changesChannel = keyPrefix + "changes"
+
+type changes struct {
+	// Instance is the replica that sent the changes, which skips them.
+	Instance   string   `json:"instance"`
+	ProductIDs []string `json:"productIds"`
+}
+
+// Broadcast sends the ids of changed products to the other replicas. The
+// channel delivers to the replicas connected at the time only; a replica
+// that was not builds its index from the table when it starts.
+func (c *Redis) Broadcast(ctx context.Context, productIDs ...string) {
+	if len(productIDs) == 0 {
+		return
+	}
+	message, err := json.Marshal(changes{Instance: c.instance, ProductIDs: productIDs})
+	if err == nil {
+		err = c.client.Publish(ctx, changesChannel, message).Err()
+	}
+	if err != nil {
+		tracing.SpanFromContext(ctx).RecordError(err)
+		slog.ErrorContext(ctx, "failed to broadcast product changes", "productIds", productIDs, "error", err)
+	}
+}
+
+// ListenForChanges hands the products the other replicas changed to
+// refresh as their ids arrive. It returns when ctx is done.
+func (c *Redis) ListenForChanges(ctx context.Context, refresh func(ctx context.Context, productIDs ...string)) {
+	subscription := c.client.Subscribe(ctx, changesChannel)
+	defer subscription.Close()
+
+	messages := subscription.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			var received changes
+			if err := json.Unmarshal([]byte(msg.Payload), &received); err != nil {
+				slog.WarnContext(ctx, "dropping unreadable product changes", "error", err)
+				continue
+			}
+			if received.Instance != c.instance {
+				refresh(ctx, received.ProductIDs...)
+			}
+		}
+	}
+}
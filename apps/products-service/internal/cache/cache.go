@@ -0,0 +1,13 @@
+package cache
+
+import "context"
+
+// Cache keeps encoded values for a limited time. Implementations are safe
+// for concurrent use.
+type Cache interface {
+	// Get returns the value stored under key, and false when there is none
+	// or it expired.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte) error
+	Delete(ctx context.Context, keys ...string) error
+}
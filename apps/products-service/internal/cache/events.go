@@ -0,0 +1,91 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"telemetry/tracing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+type snsMessage struct {
+	Message           string                                 `json:"Message"`
+	MessageAttributes map[string]tracing.SNSMessageAttribute `json:"MessageAttributes"`
+}
+
+// ListenForStockEvents invalidates products, in the cache and the search
+// index, as the product events on queueURL arrive. The queue is subscribed
+// to the products topic for the stock changes the worker makes, which do
+// not go through this service. It returns when ctx is done.
+func ListenForStockEvents(ctx context.Context, client *sqs.Client, queueURL string, cache Invalidator) {
+	queue := tracing.QueueName(queueURL)
+	for ctx.Err() == nil {
+		start := time.Now()
+		output, err := client.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:            aws.String(queueURL),
+			MaxNumberOfMessages: 10,
+			WaitTimeSeconds:     10,
+		})
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.ErrorContext(ctx, "failed to receive cache invalidation events", "error", err)
+			}
+			continue
+		}
+		if len(output.Messages) == 0 {
+			continue
+		}
+
+		envelopes := make([]snsMessage, len(output.Messages))
+		headers := make([]map[string]string, len(output.Messages))
+		for i, msg := range output.Messages {
+			// An unreadable envelope has no headers and is dropped below.
+			_ = json.Unmarshal([]byte(aws.ToString(msg.Body)), &envelopes[i])
+			headers[i] = tracing.SNSHeaders(envelopes[i].MessageAttributes)
+		}
+
+		batchCtx, span := tracing.NewReceiveSpan(ctx, tracing.MessagingSQS, queue, start, headers)
+		span.End()
+
+		for i, msg := range output.Messages {
+			msgCtx, span := tracing.NewProcessSpan(batchCtx, tracing.MessagingSQS, queue, aws.ToString(msg.MessageId), headers[i])
+			if err := invalidate(msgCtx, client, queueURL, msg, envelopes[i], cache); err != nil {
+				span.RecordError(err)
+				slog.ErrorContext(msgCtx, "failed to process cache invalidation event", "messageId", aws.ToString(msg.MessageId), "error", err)
+			}
+			span.End()
+		}
+	}
+}
+
+func invalidate(ctx context.Context, client *sqs.Client, queueURL string, msg types.Message, envelope snsMessage, cache Invalidator) error {
+	span := tracing.SpanFromContext(ctx)
+
+	var event struct {
+		Type      string `json:"type"`
+		ProductID string `json:"productId"`
+	}
+	if err := json.Unmarshal([]byte(envelope.Message), &event); err != nil {
+		// Redelivering it would not make it readable.
+		span.RecordError(err)
+		slog.WarnContext(ctx, "dropping unreadable cache invalidation event", "error", err)
+	}
+	span.SetAttributes(
+		tracing.StringAttribute("productId", event.ProductID),
+		tracing.StringAttribute("eventType", event.Type),
+	)
+
+	if event.ProductID != "" {
+		cache.Invalidate(ctx, event.ProductID)
+	}
+
+	_, err := client.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(queueURL),
+		ReceiptHandle: msg.ReceiptHandle,
+	})
+	return err
+}
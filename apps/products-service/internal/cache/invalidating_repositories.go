@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"context"
+	"products-service/internal/domain"
+	"products-service/internal/repository"
+	"time"
+)
+
+// Invalidator drops products from the cache.
+type Invalidator interface {
+	Invalidate(ctx context.Context, productIDs ...string)
+}
+
+// Invalidators invalidates the products in each of them in turn, e.g. in
+// the search index and in the cache.
+type Invalidators []Invalidator
+
+func (all Invalidators) Invalidate(ctx context.Context, productIDs ...string) {
+	for _, invalidator := range all {
+		invalidator.Invalidate(ctx, productIDs...)
+	}
+}
+
+// InvalidatingStockMovementRepository drops the product from the cache
+// after its stock was written, as stock movements update the product item
+// directly.
+type InvalidatingStockMovementRepository struct {
+	repository.StockMovementRepository
+	cache Invalidator
+}
+
+func NewInvalidatingStockMovementRepository(repo repository.StockMovementRepository, cache Invalidator) *InvalidatingStockMovementRepository {
+	return &InvalidatingStockMovementRepository{
+		StockMovementRepository: repo,
+		cache:                   cache,
+	}
+}
+
+func (r *InvalidatingStockMovementRepository) Adjust(ctx context.Context, movement *domain.StockMovement) (*domain.StockChange, error) {
+	defer r.cache.Invalidate(ctx, movement.ProductID)
+	return r.StockMovementRepository.Adjust(ctx, movement)
+}
+
+func (r *InvalidatingStockMovementRepository) Recount(ctx context.Context, movement *domain.StockMovement, quantity int) (*domain.StockChange, error) {
+	defer r.cache.Invalidate(ctx, movement.ProductID)
+	return r.StockMovementRepository.Recount(ctx, movement, quantity)
+}
+
+func (r *InvalidatingStockMovementRepository) Record(ctx context.Context, movement *domain.StockMovement) error {
+	defer r.cache.Invalidate(ctx, movement.ProductID)
+	return r.StockMovementRepository.Record(ctx, movement)
+}
+
+// InvalidatingReservationRepository drops the product from the cache after
+// a reservation changed its reserved stock.
+type InvalidatingReservationRepository struct {
+	repository.ReservationRepository
+	cache Invalidator
+}
+
+func NewInvalidatingReservationRepository(repo repository.ReservationRepository, cache Invalidator) *InvalidatingReservationRepository {
+	return &InvalidatingReservationRepository{
+		ReservationRepository: repo,
+		cache:                 cache,
+	}
+}
+
+func (r *InvalidatingReservationRepository) Create(ctx context.Context, reservation *domain.Reservation) error {
+	defer r.cache.Invalidate(ctx, reservation.ProductID)
+	return r.ReservationRepository.Create(ctx, reservation)
+}
+
+func (r *InvalidatingReservationRepository) Confirm(ctx context.Context, id string, orderID string, ordered int, expiresAt time.Time) (*domain.Reservation, error) {
+	reservation, err := r.ReservationRepository.Confirm(ctx, id, orderID, ordered, expiresAt)
+	if reservation != nil {
+		r.cache.Invalidate(ctx, reservation.ProductID)
+	}
+	return reservation, err
+}
+
+func (r *InvalidatingReservationRepository) Settle(ctx context.Context, id string, status domain.ReservationStatus, orderID string) (*domain.Reservation, error) {
+	reservation, err := r.ReservationRepository.Settle(ctx, id, status, orderID)
+	if reservation != nil {
+		r.cache.Invalidate(ctx, reservation.ProductID)
+	}
+	return reservation, err
+}
+
+func (r *InvalidatingReservationRepository) ExpireConfirmed(ctx context.Context, id string) (*domain.Reservation, error) {
+	reservation, err := r.ReservationRepository.ExpireConfirmed(ctx, id)
+	if reservation != nil {
+		r.cache.Invalidate(ctx, reservation.ProductID)
+	}
+	return reservation, err
+}
+
+// InvalidatingReviewRepository drops the product from the cache after a
+// review moderation or deletion changed its rating.
+type InvalidatingReviewRepository struct {
+	repository.ReviewRepository
+	cache Invalidator
+}
+
+func NewInvalidatingReviewRepository(repo repository.ReviewRepository, cache Invalidator) *InvalidatingReviewRepository {
+	return &InvalidatingReviewRepository{
+		ReviewRepository: repo,
+		cache:            cache,
+	}
+}
+
+func (r *InvalidatingReviewRepository) Moderate(ctx context.Context, review *domain.Review, from domain.ReviewStatus) error {
+	defer r.cache.Invalidate(ctx, review.ProductID)
+	return r.ReviewRepository.Moderate(ctx, review, from)
+}
+
+func (r *InvalidatingReviewRepository) Delete(ctx context.Context, review *domain.Review) error {
+	defer r.cache.Invalidate(ctx, review.ProductID)
+	return r.ReviewRepository.Delete(ctx, review)
+}
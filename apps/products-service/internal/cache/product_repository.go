@@ -0,0 +1,160 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"products-service/internal/domain"
+	"products-service/internal/repository"
+	"telemetry/tracing"
+)
+
+// allProductsKey holds the whole catalog, which the storefront reads on
+// every page load.
+const allProductsKey = "products:all"
+
+func productKey(id string) string {
+	return "product:" + id
+}
+
+// InvalidatingProductRepository drops the products it writes, and the
+// catalog listing, from the cache. Its reads go to the repository, so the
+// products that are read to be changed and written back are never stale.
+type InvalidatingProductRepository struct {
+	repository.ProductRepository
+	cache Cache
+}
+
+func NewInvalidatingProductRepository(repo repository.ProductRepository, cache Cache) *InvalidatingProductRepository {
+	return &InvalidatingProductRepository{
+		ProductRepository: repo,
+		cache:             cache,
+	}
+}
+
+func (r *InvalidatingProductRepository) Create(ctx context.Context, product *domain.Product) error {
+	defer r.Invalidate(ctx, product.ID)
+	return r.ProductRepository.Create(ctx, product)
+}
+
+func (r *InvalidatingProductRepository) Update(ctx context.Context, product *domain.Product) error {
+	defer r.Invalidate(ctx, product.ID)
+	return r.ProductRepository.Update(ctx, product)
+}
+
+func (r *InvalidatingProductRepository) SetPrice(ctx context.Context, id string, price, current float64) (*domain.Product, error) {
+	defer r.Invalidate(ctx, id)
+	return r.ProductRepository.SetPrice(ctx, id, price, current)
+}
+
+func (r *InvalidatingProductRepository) PutBatch(ctx context.Context, products []domain.Product) ([]string, error) {
+	ids := make([]string, len(products))
+	for i, product := range products {
+		ids[i] = product.ID
+	}
+	defer r.Invalidate(ctx, ids...)
+	return r.ProductRepository.PutBatch(ctx, products)
+}
+
+func (r *InvalidatingProductRepository) Delete(ctx context.Context, id string) error {
+	defer r.Invalidate(ctx, id)
+	return r.ProductRepository.Delete(ctx, id)
+}
+
+// Invalidate drops the products and the catalog listing from the cache. It
+// is called after every write, including the stock writes that do not go
+// through the product repository, whether the write succeeded or not.
+func (r *InvalidatingProductRepository) Invalidate(ctx context.Context, productIDs ...string) {
+	keys := []string{allProductsKey}
+	for _, id := range productIDs {
+		keys = append(keys, productKey(id))
+	}
+	if err := r.cache.Delete(ctx, keys...); err != nil {
+		tracing.SpanFromContext(ctx).RecordError(err)
+		slog.ErrorContext(ctx, "failed to invalidate cached products", "productIds", productIDs, "error", err)
+	}
+}
+
+// CachedProductRepository serves GetByID and GetAll from the cache and
+// reads through to the repository on a miss. It is meant for the endpoints
+// that only read products. The cache is an optimization only: when it
+// fails, reads go to the repository and the error is recorded.
+type CachedProductRepository struct {
+	*InvalidatingProductRepository
+}
+
+func NewCachedProductRepository(repo *InvalidatingProductRepository) *CachedProductRepository {
+	return &CachedProductRepository{
+		InvalidatingProductRepository: repo,
+	}
+}
+
+func (r *CachedProductRepository) GetByID(ctx context.Context, id string) (*domain.Product, error) {
+	ctx, span := tracing.NewSpan(ctx, "CachedProductRepository#GetByID")
+	defer span.End()
+	span.SetAttributes(
+		tracing.StringAttribute("productId", id),
+	)
+
+	var product domain.Product
+	if r.get(ctx, productKey(id), &product) {
+		return &product, nil
+	}
+
+	found, err := r.ProductRepository.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	r.set(ctx, productKey(id), found)
+	return found, nil
+}
+
+func (r *CachedProductRepository) GetAll(ctx context.Context) ([]domain.Product, error) {
+	ctx, span := tracing.NewSpan(ctx, "CachedProductRepository#GetAll")
+	defer span.End()
+
+	var products []domain.Product
+	if r.get(ctx, allProductsKey, &products) {
+		return products, nil
+	}
+
+	products, err := r.ProductRepository.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r.set(ctx, allProductsKey, products)
+	return products, nil
+}
+
+// get decodes the cached value into v and records on the span whether it
+// was a hit.
+func (r *CachedProductRepository) get(ctx context.Context, key string, v interface{}) bool {
+	span := tracing.SpanFromContext(ctx)
+
+	value, ok, err := r.cache.Get(ctx, key)
+	if err != nil {
+		span.RecordError(err)
+		slog.WarnContext(ctx, "failed to read from cache", "key", key, "error", err)
+	}
+	if ok {
+		if err := json.Unmarshal(value, v); err != nil {
+			span.RecordError(err)
+			ok = false
+		}
+	}
+	span.SetAttributes(
+		tracing.BoolAttribute("cache.hit", ok),
+	)
+	return ok
+}
+
+func (r *CachedProductRepository) set(ctx context.Context, key string, v interface{}) {
+	value, err := json.Marshal(v)
+	if err == nil {
+		err = r.cache.Set(ctx, key, value)
+	}
+	if err != nil {
+		tracing.SpanFromContext(ctx).RecordError(err)
+		slog.WarnContext(ctx, "failed to write to cache", "key", key, "error", err)
+	}
+}
@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// keyPrefix keeps the keys of the service apart from others sharing the
+// Redis database.
+const keyPrefix = "products-service:"
+
+// Redis is a cache shared by every replica of the service, so a write
+// through one replica is seen by all of them.
+type Redis struct {
+	client *redis.Client
+	ttl    time.Duration
+	// instance tells the changes this replica broadcasts from the ones of
+	// the other replicas.
+	instance string
+}
+
+// NewRedis connects to the Redis server at url, e.g.
+// redis://localhost:6379/0.
+func NewRedis(url string, ttl time.Duration) (*Redis, error) {
+	options, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	return &Redis{
+		client:   redis.NewClient(options),
+		ttl:      ttl,
+		instance: uuid.New().String(),
+	}, nil
+}
+
+func (c *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := c.client.Get(ctx, keyPrefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (c *Redis) Set(ctx context.Context, key string, value []byte) error {
+	return c.client.Set(ctx, keyPrefix+key, value, c.ttl).Err()
+}
+
+func (c *Redis) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = keyPrefix + key
+	}
+	return c.client.Del(ctx, prefixed...).Err()
+}
+
+func (c *Redis) Close() error {
+	return c.client.Close()
+}
@@ -0,0 +1,211 @@
+package catalog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"products-service/internal/domain"
+	"strconv"
+	"strings"
+)
+
+// maxLineSize bounds one NDJSON line, i.e. one product.
+const maxLineSize = 1 << 20
+
+// tagSeparator separates the tags within a CSV cell.
+const tagSeparator = "|"
+
+// columns are the CSV columns, in the order they are exported. The id,
+// reserved and available columns are only exported; imports ignore them.
+var columns = []string{
+	"id", "sku", "name", "description", "price", "stock", "reserved", "available",
+	"categoryId", "tags", "reorderThreshold", "attributes",
+}
+
+var readOnlyColumns = map[string]bool{"id": true, "reserved": true, "available": true}
+
+// Row is one record of an import file. Apply writes the fields the record
+// sets onto a product and leaves the others untouched, so the same row can
+// create a product or update an existing one.
+type Row struct {
+	Line  int
+	SKU   string
+	Apply func(product *domain.Product) error
+}
+
+// RowError is a record that could not be read. The import carries on with
+// the next one.
+type RowError struct {
+	Line int
+	Err  error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// Reader reads the rows of an import file. Read returns io.EOF after the
+// last row and a *RowError for a malformed row; any other error means the
+// file cannot be read any further.
+type Reader interface {
+	Read() (*Row, error)
+}
+
+func NewReader(format Format, r io.Reader) (Reader, error) {
+	if format == FormatCSV {
+		return newCSVReader(r)
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	return &ndjsonReader{scanner: scanner}, nil
+}
+
+type ndjsonReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (r *ndjsonReader) Read() (*Row, error) {
+	for r.scanner.Scan() {
+		r.line++
+		data := bytes.TrimSpace(r.scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		// The scanner reuses its buffer, so the row keeps its own copy.
+		data = append([]byte(nil), data...)
+
+		var key struct {
+			SKU string `json:"sku"`
+		}
+		if err := json.Unmarshal(data, &key); err != nil {
+			return nil, &RowError{Line: r.line, Err: fmt.Errorf("invalid JSON: %w", err)}
+		}
+		return &Row{
+			Line: r.line,
+			SKU:  strings.TrimSpace(key.SKU),
+			Apply: func(product *domain.Product) error {
+				return json.Unmarshal(data, product)
+			},
+		}, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+type csvReader struct {
+	reader *csv.Reader
+	header []string
+}
+
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("CSV file is empty, expected a header row")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		known[column] = true
+	}
+	hasSKU := false
+	for i, column := range header {
+		column = strings.TrimSpace(column)
+		if !known[column] {
+			return nil, fmt.Errorf("unknown CSV column %q", column)
+		}
+		hasSKU = hasSKU || column == "sku"
+		header[i] = column
+	}
+	if !hasSKU {
+		return nil, errors.New("CSV header has no sku column")
+	}
+	return &csvReader{reader: reader, header: header}, nil
+}
+
+func (r *csvReader) Read() (*Row, error) {
+	record, err := r.reader.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, &RowError{Line: parseErr.StartLine, Err: parseErr.Err}
+		}
+		return nil, err
+	}
+	line, _ := r.reader.FieldPos(0)
+
+	row := &Row{Line: line}
+	cells := make(map[string]string, len(record))
+	for i, value := range record {
+		value = strings.TrimSpace(value)
+		if r.header[i] == "sku" {
+			row.SKU = value
+		}
+		cells[r.header[i]] = value
+	}
+	row.Apply = func(product *domain.Product) error {
+		return applyCells(product, cells)
+	}
+	return row, nil
+}
+
+// applyCells sets the fields of the non-empty cells. An empty cell leaves
+// the field as it is.
+func applyCells(product *domain.Product, cells map[string]string) error {
+	for column, value := range cells {
+		if value == "" || readOnlyColumns[column] {
+			continue
+		}
+		switch column {
+		case "sku":
+			product.SKU = value
+		case "name":
+			product.Name = value
+		case "description":
+			product.Description = value
+		case "categoryId":
+			product.CategoryID = value
+		case "tags":
+			product.Tags = strings.Split(value, tagSeparator)
+		case "price":
+			price, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Errorf("price %q is not a number", value)
+			}
+			product.Price = price
+		case "stock", "reorderThreshold":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("%s %q is not a whole number", column, value)
+			}
+			if column == "stock" {
+				product.Stock = n
+			} else {
+				product.ReorderThreshold = n
+			}
+		case "attributes":
+			var attributes map[string]interface{}
+			if err := json.Unmarshal([]byte(value), &attributes); err != nil {
+				return fmt.Errorf("attributes must be a JSON object: %v", err)
+			}
+			product.Attributes = attributes
+		}
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package catalog
+
+import (
+	"errors"
+	"io"
+	"products-service/internal/domain"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// result is what reading one record and applying it to a new product gave.
+type result struct {
+	line int
+	sku  string
+	// rowErr is set when the record could not be read, applyErr when it
+	// could not be applied.
+	rowErr   string
+	applyErr string
+	product  domain.Product
+}
+
+func readAll(t *testing.T, reader Reader) []result {
+	t.Helper()
+	var results []result
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return results
+		}
+		var rowErr *RowError
+		if errors.As(err, &rowErr) {
+			results = append(results, result{line: rowErr.Line, rowErr: rowErr.Err.Error()})
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Read() error = %v", err)
+		}
+
+		r := result{line: row.Line, sku: row.SKU}
+		if err := row.Apply(&r.product); err != nil {
+			r.applyErr = err.Error()
+		}
+		results = append(results, r)
+	}
+}
+
+func TestReader(t *testing.T) {
+	tests := []struct {
+		name   string
+		format Format
+		file   string
+		want   []result
+	}{
+		{
+			name:   "CSV rows",
+			format: FormatCSV,
+			file: "sku,name,price,stock,tags,attributes\n" +
+				"A-1,Lamp,19.5,4,home|light,\"{\"\"color\"\":\"\"red\"\"}\"\n" +
+				" A-2 , Chair ,,,,\n",
+			want: []result{
+				{line: 2, sku: "A-1", product: domain.Product{
+					SKU: "A-1", Name: "Lamp", Price: 19.5, Stock: 4,
+					Tags:       []string{"home", "light"},
+					Attributes: map[string]interface{}{"color": "red"},
+				}},
+				{line: 3, sku: "A-2", product: domain.Product{SKU: "A-2", Name: "Chair"}},
+			},
+		},
+		{
+			name:   "CSV read-only columns are ignored",
+			format: FormatCSV,
+			file:   "id,sku,reserved,available,name\nx-1,C-1,3,4,Mug\n",
+			want: []result{
+				{line: 2, sku: "C-1", product: domain.Product{SKU: "C-1", Name: "Mug"}},
+			},
+		},
+		{
+			name:   "CSV per-row errors do not stop the file",
+			format: FormatCSV,
+			file: "sku,name,price,stock,attributes\n" +
+				"A-1,Lamp,abc,1,\n" +
+				"A-2,Desk\n" +
+				"A-3,Shelf,10,x,\n" +
+				"A-4,Box,5,1,\"[1,2]\"\n" +
+				"A-5,Bin,5,-1.5,\n" +
+				",Nameless,1,1,\n" +
+				"A-6,Vase,7,2,\n",
+			want: []result{
+				{line: 2, sku: "A-1", applyErr: `price "abc" is not a number`},
+				{line: 3, rowErr: "wrong number of fields"},
+				{line: 4, sku: "A-3", applyErr: `stock "x" is not a whole number`},
+				{line: 5, sku: "A-4", applyErr: "attributes must be a JSON object"},
+				{line: 6, sku: "A-5", applyErr: `stock "-1.5" is not a whole number`},
+				{line: 7, sku: "", product: domain.Product{Name: "Nameless", Price: 1, Stock: 1}},
+				{line: 8, sku: "A-6", product: domain.Product{SKU: "A-6", Name: "Vase", Price: 7, Stock: 2}},
+			},
+		},
+		{
+			name:   "CSV with a bare quote",
+			format: FormatCSV,
+			file:   "sku,name\nA-1,Lamp \"XL\"\nA-2,Chair\n",
+			want: []result{
+				{line: 2, rowErr: `bare " in non-quoted-field`},
+				{line: 3, sku: "A-2", product: domain.Product{SKU: "A-2", Name: "Chair"}},
+			},
+		},
+		{
+			name:   "NDJSON rows",
+			format: FormatNDJSON,
+			file: `{"sku":"B-1","name":"Lamp","price":10,"tags":["home"]}` + "\n" +
+				"\n" +
+				`{"sku":" B-2 ","attributes":{"watts":40}}` + "\n",
+			want: []result{
+				{line: 1, sku: "B-1", product: domain.Product{SKU: "B-1", Name: "Lamp", Price: 10, Tags: []string{"home"}}},
+				{line: 3, sku: "B-2", product: domain.Product{SKU: " B-2 ", Attributes: map[string]interface{}{"watts": float64(40)}}},
+			},
+		},
+		{
+			name:   "NDJSON per-row errors do not stop the file",
+			format: FormatNDJSON,
+			file: `{"sku":"B-1","stock":"many"}` + "\n" +
+				`{not json` + "\n" +
+				`["B-3"]` + "\n" +
+				`{"name":"no sku"}` + "\n" +
+				`{"sku":"B-5","name":"Vase"}`,
+			want: []result{
+				{line: 1, sku: "B-1", applyErr: "cannot unmarshal string"},
+				{line: 2, rowErr: "invalid JSON"},
+				{line: 3, rowErr: "invalid JSON"},
+				{line: 4, sku: "", product: domain.Product{Name: "no sku"}},
+				{line: 5, sku: "B-5", product: domain.Product{SKU: "B-5", Name: "Vase"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader, err := NewReader(tt.format, strings.NewReader(tt.file))
+			if err != nil {
+				t.Fatalf("NewReader() error = %v", err)
+			}
+			got := readAll(t, reader)
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("read %d records, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i, want := range tt.want {
+				g := got[i]
+				if g.line != want.line || g.sku != want.sku {
+					t.Errorf("record %d is line %d sku %q, want line %d sku %q", i, g.line, g.sku, want.line, want.sku)
+				}
+				if !containsError(g.rowErr, want.rowErr) {
+					t.Errorf("line %d: row error %q, want %q", want.line, g.rowErr, want.rowErr)
+				}
+				if !containsError(g.applyErr, want.applyErr) {
+					t.Errorf("line %d: apply error %q, want %q", want.line, g.applyErr, want.applyErr)
+				}
+				if want.rowErr == "" && want.applyErr == "" && !reflect.DeepEqual(g.product, want.product) {
+					t.Errorf("line %d: product %+v, want %+v", want.line, g.product, want.product)
+				}
+			}
+		})
+	}
+}
+
+// containsError reports whether got contains the error wanted; an empty
+// want expects no error.
+func containsError(got, want string) bool {
+	if want == "" {
+		return got == ""
+	}
+	return strings.Contains(got, want)
+}
+
+func TestNewCSVReaderHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		wantErr string
+	}{
+		{name: "empty file", file: "", wantErr: "CSV file is empty"},
+		{name: "no sku column", file: "name,price\nLamp,1\n", wantErr: "no sku column"},
+		{name: "unknown column", file: "sku,colour\nA-1,red\n", wantErr: `unknown CSV column "colour"`},
+		{name: "columns in any order", file: "price, sku ,name\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewReader(FormatCSV, strings.NewReader(tt.file))
+			var got string
+			if err != nil {
+				got = err.Error()
+			}
+			if !containsError(got, tt.wantErr) {
+				t.Errorf("NewReader() error = %q, want %q", got, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package catalog
+
+// Report summarizes an import. Failed counts the rows that were not written;
+// Errors also lists rows that were written but whose follow-up work, such as
+// publishing the event, failed. Error is set when the import was aborted.
+type Report struct {
+	DryRun    bool          `json:"dryRun"`
+	Rows      int           `json:"rows"`
+	Created   int           `json:"created"`
+	Updated   int           `json:"updated"`
+	Unchanged int           `json:"unchanged"`
+	Failed    int           `json:"failed"`
+	Errors    []ReportError `json:"errors"`
+	Error     string        `json:"error,omitempty"`
+}
+
+// ReportError is a problem with one row of the import file.
+type ReportError struct {
+	Line  int    `json:"line"`
+	SKU   string `json:"sku,omitempty"`
+	Error string `json:"error"`
+}
+
+func (r *Report) AddError(line int, sku string, err error) {
+	r.Errors = append(r.Errors, ReportError{Line: line, SKU: sku, Error: err.Error()})
+}
@@ -0,0 +1,91 @@
+package catalog
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"products-service/internal/domain"
+	"strconv"
+	"strings"
+)
+
+// Writer writes products in an export format. Flush has to be called once
+// all products were written.
+type Writer interface {
+	Write(product domain.Product) error
+	Flush() error
+}
+
+func NewWriter(format Format, w io.Writer) Writer {
+	if format == FormatCSV {
+		return &csvWriter{writer: csv.NewWriter(w)}
+	}
+	return &ndjsonWriter{encoder: json.NewEncoder(w)}
+}
+
+// ndjsonWriter writes every product as one JSON line, including its
+// variants.
+type ndjsonWriter struct {
+	encoder *json.Encoder
+}
+
+func (w *ndjsonWriter) Write(product domain.Product) error {
+	return w.encoder.Encode(product)
+}
+
+func (w *ndjsonWriter) Flush() error {
+	return nil
+}
+
+// csvWriter writes one product per row. Variants and stock per warehouse do
+// not fit in a row and are only exported as NDJSON.
+type csvWriter struct {
+	writer        *csv.Writer
+	headerWritten bool
+}
+
+func (w *csvWriter) Write(product domain.Product) error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+
+	attributes := ""
+	if len(product.Attributes) > 0 {
+		encoded, err := json.Marshal(product.Attributes)
+		if err != nil {
+			return err
+		}
+		attributes = string(encoded)
+	}
+
+	return w.writer.Write([]string{
+		product.ID,
+		product.SKU,
+		product.Name,
+		product.Description,
+		strconv.FormatFloat(product.Price, 'f', -1, 64),
+		strconv.Itoa(product.Stock),
+		strconv.Itoa(product.Reserved),
+		strconv.Itoa(product.Available),
+		product.CategoryID,
+		strings.Join(product.Tags, tagSeparator),
+		strconv.Itoa(product.ReorderThreshold),
+		attributes,
+	})
+}
+
+func (w *csvWriter) Flush() error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+func (w *csvWriter) writeHeader() error {
+	if w.headerWritten {
+		return nil
+	}
+	w.headerWritten = true
+	return w.writer.Write(columns)
+}
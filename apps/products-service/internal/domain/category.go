@@ -0,0 +1,195 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+)
+
+type AttributeType string
+
+const (
+	AttributeTypeString  AttributeType = "string"
+	AttributeTypeNumber  AttributeType = "number"
+	AttributeTypeBoolean AttributeType = "boolean"
+)
+
+// AttributeDefinition declares a typed attribute that products in a category
+// (or any of its subcategories) may carry.
+type AttributeDefinition struct {
+	Name     string        `json:"name" dynamodbav:"name"`
+	Type     AttributeType `json:"type" dynamodbav:"type"`
+	Required bool          `json:"required,omitempty" dynamodbav:"required,omitempty"`
+}
+
+type Category struct {
+	ID          string                `json:"id" dynamodbav:"id"`
+	Name        string                `json:"name" dynamodbav:"name"`
+	Description string                `json:"description,omitempty" dynamodbav:"description,omitempty"`
+	ParentID    string                `json:"parentId,omitempty" dynamodbav:"parentId,omitempty"`
+	Attributes  []AttributeDefinition `json:"attributes,omitempty" dynamodbav:"attributes,omitempty"`
+}
+
+func (c *Category) Validate() error {
+	if strings.TrimSpace(c.Name) == "" {
+		return fmt.Errorf("category name is required")
+	}
+	if c.ParentID != "" && c.ParentID == c.ID {
+		return fmt.Errorf("category cannot be its own parent")
+	}
+	seen := make(map[string]bool, len(c.Attributes))
+	for _, d := range c.Attributes {
+		if err := d.Validate(); err != nil {
+			return err
+		}
+		if seen[d.Name] {
+			return fmt.Errorf("attribute %q is declared twice", d.Name)
+		}
+		seen[d.Name] = true
+	}
+	return nil
+}
+
+func (d AttributeDefinition) Validate() error {
+	if strings.TrimSpace(d.Name) == "" {
+		return fmt.Errorf("attribute name is required")
+	}
+	switch d.Type {
+	case AttributeTypeString, AttributeTypeNumber, AttributeTypeBoolean:
+		return nil
+	default:
+		return fmt.Errorf("attribute %q has unsupported type %q", d.Name, d.Type)
+	}
+}
+
+// Accepts reports whether a JSON-decoded value matches the attribute type.
+func (d AttributeDefinition) Accepts(value interface{}) bool {
+	switch d.Type {
+	case AttributeTypeString:
+		_, ok := value.(string)
+		return ok
+	case AttributeTypeNumber:
+		_, ok := value.(float64)
+		return ok
+	case AttributeTypeBoolean:
+		_, ok := value.(bool)
+		return ok
+	}
+	return false
+}
+
+// CategoryTree indexes a flat list of categories by id and parent so the
+// hierarchy can be walked without further lookups.
+type CategoryTree struct {
+	byID     map[string]Category
+	children map[string][]string
+}
+
+func NewCategoryTree(categories []Category) *CategoryTree {
+	t := &CategoryTree{
+		byID:     make(map[string]Category, len(categories)),
+		children: make(map[string][]string),
+	}
+	for _, c := range categories {
+		t.byID[c.ID] = c
+		t.children[c.ParentID] = append(t.children[c.ParentID], c.ID)
+	}
+	return t
+}
+
+func (t *CategoryTree) Get(id string) (Category, bool) {
+	c, ok := t.byID[id]
+	return c, ok
+}
+
+func (t *CategoryTree) HasChildren(id string) bool {
+	return len(t.children[id]) > 0
+}
+
+// Descendants returns id followed by the ids of every category below it.
+func (t *CategoryTree) Descendants(id string) []string {
+	ids := []string{id}
+	seen := map[string]bool{id: true}
+	for i := 0; i < len(ids); i++ {
+		for _, child := range t.children[ids[i]] {
+			if !seen[child] {
+				seen[child] = true
+				ids = append(ids, child)
+			}
+		}
+	}
+	return ids
+}
+
+// Ancestors returns the chain from id up to its root, starting with id.
+func (t *CategoryTree) Ancestors(id string) []Category {
+	var chain []Category
+	seen := map[string]bool{}
+	for id != "" && !seen[id] {
+		c, ok := t.byID[id]
+		if !ok {
+			break
+		}
+		seen[id] = true
+		chain = append(chain, c)
+		id = c.ParentID
+	}
+	return chain
+}
+
+// AttributeDefinitions returns the attribute definitions that apply to id,
+// inherited from every ancestor. Closer categories override farther ones.
+func (t *CategoryTree) AttributeDefinitions(id string) map[string]AttributeDefinition {
+	defs := make(map[string]AttributeDefinition)
+	chain := t.Ancestors(id)
+	for i := len(chain) - 1; i >= 0; i-- {
+		for _, d := range chain[i].Attributes {
+			defs[d.Name] = d
+		}
+	}
+	return defs
+}
+
+// ValidateParent checks that setting parentID as the parent of id keeps the
+// hierarchy acyclic.
+func (t *CategoryTree) ValidateParent(id, parentID string) error {
+	if parentID == "" {
+		return nil
+	}
+	if _, ok := t.byID[parentID]; !ok {
+		return fmt.Errorf("parent category %s not found", parentID)
+	}
+	for _, ancestor := range t.Ancestors(parentID) {
+		if ancestor.ID == id {
+			return fmt.Errorf("category %s cannot be moved below itself", id)
+		}
+	}
+	return nil
+}
+
+// ValidateProduct checks the product's attributes against the definitions of
+// its category and ancestors.
+func (t *CategoryTree) ValidateProduct(product *Product) error {
+	if product.CategoryID == "" {
+		return nil
+	}
+	if _, ok := t.byID[product.CategoryID]; !ok {
+		return fmt.Errorf("category %s not found", product.CategoryID)
+	}
+
+	defs := t.AttributeDefinitions(product.CategoryID)
+	for name, value := range product.Attributes {
+		def, ok := defs[name]
+		if !ok {
+			continue
+		}
+		if !def.Accepts(value) {
+			return fmt.Errorf("attribute %q must be a %s", name, def.Type)
+		}
+	}
+	for name, def := range defs {
+		if _, ok := product.Attributes[name]; def.Required && !ok {
+			return fmt.Errorf("attribute %q is required", name)
+		}
+	}
+	return nil
+}
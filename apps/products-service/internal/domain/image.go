@@ -0,0 +1,28 @@
+package domain
+
+// ProductImage is an image of a product. The original and its thumbnail are
+// kept in the image store under keys derived from the product and image ids;
+// the URLs to fetch them are filled in when the product is read and never
+// stored.
+type ProductImage struct {
+	ID          string `json:"id" dynamodbav:"id"`
+	ContentType string `json:"contentType" dynamodbav:"contentType"`
+	Width       int    `json:"width" dynamodbav:"width"`
+	Height      int    `json:"height" dynamodbav:"height"`
+	Alt         string `json:"alt,omitempty" dynamodbav:"alt,omitempty"`
+	CreatedAt   string `json:"createdAt" dynamodbav:"createdAt"`
+
+	URL          string `json:"url,omitempty" dynamodbav:"-"`
+	ThumbnailURL string `json:"thumbnailUrl,omitempty" dynamodbav:"-"`
+}
+
+// ImageIndex returns the position of the image in the product gallery, or
+// -1 when the product has no such image.
+func (p *Product) ImageIndex(id string) int {
+	for i, image := range p.Images {
+		if image.ID == id {
+			return i
+		}
+	}
+	return -1
+}
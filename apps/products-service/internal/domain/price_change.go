@@ -0,0 +1,60 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+type PriceChangeStatus string
+
+const (
+	PriceChangeScheduled PriceChangeStatus = "scheduled"
+	PriceChangeApplied   PriceChangeStatus = "applied"
+	PriceChangeCanceled  PriceChangeStatus = "canceled"
+)
+
+type PriceChangeKind string
+
+const (
+	PriceChangeKindChange    PriceChangeKind = "change"
+	PriceChangeKindSaleStart PriceChangeKind = "sale-start"
+	PriceChangeKindSaleEnd   PriceChangeKind = "sale-end"
+)
+
+// PriceChange sets the price of a product from EffectiveAt on. Applied
+// changes make up the price history; scheduled ones are applied by the price
+// scheduler once they are due.
+//
+// A time-boxed sale is a sale-start and a sale-end change sharing SaleID,
+// which is the id of the sale-start. The sale-end has no price of its own: it
+// goes back to the price the product had before the sale started.
+type PriceChange struct {
+	ID        string            `json:"id" dynamodbav:"id"`
+	ProductID string            `json:"productId" dynamodbav:"productId"`
+	Kind      PriceChangeKind   `json:"kind" dynamodbav:"kind"`
+	Status    PriceChangeStatus `json:"status" dynamodbav:"status"`
+	Price     float64           `json:"price" dynamodbav:"price"`
+	// PreviousPrice is the price the change replaced, known once applied.
+	PreviousPrice *float64 `json:"previousPrice,omitempty" dynamodbav:"previousPrice,omitempty"`
+	SaleID        string   `json:"saleId,omitempty" dynamodbav:"saleId,omitempty"`
+	EffectiveAt   string   `json:"effectiveAt" dynamodbav:"effectiveAt"`
+	Actor         string   `json:"actor" dynamodbav:"actor"`
+	Note          string   `json:"note,omitempty" dynamodbav:"note,omitempty"`
+	CreatedAt     string   `json:"createdAt" dynamodbav:"createdAt"`
+	AppliedAt     string   `json:"appliedAt,omitempty" dynamodbav:"appliedAt,omitempty"`
+}
+
+// Validate checks a change that is about to be scheduled.
+func (c *PriceChange) Validate(now time.Time) error {
+	if c.Kind != PriceChangeKindSaleEnd && c.Price < 0 {
+		return fmt.Errorf("price cannot be negative")
+	}
+	effectiveAt, err := time.Parse(time.RFC3339, c.EffectiveAt)
+	if err != nil {
+		return fmt.Errorf("effectiveAt must be an RFC 3339 time")
+	}
+	if effectiveAt.Before(now) {
+		return fmt.Errorf("effectiveAt cannot be in the past")
+	}
+	return nil
+}
@@ -1,9 +1,331 @@
 package domain
 
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type ProductStatus string
+
+const (
+	ProductActive   ProductStatus = "active"
+	ProductArchived ProductStatus = "archived"
+)
+
 type Product struct {
-	ID          string  `json:"id" dynamodbav:"id"`
+	ID string `json:"id" dynamodbav:"id"`
+	// SKU is the catalog code of the product. Bulk imports match rows to
+	// existing products by it.
+	SKU         string  `json:"sku,omitempty" dynamodbav:"sku,omitempty"`
 	Name        string  `json:"name" dynamodbav:"name"`
 	Description string  `json:"description" dynamodbav:"description"`
 	Price       float64 `json:"price" dynamodbav:"price"`
 	Stock       int     `json:"stock" dynamodbav:"stock"`
+	// Reserved is the stock held by open reservations; Available is what is
+	// left to sell. Available is computed and never stored.
+	Reserved   int                    `json:"reserved" dynamodbav:"reserved"`
+	Available  int                    `json:"available" dynamodbav:"-"`
+	CategoryID string                 `json:"categoryId,omitempty" dynamodbav:"categoryId,omitempty"`
+	Tags       []string               `json:"tags,omitempty" dynamodbav:"tags,omitempty"`
+	Attributes map[string]interface{} `json:"attributes,omitempty" dynamodbav:"attributes,omitempty"`
+	// ReorderThreshold is the stock at or below which the product is
+	// reported as low on stock. Zero only reports products that ran out.
+	ReorderThreshold int `json:"reorderThreshold,omitempty" dynamodbav:"reorderThreshold"`
+	// Locations holds the stock per warehouse id. When set, Stock is the sum
+	// of the location stock.
+	Locations map[string]int `json:"locations,omitempty" dynamodbav:"locations,omitempty"`
+	// Variants are keyed by SKU. When a product has variants its Stock is
+	// the sum of the variant stock.
+	Variants map[string]Variant `json:"variants,omitempty" dynamodbav:"variants,omitempty"`
+	// Images are shown in this order; the first one is the main image.
+	Images []ProductImage `json:"images,omitempty" dynamodbav:"images,omitempty"`
+	// Status is the lifecycle of the product. Archived products are kept
+	// for order history but are no longer listed, reserved or restocked.
+	// Products stored before the lifecycle existed have no status and are
+	// active.
+	Status     ProductStatus `json:"status,omitempty" dynamodbav:"status,omitempty"`
+	ArchivedAt string        `json:"archivedAt,omitempty" dynamodbav:"archivedAt,omitempty"`
+	// ReviewCount and RatingTotal add up the approved reviews and are only
+	// written by the review repository. Rating is their average, computed
+	// and never stored.
+	ReviewCount int     `json:"reviewCount,omitempty" dynamodbav:"reviewCount,omitempty"`
+	RatingTotal int     `json:"-" dynamodbav:"ratingTotal,omitempty"`
+	Rating      float64 `json:"rating,omitempty" dynamodbav:"-"`
+	// Version counts the writes to the product, so it is only replaced
+	// when nothing else wrote it since it was read.
+	Version int `json:"-" dynamodbav:"version,omitempty"`
+}
+
+// DefaultLocation holds the stock a product or variant had before it was
+// tracked per warehouse, until it is moved to a warehouse.
+const DefaultLocation = "default"
+
+func (p *Product) IsArchived() bool {
+	return p.Status == ProductArchived
+}
+
+// ProductFilter narrows a product listing. All conditions must match: the
+// product belongs to one of CategoryIDs, carries every tag in Tags and has
+// each attribute in Attributes set to the given value.
+type ProductFilter struct {
+	CategoryIDs []string
+	Tags        []string
+	Attributes  map[string]string
+}
+
+func (f ProductFilter) IsEmpty() bool {
+	return len(f.CategoryIDs) == 0 && len(f.Tags) == 0 && len(f.Attributes) == 0
+}
+
+// Facets returns, for each tag and attribute condition of the filter, the
+// facets of which a matching product has at least one. Numeric looking
+// attribute values also match numbers written differently, e.g. "1.50"
+// matches 1.5.
+func (f ProductFilter) Facets() [][]string {
+	var conditions [][]string
+	for _, tag := range f.Tags {
+		conditions = append(conditions, []string{TagFacet(tag)})
+	}
+	for name, value := range f.Attributes {
+		alternatives := []string{AttributeFacet(name, value)}
+		if n, err := strconv.ParseFloat(value, 64); err == nil && !math.IsNaN(n) && !math.IsInf(n, 0) {
+			if formatted := strconv.FormatFloat(n, 'f', -1, 64); formatted != value {
+				alternatives = append(alternatives, AttributeFacet(name, formatted))
+			}
+		}
+		conditions = append(conditions, alternatives)
+	}
+	return conditions
+}
+
+// Matches reports whether the product meets every condition of the filter.
+func (f ProductFilter) Matches(p *Product) bool {
+	if len(f.CategoryIDs) > 0 && !slices.Contains(f.CategoryIDs, p.CategoryID) {
+		return false
+	}
+	facets := make(map[string]bool)
+	for _, facet := range p.Facets() {
+		facets[facet] = true
+	}
+	for _, alternatives := range f.Facets() {
+		if !slices.ContainsFunc(alternatives, func(facet string) bool { return facets[facet] }) {
+			return false
+		}
+	}
+	return true
+}
+
+// Facets returns the tags and attribute values the product can be filtered
+// on, in the form the filter conditions look them up by. Attributes holding
+// lists or objects cannot be filtered on and have none.
+func (p *Product) Facets() []string {
+	var facets []string
+	for _, tag := range p.Tags {
+		facets = append(facets, TagFacet(tag))
+	}
+	for name, value := range p.Attributes {
+		switch v := value.(type) {
+		case string:
+			facets = append(facets, AttributeFacet(name, v))
+		case float64:
+			facets = append(facets, AttributeFacet(name, strconv.FormatFloat(v, 'f', -1, 64)))
+		case bool:
+			facets = append(facets, AttributeFacet(name, strconv.FormatBool(v)))
+		}
+	}
+	return facets
+}
+
+// MaxFacets bounds the tags and attribute values of a product together. A
+// product is written in one transaction with the changes to its facets, and
+// DynamoDB takes at most 100 items in one: the product, the facets it gains
+// and the ones it loses.
+const MaxFacets = 48
+
+// ValidateFacets checks that the product has no more than MaxFacets tags and
+// attribute values.
+func (p *Product) ValidateFacets() error {
+	if n := len(p.Facets()); n > MaxFacets {
+		return fmt.Errorf("a product can have at most %d tags and attributes, got %d", MaxFacets, n)
+	}
+	return nil
+}
+
+// TagFacet and AttributeFacet name a tag and an attribute value as facets.
+func TagFacet(tag string) string {
+	return "tag:" + tag
+}
+
+func AttributeFacet(name, value string) string {
+	return "attribute:" + name + "=" + value
+}
+
+// Normalize lowercases and deduplicates tags, drops empty ones and keeps the
+// variant SKUs and the aggregated stock consistent with the variants map.
+func (p *Product) Normalize() {
+	p.Tags = NormalizeTags(p.Tags)
+
+	if len(p.Locations) > 0 {
+		p.Stock = sumLocations(p.Locations)
+	}
+
+	if len(p.Variants) > 0 {
+		stock, reserved := 0, 0
+		for sku, v := range p.Variants {
+			v.SKU = sku
+			if len(v.Locations) > 0 {
+				v.Stock = sumLocations(v.Locations)
+			}
+			p.Variants[sku] = v
+			stock += v.Stock
+			reserved += v.Reserved
+		}
+		p.Stock = stock
+		p.Reserved = reserved
+	}
+
+	p.ComputeAvailability()
+}
+
+// ComputeAvailability fills in the stock that is not held by reservations.
+func (p *Product) ComputeAvailability() {
+	p.Available = p.Stock - p.Reserved
+	for sku, v := range p.Variants {
+		v.Available = v.Stock - v.Reserved
+		p.Variants[sku] = v
+	}
+}
+
+// Clone returns a deep copy of the product, so it can be compared with the
+// product after a request body was decoded into it.
+func (p *Product) Clone() *Product {
+	clone := *p
+	clone.Tags = append([]string(nil), p.Tags...)
+	if p.Attributes != nil {
+		clone.Attributes = make(map[string]interface{}, len(p.Attributes))
+		for name, value := range p.Attributes {
+			clone.Attributes[name] = value
+		}
+	}
+	clone.Images = append([]ProductImage(nil), p.Images...)
+	clone.Locations = copyLocations(p.Locations)
+	if p.Variants != nil {
+		clone.Variants = make(map[string]Variant, len(p.Variants))
+		for sku, v := range p.Variants {
+			clone.Variants[sku] = v.clone()
+		}
+	}
+	return &clone
+}
+
+// ChangedFields returns the JSON names of the fields that differ between two
+// versions of a product. Empty and missing collections are the same.
+func ChangedFields(before, after *Product) []string {
+	fields := []struct {
+		name          string
+		before, after interface{}
+	}{
+		{"sku", before.SKU, after.SKU},
+		{"name", before.Name, after.Name},
+		{"description", before.Description, after.Description},
+		{"price", before.Price, after.Price},
+		{"stock", before.Stock, after.Stock},
+		{"reserved", before.Reserved, after.Reserved},
+		{"reorderThreshold", before.ReorderThreshold, after.ReorderThreshold},
+		{"categoryId", before.CategoryID, after.CategoryID},
+		{"tags", before.Tags, after.Tags},
+		{"attributes", before.Attributes, after.Attributes},
+		{"locations", before.Locations, after.Locations},
+		{"variants", before.Variants, after.Variants},
+		{"images", before.Images, after.Images},
+		{"status", before.Status, after.Status},
+	}
+
+	var changed []string
+	for _, f := range fields {
+		if !equalValues(f.before, f.after) {
+			changed = append(changed, f.name)
+		}
+	}
+	return changed
+}
+
+func equalValues(a, b interface{}) bool {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	switch va.Kind() {
+	case reflect.Map, reflect.Slice:
+		if va.Len() == 0 && vb.Len() == 0 {
+			return true
+		}
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func copyLocations(locations map[string]int) map[string]int {
+	if locations == nil {
+		return nil
+	}
+	copied := make(map[string]int, len(locations))
+	for warehouseID, stock := range locations {
+		copied[warehouseID] = stock
+	}
+	return copied
+}
+
+func sumLocations(locations map[string]int) int {
+	total := 0
+	for _, stock := range locations {
+		total += stock
+	}
+	return total
+}
+
+// ValidateLocations checks that no warehouse holds negative stock.
+func (p *Product) ValidateLocations() error {
+	for warehouseID, stock := range p.Locations {
+		if stock < 0 {
+			return fmt.Errorf("stock at warehouse %s cannot be negative", warehouseID)
+		}
+	}
+	for sku, v := range p.Variants {
+		for warehouseID, stock := range v.Locations {
+			if stock < 0 {
+				return fmt.Errorf("variant %s stock at warehouse %s cannot be negative", sku, warehouseID)
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateAttributes checks that every attribute holds a scalar value.
+func (p *Product) ValidateAttributes() error {
+	for name, value := range p.Attributes {
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("attribute name is required")
+		}
+		switch value.(type) {
+		case string, float64, bool:
+		default:
+			return fmt.Errorf("attribute %q must be a string, number or boolean", name)
+		}
+	}
+	return nil
+}
+
+func NormalizeTags(tags []string) []string {
+	var normalized []string
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
 }
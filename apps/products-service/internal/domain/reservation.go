@@ -0,0 +1,49 @@
+package domain
+
+import "fmt"
+
+type ReservationStatus string
+
+const (
+	ReservationHeld      ReservationStatus = "held"
+	ReservationConfirmed ReservationStatus = "confirmed"
+	ReservationReleased  ReservationStatus = "released"
+	ReservationExpired   ReservationStatus = "expired"
+	// ReservationFulfilled is a confirmed reservation whose order the
+	// products-worker processed, taking the stock held for it.
+	ReservationFulfilled ReservationStatus = "fulfilled"
+)
+
+// Reservation holds stock for a checkout until it is confirmed, released or
+// expires. Held quantities count as reserved on the product and are not
+// available to other customers; the stock itself only moves when the order
+// is placed. A confirmed reservation keeps holding the stock for its order
+// until the order is processed, or until it expires again, which its
+// confirmation renews.
+type Reservation struct {
+	ID        string `json:"id" dynamodbav:"id"`
+	ProductID string `json:"productId" dynamodbav:"productId"`
+	// CustomerID is the subject the reservation was made by, the only one
+	// besides the staff who can read and settle it.
+	CustomerID string            `json:"customerId,omitempty" dynamodbav:"customerId,omitempty"`
+	SKU        string            `json:"sku,omitempty" dynamodbav:"sku,omitempty"`
+	Quantity   int               `json:"quantity" dynamodbav:"quantity"`
+	Status     ReservationStatus `json:"status" dynamodbav:"status"`
+	OrderID    string            `json:"orderId,omitempty" dynamodbav:"orderId,omitempty"`
+	ExpiresAt  string            `json:"expiresAt" dynamodbav:"expiresAt"`
+	CreatedAt  string            `json:"createdAt" dynamodbav:"createdAt"`
+	UpdatedAt  string            `json:"updatedAt,omitempty" dynamodbav:"updatedAt,omitempty"`
+	// TTL lets DynamoDB delete settled reservations some time after they
+	// expire. It is not what releases a hold; the expiry sweeper is.
+	TTL int64 `json:"-" dynamodbav:"ttl"`
+}
+
+func (r *Reservation) Validate() error {
+	if r.ProductID == "" {
+		return fmt.Errorf("productId is required")
+	}
+	if r.Quantity <= 0 {
+		return fmt.Errorf("quantity must be positive")
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package domain
+
+import (
+	"fmt"
+	"math"
+	"unicode/utf8"
+)
+
+type ReviewStatus string
+
+const (
+	ReviewPending  ReviewStatus = "pending"
+	ReviewApproved ReviewStatus = "approved"
+	ReviewRejected ReviewStatus = "rejected"
+)
+
+const (
+	maxReviewTitleLength = 200
+	maxReviewBodyLength  = 5000
+)
+
+// Review is the opinion of a customer on a product they received. A
+// customer reviews a product once. Reviews are pending until moderated and
+// only approved ones are shown and count towards the rating of the product.
+type Review struct {
+	ProductID  string `json:"productId" dynamodbav:"productId"`
+	CustomerID string `json:"customerId" dynamodbav:"customerId"`
+	// OrderID is the delivered order that proves the customer bought the
+	// product.
+	OrderID        string       `json:"orderId" dynamodbav:"orderId"`
+	Rating         int          `json:"rating" dynamodbav:"rating"`
+	Title          string       `json:"title,omitempty" dynamodbav:"title,omitempty"`
+	Body           string       `json:"body,omitempty" dynamodbav:"body,omitempty"`
+	Status         ReviewStatus `json:"status" dynamodbav:"status"`
+	ModeratedBy    string       `json:"moderatedBy,omitempty" dynamodbav:"moderatedBy,omitempty"`
+	ModerationNote string       `json:"moderationNote,omitempty" dynamodbav:"moderationNote,omitempty"`
+	CreatedAt      string       `json:"createdAt" dynamodbav:"createdAt"`
+	ModeratedAt    string       `json:"moderatedAt,omitempty" dynamodbav:"moderatedAt,omitempty"`
+}
+
+func (r *Review) Validate() error {
+	if r.Rating < 1 || r.Rating > 5 {
+		return fmt.Errorf("rating must be between 1 and 5")
+	}
+	if utf8.RuneCountInString(r.Title) > maxReviewTitleLength {
+		return fmt.Errorf("title cannot be longer than %d characters", maxReviewTitleLength)
+	}
+	if utf8.RuneCountInString(r.Body) > maxReviewBodyLength {
+		return fmt.Errorf("body cannot be longer than %d characters", maxReviewBodyLength)
+	}
+	return nil
+}
+
+// ComputeRating fills in the average rating of the approved reviews,
+// rounded to one decimal.
+func (p *Product) ComputeRating() {
+	p.Rating = 0
+	if p.ReviewCount > 0 {
+		p.Rating = math.Round(float64(p.RatingTotal)/float64(p.ReviewCount)*10) / 10
+	}
+}
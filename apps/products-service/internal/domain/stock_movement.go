@@ -0,0 +1,70 @@
+package domain
+
+type MovementReason string
+
+const (
+	MovementReasonSale             MovementReason = "sale"
+	MovementReasonCancel           MovementReason = "cancel"
+	MovementReasonReturn           MovementReason = "return"
+	MovementReasonManualAdjustment MovementReason = "manual-adjustment"
+	MovementReasonRecount          MovementReason = "recount"
+	// MovementReasonRelease moves no stock: it records the products-worker
+	// giving back what reservations held for an order the order did not
+	// take.
+	MovementReasonRelease MovementReason = "release"
+)
+
+// StockMovement is an immutable record of a stock change. Balance is the
+// resulting stock of the variant when SKU is set, otherwise of the product;
+// when WarehouseID is set it is the resulting stock at that warehouse.
+type StockMovement struct {
+	ID          string         `json:"id" dynamodbav:"id"`
+	ProductID   string         `json:"productId" dynamodbav:"productId"`
+	SKU         string         `json:"sku,omitempty" dynamodbav:"sku,omitempty"`
+	WarehouseID string         `json:"warehouseId,omitempty" dynamodbav:"warehouseId,omitempty"`
+	OrderID     string         `json:"orderId,omitempty" dynamodbav:"orderId,omitempty"`
+	EventID     string         `json:"eventId,omitempty" dynamodbav:"eventId,omitempty"`
+	Reason      MovementReason `json:"reason" dynamodbav:"reason"`
+	Actor       string         `json:"actor" dynamodbav:"actor"`
+	Note        string         `json:"note,omitempty" dynamodbav:"note,omitempty"`
+	Delta       int            `json:"delta" dynamodbav:"delta"`
+	Balance     int            `json:"balance" dynamodbav:"balance"`
+	CreatedAt   string         `json:"createdAt" dynamodbav:"createdAt"`
+}
+
+// Stock alerts published when a stock change moves a product into another
+// stock state. The products-worker publishes the same for the stock of
+// orders.
+const (
+	StockAlertLow         = "stock.low"
+	StockAlertDepleted    = "stock.depleted"
+	StockAlertReplenished = "stock.replenished"
+)
+
+// StockChange is the stock of a product before and after a movement.
+type StockChange struct {
+	ProductID        string
+	Name             string
+	Before           int
+	After            int
+	ReorderThreshold int
+}
+
+// AlertType returns the stock alert for a change that moved the product
+// into another stock state (depleted, low at or below the reorder threshold,
+// or available), or "" when the state did not change.
+func (c StockChange) AlertType() string {
+	state := func(stock int) string {
+		switch {
+		case stock <= 0:
+			return StockAlertDepleted
+		case stock <= c.ReorderThreshold:
+			return StockAlertLow
+		}
+		return StockAlertReplenished
+	}
+	if after := state(c.After); after != state(c.Before) {
+		return after
+	}
+	return ""
+}
@@ -0,0 +1,85 @@
+package domain
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Variant is a purchasable version of a product, e.g. a T-shirt in size M and
+// color red. Variants are keyed by SKU on the product; Price overrides the
+// product price when set.
+type Variant struct {
+	SKU     string            `json:"sku" dynamodbav:"sku"`
+	Options map[string]string `json:"options,omitempty" dynamodbav:"options,omitempty"`
+	Price   *float64          `json:"price,omitempty" dynamodbav:"price,omitempty"`
+	Stock   int               `json:"stock" dynamodbav:"stock"`
+	// Reserved and Available have the same meaning as on Product.
+	Reserved  int `json:"reserved" dynamodbav:"reserved"`
+	Available int `json:"available" dynamodbav:"-"`
+	// Locations holds the variant stock per warehouse id.
+	Locations map[string]int `json:"locations,omitempty" dynamodbav:"locations,omitempty"`
+}
+
+// EffectivePrice returns the variant price override or the product price.
+func (v Variant) EffectivePrice(product *Product) float64 {
+	if v.Price != nil {
+		return *v.Price
+	}
+	return product.Price
+}
+
+// optionKey is a canonical representation of the option values, used to find
+// variants that would be indistinguishable to a customer.
+func (v Variant) optionKey() string {
+	names := make([]string, 0, len(v.Options))
+	for name := range v.Options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, name+"="+v.Options[name])
+	}
+	return strings.Join(parts, ";")
+}
+
+// ValidateVariants checks SKUs, prices, stock and that no two variants share
+// the same option values.
+func (p *Product) ValidateVariants() error {
+	combinations := make(map[string]string, len(p.Variants))
+	for sku, v := range p.Variants {
+		if strings.TrimSpace(sku) == "" {
+			return fmt.Errorf("variant sku is required")
+		}
+		if v.Stock < 0 {
+			return fmt.Errorf("variant %s stock cannot be negative", sku)
+		}
+		if v.Price != nil && *v.Price < 0 {
+			return fmt.Errorf("variant %s price cannot be negative", sku)
+		}
+		key := v.optionKey()
+		if other, ok := combinations[key]; ok {
+			return fmt.Errorf("variants %s and %s have the same options", other, sku)
+		}
+		combinations[key] = sku
+	}
+	return nil
+}
+
+func (v Variant) clone() Variant {
+	if v.Options != nil {
+		options := make(map[string]string, len(v.Options))
+		for name, value := range v.Options {
+			options[name] = value
+		}
+		v.Options = options
+	}
+	if v.Price != nil {
+		price := *v.Price
+		v.Price = &price
+	}
+	v.Locations = copyLocations(v.Locations)
+	return v
+}
@@ -0,0 +1,62 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Warehouse is a location products are stocked at and shipped from. Lower
+// Priority values are preferred by the priority allocation strategy; the
+// coordinates are used by the nearest strategy.
+type Warehouse struct {
+	ID        string   `json:"id" dynamodbav:"id"`
+	Name      string   `json:"name" dynamodbav:"name"`
+	Priority  int      `json:"priority" dynamodbav:"priority"`
+	Latitude  *float64 `json:"latitude,omitempty" dynamodbav:"latitude,omitempty"`
+	Longitude *float64 `json:"longitude,omitempty" dynamodbav:"longitude,omitempty"`
+	Disabled  bool     `json:"disabled,omitempty" dynamodbav:"disabled,omitempty"`
+}
+
+func (w *Warehouse) Validate() error {
+	if strings.TrimSpace(w.Name) == "" {
+		return fmt.Errorf("warehouse name is required")
+	}
+	if (w.Latitude == nil) != (w.Longitude == nil) {
+		return fmt.Errorf("latitude and longitude must be set together")
+	}
+	if w.Latitude != nil && (*w.Latitude < -90 || *w.Latitude > 90 || *w.Longitude < -180 || *w.Longitude > 180) {
+		return fmt.Errorf("coordinates are out of range")
+	}
+	return nil
+}
+
+// AllocationLine says how many units of the order item at position Item are
+// picked from a warehouse.
+type AllocationLine struct {
+	Item        int    `json:"item" dynamodbav:"item"`
+	ProductID   string `json:"productId" dynamodbav:"productId"`
+	SKU         string `json:"sku,omitempty" dynamodbav:"sku,omitempty"`
+	WarehouseID string `json:"warehouseId" dynamodbav:"warehouseId"`
+	Quantity    int    `json:"quantity" dynamodbav:"quantity"`
+}
+
+// AllocationHold is a reservation confirmed for the order. Its quantity
+// stays reserved until the products-worker takes the stock for the order.
+type AllocationHold struct {
+	ReservationID string `json:"reservationId" dynamodbav:"reservationId"`
+	ProductID     string `json:"productId" dynamodbav:"productId"`
+	SKU           string `json:"sku,omitempty" dynamodbav:"sku,omitempty"`
+	Quantity      int    `json:"quantity" dynamodbav:"quantity"`
+}
+
+// Allocation is the fulfillment plan the products-worker computed for an order.
+type Allocation struct {
+	OrderID   string           `json:"orderId" dynamodbav:"orderId"`
+	Strategy  string           `json:"strategy" dynamodbav:"strategy"`
+	Lines     []AllocationLine `json:"lines" dynamodbav:"lines"`
+	Holds     []AllocationHold `json:"holds,omitempty" dynamodbav:"holds,omitempty"`
+	CreatedAt string           `json:"createdAt" dynamodbav:"createdAt"`
+	// HoldsVersion counts the changes to Holds, which are written on the
+	// condition that it did not change since they were read.
+	HoldsVersion int `json:"-" dynamodbav:"holdsVersion,omitempty"`
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"strings"
+
+	"products-service/internal/domain"
+	"products-service/internal/repository"
+	"telemetry/tracing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// maxBatchGetIDs bounds how many products one batch lookup may ask for.
+const maxBatchGetIDs = 500
+
+// BatchGetProductsHandler handles POST /api/products:batchGet and
+// GET /api/products?ids=<id>,<id>
+//
+// The POST body is {"ids": [...]}. Products are returned in the order they
+// were asked for, together with the ids that do not exist.
+func BatchGetProductsHandler(repo repository.ProductRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, span := tracing.NewSpan(c.UserContext(), "BatchGetProductsHandler")
+		defer span.End()
+
+		var ids []string
+		if c.Method() == fiber.MethodPost {
+			var input struct {
+				IDs []string `json:"ids"`
+			}
+			if err := c.BodyParser(&input); err != nil {
+				span.RecordError(err)
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "Invalid request body",
+				})
+			}
+			ids = input.IDs
+		} else {
+			for _, value := range c.Context().QueryArgs().PeekMulti("ids") {
+				ids = append(ids, strings.Split(string(value), ",")...)
+			}
+		}
+
+		ids = uniqueIDs(ids)
+		if len(ids) == 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "ids is required",
+			})
+		}
+		if len(ids) > maxBatchGetIDs {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Too many ids, at most 500 per request",
+			})
+		}
+		span.SetAttributes(
+			tracing.IntAttribute("ids", len(ids)),
+		)
+
+		found, err := repo.GetByIDs(ctx, ids)
+		if err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		byID := make(map[string]domain.Product, len(found))
+		for _, product := range found {
+			byID[product.ID] = product
+		}
+		products := make([]domain.Product, 0, len(found))
+		missing := []string{}
+		for _, id := range ids {
+			if product, ok := byID[id]; ok {
+				products = append(products, product)
+			} else {
+				missing = append(missing, id)
+			}
+		}
+
+		return c.JSON(fiber.Map{
+			"products": products,
+			"missing":  missing,
+		})
+	}
+}
+
+// uniqueIDs trims the ids and drops empty and repeated ones, keeping the
+// order they were given in.
+func uniqueIDs(ids []string) []string {
+	var unique []string
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		id = strings.TrimSpace(id)
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		unique = append(unique, id)
+	}
+	return unique
+}
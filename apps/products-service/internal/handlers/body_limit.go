@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"io"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// LimitBody puts back the request body limit that streaming request bodies
+// lifts. fasthttp streams the bodies of every route or of none, and only
+// the paths given want them streamed; the bodies of the other routes are
+// read into memory up to limit, as without streaming, and larger ones are
+// answered 413.
+func LimitBody(limit int, streamed ...string) fiber.Handler {
+	skip := make(map[string]bool, len(streamed))
+	for _, path := range streamed {
+		skip[path] = true
+	}
+	return func(c *fiber.Ctx) error {
+		if skip[c.Path()] {
+			return c.Next()
+		}
+
+		tooLarge := c.Request().Header.ContentLength() > limit
+		if stream := c.Context().RequestBodyStream(); stream != nil && !tooLarge {
+			body, err := io.ReadAll(io.LimitReader(stream, int64(limit)+1))
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "Failed to read the request body",
+				})
+			}
+			tooLarge = len(body) > limit
+			c.Request().SetBody(body)
+		}
+		if tooLarge {
+			// The rest of the body is left unread, so the connection
+			// cannot carry another request.
+			c.Context().SetConnectionClose()
+			return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+				"error": "Request body is too large",
+			})
+		}
+		return c.Next()
+	}
+}
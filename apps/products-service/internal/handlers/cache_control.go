@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// CacheControl lets clients and proxies keep successful responses for
+// maxAge. Combined with an ETag they revalidate afterwards instead of
+// downloading the response again.
+func CacheControl(maxAge time.Duration) fiber.Handler {
+	value := "public, max-age=" + strconv.Itoa(int(maxAge.Seconds()))
+	return func(c *fiber.Ctx) error {
+		if err := c.Next(); err != nil {
+			return err
+		}
+		switch c.Response().StatusCode() {
+		case fiber.StatusOK, fiber.StatusNotModified:
+			c.Set(fiber.HeaderCacheControl, value)
+		default:
+			c.Set(fiber.HeaderCacheControl, "no-store")
+		}
+		return nil
+	}
+}
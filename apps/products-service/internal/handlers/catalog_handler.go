@@ -0,0 +1,400 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+
+	"products-service/internal/catalog"
+	"products-service/internal/domain"
+	"products-service/internal/publisher"
+	"products-service/internal/repository"
+	"telemetry/tracing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// importChunkSize is how many rows are matched against the catalog and
+// written together, one transaction.
+const importChunkSize = 25
+
+// errAmbiguousSKU aborts an import that would have to pick one of several
+// products with the same SKU to update.
+var errAmbiguousSKU = errors.New("sku matches more than one product")
+
+// ImportCatalogHandler handles POST /api/products:import
+//
+// The body is a CSV or NDJSON file, picked by ?format= or the Content-Type,
+// and is read as it streams in. Rows are upserted by SKU: a row updates the
+// product with its SKU or creates a new one. Stock in a row is the opening
+// stock of a new product; existing products keep theirs, it only changes
+// through stock adjustments. With ?dryRun=true the rows are checked and
+// matched but nothing is written. Rows that fail, including those whose
+// product changed while they were imported, are listed in the report and
+// do not stop the import. A SKU shared by several products does, with 409.
+func ImportCatalogHandler(repo repository.ProductRepository, categories repository.CategoryRepository, movements repository.StockMovementRepository, prices repository.PriceChangeRepository, pub publisher.ProductPublisher) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, span := tracing.NewSpan(c.UserContext(), "ImportCatalogHandler")
+		defer span.End()
+
+		format, err := catalog.ParseFormat(c.Query("format", c.Get(fiber.HeaderContentType)))
+		if err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		body := c.Context().RequestBodyStream()
+		if body == nil {
+			body = bytes.NewReader(c.Body())
+		}
+		reader, err := catalog.NewReader(format, body)
+		if err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		imp := &catalogImport{
+			repo:       repo,
+			categories: &importCategories{CategoryRepository: categories},
+			movements:  movements,
+			prices:     prices,
+			pub:        pub,
+			actor:      actorFrom(c),
+			seen:       make(map[string]int),
+			report: &catalog.Report{
+				DryRun: c.QueryBool("dryRun"),
+				Errors: []catalog.ReportError{},
+			},
+		}
+		span.SetAttributes(
+			tracing.StringAttribute("format", string(format)),
+			tracing.BoolAttribute("dryRun", imp.report.DryRun),
+		)
+
+		status, err := imp.run(ctx, reader)
+		if err != nil {
+			span.RecordError(err)
+			imp.report.Error = "Import aborted: " + err.Error()
+		}
+
+		span.SetAttributes(
+			tracing.IntAttribute("rows", imp.report.Rows),
+			tracing.IntAttribute("failed", imp.report.Failed),
+		)
+		return c.Status(status).JSON(imp.report)
+	}
+}
+
+// catalogImport collects the rows of an import into chunks and upserts
+// them.
+type catalogImport struct {
+	repo       repository.ProductRepository
+	categories repository.CategoryRepository
+	movements  repository.StockMovementRepository
+	prices     repository.PriceChangeRepository
+	pub        publisher.ProductPublisher
+	actor      string
+	report     *catalog.Report
+
+	// seen maps every SKU in the file to the line it first appeared on.
+	seen map[string]int
+	rows []*catalog.Row
+}
+
+// importedProduct is a row ready to be written. before is nil when the row
+// creates the product.
+type importedProduct struct {
+	line    int
+	before  *domain.Product
+	product *domain.Product
+}
+
+// run reads the file to the end. It only returns an error, together with
+// the HTTP status to answer with, when the import cannot go on because the
+// file cannot be read or the catalog cannot be written.
+func (imp *catalogImport) run(ctx context.Context, reader catalog.Reader) (int, error) {
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		var rowErr *catalog.RowError
+		if errors.As(err, &rowErr) {
+			imp.report.Rows++
+			imp.report.Failed++
+			imp.report.AddError(rowErr.Line, "", rowErr.Err)
+			continue
+		}
+		if err != nil {
+			return fiber.StatusBadRequest, err
+		}
+
+		imp.add(row)
+		if len(imp.rows) == importChunkSize {
+			if err := imp.flush(ctx); err != nil {
+				return flushStatus(err), err
+			}
+		}
+	}
+
+	if err := imp.flush(ctx); err != nil {
+		return flushStatus(err), err
+	}
+	return fiber.StatusOK, nil
+}
+
+// flushStatus is the HTTP status to answer an import that failed to flush
+// with.
+func flushStatus(err error) int {
+	if errors.Is(err, errAmbiguousSKU) {
+		return fiber.StatusConflict
+	}
+	return fiber.StatusInternalServerError
+}
+
+func (imp *catalogImport) add(row *catalog.Row) {
+	imp.report.Rows++
+	if row.SKU == "" {
+		imp.fail(row, errors.New("sku is required"))
+		return
+	}
+	if line, ok := imp.seen[row.SKU]; ok {
+		imp.fail(row, fmt.Errorf("sku already imported on line %d", line))
+		return
+	}
+	imp.seen[row.SKU] = row.Line
+	imp.rows = append(imp.rows, row)
+}
+
+func (imp *catalogImport) fail(row *catalog.Row, err error) {
+	imp.report.Failed++
+	imp.report.AddError(row.Line, row.SKU, err)
+}
+
+// flush matches the pending rows to the products with their SKUs and
+// writes the ones that create or change a product.
+func (imp *catalogImport) flush(ctx context.Context) error {
+	if len(imp.rows) == 0 {
+		return nil
+	}
+	rows := imp.rows
+	imp.rows = nil
+
+	skus := make([]string, 0, len(rows))
+	for _, row := range rows {
+		skus = append(skus, row.SKU)
+	}
+	found, err := imp.repo.FindBySKUs(ctx, skus)
+	if err != nil {
+		imp.report.Failed += len(rows)
+		return err
+	}
+	existing := make(map[string]*domain.Product, len(found))
+	for i := range found {
+		if other, ok := existing[found[i].SKU]; ok {
+			imp.report.Failed += len(rows)
+			return fmt.Errorf("%w: sku %s is used by products %s and %s", errAmbiguousSKU, found[i].SKU, other.ID, found[i].ID)
+		}
+		existing[found[i].SKU] = &found[i]
+	}
+
+	var imported []importedProduct
+	for _, row := range rows {
+		p, err := imp.prepare(ctx, row, existing[row.SKU])
+		if err != nil {
+			imp.fail(row, err)
+			continue
+		}
+		if p.before != nil && len(domain.ChangedFields(p.before, p.product)) == 0 {
+			imp.report.Unchanged++
+			continue
+		}
+		imported = append(imported, p)
+	}
+
+	if !imp.report.DryRun && len(imported) > 0 {
+		products := make([]domain.Product, 0, len(imported))
+		for _, p := range imported {
+			products = append(products, *p.product)
+		}
+		changed, err := imp.repo.PutBatch(ctx, products)
+		if err != nil {
+			imp.report.Failed += len(imported)
+			return err
+		}
+		imported = slices.DeleteFunc(imported, func(p importedProduct) bool {
+			if !slices.Contains(changed, p.product.ID) {
+				return false
+			}
+			imp.report.Failed++
+			imp.report.AddError(p.line, p.product.SKU, errors.New("product was changed meanwhile, import the row again"))
+			return true
+		})
+	}
+
+	for _, p := range imported {
+		if p.before == nil {
+			imp.report.Created++
+		} else {
+			imp.report.Updated++
+		}
+		if !imp.report.DryRun {
+			imp.announce(ctx, p)
+		}
+	}
+	return nil
+}
+
+// prepare applies a row to a new product, or to a copy of the stored one,
+// and validates the result the same way the product endpoints do.
+func (imp *catalogImport) prepare(ctx context.Context, row *catalog.Row, stored *domain.Product) (importedProduct, error) {
+	p := importedProduct{line: row.Line}
+
+	if stored == nil {
+		p.product = &domain.Product{}
+		if err := row.Apply(p.product); err != nil {
+			return p, err
+		}
+		p.product.ID = uuid.New().String()
+		clearReservations(p.product)
+		keepManaged(p.product, nil)
+	} else {
+		p.before = stored.Clone()
+		p.product = stored.Clone()
+		levels := stockLevels(p.product)
+		if err := row.Apply(p.product); err != nil {
+			return p, err
+		}
+		p.product.ID = stored.ID
+		levels.restore(p.product)
+		keepManaged(p.product, stored)
+	}
+	p.product.SKU = row.SKU
+
+	p.product.Normalize()
+	if _, err := validateProduct(ctx, imp.categories, p.product); err != nil {
+		return p, err
+	}
+	return p, nil
+}
+
+// announce records the opening stock of created products and the price
+// history, and publishes the product events. The product is already
+// written, so failures are only reported.
+func (imp *catalogImport) announce(ctx context.Context, p importedProduct) {
+	if p.before != nil {
+		if err := recordPriceChange(ctx, imp.prices, p.before, p.product, imp.actor); err != nil {
+			imp.report.AddError(p.line, p.product.SKU, fmt.Errorf("product updated but failed to record price history: %w", err))
+			return
+		}
+		if err := publisher.PublishUpdate(ctx, imp.pub, p.before, p.product); err != nil {
+			imp.report.AddError(p.line, p.product.SKU, fmt.Errorf("product updated but failed to publish event: %w", err))
+		}
+		return
+	}
+
+	for _, movement := range openingMovements(p.product, imp.actor) {
+		if err := imp.movements.Record(ctx, &movement); err != nil {
+			imp.report.AddError(p.line, p.product.SKU, fmt.Errorf("product created but failed to record opening stock: %w", err))
+			return
+		}
+	}
+	if err := recordPriceChange(ctx, imp.prices, nil, p.product, imp.actor); err != nil {
+		imp.report.AddError(p.line, p.product.SKU, fmt.Errorf("product created but failed to record price history: %w", err))
+		return
+	}
+	if err := imp.pub.PublishProductCreated(ctx, *p.product); err != nil {
+		imp.report.AddError(p.line, p.product.SKU, fmt.Errorf("product created but failed to publish event: %w", err))
+	}
+}
+
+// importCategories loads the categories once per import instead of once
+// per validated row.
+type importCategories struct {
+	repository.CategoryRepository
+	all []domain.Category
+}
+
+func (c *importCategories) GetAll(ctx context.Context) ([]domain.Category, error) {
+	if c.all != nil {
+		return c.all, nil
+	}
+	all, err := c.CategoryRepository.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if all == nil {
+		all = []domain.Category{}
+	}
+	c.all = all
+	return all, nil
+}
+
+// ExportCatalogHandler handles GET /api/products:export
+//
+// The whole catalog is streamed as NDJSON or, with ?format=csv, as CSV. The
+// table is read page by page, so the export never holds the full catalog.
+func ExportCatalogHandler(repo repository.ProductRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, span := tracing.NewSpan(c.UserContext(), "ExportCatalogHandler")
+		defer span.End()
+
+		format, err := catalog.ParseFormat(c.Query("format", string(catalog.FormatNDJSON)))
+		if err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		span.SetAttributes(
+			tracing.StringAttribute("format", string(format)),
+		)
+
+		c.Set(fiber.HeaderContentType, format.ContentType())
+		c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="catalog.%s"`, format))
+
+		// The body is written after the handler returned, so the stream is
+		// traced in a span of its own. Once the first page is sent the
+		// status cannot change anymore; a failure cuts the export short.
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			ctx, span := tracing.NewSpan(ctx, "ExportCatalogHandler#stream")
+			defer span.End()
+
+			writer := catalog.NewWriter(format, w)
+			exported := 0
+			err := repo.Walk(ctx, func(page []domain.Product) error {
+				for _, product := range page {
+					if err := writer.Write(product); err != nil {
+						return err
+					}
+					exported++
+				}
+				if err := writer.Flush(); err != nil {
+					return err
+				}
+				return w.Flush()
+			})
+			if err == nil {
+				err = writer.Flush()
+			}
+			span.SetAttributes(
+				tracing.IntAttribute("products", exported),
+			)
+			if err != nil {
+				span.RecordError(err)
+				slog.ErrorContext(ctx, "catalog export stopped", "products", exported, "error", err)
+			}
+		})
+		return nil
+	}
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"products-service/internal/catalog"
+	"products-service/internal/domain"
+	"products-service/internal/publisher"
+	"products-service/internal/repository"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// importProducts matches the SKUs against stored products and records the
+// batches written. changed are the ids PutBatch reports as written
+// meanwhile.
+type importProducts struct {
+	repository.ProductRepository
+	stored  []domain.Product
+	changed []string
+	written []domain.Product
+}
+
+func (r *importProducts) FindBySKUs(ctx context.Context, skus []string) ([]domain.Product, error) {
+	var found []domain.Product
+	for _, p := range r.stored {
+		if slices.Contains(skus, p.SKU) {
+			found = append(found, *p.Clone())
+		}
+	}
+	return found, nil
+}
+
+func (r *importProducts) PutBatch(ctx context.Context, products []domain.Product) ([]string, error) {
+	for _, p := range products {
+		if !slices.Contains(r.changed, p.ID) {
+			r.written = append(r.written, p)
+		}
+	}
+	return r.changed, nil
+}
+
+// importCounts counts what an import records and publishes besides the
+// products.
+type importCounts struct {
+	movements, priceChanges, created, updated int
+}
+
+type importMovements struct {
+	repository.StockMovementRepository
+	*importCounts
+}
+
+func (r importMovements) Record(ctx context.Context, movement *domain.StockMovement) error {
+	r.movements++
+	return nil
+}
+
+type importPrices struct {
+	repository.PriceChangeRepository
+	*importCounts
+}
+
+func (r importPrices) Create(ctx context.Context, change *domain.PriceChange) error {
+	r.priceChanges++
+	return nil
+}
+
+type importPublisher struct {
+	publisher.ProductPublisher
+	*importCounts
+}
+
+func (p importPublisher) PublishProductCreated(ctx context.Context, product domain.Product) error {
+	p.created++
+	return nil
+}
+
+func (p importPublisher) PublishProductUpdated(ctx context.Context, product domain.Product, changedFields []string) error {
+	p.updated++
+	return nil
+}
+
+// importFile has one row for each outcome: created, updated, unchanged and
+// the per-row errors, which do not stop the import.
+const importFile = "sku,name,price,stock,reorderThreshold\n" +
+	"NEW-1,Lamp,10,5,\n" + // 2: created
+	"OLD-1,Chair renamed,20,,\n" + // 3: updated
+	"OLD-2,Table,30,,\n" + // 4: unchanged
+	"BAD-1,Shelf,abc,,\n" + // 5: price is not a number
+	"NEW-2,Vase,5,,-1\n" + // 6: fails validation
+	"NEW-1,Lamp again,1,,\n" + // 7: SKU repeated
+	",Nameless,1,,\n" + // 8: no SKU
+	"BAD-2,Desk\n" // 9: too few cells
+
+func storedProducts() []domain.Product {
+	products := []domain.Product{
+		{ID: "p-1", SKU: "OLD-1", Name: "Chair", Price: 20, Status: domain.ProductActive, Version: 3},
+		{ID: "p-2", SKU: "OLD-2", Name: "Table", Price: 30, Status: domain.ProductActive, Version: 1},
+	}
+	for i := range products {
+		products[i].Normalize()
+	}
+	return products
+}
+
+func TestImportCatalogHandler(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		changed []string
+		// want holds the counts of the report; its errors are compared by
+		// line in wantErrorLines.
+		want           catalog.Report
+		wantErrorLines []int
+		wantWritten    []string
+		wantRecorded   importCounts
+	}{
+		{
+			name:           "dry run writes nothing",
+			query:          "?format=csv&dryRun=true",
+			want:           catalog.Report{DryRun: true, Rows: 8, Created: 1, Updated: 1, Unchanged: 1, Failed: 5},
+			wantErrorLines: []int{5, 6, 7, 8, 9},
+		},
+		{
+			name:           "import",
+			query:          "?format=csv",
+			want:           catalog.Report{Rows: 8, Created: 1, Updated: 1, Unchanged: 1, Failed: 5},
+			wantErrorLines: []int{5, 6, 7, 8, 9},
+			wantWritten:    []string{"NEW-1", "OLD-1"},
+			wantRecorded:   importCounts{movements: 1, priceChanges: 1, created: 1, updated: 1},
+		},
+		{
+			name:           "import of a product changed meanwhile",
+			query:          "?format=csv",
+			changed:        []string{"p-1"},
+			want:           catalog.Report{Rows: 8, Created: 1, Unchanged: 1, Failed: 6},
+			wantErrorLines: []int{3, 5, 6, 7, 8, 9},
+			wantWritten:    []string{"NEW-1"},
+			wantRecorded:   importCounts{movements: 1, priceChanges: 1, created: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			products := &importProducts{stored: storedProducts(), changed: tt.changed}
+			counts := &importCounts{}
+			app := fiber.New()
+			app.Post("/api/products:import", ImportCatalogHandler(products, nil,
+				importMovements{importCounts: counts}, importPrices{importCounts: counts}, importPublisher{importCounts: counts}))
+
+			req := httptest.NewRequest(fiber.MethodPost, "/api/products:import"+tt.query, strings.NewReader(importFile))
+			res, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != fiber.StatusOK {
+				t.Fatalf("status = %d, want 200", res.StatusCode)
+			}
+			var report catalog.Report
+			if err := json.NewDecoder(res.Body).Decode(&report); err != nil {
+				t.Fatal(err)
+			}
+
+			var lines []int
+			for _, e := range report.Errors {
+				lines = append(lines, e.Line)
+			}
+			slices.Sort(lines)
+			if !slices.Equal(lines, tt.wantErrorLines) {
+				t.Errorf("errors on lines %v, want %v: %+v", lines, tt.wantErrorLines, report.Errors)
+			}
+			report.Errors = nil
+			if !reflect.DeepEqual(report, tt.want) {
+				t.Errorf("report = %+v, want %+v", report, tt.want)
+			}
+
+			var written []string
+			for _, p := range products.written {
+				written = append(written, p.SKU)
+			}
+			slices.Sort(written)
+			if !slices.Equal(written, tt.wantWritten) {
+				t.Errorf("wrote %v, want %v", written, tt.wantWritten)
+			}
+			if *counts != tt.wantRecorded {
+				t.Errorf("recorded %+v, want %+v", *counts, tt.wantRecorded)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"products-service/internal/domain"
+	"products-service/internal/repository"
+	"telemetry/tracing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// CreateCategoryHandler handles POST /api/categories
+func CreateCategoryHandler(repo repository.CategoryRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, span := tracing.NewSpan(c.UserContext(), "CreateCategoryHandler")
+		defer span.End()
+
+		var category domain.Category
+		if err := c.BodyParser(&category); err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+
+		category.ID = uuid.New().String()
+		if err := category.Validate(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		all, err := repo.GetAll(ctx)
+		if err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if err := domain.NewCategoryTree(all).ValidateParent(category.ID, category.ParentID); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		if err := repo.Create(ctx, &category); err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(category)
+	}
+}
+
+// ListCategoriesHandler handles GET /api/categories and GET /api/categories/:id
+func ListCategoriesHandler(repo repository.CategoryRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, span := tracing.NewSpan(c.UserContext(), "ListCategoriesHandler")
+		defer span.End()
+		id := c.Params("id")
+		span.SetAttributes(
+			tracing.StringAttribute("categoryId", id),
+		)
+
+		if id != "" {
+			category, err := repo.GetByID(ctx, id)
+			if err != nil {
+				span.RecordError(err)
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": "Category not found",
+				})
+			}
+			return c.JSON(category)
+		}
+
+		categories, err := repo.GetAll(ctx)
+		if err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		return c.JSON(categories)
+	}
+}
+
+// UpdateCategoryHandler handles PUT /api/categories/:id
+func UpdateCategoryHandler(repo repository.CategoryRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, span := tracing.NewSpan(c.UserContext(), "UpdateCategoryHandler")
+		defer span.End()
+
+		id := c.Params("id")
+		span.SetAttributes(
+			tracing.StringAttribute("categoryId", id),
+		)
+
+		category, err := repo.GetByID(ctx, id)
+		if err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Category not found",
+			})
+		}
+
+		if err := c.BodyParser(category); err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+
+		category.ID = id
+		if err := category.Validate(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		all, err := repo.GetAll(ctx)
+		if err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if err := domain.NewCategoryTree(all).ValidateParent(category.ID, category.ParentID); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		if err := repo.Update(ctx, category); err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		return c.JSON(category)
+	}
+}
+
+// DeleteCategoryHandler handles DELETE /api/categories/:id
+//
+// Categories that still have subcategories or products are not deleted.
+func DeleteCategoryHandler(repo repository.CategoryRepository, products repository.ProductRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, span := tracing.NewSpan(c.UserContext(), "DeleteCategoryHandler")
+		defer span.End()
+
+		id := c.Params("id")
+		span.SetAttributes(
+			tracing.StringAttribute("categoryId", id),
+		)
+
+		all, err := repo.GetAll(ctx)
+		if err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		tree := domain.NewCategoryTree(all)
+		if _, ok := tree.Get(id); !ok {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Category not found",
+			})
+		}
+		if tree.HasChildren(id) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Category has subcategories",
+			})
+		}
+
+		assigned, err := products.Find(ctx, domain.ProductFilter{CategoryIDs: []string{id}})
+		if err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if len(assigned) > 0 {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Category still has products",
+			})
+		}
+
+		if err := repo.Delete(ctx, id); err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
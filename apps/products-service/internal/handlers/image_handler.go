@@ -0,0 +1,299 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"products-service/internal/domain"
+	"products-service/internal/images"
+	"products-service/internal/publisher"
+	"products-service/internal/repository"
+	"telemetry/tracing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// maxImageSize is the largest image upload accepted, in bytes.
+const maxImageSize = 10 << 20
+
+// UploadProductImageHandler handles POST /api/products/:id/images
+//
+// The image is sent as the "image" field of a multipart form, with an
+// optional "alt" text and "position" in the gallery (appended by default).
+// The original is stored as uploaded and a thumbnail is rendered from it.
+func UploadProductImageHandler(repo repository.ProductRepository, store images.Store, pub publisher.ProductPublisher) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, span := tracing.NewSpan(c.UserContext(), "UploadProductImageHandler")
+		defer span.End()
+		id := c.Params("id")
+
+		span.SetAttributes(
+			tracing.StringAttribute("productId", id),
+		)
+
+		product, err := repo.GetByID(ctx, id)
+		if err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Product not found",
+			})
+		}
+
+		header, err := c.FormFile("image")
+		if err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "The image must be sent as the image field of a multipart form",
+			})
+		}
+		if header.Size > maxImageSize {
+			return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+				"error": "Image cannot be larger than " + strconv.Itoa(maxImageSize>>20) + " MB",
+			})
+		}
+
+		position := len(product.Images)
+		if value := c.FormValue("position"); value != "" {
+			position, err = strconv.Atoi(value)
+			if err != nil || position < 0 || position > len(product.Images) {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "position must be between 0 and " + strconv.Itoa(len(product.Images)),
+				})
+			}
+		}
+
+		file, err := header.Open()
+		if err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		data, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		processed, err := images.Process(data)
+		if err != nil {
+			span.RecordError(err)
+			if errors.Is(err, images.ErrImageTooLarge) {
+				return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			}
+			if errors.Is(err, images.ErrUnsupportedImage) {
+				return c.Status(fiber.StatusUnsupportedMediaType).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		image := domain.ProductImage{
+			ID:          uuid.New().String(),
+			ContentType: processed.ContentType,
+			Width:       processed.Width,
+			Height:      processed.Height,
+			Alt:         c.FormValue("alt"),
+			CreatedAt:   time.Now().UTC().Format(time.RFC3339),
+		}
+		span.SetAttributes(
+			tracing.StringAttribute("imageId", image.ID),
+			tracing.StringAttribute("contentType", image.ContentType),
+			tracing.IntAttribute("size", len(data)),
+		)
+
+		err = store.Put(ctx, images.OriginalKey(id, image.ID), processed.ContentType, data)
+		if err == nil {
+			err = store.Put(ctx, images.ThumbnailKey(id, image.ID), processed.ThumbnailContentType, processed.Thumbnail)
+		}
+		if err != nil {
+			span.RecordError(err)
+			deleteImageFiles(ctx, store, id, image.ID)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to store image: " + err.Error(),
+			})
+		}
+
+		before := product.Clone()
+		product.Images = append(product.Images, domain.ProductImage{})
+		copy(product.Images[position+1:], product.Images[position:])
+		product.Images[position] = image
+
+		if err := repo.Update(ctx, product); err != nil {
+			span.RecordError(err)
+			deleteImageFiles(ctx, store, id, image.ID)
+			if errors.Is(err, repository.ErrProductChanged) {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+					"error": "Product was changed meanwhile, try again",
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		if err := publisher.PublishUpdate(ctx, pub, before, product); err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Image added but failed to publish event: " + err.Error(),
+			})
+		}
+
+		images.ResolveURLs(ctx, store, product)
+		return c.Status(fiber.StatusCreated).JSON(product.Images[position])
+	}
+}
+
+// ReorderProductImagesHandler handles PUT /api/products/:id/images/order
+//
+// The body lists every image id of the product in the new order:
+// {"imageIds": ["...", "..."]}.
+func ReorderProductImagesHandler(repo repository.ProductRepository, pub publisher.ProductPublisher) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, span := tracing.NewSpan(c.UserContext(), "ReorderProductImagesHandler")
+		defer span.End()
+		id := c.Params("id")
+
+		span.SetAttributes(
+			tracing.StringAttribute("productId", id),
+		)
+
+		var body struct {
+			ImageIDs []string `json:"imageIds"`
+		}
+		if err := c.BodyParser(&body); err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+
+		product, err := repo.GetByID(ctx, id)
+		if err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Product not found",
+			})
+		}
+
+		if len(body.ImageIDs) != len(product.Images) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "imageIds must list every image of the product exactly once",
+			})
+		}
+		ordered := make([]domain.ProductImage, 0, len(body.ImageIDs))
+		seen := make(map[string]bool, len(body.ImageIDs))
+		for _, imageID := range body.ImageIDs {
+			i := product.ImageIndex(imageID)
+			if i < 0 || seen[imageID] {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "imageIds must list every image of the product exactly once",
+				})
+			}
+			seen[imageID] = true
+			ordered = append(ordered, product.Images[i])
+		}
+
+		before := product.Clone()
+		product.Images = ordered
+
+		if err := repo.Update(ctx, product); err != nil {
+			span.RecordError(err)
+			if errors.Is(err, repository.ErrProductChanged) {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+					"error": "Product was changed meanwhile, try again",
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		if err := publisher.PublishUpdate(ctx, pub, before, product); err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Images reordered but failed to publish event: " + err.Error(),
+			})
+		}
+
+		return c.JSON(product.Images)
+	}
+}
+
+// DeleteProductImageHandler handles DELETE /api/products/:id/images/:imageId
+func DeleteProductImageHandler(repo repository.ProductRepository, store images.Store, pub publisher.ProductPublisher) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, span := tracing.NewSpan(c.UserContext(), "DeleteProductImageHandler")
+		defer span.End()
+		id := c.Params("id")
+		imageID := c.Params("imageId")
+
+		span.SetAttributes(
+			tracing.StringAttribute("productId", id),
+			tracing.StringAttribute("imageId", imageID),
+		)
+
+		product, err := repo.GetByID(ctx, id)
+		if err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Product not found",
+			})
+		}
+
+		i := product.ImageIndex(imageID)
+		if i < 0 {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Image not found",
+			})
+		}
+
+		before := product.Clone()
+		product.Images = append(product.Images[:i], product.Images[i+1:]...)
+
+		if err := repo.Update(ctx, product); err != nil {
+			span.RecordError(err)
+			if errors.Is(err, repository.ErrProductChanged) {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+					"error": "Product was changed meanwhile, try again",
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		// The product no longer references the files, so failing to remove
+		// them only leaves garbage behind.
+		deleteImageFiles(ctx, store, id, imageID)
+
+		if err := publisher.PublishUpdate(ctx, pub, before, product); err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Image deleted but failed to publish event: " + err.Error(),
+			})
+		}
+
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+func deleteImageFiles(ctx context.Context, store images.Store, productID, imageID string) {
+	if err := store.DeletePrefix(ctx, images.ImagePrefix(productID, imageID)); err != nil {
+		tracing.SpanFromContext(ctx).RecordError(err)
+		slog.ErrorContext(ctx, "failed to delete the files of image", "imageId", imageID, "productId", productID, "error", err)
+	}
+}
@@ -0,0 +1,389 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"products-service/internal/domain"
+	"products-service/internal/repository"
+	"telemetry/tracing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultPriceChangesLimit = 50
+	maxPriceChangesLimit     = 200
+)
+
+// SchedulePriceChangeHandler handles POST /api/products/:id/price-changes
+//
+// The body is {"price": 9.99, "effectiveAt": "<RFC 3339>", "note": "..."}.
+// The price scheduler applies the change once effectiveAt is reached; to
+// change the price right away use PATCH /api/products/:id.
+func SchedulePriceChangeHandler(products repository.ProductRepository, changes repository.PriceChangeRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, span := tracing.NewSpan(c.UserContext(), "SchedulePriceChangeHandler")
+		defer span.End()
+
+		id := c.Params("id")
+		span.SetAttributes(
+			tracing.StringAttribute("productId", id),
+		)
+
+		var input struct {
+			Price       *float64 `json:"price"`
+			EffectiveAt string   `json:"effectiveAt"`
+			Note        string   `json:"note"`
+		}
+		if err := c.BodyParser(&input); err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+		if input.Price == nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "price is required",
+			})
+		}
+
+		if _, err := products.GetByID(ctx, id); err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Product not found",
+			})
+		}
+
+		change := newScheduledChange(id, domain.PriceChangeKindChange, *input.Price, input.EffectiveAt, input.Note, actorFrom(c))
+		if err := validateScheduledChange(&change); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		if err := changes.Create(ctx, &change); err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(change)
+	}
+}
+
+// ScheduleSaleHandler handles POST /api/products/:id/sales
+//
+// The body is {"price": 7.99, "startsAt": "<RFC 3339>", "endsAt": "<RFC 3339>",
+// "note": "..."}. Without startsAt the sale starts right away. When it ends
+// the product goes back to the price it had before the sale, unless the price
+// was changed in between.
+func ScheduleSaleHandler(products repository.ProductRepository, changes repository.PriceChangeRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, span := tracing.NewSpan(c.UserContext(), "ScheduleSaleHandler")
+		defer span.End()
+
+		id := c.Params("id")
+		span.SetAttributes(
+			tracing.StringAttribute("productId", id),
+		)
+
+		var input struct {
+			Price    *float64 `json:"price"`
+			StartsAt string   `json:"startsAt"`
+			EndsAt   string   `json:"endsAt"`
+			Note     string   `json:"note"`
+		}
+		if err := c.BodyParser(&input); err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+		if input.Price == nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "price is required",
+			})
+		}
+		if input.StartsAt == "" {
+			input.StartsAt = time.Now().UTC().Format(time.RFC3339)
+		}
+
+		if _, err := products.GetByID(ctx, id); err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Product not found",
+			})
+		}
+
+		actor := actorFrom(c)
+		start := newScheduledChange(id, domain.PriceChangeKindSaleStart, *input.Price, input.StartsAt, input.Note, actor)
+		start.SaleID = start.ID
+		end := newScheduledChange(id, domain.PriceChangeKindSaleEnd, 0, input.EndsAt, input.Note, actor)
+		end.SaleID = start.ID
+
+		for _, change := range []*domain.PriceChange{&start, &end} {
+			if err := validateScheduledChange(change); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			}
+		}
+		if end.EffectiveAt <= start.EffectiveAt {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "endsAt must be after startsAt",
+			})
+		}
+
+		for _, change := range []*domain.PriceChange{&start, &end} {
+			if err := changes.Create(ctx, change); err != nil {
+				span.RecordError(err)
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			}
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+			"saleId": start.ID,
+			"start":  start,
+			"end":    end,
+		})
+	}
+}
+
+// ListPriceChangesHandler handles GET /api/products/:id/price-changes
+//
+// Changes are returned latest effective time first, scheduled ones
+// included; ?status=applied gives the price history. Pass the returned
+// nextCursor as ?cursor= to get the following page.
+func ListPriceChangesHandler(changes repository.PriceChangeRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, span := tracing.NewSpan(c.UserContext(), "ListPriceChangesHandler")
+		defer span.End()
+
+		id := c.Params("id")
+		span.SetAttributes(
+			tracing.StringAttribute("productId", id),
+		)
+
+		status := domain.PriceChangeStatus(c.Query("status"))
+		switch status {
+		case "", domain.PriceChangeScheduled, domain.PriceChangeApplied, domain.PriceChangeCanceled:
+		default:
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "status must be scheduled, applied or canceled",
+			})
+		}
+
+		limit := c.QueryInt("limit", defaultPriceChangesLimit)
+		if limit <= 0 || limit > maxPriceChangesLimit {
+			limit = maxPriceChangesLimit
+		}
+
+		items, next, err := changes.ListByProduct(ctx, id, status, limit, c.Query("cursor"))
+		if err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if items == nil {
+			items = []domain.PriceChange{}
+		}
+
+		return c.JSON(fiber.Map{
+			"items":      items,
+			"nextCursor": next,
+		})
+	}
+}
+
+// GetPriceAtHandler handles GET /api/products/:id/price?at=<RFC 3339>
+//
+// It answers what the product cost at the given time, now by default,
+// together with the change that set that price.
+func GetPriceAtHandler(changes repository.PriceChangeRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, span := tracing.NewSpan(c.UserContext(), "GetPriceAtHandler")
+		defer span.End()
+
+		id := c.Params("id")
+		span.SetAttributes(
+			tracing.StringAttribute("productId", id),
+		)
+
+		at := time.Now()
+		if value := c.Query("at"); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "at must be an RFC 3339 time",
+				})
+			}
+			at = parsed
+		}
+
+		change, err := changes.PriceAt(ctx, id, at)
+		if errors.Is(err, repository.ErrPriceChangeNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "No price recorded for the product at that time",
+			})
+		}
+		if err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		return c.JSON(fiber.Map{
+			"productId": id,
+			"at":        at.UTC().Format(time.RFC3339),
+			"price":     change.Price,
+			"change":    change,
+		})
+	}
+}
+
+// CancelPriceChangeHandler handles DELETE /api/products/:id/price-changes/:changeId
+//
+// Canceling either part of a sale cancels every part of it that is still
+// scheduled: canceling the end of a running sale keeps the sale price.
+func CancelPriceChangeHandler(changes repository.PriceChangeRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, span := tracing.NewSpan(c.UserContext(), "CancelPriceChangeHandler")
+		defer span.End()
+
+		id := c.Params("id")
+		changeID := c.Params("changeId")
+		span.SetAttributes(
+			tracing.StringAttribute("productId", id),
+			tracing.StringAttribute("priceChangeId", changeID),
+		)
+
+		change, err := changes.GetByID(ctx, id, changeID)
+		if errors.Is(err, repository.ErrPriceChangeNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Price change not found",
+			})
+		}
+		if err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		if change.Status != domain.PriceChangeScheduled {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Price change is no longer scheduled",
+			})
+		}
+
+		toCancel := []domain.PriceChange{*change}
+		if change.SaleID != "" {
+			toCancel, err = scheduledSale(ctx, changes, id, change.SaleID)
+			if err != nil {
+				span.RecordError(err)
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			}
+		}
+
+		note := "canceled by " + actorFrom(c)
+		for i := range toCancel {
+			toCancel[i].Status = domain.PriceChangeCanceled
+			toCancel[i].Note = note
+			err := changes.Settle(ctx, &toCancel[i])
+			if errors.Is(err, repository.ErrPriceChangeNotScheduled) {
+				// Applied by the scheduler in the meantime.
+				continue
+			}
+			if err != nil {
+				span.RecordError(err)
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			}
+		}
+
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+// scheduledSale returns the parts of a sale that are still scheduled.
+func scheduledSale(ctx context.Context, changes repository.PriceChangeRepository, productID, saleID string) ([]domain.PriceChange, error) {
+	var sale []domain.PriceChange
+	cursor := ""
+	for {
+		page, next, err := changes.ListByProduct(ctx, productID, domain.PriceChangeScheduled, maxPriceChangesLimit, cursor)
+		if err != nil {
+			return nil, err
+		}
+		for _, change := range page {
+			if change.SaleID == saleID {
+				sale = append(sale, change)
+			}
+		}
+		if next == "" {
+			return sale, nil
+		}
+		cursor = next
+	}
+}
+
+func newScheduledChange(productID string, kind domain.PriceChangeKind, price float64, effectiveAt, note, actor string) domain.PriceChange {
+	return domain.PriceChange{
+		ID:          uuid.New().String(),
+		ProductID:   productID,
+		Kind:        kind,
+		Status:      domain.PriceChangeScheduled,
+		Price:       price,
+		EffectiveAt: effectiveAt,
+		Actor:       actor,
+		Note:        note,
+		CreatedAt:   time.Now().UTC().Format(time.RFC3339),
+	}
+}
+
+// validateScheduledChange validates the change and stores effectiveAt in
+// UTC, so the times sort correctly in the scheduler index.
+func validateScheduledChange(change *domain.PriceChange) error {
+	if err := change.Validate(time.Now().Add(-time.Minute)); err != nil {
+		return err
+	}
+	effectiveAt, _ := time.Parse(time.RFC3339, change.EffectiveAt)
+	change.EffectiveAt = effectiveAt.UTC().Format(time.RFC3339)
+	return nil
+}
+
+// recordPriceChange adds an applied change to the price history when a
+// product is created (before is nil) or its price was edited directly.
+func recordPriceChange(ctx context.Context, changes repository.PriceChangeRepository, before, after *domain.Product, actor string) error {
+	change := domain.PriceChange{
+		ID:        uuid.New().String(),
+		ProductID: after.ID,
+		Kind:      domain.PriceChangeKindChange,
+		Status:    domain.PriceChangeApplied,
+		Price:     after.Price,
+		Actor:     actor,
+	}
+	if before != nil {
+		if before.Price == after.Price {
+			return nil
+		}
+		previous := before.Price
+		change.PreviousPrice = &previous
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	change.EffectiveAt = now
+	change.CreatedAt = now
+	change.AppliedAt = now
+	return changes.Create(ctx, &change)
+}
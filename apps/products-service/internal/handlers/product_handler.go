@@ -1,16 +1,27 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
 	"products-service/internal/domain"
+	"products-service/internal/orders"
+	"products-service/internal/publisher"
 	"products-service/internal/repository"
-	"products-service/internal/tracing"
+	"telemetry/tracing"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 )
 
 // CreateProductHandler handles POST /api/products
-func CreateProductHandler(repo repository.ProductRepository) fiber.Handler {
+//
+// The stock sent on creation is recorded as the opening balance in the
+// stock ledger; afterwards stock only changes through stock adjustments.
+func CreateProductHandler(repo repository.ProductRepository, categories repository.CategoryRepository, movements repository.StockMovementRepository, prices repository.PriceChangeRepository, pub publisher.ProductPublisher) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		ctx, span := tracing.NewSpan(c.UserContext(), "CreateProductHandler")
 		defer span.End()
@@ -24,6 +35,16 @@ func CreateProductHandler(repo repository.ProductRepository) fiber.Handler {
 			})
 		}
 
+		clearReservations(&product)
+		keepManaged(&product, nil)
+		product.Normalize()
+		if status, err := validateProduct(ctx, categories, &product); err != nil {
+			span.RecordError(err)
+			return c.Status(status).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
 		// Generate UUID for new product
 		product.ID = uuid.New().String()
 
@@ -34,13 +55,47 @@ func CreateProductHandler(repo repository.ProductRepository) fiber.Handler {
 			})
 		}
 
+		for _, movement := range openingMovements(&product, actorFrom(c)) {
+			if err := movements.Record(ctx, &movement); err != nil {
+				span.RecordError(err)
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": "Product created but failed to record opening stock: " + err.Error(),
+				})
+			}
+		}
+
+		if err := recordPriceChange(ctx, prices, nil, &product, actorFrom(c)); err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Product created but failed to record price history: " + err.Error(),
+			})
+		}
+
+		if err := pub.PublishProductCreated(ctx, product); err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Product created but failed to publish event: " + err.Error(),
+			})
+		}
+
 		return c.Status(fiber.StatusCreated).JSON(product)
 	}
 }
 
 // ListProductsHandler handles GET /api/products
-func ListProductsHandler(repo repository.ProductRepository) fiber.Handler {
+//
+// Products can be filtered with ?category=<id> (including subcategories),
+// ?tag=<tag> (repeatable or comma separated) and ?attr.<name>=<value>.
+// Only active products are listed unless ?status=archived or ?status=all
+// is given. With ?ids= the request is a batch lookup, see
+// BatchGetProductsHandler.
+func ListProductsHandler(repo repository.ProductRepository, categories repository.CategoryRepository) fiber.Handler {
+	batchGet := BatchGetProductsHandler(repo)
 	return func(c *fiber.Ctx) error {
+		if c.Params("id") == "" && c.Query("ids") != "" {
+			return batchGet(c)
+		}
+
 		ctx, span := tracing.NewSpan(c.UserContext(), "ListProductsHandler")
 		defer span.End()
 		id := c.Params("id")
@@ -59,20 +114,87 @@ func ListProductsHandler(repo repository.ProductRepository) fiber.Handler {
 			return c.JSON(product)
 		}
 
-		products, err := repo.GetAll(ctx)
+		filter, status, err := parseProductFilter(ctx, c, categories)
+		if err != nil {
+			span.RecordError(err)
+			return c.Status(status).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		lifecycle := c.Query("status", string(domain.ProductActive))
+		switch lifecycle {
+		case string(domain.ProductActive), string(domain.ProductArchived), "all":
+		default:
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "status must be active, archived or all",
+			})
+		}
+
+		var products []domain.Product
+		if filter.IsEmpty() {
+			products, err = repo.GetAll(ctx)
+		} else {
+			products, err = repo.Find(ctx, filter)
+		}
+		if err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		if lifecycle != "all" {
+			products = filterByStatus(products, domain.ProductStatus(lifecycle))
+		}
+		if products == nil {
+			products = []domain.Product{}
+		}
+
+		return c.JSON(products)
+	}
+}
+
+// LowStockProductsHandler handles GET /api/products/low-stock
+//
+// Archived products are left out. Products are returned most urgent first:
+// out of stock, then by how far they are below their reorder threshold.
+func LowStockProductsHandler(repo repository.ProductRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, span := tracing.NewSpan(c.UserContext(), "LowStockProductsHandler")
+		defer span.End()
+
+		products, err := repo.FindLowStock(ctx)
 		if err != nil {
 			span.RecordError(err)
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": err.Error(),
 			})
 		}
+		products = filterByStatus(products, domain.ProductActive)
+		if products == nil {
+			products = []domain.Product{}
+		}
+
+		sort.SliceStable(products, func(i, j int) bool {
+			a, b := products[i], products[j]
+			if (a.Stock <= 0) != (b.Stock <= 0) {
+				return a.Stock <= 0
+			}
+			return a.Stock-a.ReorderThreshold < b.Stock-b.ReorderThreshold
+		})
+		span.SetAttributes(
+			tracing.IntAttribute("products", len(products)),
+		)
 
 		return c.JSON(products)
 	}
 }
 
 // UpdateProductHandler handles PUT /api/products/:id
-func UpdateProductHandler(repo repository.ProductRepository) fiber.Handler {
+//
+// Stock levels in the body are ignored; new variants start without stock.
+func UpdateProductHandler(repo repository.ProductRepository, categories repository.CategoryRepository, prices repository.PriceChangeRepository, pub publisher.ProductPublisher) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		ctx, span := tracing.NewSpan(c.UserContext(), "UpdateProductHandler")
 		defer span.End()
@@ -90,6 +212,9 @@ func UpdateProductHandler(repo repository.ProductRepository) fiber.Handler {
 			})
 		}
 
+		before := product.Clone()
+		stored := stockLevels(product)
+
 		if err := c.BodyParser(product); err != nil {
 			span.RecordError(err)
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -98,20 +223,49 @@ func UpdateProductHandler(repo repository.ProductRepository) fiber.Handler {
 		}
 
 		product.ID = id // aseguramos que no se modifique el ID
+		stored.restore(product)
+		keepManaged(product, before)
+
+		product.Normalize()
+		if status, err := validateProduct(ctx, categories, product); err != nil {
+			span.RecordError(err)
+			return c.Status(status).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
 
 		if err := repo.Update(ctx, product); err != nil {
 			span.RecordError(err)
+			if errors.Is(err, repository.ErrProductChanged) {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+					"error": "Product was changed meanwhile, try again",
+				})
+			}
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": err.Error(),
 			})
 		}
 
+		if err := recordPriceChange(ctx, prices, before, product, actorFrom(c)); err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Product updated but failed to record price history: " + err.Error(),
+			})
+		}
+
+		if err := publisher.PublishUpdate(ctx, pub, before, product); err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Product updated but failed to publish event: " + err.Error(),
+			})
+		}
+
 		return c.JSON(product)
 	}
 }
 
 // PatchProductHandler handles PATCH /api/products/:id
-func PatchProductHandler(repo repository.ProductRepository) fiber.Handler {
+func PatchProductHandler(repo repository.ProductRepository, categories repository.CategoryRepository, prices repository.PriceChangeRepository, pub publisher.ProductPublisher) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		ctx, span := tracing.NewSpan(c.UserContext(), "PatchProductHandler")
 		defer span.End()
@@ -129,6 +283,8 @@ func PatchProductHandler(repo repository.ProductRepository) fiber.Handler {
 			})
 		}
 
+		before := product.Clone()
+
 		patchData := make(map[string]interface{})
 		if err := c.BodyParser(&patchData); err != nil {
 			span.RecordError(err)
@@ -146,39 +302,337 @@ func PatchProductHandler(repo repository.ProductRepository) fiber.Handler {
 		if price, ok := patchData["price"].(float64); ok {
 			product.Price = price
 		}
-		if stock, ok := patchData["stock"].(float64); ok {
-			product.Stock = int(stock)
+		if _, ok := patchData["stock"]; ok {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Stock cannot be patched, use POST /api/products/:id/stock-adjustments",
+			})
+		}
+		if threshold, ok := patchData["reorderThreshold"].(float64); ok {
+			product.ReorderThreshold = int(threshold)
+		}
+		if categoryID, ok := patchData["categoryId"].(string); ok {
+			product.CategoryID = categoryID
+		}
+		if tags, ok := patchData["tags"].([]interface{}); ok {
+			product.Tags = nil
+			for _, tag := range tags {
+				if s, ok := tag.(string); ok {
+					product.Tags = append(product.Tags, s)
+				}
+			}
+		}
+		if attributes, ok := patchData["attributes"].(map[string]interface{}); ok {
+			// Attributes are merged; a null value removes the attribute.
+			if product.Attributes == nil {
+				product.Attributes = make(map[string]interface{})
+			}
+			for name, value := range attributes {
+				if value == nil {
+					delete(product.Attributes, name)
+					continue
+				}
+				product.Attributes[name] = value
+			}
+		}
+
+		product.Normalize()
+		if status, err := validateProduct(ctx, categories, product); err != nil {
+			span.RecordError(err)
+			return c.Status(status).JSON(fiber.Map{
+				"error": err.Error(),
+			})
 		}
 
 		if err := repo.Update(ctx, product); err != nil {
 			span.RecordError(err)
+			if errors.Is(err, repository.ErrProductChanged) {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+					"error": "Product was changed meanwhile, try again",
+				})
+			}
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": err.Error(),
 			})
 		}
 
+		if err := recordPriceChange(ctx, prices, before, product, actorFrom(c)); err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Product updated but failed to record price history: " + err.Error(),
+			})
+		}
+
+		if err := publisher.PublishUpdate(ctx, pub, before, product); err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Product updated but failed to publish event: " + err.Error(),
+			})
+		}
+
 		return c.JSON(product)
 	}
 }
 
 // DeleteProductHandler handles DELETE /api/products/:id
-func DeleteProductHandler(repo repository.ProductRepository) fiber.Handler {
+//
+// Products are archived rather than deleted, so the orders and the stock
+// ledger that reference them keep resolving. Archiving an archived product
+// does nothing. With ?hard=true the product is removed for good, which is
+// refused while orders that are still open reference it.
+func DeleteProductHandler(repo repository.ProductRepository, openOrders orders.OrderClient, pub publisher.ProductPublisher) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		ctx, span := tracing.NewSpan(c.UserContext(), "DeleteProductHandler")
 		defer span.End()
 		id := c.Params("id")
+		hard := c.QueryBool("hard")
 
 		span.SetAttributes(
 			tracing.StringAttribute("productId", id),
+			tracing.BoolAttribute("hard", hard),
 		)
 
+		product, err := repo.GetByID(ctx, id)
+		if err != nil {
+			span.RecordError(err)
+			if errors.Is(err, repository.ErrProductNotFound) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": "Product not found",
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		if !hard {
+			if product.IsArchived() {
+				return c.SendStatus(fiber.StatusNoContent)
+			}
+
+			before := product.Clone()
+			product.Status = domain.ProductArchived
+			product.ArchivedAt = time.Now().UTC().Format(time.RFC3339)
+			if err := repo.Update(ctx, product); err != nil {
+				span.RecordError(err)
+				if errors.Is(err, repository.ErrProductChanged) {
+					return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+						"error": "Product was changed meanwhile, try again",
+					})
+				}
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			}
+
+			if err := publisher.PublishUpdate(ctx, pub, before, product); err != nil {
+				span.RecordError(err)
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": "Product archived but failed to publish event: " + err.Error(),
+				})
+			}
+
+			return c.SendStatus(fiber.StatusNoContent)
+		}
+
+		open, err := openOrders.HasOpenOrders(ctx, id)
+		if err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+				"error": "Failed to check the open orders of the product: " + err.Error(),
+			})
+		}
+		if open {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Product is referenced by open orders, archive it instead",
+			})
+		}
+
 		if err := repo.Delete(ctx, id); err != nil {
 			span.RecordError(err)
+			if errors.Is(err, repository.ErrProductNotFound) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": "Product not found",
+				})
+			}
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": err.Error(),
 			})
 		}
 
+		if err := pub.PublishProductDeleted(ctx, id); err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Product deleted but failed to publish event: " + err.Error(),
+			})
+		}
+
 		return c.SendStatus(fiber.StatusNoContent)
 	}
 }
+
+// RestoreProductHandler handles POST /api/products/:id/restore
+//
+// It makes an archived product active again. Restoring an active product
+// returns it unchanged.
+func RestoreProductHandler(repo repository.ProductRepository, pub publisher.ProductPublisher) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, span := tracing.NewSpan(c.UserContext(), "RestoreProductHandler")
+		defer span.End()
+		id := c.Params("id")
+
+		span.SetAttributes(
+			tracing.StringAttribute("productId", id),
+		)
+
+		product, err := repo.GetByID(ctx, id)
+		if err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Product not found",
+			})
+		}
+
+		if !product.IsArchived() {
+			return c.JSON(product)
+		}
+
+		before := product.Clone()
+		product.Status = domain.ProductActive
+		product.ArchivedAt = ""
+		if err := repo.Update(ctx, product); err != nil {
+			span.RecordError(err)
+			if errors.Is(err, repository.ErrProductChanged) {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+					"error": "Product was changed meanwhile, try again",
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		if err := publisher.PublishUpdate(ctx, pub, before, product); err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Product restored but failed to publish event: " + err.Error(),
+			})
+		}
+
+		return c.JSON(product)
+	}
+}
+
+// keepManaged undoes changes a request body or an imported row made to the
+// fields the service manages itself: products are only archived and
+// restored through their own endpoints, their images only change through
+// the image endpoints and their rating only moves with their reviews. New
+// products are active, unrated and without images.
+func keepManaged(product, stored *domain.Product) {
+	product.Status = domain.ProductActive
+	product.ArchivedAt = ""
+	product.Images = nil
+	product.ReviewCount = 0
+	product.RatingTotal = 0
+	if stored != nil {
+		product.Status = stored.Status
+		product.ArchivedAt = stored.ArchivedAt
+		product.Images = stored.Images
+		product.ReviewCount = stored.ReviewCount
+		product.RatingTotal = stored.RatingTotal
+	}
+	product.ComputeRating()
+}
+
+// filterByStatus keeps the products in the given lifecycle status. Products
+// without a status are active.
+func filterByStatus(products []domain.Product, status domain.ProductStatus) []domain.Product {
+	archived := status == domain.ProductArchived
+	matching := products[:0]
+	for _, product := range products {
+		if product.IsArchived() == archived {
+			matching = append(matching, product)
+		}
+	}
+	return matching
+}
+
+// clearReservations drops reserved stock from a product that is about to be
+// created: nothing can be reserved before the product exists.
+func clearReservations(product *domain.Product) {
+	product.Reserved = 0
+	for sku, v := range product.Variants {
+		v.Reserved = 0
+		product.Variants[sku] = v
+	}
+}
+
+// validateProduct checks the product attributes and, when it is assigned to a
+// category, the attribute definitions inherited from that category. It
+// returns the HTTP status to answer with when the product is rejected.
+func validateProduct(ctx context.Context, categories repository.CategoryRepository, product *domain.Product) (int, error) {
+	if err := product.ValidateAttributes(); err != nil {
+		return fiber.StatusBadRequest, err
+	}
+	if err := product.ValidateFacets(); err != nil {
+		return fiber.StatusBadRequest, err
+	}
+	if err := product.ValidateLocations(); err != nil {
+		return fiber.StatusBadRequest, err
+	}
+	if product.ReorderThreshold < 0 {
+		return fiber.StatusBadRequest, errors.New("reorderThreshold cannot be negative")
+	}
+	if err := product.ValidateVariants(); err != nil {
+		return fiber.StatusBadRequest, err
+	}
+	if product.CategoryID == "" {
+		return fiber.StatusOK, nil
+	}
+
+	all, err := categories.GetAll(ctx)
+	if err != nil {
+		return fiber.StatusInternalServerError, err
+	}
+	if err := domain.NewCategoryTree(all).ValidateProduct(product); err != nil {
+		return fiber.StatusBadRequest, err
+	}
+	return fiber.StatusOK, nil
+}
+
+// parseProductFilter builds a ProductFilter from the query string, expanding
+// the requested category to all of its subcategories.
+func parseProductFilter(ctx context.Context, c *fiber.Ctx, categories repository.CategoryRepository) (domain.ProductFilter, int, error) {
+	var filter domain.ProductFilter
+	args := c.Context().QueryArgs()
+
+	var tags []string
+	for _, value := range args.PeekMulti("tag") {
+		tags = append(tags, strings.Split(string(value), ",")...)
+	}
+	filter.Tags = domain.NormalizeTags(tags)
+
+	args.VisitAll(func(key, value []byte) {
+		name, ok := strings.CutPrefix(string(key), "attr.")
+		if !ok || name == "" {
+			return
+		}
+		if filter.Attributes == nil {
+			filter.Attributes = make(map[string]string)
+		}
+		filter.Attributes[name] = string(value)
+	})
+
+	if categoryID := c.Query("category"); categoryID != "" {
+		all, err := categories.GetAll(ctx)
+		if err != nil {
+			return filter, fiber.StatusInternalServerError, err
+		}
+		tree := domain.NewCategoryTree(all)
+		if _, ok := tree.Get(categoryID); !ok {
+			return filter, fiber.StatusNotFound, errors.New("category not found")
+		}
+		filter.CategoryIDs = tree.Descendants(categoryID)
+	}
+
+	return filter, fiber.StatusOK, nil
+}
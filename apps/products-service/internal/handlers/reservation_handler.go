@@ -0,0 +1,312 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"auth"
+	"products-service/internal/domain"
+	"products-service/internal/orders"
+	"products-service/internal/repository"
+	"telemetry/tracing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// maxReservationTTL bounds how long a checkout can hold stock.
+const maxReservationTTL = time.Hour
+
+// settledRetention is how long settled reservations are kept before
+// DynamoDB's TTL deletes them.
+const settledRetention = 7 * 24 * time.Hour
+
+// CreateReservationHandler handles POST /api/reservations
+//
+// The quantity is held until the reservation is confirmed or released, or
+// for ttlSeconds (defaultTTL when omitted) after which it expires. The
+// reservation belongs to the caller, who can hold at most maxHeld at once.
+func CreateReservationHandler(repo repository.ReservationRepository, defaultTTL time.Duration, maxHeld int) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, span := tracing.NewSpan(c.UserContext(), "CreateReservationHandler")
+		defer span.End()
+
+		var input struct {
+			ProductID  string `json:"productId"`
+			SKU        string `json:"sku"`
+			Quantity   int    `json:"quantity"`
+			TTLSeconds int    `json:"ttlSeconds"`
+		}
+		if err := c.BodyParser(&input); err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+
+		ttl := defaultTTL
+		if input.TTLSeconds != 0 {
+			ttl = time.Duration(input.TTLSeconds) * time.Second
+		}
+		if ttl <= 0 || ttl > maxReservationTTL {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "ttlSeconds must be between 1 and 3600",
+			})
+		}
+
+		now := time.Now().UTC()
+		expiresAt := now.Add(ttl)
+		reservation := domain.Reservation{
+			ID:         uuid.New().String(),
+			ProductID:  input.ProductID,
+			CustomerID: customerFrom(c),
+			SKU:        input.SKU,
+			Quantity:   input.Quantity,
+			Status:     domain.ReservationHeld,
+			ExpiresAt:  expiresAt.Format(time.RFC3339),
+			CreatedAt:  now.Format(time.RFC3339Nano),
+			TTL:        expiresAt.Add(settledRetention).Unix(),
+		}
+		if err := reservation.Validate(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		span.SetAttributes(
+			tracing.StringAttribute("reservationId", reservation.ID),
+			tracing.StringAttribute("productId", reservation.ProductID),
+		)
+
+		// Two reservations created at the same time can both get under the
+		// limit; it only keeps a caller from holding the stock of a product
+		// hostage.
+		held, err := repo.CountHeld(ctx, reservation.CustomerID)
+		if err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if held >= maxHeld {
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+				"error": fmt.Sprintf("At most %d reservations can be held at once", maxHeld),
+			})
+		}
+
+		if err := repo.Create(ctx, &reservation); err != nil {
+			span.RecordError(err)
+			switch {
+			case errors.Is(err, repository.ErrInsufficientStock):
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+					"error": "Not enough stock available",
+				})
+			case errors.Is(err, repository.ErrVariantNotFound):
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": "Variant not found",
+				})
+			case errors.Is(err, repository.ErrProductNotFound):
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": "Product not found",
+				})
+			case errors.Is(err, repository.ErrProductArchived):
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+					"error": "Product is archived",
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(reservation)
+	}
+}
+
+// GetReservationHandler handles GET /api/reservations/:id
+func GetReservationHandler(repo repository.ReservationRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, span := tracing.NewSpan(c.UserContext(), "GetReservationHandler")
+		defer span.End()
+
+		id := c.Params("id")
+		span.SetAttributes(
+			tracing.StringAttribute("reservationId", id),
+		)
+
+		reservation, err := repo.GetByID(ctx, id)
+		if err == nil && !ownsReservation(c, reservation) {
+			err = repository.ErrReservationNotFound
+		}
+		if err != nil {
+			span.RecordError(err)
+			if errors.Is(err, repository.ErrReservationNotFound) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": "Reservation not found",
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		return c.JSON(reservation)
+	}
+}
+
+// ConfirmReservationHandler handles POST /api/reservations/:id/confirm
+//
+// Confirming hands the hold over to the order given as orderId, which has to
+// be an open order of the caller with at least the reserved quantity of the
+// product. The quantity stays reserved until the order.created event is
+// processed, which takes the stock and the hold together, or until
+// confirmedTTL passes, after which the sweeper gives it back.
+func ConfirmReservationHandler(repo repository.ReservationRepository, orderClient orders.OrderClient, confirmedTTL time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return settleReservation(c, "ConfirmReservationHandler", repo, domain.ReservationConfirmed, func(ctx context.Context, reservation *domain.Reservation, orderID string) (*domain.Reservation, error) {
+			order, err := orderClient.GetOrder(ctx, orderID)
+			if err != nil && !errors.Is(err, orders.ErrOrderNotFound) {
+				err = fmt.Errorf("%w: %v", errOrdersUnavailable, err)
+			}
+			if err != nil {
+				return nil, err
+			}
+			// orders-service answers with the orders the caller can see,
+			// which for the staff are all of them.
+			principal, _ := auth.FromContext(ctx)
+			if !principal.IsStaff() && order.CustomerID != principal.Subject {
+				return nil, orders.ErrOrderNotFound
+			}
+			if !order.IsOpen() {
+				return nil, errOrderClosed
+			}
+			ordered := order.Quantity(reservation.ProductID, reservation.SKU)
+			return repo.Confirm(ctx, reservation.ID, orderID, ordered, time.Now().Add(confirmedTTL))
+		})
+	}
+}
+
+var (
+	// errOrderClosed is returned when a reservation is confirmed for an
+	// order that was delivered, canceled or returned already.
+	errOrderClosed = errors.New("order is no longer open")
+	// errOrdersUnavailable is returned when orders-service could not be
+	// asked for the order.
+	errOrdersUnavailable = errors.New("failed to check the order")
+)
+
+// ReleaseReservationHandler handles POST /api/reservations/:id/release
+func ReleaseReservationHandler(repo repository.ReservationRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return settleReservation(c, "ReleaseReservationHandler", repo, domain.ReservationReleased, nil)
+	}
+}
+
+// confirmFunc confirms the reservation for the order.
+type confirmFunc func(ctx context.Context, reservation *domain.Reservation, orderID string) (*domain.Reservation, error)
+
+// settleReservation confirms, with confirm, or releases a held reservation.
+// A reservation past its expiry that the sweeper has not reached yet is
+// expired instead.
+func settleReservation(c *fiber.Ctx, spanName string, repo repository.ReservationRepository, status domain.ReservationStatus, confirm confirmFunc) error {
+	ctx, span := tracing.NewSpan(c.UserContext(), spanName)
+	defer span.End()
+
+	id := c.Params("id")
+	span.SetAttributes(
+		tracing.StringAttribute("reservationId", id),
+	)
+
+	var input struct {
+		OrderID string `json:"orderId"`
+	}
+	if status == domain.ReservationConfirmed && len(c.Body()) > 0 {
+		if err := c.BodyParser(&input); err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+	}
+
+	reservation, err := repo.GetByID(ctx, id)
+	if err == nil && !ownsReservation(c, reservation) {
+		err = repository.ErrReservationNotFound
+	}
+	if err != nil {
+		span.RecordError(err)
+		if errors.Is(err, repository.ErrReservationNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Reservation not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if status == domain.ReservationConfirmed && input.OrderID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "orderId is required",
+		})
+	}
+
+	expired := reservation.ExpiresAt <= time.Now().UTC().Format(time.RFC3339)
+	switch {
+	case expired:
+		reservation, err = repo.Settle(ctx, id, domain.ReservationExpired, "")
+	case status == domain.ReservationConfirmed:
+		span.SetAttributes(
+			tracing.StringAttribute("orderId", input.OrderID),
+		)
+		reservation, err = confirm(ctx, reservation, input.OrderID)
+	default:
+		reservation, err = repo.Settle(ctx, id, status, "")
+	}
+	if err != nil {
+		span.RecordError(err)
+		switch {
+		case errors.Is(err, repository.ErrReservationNotHeld):
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Reservation is no longer held",
+			})
+		case errors.Is(err, orders.ErrOrderNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Order not found",
+			})
+		case errors.Is(err, errOrdersUnavailable):
+			return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		case errors.Is(err, errOrderClosed):
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Order is no longer open",
+			})
+		case errors.Is(err, repository.ErrOrderQuantityExceeded):
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Order does not have the reserved quantity: " + err.Error(),
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if expired {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Reservation expired",
+		})
+	}
+	return c.JSON(reservation)
+}
+
+// ownsReservation reports whether the caller made the reservation, or is
+// staff. The reservations of others are answered as not found.
+func ownsReservation(c *fiber.Ctx, reservation *domain.Reservation) bool {
+	principal, _ := auth.FromContext(c.UserContext())
+	if principal.IsStaff() {
+		return true
+	}
+	return principal != nil && reservation.CustomerID != "" && reservation.CustomerID == principal.Subject
+}
@@ -0,0 +1,347 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"auth"
+	"products-service/internal/domain"
+	"products-service/internal/orders"
+	"products-service/internal/repository"
+	"telemetry/tracing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	defaultReviewsLimit = 20
+	maxReviewsLimit     = 100
+)
+
+// CreateReviewHandler handles POST /api/products/:id/reviews
+//
+// The body is {"rating": 1-5, "title": "...", "body": "..."} and the
+// customer is the subject of the token. Only customers with a
+// delivered order for the product can review it, once. The review is
+// pending until a moderator approves it.
+func CreateReviewHandler(products repository.ProductRepository, reviews repository.ReviewRepository, orderClient orders.OrderClient) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, span := tracing.NewSpan(c.UserContext(), "CreateReviewHandler")
+		defer span.End()
+
+		id := c.Params("id")
+		customerID := customerFrom(c)
+		span.SetAttributes(
+			tracing.StringAttribute("productId", id),
+			tracing.StringAttribute("customerId", customerID),
+		)
+
+		if customerID == "" {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Authentication required",
+			})
+		}
+
+		var input struct {
+			Rating int    `json:"rating"`
+			Title  string `json:"title"`
+			Body   string `json:"body"`
+		}
+		if err := c.BodyParser(&input); err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+
+		review := domain.Review{
+			ProductID:  id,
+			CustomerID: customerID,
+			Rating:     input.Rating,
+			Title:      input.Title,
+			Body:       input.Body,
+			Status:     domain.ReviewPending,
+			CreatedAt:  time.Now().UTC().Format(time.RFC3339),
+		}
+		if err := review.Validate(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		if _, err := products.GetByID(ctx, id); err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Product not found",
+			})
+		}
+
+		orderID, err := orderClient.FindDeliveredOrder(ctx, customerID, id)
+		if err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+				"error": "Failed to check the orders of the customer: " + err.Error(),
+			})
+		}
+		if orderID == "" {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Only customers who received the product can review it",
+			})
+		}
+		review.OrderID = orderID
+
+		err = reviews.Create(ctx, &review)
+		if errors.Is(err, repository.ErrReviewExists) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Customer already reviewed the product",
+			})
+		}
+		if err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(review)
+	}
+}
+
+// ListReviewsHandler handles GET /api/products/:id/reviews
+//
+// Approved reviews are returned newest first; moderators can ask for the
+// pending or rejected ones with ?status=, which is answered 403 to anyone
+// else. Pass the returned nextCursor as
+// ?cursor= to get the following page.
+func ListReviewsHandler(reviews repository.ReviewRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, span := tracing.NewSpan(c.UserContext(), "ListReviewsHandler")
+		defer span.End()
+
+		id := c.Params("id")
+		span.SetAttributes(
+			tracing.StringAttribute("productId", id),
+		)
+
+		status, ok := reviewStatus(c.Query("status", string(domain.ReviewApproved)))
+		if !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "status must be pending, approved or rejected",
+			})
+		}
+		if status != domain.ReviewApproved {
+			principal, _ := auth.FromContext(ctx)
+			if !principal.IsStaff() {
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+					"error": "Only moderators can list the reviews that are not approved",
+				})
+			}
+		}
+
+		items, next, err := reviews.ListByProduct(ctx, id, status, reviewsLimit(c), c.Query("cursor"))
+		if err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if items == nil {
+			items = []domain.Review{}
+		}
+
+		return c.JSON(fiber.Map{
+			"items":      items,
+			"nextCursor": next,
+		})
+	}
+}
+
+// ListReviewsByStatusHandler handles GET /api/reviews
+//
+// It is the moderation queue: the pending reviews of every product, oldest
+// first, or those with another ?status=.
+func ListReviewsByStatusHandler(reviews repository.ReviewRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, span := tracing.NewSpan(c.UserContext(), "ListReviewsByStatusHandler")
+		defer span.End()
+
+		status, ok := reviewStatus(c.Query("status", string(domain.ReviewPending)))
+		if !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "status must be pending, approved or rejected",
+			})
+		}
+		span.SetAttributes(
+			tracing.StringAttribute("status", string(status)),
+		)
+
+		items, next, err := reviews.ListByStatus(ctx, status, reviewsLimit(c), c.Query("cursor"))
+		if err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if items == nil {
+			items = []domain.Review{}
+		}
+
+		return c.JSON(fiber.Map{
+			"items":      items,
+			"nextCursor": next,
+		})
+	}
+}
+
+// ModerateReviewHandler handles PUT /api/products/:id/reviews/:customerId/status
+//
+// The body is {"status": "approved" | "rejected", "note": "..."}. The
+// rating of the product follows: approving a review adds it, rejecting an
+// approved one takes it out.
+func ModerateReviewHandler(reviews repository.ReviewRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, span := tracing.NewSpan(c.UserContext(), "ModerateReviewHandler")
+		defer span.End()
+
+		id := c.Params("id")
+		customerID := c.Params("customerId")
+		span.SetAttributes(
+			tracing.StringAttribute("productId", id),
+			tracing.StringAttribute("customerId", customerID),
+		)
+
+		var input struct {
+			Status domain.ReviewStatus `json:"status"`
+			Note   string              `json:"note"`
+		}
+		if err := c.BodyParser(&input); err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+		if input.Status != domain.ReviewApproved && input.Status != domain.ReviewRejected {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "status must be approved or rejected",
+			})
+		}
+
+		review, err := reviews.Get(ctx, id, customerID)
+		if errors.Is(err, repository.ErrReviewNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Review not found",
+			})
+		}
+		if err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		from := review.Status
+		review.Status = input.Status
+		review.ModerationNote = input.Note
+		review.ModeratedBy = actorFrom(c)
+		review.ModeratedAt = time.Now().UTC().Format(time.RFC3339)
+
+		err = reviews.Moderate(ctx, review, from)
+		if errors.Is(err, repository.ErrReviewModerated) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Review was moderated meanwhile, try again",
+			})
+		}
+		if errors.Is(err, repository.ErrProductNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Product not found",
+			})
+		}
+		if err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		return c.JSON(review)
+	}
+}
+
+// DeleteReviewHandler handles DELETE /api/products/:id/reviews/:customerId
+//
+// Customers can only delete their own reviews; the back office can delete
+// any.
+func DeleteReviewHandler(reviews repository.ReviewRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, span := tracing.NewSpan(c.UserContext(), "DeleteReviewHandler")
+		defer span.End()
+
+		id := c.Params("id")
+		customerID := c.Params("customerId")
+		span.SetAttributes(
+			tracing.StringAttribute("productId", id),
+			tracing.StringAttribute("customerId", customerID),
+		)
+
+		principal, ok := auth.FromContext(ctx)
+		if !ok || (!principal.IsStaff() && principal.Subject != customerID) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Forbidden",
+			})
+		}
+
+		review, err := reviews.Get(ctx, id, customerID)
+		if errors.Is(err, repository.ErrReviewNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Review not found",
+			})
+		}
+		if err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		err = reviews.Delete(ctx, review)
+		if errors.Is(err, repository.ErrReviewModerated) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Review was moderated meanwhile, try again",
+			})
+		}
+		if err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+// customerFrom identifies the customer making the request, by the subject
+// of its token.
+func customerFrom(c *fiber.Ctx) string {
+	if principal, ok := auth.FromContext(c.UserContext()); ok {
+		return principal.Subject
+	}
+	return ""
+}
+
+func reviewStatus(value string) (domain.ReviewStatus, bool) {
+	status := domain.ReviewStatus(value)
+	switch status {
+	case domain.ReviewPending, domain.ReviewApproved, domain.ReviewRejected:
+		return status, true
+	}
+	return "", false
+}
+
+func reviewsLimit(c *fiber.Ctx) int {
+	limit := c.QueryInt("limit", defaultReviewsLimit)
+	if limit <= 0 || limit > maxReviewsLimit {
+		limit = maxReviewsLimit
+	}
+	return limit
+}
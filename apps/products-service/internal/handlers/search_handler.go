@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"products-service/internal/domain"
+	"products-service/internal/images"
+	"products-service/internal/repository"
+	"products-service/internal/search"
+	"telemetry/tracing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SearchProductsHandler handles GET /api/products/search?q=
+//
+// Optional parameters: category (includes subcategories), limit and offset.
+// The image URLs of the hits are built fresh, as the indexed ones may have
+// expired.
+func SearchProductsHandler(index search.ProductIndex, categories repository.CategoryRepository, store images.Store) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, span := tracing.NewSpan(c.UserContext(), "SearchProductsHandler")
+		defer span.End()
+
+		q := search.Query{
+			Text:   c.Query("q"),
+			Limit:  c.QueryInt("limit"),
+			Offset: c.QueryInt("offset"),
+		}
+		span.SetAttributes(
+			tracing.StringAttribute("query", q.Text),
+		)
+
+		if q.Text == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Query parameter q is required",
+			})
+		}
+
+		if categoryID := c.Query("category"); categoryID != "" {
+			all, err := categories.GetAll(ctx)
+			if err != nil {
+				span.RecordError(err)
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			}
+			tree := domain.NewCategoryTree(all)
+			if _, ok := tree.Get(categoryID); !ok {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": "Category not found",
+				})
+			}
+			q.CategoryIDs = tree.Descendants(categoryID)
+		}
+
+		result, err := index.Search(ctx, q)
+		if err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		for i := range result.Hits {
+			images.ResolveURLs(ctx, store, &result.Hits[i].Product)
+		}
+
+		return c.JSON(result)
+	}
+}
+
+// ReindexProductsHandler handles POST /api/products/search/reindex
+//
+// It rebuilds the search index from the products table.
+func ReindexProductsHandler(repo repository.ProductRepository, index search.ProductIndex) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, span := tracing.NewSpan(c.UserContext(), "ReindexProductsHandler")
+		defer span.End()
+
+		indexed, err := search.Rebuild(ctx, repo, index)
+		if err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		return c.JSON(fiber.Map{
+			"indexed": indexed,
+		})
+	}
+}
@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"auth"
+	"products-service/internal/domain"
+	"products-service/internal/publisher"
+	"products-service/internal/repository"
+	"telemetry/tracing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultMovementsLimit = 50
+	maxMovementsLimit     = 200
+)
+
+// AdjustStockHandler handles POST /api/products/:id/stock-adjustments
+//
+// A "manual-adjustment" (the default) applies delta to the current stock; a
+// "recount" sets the stock to quantity. Either way the change is recorded in
+// the stock ledger, and a stock alert is published when the product
+// crosses its reorder threshold. Products with variants need a sku, and
+// stock tracked per location needs a warehouseId; the first adjustment at a
+// warehouse moves the stock counted until then to the "default" location.
+func AdjustStockHandler(movements repository.StockMovementRepository, warehouses repository.WarehouseRepository, pub publisher.ProductPublisher) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, span := tracing.NewSpan(c.UserContext(), "AdjustStockHandler")
+		defer span.End()
+
+		id := c.Params("id")
+		span.SetAttributes(
+			tracing.StringAttribute("productId", id),
+		)
+
+		var input struct {
+			SKU         string                `json:"sku"`
+			WarehouseID string                `json:"warehouseId"`
+			Reason      domain.MovementReason `json:"reason"`
+			Delta       int                   `json:"delta"`
+			Quantity    *int                  `json:"quantity"`
+			Note        string                `json:"note"`
+		}
+		if err := c.BodyParser(&input); err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+		if input.Reason == "" {
+			input.Reason = domain.MovementReasonManualAdjustment
+		}
+		if input.WarehouseID != "" && input.WarehouseID != domain.DefaultLocation {
+			if _, err := warehouses.GetByID(ctx, input.WarehouseID); err != nil {
+				span.RecordError(err)
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "Warehouse not found",
+				})
+			}
+		}
+
+		movement := domain.StockMovement{
+			ID:          uuid.New().String(),
+			ProductID:   id,
+			SKU:         input.SKU,
+			WarehouseID: input.WarehouseID,
+			Reason:      input.Reason,
+			Actor:       actorFrom(c),
+			Note:        input.Note,
+			Delta:       input.Delta,
+			CreatedAt:   time.Now().UTC().Format(time.RFC3339Nano),
+		}
+
+		var change *domain.StockChange
+		var err error
+		switch input.Reason {
+		case domain.MovementReasonManualAdjustment:
+			if input.Delta == 0 {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "delta must not be zero",
+				})
+			}
+			change, err = movements.Adjust(ctx, &movement)
+		case domain.MovementReasonRecount:
+			if input.Quantity == nil || *input.Quantity < 0 {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "quantity must be zero or positive",
+				})
+			}
+			change, err = movements.Recount(ctx, &movement, *input.Quantity)
+		default:
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "reason must be manual-adjustment or recount",
+			})
+		}
+
+		if err != nil {
+			span.RecordError(err)
+			switch {
+			case errors.Is(err, repository.ErrInsufficientStock):
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			case errors.Is(err, repository.ErrWarehouseRequired), errors.Is(err, repository.ErrSKURequired):
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			case errors.Is(err, repository.ErrVariantNotFound):
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": "Variant not found",
+				})
+			case errors.Is(err, repository.ErrProductNotFound):
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": "Product not found",
+				})
+			case errors.Is(err, repository.ErrProductArchived):
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+					"error": "Product is archived, restore it before adjusting its stock",
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		if err := pub.PublishProductStockChanged(ctx, movement); err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Stock adjusted but failed to publish event: " + err.Error(),
+			})
+		}
+		if alertType := change.AlertType(); alertType != "" {
+			if err := pub.PublishStockAlert(ctx, alertType, *change); err != nil {
+				span.RecordError(err)
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": "Stock adjusted but failed to publish stock alert: " + err.Error(),
+				})
+			}
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(movement)
+	}
+}
+
+// ListStockMovementsHandler handles GET /api/products/:id/stock-movements
+//
+// Movements are returned newest first; pass the returned nextCursor as
+// ?cursor= to get the following page.
+func ListStockMovementsHandler(movements repository.StockMovementRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, span := tracing.NewSpan(c.UserContext(), "ListStockMovementsHandler")
+		defer span.End()
+
+		id := c.Params("id")
+		span.SetAttributes(
+			tracing.StringAttribute("productId", id),
+		)
+
+		limit := c.QueryInt("limit", defaultMovementsLimit)
+		if limit <= 0 || limit > maxMovementsLimit {
+			limit = maxMovementsLimit
+		}
+
+		items, next, err := movements.ListByProduct(ctx, id, limit, c.Query("cursor"))
+		if err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if items == nil {
+			items = []domain.StockMovement{}
+		}
+
+		return c.JSON(fiber.Map{
+			"items":      items,
+			"nextCursor": next,
+		})
+	}
+}
+
+// actorFrom identifies who is making the request for audit records: the
+// subject of the token it was authenticated with.
+func actorFrom(c *fiber.Ctx) string {
+	if principal, ok := auth.FromContext(c.UserContext()); ok {
+		return principal.Subject
+	}
+	return "anonymous"
+}
+
+// openingMovements records the stock a product is created with, one
+// movement per warehouse when the stock is tracked per location.
+func openingMovements(product *domain.Product, actor string) []domain.StockMovement {
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	var movements []domain.StockMovement
+	opening := func(sku, warehouseID string, stock int) {
+		if stock == 0 {
+			return
+		}
+		movements = append(movements, domain.StockMovement{
+			ID:          uuid.New().String(),
+			ProductID:   product.ID,
+			SKU:         sku,
+			WarehouseID: warehouseID,
+			Reason:      domain.MovementReasonRecount,
+			Actor:       actor,
+			Note:        "opening balance",
+			Delta:       stock,
+			Balance:     stock,
+			CreatedAt:   now,
+		})
+	}
+	openLevel := func(sku string, stock int, locations map[string]int) {
+		if len(locations) == 0 {
+			opening(sku, "", stock)
+			return
+		}
+		for warehouseID, stock := range locations {
+			opening(sku, warehouseID, stock)
+		}
+	}
+
+	if len(product.Variants) == 0 {
+		openLevel("", product.Stock, product.Locations)
+		return movements
+	}
+	for sku, v := range product.Variants {
+		openLevel(sku, v.Stock, v.Locations)
+	}
+	return movements
+}
+
+// storedStock remembers the stock and reservations of a product before a
+// body is parsed into it, so updates cannot change stock outside of the
+// ledger.
+type storedStock struct {
+	product *domain.Product
+}
+
+func stockLevels(product *domain.Product) storedStock {
+	return storedStock{product: product.Clone()}
+}
+
+func (s storedStock) restore(product *domain.Product) {
+	product.Stock = s.product.Stock
+	product.Reserved = s.product.Reserved
+	product.Locations = s.product.Locations
+	for sku, v := range product.Variants {
+		stored := s.product.Variants[sku]
+		v.Stock = stored.Stock
+		v.Reserved = stored.Reserved
+		v.Locations = stored.Locations
+		product.Variants[sku] = v
+	}
+}
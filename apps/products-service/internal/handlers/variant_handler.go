@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"products-service/internal/domain"
+	"products-service/internal/publisher"
+	"products-service/internal/repository"
+	"telemetry/tracing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// PutVariantHandler handles PUT /api/products/:id/variants/:sku
+//
+// It creates the variant or replaces it when the SKU already exists. The
+// stock of a new variant is recorded as its opening balance; the stock of an
+// existing variant is kept and only changes through stock adjustments.
+func PutVariantHandler(repo repository.ProductRepository, categories repository.CategoryRepository, movements repository.StockMovementRepository, pub publisher.ProductPublisher) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, span := tracing.NewSpan(c.UserContext(), "PutVariantHandler")
+		defer span.End()
+
+		id := c.Params("id")
+		sku := c.Params("sku")
+		span.SetAttributes(
+			tracing.StringAttribute("productId", id),
+			tracing.StringAttribute("sku", sku),
+		)
+
+		product, err := repo.GetByID(ctx, id)
+		if err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Product not found",
+			})
+		}
+
+		before := product.Clone()
+
+		var variant domain.Variant
+		if err := c.BodyParser(&variant); err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+
+		status := fiber.StatusCreated
+		variant.Reserved = 0
+		if existing, exists := product.Variants[sku]; exists {
+			status = fiber.StatusOK
+			variant.Stock = existing.Stock
+			variant.Reserved = existing.Reserved
+			variant.Locations = existing.Locations
+		}
+		if product.Variants == nil {
+			product.Variants = make(map[string]domain.Variant)
+		}
+		product.Variants[sku] = variant
+
+		product.Normalize()
+		if code, err := validateProduct(ctx, categories, product); err != nil {
+			span.RecordError(err)
+			return c.Status(code).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		if err := repo.Update(ctx, product); err != nil {
+			span.RecordError(err)
+			if errors.Is(err, repository.ErrProductChanged) {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+					"error": "Product was changed meanwhile, try again",
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		if status == fiber.StatusCreated {
+			created := &domain.Product{ID: id, Variants: map[string]domain.Variant{sku: product.Variants[sku]}}
+			for _, opening := range openingMovements(created, actorFrom(c)) {
+				if err := movements.Record(ctx, &opening); err != nil {
+					span.RecordError(err)
+					return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+						"error": "Variant created but failed to record opening stock: " + err.Error(),
+					})
+				}
+			}
+		}
+
+		if err := publisher.PublishUpdate(ctx, pub, before, product); err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Variant saved but failed to publish event: " + err.Error(),
+			})
+		}
+
+		return c.Status(status).JSON(product.Variants[sku])
+	}
+}
+
+// DeleteVariantHandler handles DELETE /api/products/:id/variants/:sku
+//
+// Any stock left on the variant is written off in the stock ledger.
+func DeleteVariantHandler(repo repository.ProductRepository, movements repository.StockMovementRepository, pub publisher.ProductPublisher) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, span := tracing.NewSpan(c.UserContext(), "DeleteVariantHandler")
+		defer span.End()
+
+		id := c.Params("id")
+		sku := c.Params("sku")
+		span.SetAttributes(
+			tracing.StringAttribute("productId", id),
+			tracing.StringAttribute("sku", sku),
+		)
+
+		product, err := repo.GetByID(ctx, id)
+		if err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Product not found",
+			})
+		}
+
+		before := product.Clone()
+
+		variant, ok := product.Variants[sku]
+		if !ok {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Variant not found",
+			})
+		}
+		if variant.Reserved > 0 {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Variant has open reservations",
+			})
+		}
+		delete(product.Variants, sku)
+		product.Stock -= variant.Stock
+		product.Normalize()
+
+		if err := repo.Update(ctx, product); err != nil {
+			span.RecordError(err)
+			if errors.Is(err, repository.ErrProductChanged) {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+					"error": "Product was changed meanwhile, try again",
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		for _, writeOff := range writeOffMovements(id, variant, actorFrom(c)) {
+			if err := movements.Record(ctx, &writeOff); err != nil {
+				span.RecordError(err)
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": "Variant deleted but failed to record stock write-off: " + err.Error(),
+				})
+			}
+		}
+
+		if err := publisher.PublishUpdate(ctx, pub, before, product); err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Variant deleted but failed to publish event: " + err.Error(),
+			})
+		}
+
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+// writeOffMovements records the stock left on a deleted variant, one movement
+// per warehouse when the stock is tracked per location.
+func writeOffMovements(productID string, variant domain.Variant, actor string) []domain.StockMovement {
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	levels := variant.Locations
+	if len(levels) == 0 {
+		levels = map[string]int{"": variant.Stock}
+	}
+
+	var movements []domain.StockMovement
+	for warehouseID, stock := range levels {
+		if stock == 0 {
+			continue
+		}
+		movements = append(movements, domain.StockMovement{
+			ID:          uuid.New().String(),
+			ProductID:   productID,
+			SKU:         variant.SKU,
+			WarehouseID: warehouseID,
+			Reason:      domain.MovementReasonManualAdjustment,
+			Actor:       actor,
+			Note:        "variant deleted",
+			Delta:       -stock,
+			Balance:     0,
+			CreatedAt:   now,
+		})
+	}
+	return movements
+}
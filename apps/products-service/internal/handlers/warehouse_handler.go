@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"errors"
+	"products-service/internal/domain"
+	"products-service/internal/repository"
+	"telemetry/tracing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// CreateWarehouseHandler handles POST /api/warehouses
+func CreateWarehouseHandler(repo repository.WarehouseRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, span := tracing.NewSpan(c.UserContext(), "CreateWarehouseHandler")
+		defer span.End()
+
+		var warehouse domain.Warehouse
+		if err := c.BodyParser(&warehouse); err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+
+		warehouse.ID = uuid.New().String()
+		if err := warehouse.Validate(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		if err := repo.Create(ctx, &warehouse); err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(warehouse)
+	}
+}
+
+// ListWarehousesHandler handles GET /api/warehouses and GET /api/warehouses/:id
+func ListWarehousesHandler(repo repository.WarehouseRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, span := tracing.NewSpan(c.UserContext(), "ListWarehousesHandler")
+		defer span.End()
+		id := c.Params("id")
+		span.SetAttributes(
+			tracing.StringAttribute("warehouseId", id),
+		)
+
+		if id != "" {
+			warehouse, err := repo.GetByID(ctx, id)
+			if err != nil {
+				span.RecordError(err)
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": "Warehouse not found",
+				})
+			}
+			return c.JSON(warehouse)
+		}
+
+		warehouses, err := repo.GetAll(ctx)
+		if err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		return c.JSON(warehouses)
+	}
+}
+
+// UpdateWarehouseHandler handles PUT /api/warehouses/:id
+func UpdateWarehouseHandler(repo repository.WarehouseRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, span := tracing.NewSpan(c.UserContext(), "UpdateWarehouseHandler")
+		defer span.End()
+
+		id := c.Params("id")
+		span.SetAttributes(
+			tracing.StringAttribute("warehouseId", id),
+		)
+
+		warehouse, err := repo.GetByID(ctx, id)
+		if err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Warehouse not found",
+			})
+		}
+
+		if err := c.BodyParser(warehouse); err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+
+		warehouse.ID = id
+		if err := warehouse.Validate(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		if err := repo.Update(ctx, warehouse); err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		return c.JSON(warehouse)
+	}
+}
+
+// DeleteWarehouseHandler handles DELETE /api/warehouses/:id
+//
+// Warehouses that still hold stock are not deleted; disable them instead so
+// they are no longer allocated from.
+func DeleteWarehouseHandler(repo repository.WarehouseRepository, products repository.ProductRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, span := tracing.NewSpan(c.UserContext(), "DeleteWarehouseHandler")
+		defer span.End()
+
+		id := c.Params("id")
+		span.SetAttributes(
+			tracing.StringAttribute("warehouseId", id),
+		)
+
+		if _, err := repo.GetByID(ctx, id); err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Warehouse not found",
+			})
+		}
+
+		all, err := products.GetAll(ctx)
+		if err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		for _, product := range all {
+			if holdsStock(product, id) {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+					"error": "Warehouse still holds stock",
+				})
+			}
+		}
+
+		if err := repo.Delete(ctx, id); err != nil {
+			span.RecordError(err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+func holdsStock(product domain.Product, warehouseID string) bool {
+	if product.Locations[warehouseID] != 0 {
+		return true
+	}
+	for _, v := range product.Variants {
+		if v.Locations[warehouseID] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// GetAllocationHandler handles GET /api/allocations/:orderId
+func GetAllocationHandler(repo repository.AllocationRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, span := tracing.NewSpan(c.UserContext(), "GetAllocationHandler")
+		defer span.End()
+
+		orderID := c.Params("orderId")
+		span.SetAttributes(
+			tracing.StringAttribute("orderId", orderID),
+		)
+
+		allocation, err := repo.GetByOrderID(ctx, orderID)
+		if err != nil {
+			span.RecordError(err)
+			if errors.Is(err, repository.ErrAllocationNotFound) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": "Allocation not found",
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		return c.JSON(allocation)
+	}
+}
@@ -0,0 +1,114 @@
+package images
+
+import (
+	"context"
+	"log/slog"
+	"products-service/internal/domain"
+	"products-service/internal/repository"
+	"telemetry/tracing"
+)
+
+// URLProductRepository fills in the image URLs of every product it reads
+// and removes the image files of the products it deletes. Failing to remove
+// the files is recorded but does not fail the delete.
+type URLProductRepository struct {
+	repository.ProductRepository
+	store Store
+}
+
+func NewURLProductRepository(repo repository.ProductRepository, store Store) *URLProductRepository {
+	return &URLProductRepository{
+		ProductRepository: repo,
+		store:             store,
+	}
+}
+
+func (r *URLProductRepository) GetAll(ctx context.Context) ([]domain.Product, error) {
+	products, err := r.ProductRepository.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r.resolveAll(ctx, products)
+	return products, nil
+}
+
+func (r *URLProductRepository) GetByID(ctx context.Context, id string) (*domain.Product, error) {
+	product, err := r.ProductRepository.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	ResolveURLs(ctx, r.store, product)
+	return product, nil
+}
+
+func (r *URLProductRepository) GetByIDs(ctx context.Context, ids []string) ([]domain.Product, error) {
+	products, err := r.ProductRepository.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	r.resolveAll(ctx, products)
+	return products, nil
+}
+
+func (r *URLProductRepository) SetPrice(ctx context.Context, id string, price, current float64) (*domain.Product, error) {
+	product, err := r.ProductRepository.SetPrice(ctx, id, price, current)
+	if err != nil {
+		return nil, err
+	}
+	ResolveURLs(ctx, r.store, product)
+	return product, nil
+}
+
+func (r *URLProductRepository) FindBySKUs(ctx context.Context, skus []string) ([]domain.Product, error) {
+	products, err := r.ProductRepository.FindBySKUs(ctx, skus)
+	if err != nil {
+		return nil, err
+	}
+	r.resolveAll(ctx, products)
+	return products, nil
+}
+
+func (r *URLProductRepository) Find(ctx context.Context, filter domain.ProductFilter) ([]domain.Product, error) {
+	products, err := r.ProductRepository.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	r.resolveAll(ctx, products)
+	return products, nil
+}
+
+func (r *URLProductRepository) FindLowStock(ctx context.Context) ([]domain.Product, error) {
+	products, err := r.ProductRepository.FindLowStock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r.resolveAll(ctx, products)
+	return products, nil
+}
+
+func (r *URLProductRepository) Walk(ctx context.Context, fn func(page []domain.Product) error) error {
+	return r.ProductRepository.Walk(ctx, func(page []domain.Product) error {
+		r.resolveAll(ctx, page)
+		return fn(page)
+	})
+}
+
+func (r *URLProductRepository) Delete(ctx context.Context, id string) error {
+	if err := r.ProductRepository.Delete(ctx, id); err != nil {
+		return err
+	}
+
+	ctx, span := tracing.NewSpan(ctx, "URLProductRepository#deleteImages")
+	defer span.End()
+	if err := r.store.DeletePrefix(ctx, ProductPrefix(id)); err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "failed to delete the images of product", "productId", id, "error", err)
+	}
+	return nil
+}
+
+func (r *URLProductRepository) resolveAll(ctx context.Context, products []domain.Product) {
+	for i := range products {
+		ResolveURLs(ctx, r.store, &products[i])
+	}
+}
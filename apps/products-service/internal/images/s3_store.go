@@ -0,0 +1,120 @@
+package images
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"telemetry/tracing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// deleteBatchSize is the most keys S3 accepts in one DeleteObjects call.
+const deleteBatchSize = 1000
+
+// S3Store keeps the images in a bucket. Clients fetch them through
+// cdnURL when one is configured, otherwise through presigned URLs that are
+// valid for urlTTL.
+type S3Store struct {
+	client  *s3.Client
+	presign *s3.PresignClient
+	bucket  string
+	cdnURL  string
+	urlTTL  time.Duration
+}
+
+func NewS3Store(client *s3.Client, bucket, cdnURL string, urlTTL time.Duration) *S3Store {
+	return &S3Store{
+		client:  client,
+		presign: s3.NewPresignClient(client),
+		bucket:  bucket,
+		cdnURL:  strings.TrimRight(cdnURL, "/"),
+		urlTTL:  urlTTL,
+	}
+}
+
+func (s *S3Store) Put(ctx context.Context, key, contentType string, body []byte) error {
+	ctx, span := tracing.NewSpan(ctx, "S3Store#Put")
+	defer span.End()
+	span.SetAttributes(
+		tracing.StringAttribute("key", key),
+		tracing.IntAttribute("size", len(body)),
+	)
+
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(s.bucket),
+		Key:           aws.String(key),
+		Body:          bytes.NewReader(body),
+		ContentLength: aws.Int64(int64(len(body))),
+		ContentType:   aws.String(contentType),
+		// Keys are never reused, so the files can be cached for good.
+		CacheControl: aws.String("public, max-age=31536000, immutable"),
+	})
+	if err != nil {
+		span.RecordError(err)
+	}
+	return err
+}
+
+func (s *S3Store) DeletePrefix(ctx context.Context, prefix string) error {
+	ctx, span := tracing.NewSpan(ctx, "S3Store#DeletePrefix")
+	defer span.End()
+	span.SetAttributes(
+		tracing.StringAttribute("prefix", prefix),
+	)
+
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+		// One listing page is never more than one DeleteObjects call.
+		MaxKeys: aws.Int32(deleteBatchSize),
+	})
+	deleted := 0
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			span.RecordError(err)
+			return err
+		}
+		if len(page.Contents) == 0 {
+			continue
+		}
+
+		objects := make([]types.ObjectIdentifier, 0, len(page.Contents))
+		for _, object := range page.Contents {
+			objects = append(objects, types.ObjectIdentifier{Key: object.Key})
+		}
+		_, err = s.client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(s.bucket),
+			Delete: &types.Delete{Objects: objects, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			span.RecordError(err)
+			return err
+		}
+		deleted += len(objects)
+	}
+
+	span.SetAttributes(
+		tracing.IntAttribute("deleted", deleted),
+	)
+	return nil
+}
+
+func (s *S3Store) URL(ctx context.Context, key string) (string, error) {
+	if s.cdnURL != "" {
+		return s.cdnURL + "/" + key, nil
+	}
+
+	req, err := s.presign.PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	}, s3.WithPresignExpires(s.urlTTL))
+	if err != nil {
+		return "", err
+	}
+	return req.URL, nil
+}
@@ -0,0 +1,62 @@
+package images
+
+import (
+	"context"
+	"log/slog"
+	"products-service/internal/domain"
+	"telemetry/tracing"
+)
+
+// Store keeps the image files. Keys are paths inside the store; files are
+// written once and never changed.
+type Store interface {
+	Put(ctx context.Context, key, contentType string, body []byte) error
+	// DeletePrefix removes every file whose key starts with prefix.
+	DeletePrefix(ctx context.Context, prefix string) error
+	// URL returns where clients can fetch the file.
+	URL(ctx context.Context, key string) (string, error)
+}
+
+// ProductPrefix is the prefix of every file of a product.
+func ProductPrefix(productID string) string {
+	return "products/" + productID + "/"
+}
+
+// ImagePrefix is the prefix of the files of one image of a product.
+func ImagePrefix(productID, imageID string) string {
+	return ProductPrefix(productID) + "images/" + imageID + "/"
+}
+
+func OriginalKey(productID, imageID string) string {
+	return ImagePrefix(productID, imageID) + "original"
+}
+
+func ThumbnailKey(productID, imageID string) string {
+	return ImagePrefix(productID, imageID) + "thumbnail"
+}
+
+// ResolveURLs fills in the URLs of the product images. An image whose URL
+// cannot be built is returned without it rather than failing the read.
+func ResolveURLs(ctx context.Context, store Store, product *domain.Product) {
+	if len(product.Images) == 0 {
+		return
+	}
+
+	for i := range product.Images {
+		image := &product.Images[i]
+		original, err := store.URL(ctx, OriginalKey(product.ID, image.ID))
+		if err != nil {
+			tracing.SpanFromContext(ctx).RecordError(err)
+			slog.ErrorContext(ctx, "failed to build image URL", "imageId", image.ID, "productId", product.ID, "error", err)
+			continue
+		}
+		thumbnail, err := store.URL(ctx, ThumbnailKey(product.ID, image.ID))
+		if err != nil {
+			tracing.SpanFromContext(ctx).RecordError(err)
+			slog.ErrorContext(ctx, "failed to build image URL", "imageId", image.ID, "productId", product.ID, "error", err)
+			continue
+		}
+		image.URL = original
+		image.ThumbnailURL = thumbnail
+	}
+}
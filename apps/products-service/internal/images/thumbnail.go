@@ -0,0 +1,112 @@
+package images
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+
+	// Decoders for the accepted upload formats.
+	_ "image/gif"
+
+	_ "golang.org/x/image/webp"
+
+	"golang.org/x/image/draw"
+)
+
+// ThumbnailSize is the longest side of a thumbnail in pixels.
+const ThumbnailSize = 320
+
+// MaxPixels bounds the width times the height of an upload. Decoding needs
+// about four bytes per pixel, so a small file claiming a huge size is
+// refused before it is decoded.
+const MaxPixels = 40_000_000
+
+// ErrImageTooLarge is returned for uploads with more than MaxPixels pixels.
+var ErrImageTooLarge = errors.New("image must not have more than 40 megapixels")
+
+// ErrUnsupportedImage is returned for uploads that are not a JPEG, PNG, GIF
+// or WebP image.
+var ErrUnsupportedImage = errors.New("image must be a JPEG, PNG, GIF or WebP file")
+
+var supportedTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// Processed is an uploaded image ready to be stored.
+type Processed struct {
+	ContentType          string
+	Width                int
+	Height               int
+	Thumbnail            []byte
+	ThumbnailContentType string
+}
+
+// Process checks that data is an image in a supported format, going by its
+// content rather than by what the client claimed, that it is not too large
+// to decode, and renders its
+// thumbnail. Thumbnails are PNG for PNG originals, which may be
+// transparent, and JPEG otherwise.
+func Process(data []byte) (*Processed, error) {
+	contentType := http.DetectContentType(data)
+	if !supportedTypes[contentType] {
+		return nil, ErrUnsupportedImage
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || config.Width <= 0 || config.Height <= 0 {
+		return nil, ErrUnsupportedImage
+	}
+	if config.Width > MaxPixels/config.Height {
+		return nil, ErrImageTooLarge
+	}
+
+	original, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+	bounds := original.Bounds()
+
+	thumbnail := original
+	if width, height := fit(bounds.Dx(), bounds.Dy(), ThumbnailSize); width != bounds.Dx() || height != bounds.Dy() {
+		scaled := image.NewRGBA(image.Rect(0, 0, width, height))
+		draw.CatmullRom.Scale(scaled, scaled.Bounds(), original, bounds, draw.Src, nil)
+		thumbnail = scaled
+	}
+
+	processed := &Processed{
+		ContentType: contentType,
+		Width:       bounds.Dx(),
+		Height:      bounds.Dy(),
+	}
+	var buf bytes.Buffer
+	if contentType == "image/png" {
+		processed.ThumbnailContentType = "image/png"
+		err = png.Encode(&buf, thumbnail)
+	} else {
+		processed.ThumbnailContentType = "image/jpeg"
+		err = jpeg.Encode(&buf, thumbnail, &jpeg.Options{Quality: 85})
+	}
+	if err != nil {
+		return nil, err
+	}
+	processed.Thumbnail = buf.Bytes()
+	return processed, nil
+}
+
+// fit scales width and height down, keeping the aspect ratio, so that
+// neither exceeds size. Images that already fit keep their size.
+func fit(width, height, size int) (int, int) {
+	if width <= size && height <= size {
+		return width, height
+	}
+	if width >= height {
+		return size, max(height*size/width, 1)
+	}
+	return max(width*size/height, 1), size
+}
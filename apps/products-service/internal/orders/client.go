@@ -0,0 +1,59 @@
+package orders
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrOrderNotFound is returned when the order does not exist, or belongs to
+// another customer than the caller.
+var ErrOrderNotFound = errors.New("order not found")
+
+// OrderClient reads the orders orders-service keeps for the products.
+type OrderClient interface {
+	// HasOpenOrders reports whether an order that was not delivered,
+	// canceled or returned yet has an item for the product.
+	HasOpenOrders(ctx context.Context, productID string) (bool, error)
+	// FindDeliveredOrder returns the id of a delivered order of the
+	// customer with an item for the product, or "" when there is none.
+	FindDeliveredOrder(ctx context.Context, customerID, productID string) (string, error)
+	// GetOrder returns the order as the caller is allowed to see it, failing
+	// with ErrOrderNotFound when it is not.
+	GetOrder(ctx context.Context, orderID string) (*Order, error)
+}
+
+// Order is the part of an orders-service order products-service reads.
+type Order struct {
+	ID         string      `json:"id"`
+	Status     string      `json:"status"`
+	CustomerID string      `json:"customerId,omitempty"`
+	Items      []OrderItem `json:"items"`
+}
+
+type OrderItem struct {
+	ProductID string `json:"productId"`
+	SKU       string `json:"sku,omitempty"`
+	Quantity  int    `json:"quantity"`
+}
+
+// IsOpen reports whether the order is still in progress, i.e. it was not
+// delivered, canceled or returned.
+func (o *Order) IsOpen() bool {
+	switch o.Status {
+	case "delivered", "canceled", "returned":
+		return false
+	}
+	return true
+}
+
+// Quantity returns how many units of the product, or of its variant when
+// sku is set, the order has.
+func (o *Order) Quantity(productID, sku string) int {
+	quantity := 0
+	for _, item := range o.Items {
+		if item.ProductID == productID && item.SKU == sku {
+			quantity += item.Quantity
+		}
+	}
+	return quantity
+}
@@ -0,0 +1,120 @@
+package orders
+
+import (
+	"auth"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"telemetry/tracing"
+	"time"
+)
+
+type HTTPOrderClient struct {
+	client  *http.Client
+	baseURL string
+}
+
+func NewHTTPOrderClient(baseURL string) *HTTPOrderClient {
+	return &HTTPOrderClient{
+		client:  &http.Client{Timeout: 2 * time.Second},
+		baseURL: baseURL,
+	}
+}
+
+func (c *HTTPOrderClient) HasOpenOrders(ctx context.Context, productID string) (bool, error) {
+	ctx, span := tracing.NewSpan(ctx, "HTTPOrderClient#HasOpenOrders")
+	defer span.End()
+	span.SetAttributes(
+		tracing.StringAttribute("productId", productID),
+	)
+
+	query := url.Values{}
+	query.Set("productId", productID)
+	query.Set("open", "true")
+	open, err := c.list(ctx, query)
+	if err != nil {
+		span.RecordError(err)
+		return false, err
+	}
+	span.SetAttributes(
+		tracing.IntAttribute("openOrders", len(open)),
+	)
+	return len(open) > 0, nil
+}
+
+func (c *HTTPOrderClient) FindDeliveredOrder(ctx context.Context, customerID, productID string) (string, error) {
+	ctx, span := tracing.NewSpan(ctx, "HTTPOrderClient#FindDeliveredOrder")
+	defer span.End()
+	span.SetAttributes(
+		tracing.StringAttribute("customerId", customerID),
+		tracing.StringAttribute("productId", productID),
+	)
+
+	query := url.Values{}
+	query.Set("customerId", customerID)
+	query.Set("productId", productID)
+	query.Set("status", "delivered")
+	delivered, err := c.list(ctx, query)
+	if err != nil {
+		span.RecordError(err)
+		return "", err
+	}
+	if len(delivered) == 0 {
+		return "", nil
+	}
+	return delivered[0].ID, nil
+}
+
+func (c *HTTPOrderClient) GetOrder(ctx context.Context, orderID string) (*Order, error) {
+	ctx, span := tracing.NewSpan(ctx, "HTTPOrderClient#GetOrder")
+	defer span.End()
+	span.SetAttributes(
+		tracing.StringAttribute("orderId", orderID),
+	)
+
+	var order Order
+	err := c.get(ctx, "/api/orders/"+url.PathEscape(orderID), &order)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	return &order, nil
+}
+
+// list returns the orders matching the query of GET /api/orders.
+func (c *HTTPOrderClient) list(ctx context.Context, query url.Values) ([]Order, error) {
+	var orders []Order
+	if err := c.get(ctx, "/api/orders?"+query.Encode(), &orders); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// get decodes the answer of orders-service to GET path into v.
+func (c *HTTPOrderClient) get(ctx context.Context, path string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	if traceparent := tracing.GetTraceParent(ctx); traceparent != "" {
+		req.Header.Set("traceparent", traceparent)
+	}
+	// orders-service answers with the orders the caller is allowed to see.
+	auth.Forward(ctx, req)
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return ErrOrderNotFound
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("orders-service answered %d", res.StatusCode)
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
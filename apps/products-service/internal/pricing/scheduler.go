@@ -0,0 +1,167 @@
+package pricing
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"products-service/internal/domain"
+	"products-service/internal/publisher"
+	"products-service/internal/repository"
+	"telemetry/tracing"
+	"time"
+)
+
+const scheduleBatchSize = 100
+
+// Scheduler applies scheduled price changes and sales once they are due and
+// publishes the resulting product events.
+type Scheduler struct {
+	changes  repository.PriceChangeRepository
+	products repository.ProductRepository
+	pub      publisher.ProductPublisher
+	interval time.Duration
+}
+
+func NewScheduler(changes repository.PriceChangeRepository, products repository.ProductRepository, pub publisher.ProductPublisher, interval time.Duration) *Scheduler {
+	return &Scheduler{
+		changes:  changes,
+		products: products,
+		pub:      pub,
+		interval: interval,
+	}
+}
+
+// Run applies the due changes every interval until ctx is done.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			applied, err := s.ApplyDue(ctx)
+			if err != nil {
+				slog.ErrorContext(ctx, "failed to apply scheduled price changes", "error", err)
+				continue
+			}
+			if applied > 0 {
+				slog.InfoContext(ctx, "applied scheduled price changes", "priceChanges", applied)
+			}
+		}
+	}
+}
+
+// ApplyDue applies every change that is due now, earliest first, and
+// returns how many it applied.
+func (s *Scheduler) ApplyDue(ctx context.Context) (int, error) {
+	ctx, span := tracing.NewSpan(ctx, "Scheduler#ApplyDue")
+	defer span.End()
+
+	applied := 0
+	for {
+		due, err := s.changes.ListDue(ctx, time.Now(), scheduleBatchSize)
+		if err != nil {
+			span.RecordError(err)
+			return applied, err
+		}
+
+		for i := range due {
+			ok, err := s.apply(ctx, &due[i])
+			if err != nil {
+				span.RecordError(err)
+				return applied, err
+			}
+			if ok {
+				applied++
+			}
+		}
+
+		if len(due) < scheduleBatchSize {
+			span.SetAttributes(
+				tracing.IntAttribute("applied", applied),
+			)
+			return applied, nil
+		}
+	}
+}
+
+// apply claims the change by settling it as applied, so only one replica
+// applies it, and then sets the price of the product. A change that can no
+// longer be applied is canceled with a note saying why, so it is not picked
+// up again; so is a claimed change whose product changed price, or went
+// away, in between: the price set meanwhile wins.
+func (s *Scheduler) apply(ctx context.Context, change *domain.PriceChange) (bool, error) {
+	ctx, span := tracing.NewSpan(ctx, "Scheduler#apply")
+	defer span.End()
+	span.SetAttributes(
+		tracing.StringAttribute("productId", change.ProductID),
+		tracing.StringAttribute("priceChangeId", change.ID),
+		tracing.StringAttribute("kind", string(change.Kind)),
+	)
+
+	product, err := s.products.GetByID(ctx, change.ProductID)
+	if errors.Is(err, repository.ErrProductNotFound) {
+		return false, s.cancel(ctx, change, "product no longer exists")
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if change.Kind == domain.PriceChangeKindSaleEnd {
+		start, err := s.changes.GetByID(ctx, change.ProductID, change.SaleID)
+		if err != nil && !errors.Is(err, repository.ErrPriceChangeNotFound) {
+			return false, err
+		}
+		if err != nil || start.Status != domain.PriceChangeApplied || start.PreviousPrice == nil {
+			return false, s.cancel(ctx, change, "sale never started")
+		}
+		if product.Price != start.Price {
+			// Someone set a new price during the sale; it stays.
+			return false, s.cancel(ctx, change, "price changed during the sale")
+		}
+		change.Price = *start.PreviousPrice
+	}
+
+	change.Status = domain.PriceChangeApplied
+	change.PreviousPrice = &product.Price
+	change.AppliedAt = time.Now().UTC().Format(time.RFC3339)
+	err = s.changes.Settle(ctx, change)
+	if errors.Is(err, repository.ErrPriceChangeNotScheduled) {
+		// Canceled, or applied by another replica, meanwhile.
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	updated, err := s.products.SetPrice(ctx, product.ID, change.Price, product.Price)
+	switch {
+	case errors.Is(err, repository.ErrProductNotFound):
+		return false, s.changes.Revoke(ctx, change, "product no longer exists")
+	case errors.Is(err, repository.ErrProductChanged):
+		return false, s.changes.Revoke(ctx, change, "price changed while the change was applied")
+	case err != nil:
+		// The change stays applied without its price; it is logged so the
+		// price can be set by hand.
+		slog.ErrorContext(ctx, "price change was claimed but the price could not be set", "priceChangeId", change.ID, "productId", change.ProductID, "error", err)
+		return false, err
+	}
+
+	if err := publisher.PublishUpdate(ctx, s.pub, product, updated); err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "failed to publish price change", "priceChangeId", change.ID, "productId", change.ProductID, "error", err)
+	}
+	return true, nil
+}
+
+func (s *Scheduler) cancel(ctx context.Context, change *domain.PriceChange, note string) error {
+	change.Status = domain.PriceChangeCanceled
+	change.Note = note
+	err := s.changes.Settle(ctx, change)
+	if errors.Is(err, repository.ErrPriceChangeNotScheduled) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,35 @@
+package publisher
+
+import (
+	"context"
+	"products-service/internal/domain"
+)
+
+type ProductPublisher interface {
+	PublishProductCreated(ctx context.Context, product domain.Product) error
+	// PublishProductUpdated carries the JSON names of the fields that changed.
+	PublishProductUpdated(ctx context.Context, product domain.Product, changedFields []string) error
+	PublishProductPriceChanged(ctx context.Context, product domain.Product, oldPrice float64) error
+	PublishProductDeleted(ctx context.Context, productID string) error
+	PublishProductStockChanged(ctx context.Context, movement domain.StockMovement) error
+	// PublishStockAlert publishes stock.low, stock.depleted or
+	// stock.replenished for the change, like the products-worker does.
+	PublishStockAlert(ctx context.Context, alertType string, change domain.StockChange) error
+}
+
+// PublishUpdate emits product.updated when anything changed between the two
+// versions of a product and, on top of it, product.price_changed when the
+// price did.
+func PublishUpdate(ctx context.Context, pub ProductPublisher, before, after *domain.Product) error {
+	changed := domain.ChangedFields(before, after)
+	if len(changed) == 0 {
+		return nil
+	}
+	if err := pub.PublishProductUpdated(ctx, *after, changed); err != nil {
+		return err
+	}
+	if before.Price != after.Price {
+		return pub.PublishProductPriceChanged(ctx, *after, before.Price)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+	"products-service/internal/domain"
+	"telemetry/tracing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"github.com/google/uuid"
+)
+
+type SnsProductPublisher struct {
+	client   *sns.Client
+	topicArn string
+}
+
+func NewSnsProductPublisher(client *sns.Client, topicArn string) *SnsProductPublisher {
+	return &SnsProductPublisher{
+		client:   client,
+		topicArn: topicArn,
+	}
+}
+
+func (p *SnsProductPublisher) PublishProductCreated(ctx context.Context, product domain.Product) error {
+	return p.publish(ctx, "product.created", product.ID, map[string]interface{}{
+		"product": product,
+	})
+}
+
+func (p *SnsProductPublisher) PublishProductUpdated(ctx context.Context, product domain.Product, changedFields []string) error {
+	return p.publish(ctx, "product.updated", product.ID, map[string]interface{}{
+		"product":       product,
+		"changedFields": changedFields,
+	})
+}
+
+func (p *SnsProductPublisher) PublishProductPriceChanged(ctx context.Context, product domain.Product, oldPrice float64) error {
+	return p.publish(ctx, "product.price_changed", product.ID, map[string]interface{}{
+		"name":     product.Name,
+		"oldPrice": oldPrice,
+		"newPrice": product.Price,
+	})
+}
+
+func (p *SnsProductPublisher) PublishProductDeleted(ctx context.Context, productID string) error {
+	return p.publish(ctx, "product.deleted", productID, nil)
+}
+
+func (p *SnsProductPublisher) PublishProductStockChanged(ctx context.Context, movement domain.StockMovement) error {
+	return p.publish(ctx, "product.stock_changed", movement.ProductID, map[string]interface{}{
+		"movement": movement,
+	})
+}
+
+func (p *SnsProductPublisher) PublishStockAlert(ctx context.Context, alertType string, change domain.StockChange) error {
+	return p.publish(ctx, alertType, change.ProductID, map[string]interface{}{
+		"name":             change.Name,
+		"stock":            change.After,
+		"reorderThreshold": change.ReorderThreshold,
+	})
+}
+
+func (p *SnsProductPublisher) publish(ctx context.Context, eventType string, productID string, fields map[string]interface{}) error {
+	ctx, span := tracing.NewPublishSpan(ctx, tracing.MessagingSNS, tracing.TopicName(p.topicArn))
+	defer span.End()
+
+	span.SetAttributes(
+		tracing.StringAttribute("productId", productID),
+		tracing.StringAttribute("eventType", eventType),
+	)
+
+	// eventType is also sent as an attribute so subscriptions can filter on it.
+	messageAttributes := map[string]types.MessageAttributeValue{
+		"eventType": {
+			DataType:    aws.String("String"),
+			StringValue: aws.String(eventType),
+		},
+	}
+	// The trace context and baggage travel along for the consumers.
+	for key, value := range tracing.MessageHeaders(ctx) {
+		messageAttributes[key] = types.MessageAttributeValue{
+			DataType:    aws.String("String"),
+			StringValue: aws.String(value),
+		}
+	}
+
+	// eventId lets consumers recognize redelivered events.
+	payload := map[string]interface{}{
+		"type":      eventType,
+		"eventId":   uuid.New().String(),
+		"productId": productID,
+		"datetime":  time.Now().UTC().Format(time.RFC3339),
+	}
+	for key, value := range fields {
+		payload[key] = value
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	output, err := p.client.Publish(ctx, &sns.PublishInput{
+		TopicArn:          aws.String(p.topicArn),
+		Message:           aws.String(string(body)),
+		MessageAttributes: messageAttributes,
+	})
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	tracing.SetMessageID(span, aws.ToString(output.MessageId))
+	return nil
+}
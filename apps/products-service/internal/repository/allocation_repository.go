@@ -0,0 +1,15 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"products-service/internal/domain"
+)
+
+var ErrAllocationNotFound = errors.New("allocation not found")
+
+// AllocationRepository reads the allocations the products-worker writes when
+// it reserves stock for an order.
+type AllocationRepository interface {
+	GetByOrderID(ctx context.Context, orderID string) (*domain.Allocation, error)
+}
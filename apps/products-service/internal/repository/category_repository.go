@@ -0,0 +1,14 @@
+package repository
+
+import (
+	"context"
+	"products-service/internal/domain"
+)
+
+type CategoryRepository interface {
+	Create(ctx context.Context, category *domain.Category) error
+	GetAll(ctx context.Context) ([]domain.Category, error)
+	GetByID(ctx context.Context, id string) (*domain.Category, error)
+	Update(ctx context.Context, category *domain.Category) error
+	Delete(ctx context.Context, id string) error
+}
@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"context"
+	"products-service/internal/domain"
+	"telemetry/tracing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+type DynamoAllocationRepository struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+func NewDynamoAllocationRepository(client *dynamodb.Client, tableName string) *DynamoAllocationRepository {
+	return &DynamoAllocationRepository{
+		client:    client,
+		tableName: tableName,
+	}
+}
+
+func (r *DynamoAllocationRepository) GetByOrderID(ctx context.Context, orderID string) (*domain.Allocation, error) {
+	ctx, span := tracing.NewSpan(ctx, "DynamoAllocationRepository#GetByOrderID")
+	defer span.End()
+	span.SetAttributes(
+		tracing.StringAttribute("orderId", orderID),
+	)
+
+	output, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]types.AttributeValue{
+			"orderId": &types.AttributeValueMemberS{Value: orderID},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(output.Item) == 0 {
+		return nil, ErrAllocationNotFound
+	}
+
+	var allocation domain.Allocation
+	err = attributevalue.UnmarshalMap(output.Item, &allocation)
+	return &allocation, err
+}
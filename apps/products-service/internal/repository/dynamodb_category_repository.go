@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"products-service/internal/domain"
+	"telemetry/tracing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+type DynamoCategoryRepository struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+func NewDynamoCategoryRepository(client *dynamodb.Client, tableName string) *DynamoCategoryRepository {
+	return &DynamoCategoryRepository{
+		client:    client,
+		tableName: tableName,
+	}
+}
+
+func (r *DynamoCategoryRepository) Create(ctx context.Context, category *domain.Category) error {
+	ctx, span := tracing.NewSpan(ctx, "DynamoCategoryRepository#Create")
+	defer span.End()
+	span.SetAttributes(
+		tracing.StringAttribute("categoryId", category.ID),
+	)
+
+	item, err := attributevalue.MarshalMap(category)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item:      item,
+	})
+	return err
+}
+
+func (r *DynamoCategoryRepository) GetAll(ctx context.Context) ([]domain.Category, error) {
+	ctx, span := tracing.NewSpan(ctx, "DynamoCategoryRepository#GetAll")
+	defer span.End()
+
+	var categories []domain.Category
+	paginator := dynamodb.NewScanPaginator(r.client, &dynamodb.ScanInput{
+		TableName: aws.String(r.tableName),
+	})
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		var page []domain.Category
+		if err := attributevalue.UnmarshalListOfMaps(output.Items, &page); err != nil {
+			return nil, err
+		}
+		categories = append(categories, page...)
+	}
+	return categories, nil
+}
+
+func (r *DynamoCategoryRepository) GetByID(ctx context.Context, id string) (*domain.Category, error) {
+	ctx, span := tracing.NewSpan(ctx, "DynamoCategoryRepository#GetByID")
+	defer span.End()
+	span.SetAttributes(
+		tracing.StringAttribute("categoryId", id),
+	)
+
+	output, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(output.Item) == 0 {
+		return nil, errors.New("category not found")
+	}
+
+	var category domain.Category
+	err = attributevalue.UnmarshalMap(output.Item, &category)
+	return &category, err
+}
+
+func (r *DynamoCategoryRepository) Update(ctx context.Context, category *domain.Category) error {
+	ctx, span := tracing.NewSpan(ctx, "DynamoCategoryRepository#Update")
+	defer span.End()
+
+	return r.Create(ctx, category)
+}
+
+func (r *DynamoCategoryRepository) Delete(ctx context.Context, id string) error {
+	ctx, span := tracing.NewSpan(ctx, "DynamoCategoryRepository#Delete")
+	defer span.End()
+	span.SetAttributes(
+		tracing.StringAttribute("categoryId", id),
+	)
+
+	_, err := r.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+	})
+	return err
+}
@@ -0,0 +1,178 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"products-service/internal/domain"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// facetIndexName is the GSI on facet used to find the products carrying a
+// tag or attribute value. DynamoDB cannot index the elements of the tags
+// list or the attributes map, so the facets table holds one item per
+// product and facet, see domain.Product.Facets.
+const facetIndexName = "facet-productId-index"
+
+// findByFacets returns the ids of the products that have, for every
+// condition, at least one of its facets.
+func (r *DynamoProductRepository) findByFacets(ctx context.Context, conditions [][]string) ([]string, error) {
+	var ids map[string]bool
+	for _, alternatives := range conditions {
+		matching := make(map[string]bool)
+		for _, facet := range alternatives {
+			found, err := r.queryFacet(ctx, facet)
+			if err != nil {
+				return nil, err
+			}
+			for _, id := range found {
+				if ids == nil || ids[id] {
+					matching[id] = true
+				}
+			}
+		}
+		ids = matching
+		if len(ids) == 0 {
+			return nil, nil
+		}
+	}
+
+	result := make([]string, 0, len(ids))
+	for id := range ids {
+		result = append(result, id)
+	}
+	return result, nil
+}
+
+func (r *DynamoProductRepository) queryFacet(ctx context.Context, facet string) ([]string, error) {
+	paginator := dynamodb.NewQueryPaginator(r.client, &dynamodb.QueryInput{
+		TableName:              aws.String(r.facetsTable),
+		IndexName:              aws.String(facetIndexName),
+		KeyConditionExpression: aws.String("#facet = :facet"),
+		ProjectionExpression:   aws.String("#productId"),
+		ExpressionAttributeNames: map[string]string{
+			"#facet":     "facet",
+			"#productId": "productId",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":facet": &types.AttributeValueMemberS{Value: facet},
+		},
+	})
+	var ids []string
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range output.Items {
+			if id, ok := item["productId"].(*types.AttributeValueMemberS); ok {
+				ids = append(ids, id.Value)
+			}
+		}
+	}
+	return ids, nil
+}
+
+// maxTransactItems is the most items DynamoDB accepts in one
+// TransactWriteItems call.
+const maxTransactItems = 100
+
+// writeWithFacets writes the product in one transaction with the changes
+// that bring its stored facets in line, so a listing never finds a product
+// by facets it no longer has nor misses it by ones it has. product is nil
+// when write deletes it, dropping all of its facets. The write is the first
+// item of the transaction, so its cancellation reason comes first.
+func (r *DynamoProductRepository) writeWithFacets(ctx context.Context, write types.TransactWriteItem, productID string, product *domain.Product) error {
+	stored, err := r.storedFacets(ctx, productID)
+	if err != nil {
+		return err
+	}
+	items := append([]types.TransactWriteItem{write}, r.facetWrites(productID, stored, product)...)
+	if len(items) > maxTransactItems {
+		return fmt.Errorf("product %s changes %d facets, more than one transaction takes", productID, len(items)-1)
+	}
+
+	_, err = r.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: items,
+	})
+	return err
+}
+
+// facetWrites returns the puts and deletes that turn the stored facets of
+// the product into the ones it has, or delete them all when product is nil.
+func (r *DynamoProductRepository) facetWrites(productID string, stored map[string]bool, product *domain.Product) []types.TransactWriteItem {
+	wanted := make(map[string]bool)
+	if product != nil {
+		for _, facet := range product.Facets() {
+			wanted[facet] = true
+		}
+	}
+
+	var items []types.TransactWriteItem
+	for facet := range wanted {
+		if stored[facet] {
+			continue
+		}
+		items = append(items, types.TransactWriteItem{Put: &types.Put{
+			TableName: aws.String(r.facetsTable),
+			Item:      facetKey(productID, facet),
+		}})
+	}
+	for facet := range stored {
+		if wanted[facet] {
+			continue
+		}
+		items = append(items, types.TransactWriteItem{Delete: &types.Delete{
+			TableName: aws.String(r.facetsTable),
+			Key:       facetKey(productID, facet),
+		}})
+	}
+	return items
+}
+
+// firstConditionFailed reports whether err cancelled a transaction because
+// the condition of its first item failed.
+func firstConditionFailed(err error) bool {
+	var canceled *types.TransactionCanceledException
+	return errors.As(err, &canceled) &&
+		len(canceled.CancellationReasons) > 0 &&
+		aws.ToString(canceled.CancellationReasons[0].Code) == "ConditionalCheckFailed"
+}
+
+// storedFacets returns the facets stored for the product.
+func (r *DynamoProductRepository) storedFacets(ctx context.Context, productID string) (map[string]bool, error) {
+	paginator := dynamodb.NewQueryPaginator(r.client, &dynamodb.QueryInput{
+		TableName:              aws.String(r.facetsTable),
+		KeyConditionExpression: aws.String("#productId = :productId"),
+		ExpressionAttributeNames: map[string]string{
+			"#productId": "productId",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":productId": &types.AttributeValueMemberS{Value: productID},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	facets := make(map[string]bool)
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range output.Items {
+			if facet, ok := item["facet"].(*types.AttributeValueMemberS); ok {
+				facets[facet.Value] = true
+			}
+		}
+	}
+	return facets, nil
+}
+
+func facetKey(productID, facet string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"productId": &types.AttributeValueMemberS{Value: productID},
+		"facet":     &types.AttributeValueMemberS{Value: facet},
+	}
+}
@@ -0,0 +1,285 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"products-service/internal/domain"
+	"strconv"
+	"strings"
+	"telemetry/tracing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	// priceChangesByDateIndex is the LSI on (productId, effectiveAt) used
+	// for the price history of a product.
+	priceChangesByDateIndex = "effectiveAt-index"
+	// priceChangesDueIndex is the GSI on (status, effectiveAt) the scheduler
+	// uses to find the changes that are due.
+	priceChangesDueIndex = "status-effectiveAt-index"
+)
+
+type DynamoPriceChangeRepository struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+func NewDynamoPriceChangeRepository(client *dynamodb.Client, tableName string) *DynamoPriceChangeRepository {
+	return &DynamoPriceChangeRepository{
+		client:    client,
+		tableName: tableName,
+	}
+}
+
+func (r *DynamoPriceChangeRepository) Create(ctx context.Context, change *domain.PriceChange) error {
+	ctx, span := tracing.NewSpan(ctx, "DynamoPriceChangeRepository#Create")
+	defer span.End()
+	span.SetAttributes(
+		tracing.StringAttribute("productId", change.ProductID),
+		tracing.StringAttribute("priceChangeId", change.ID),
+	)
+
+	item, err := attributevalue.MarshalMap(change)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item:      item,
+	})
+	if err != nil {
+		span.RecordError(err)
+	}
+	return err
+}
+
+func (r *DynamoPriceChangeRepository) GetByID(ctx context.Context, productID, id string) (*domain.PriceChange, error) {
+	ctx, span := tracing.NewSpan(ctx, "DynamoPriceChangeRepository#GetByID")
+	defer span.End()
+	span.SetAttributes(
+		tracing.StringAttribute("productId", productID),
+		tracing.StringAttribute("priceChangeId", id),
+	)
+
+	output, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(r.tableName),
+		Key:            priceChangeKey(productID, id),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(output.Item) == 0 {
+		return nil, ErrPriceChangeNotFound
+	}
+
+	var change domain.PriceChange
+	err = attributevalue.UnmarshalMap(output.Item, &change)
+	return &change, err
+}
+
+func (r *DynamoPriceChangeRepository) ListByProduct(ctx context.Context, productID string, status domain.PriceChangeStatus, limit int, cursor string) ([]domain.PriceChange, string, error) {
+	ctx, span := tracing.NewSpan(ctx, "DynamoPriceChangeRepository#ListByProduct")
+	defer span.End()
+	span.SetAttributes(
+		tracing.StringAttribute("productId", productID),
+		tracing.StringAttribute("status", string(status)),
+	)
+
+	startKey, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		IndexName:              aws.String(priceChangesByDateIndex),
+		KeyConditionExpression: aws.String("productId = :productId"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":productId": &types.AttributeValueMemberS{Value: productID},
+		},
+		ScanIndexForward:  aws.Bool(false),
+		Limit:             aws.Int32(int32(limit)),
+		ExclusiveStartKey: startKey,
+	}
+	if status != "" {
+		input.FilterExpression = aws.String("#status = :status")
+		input.ExpressionAttributeNames = map[string]string{"#status": "status"}
+		input.ExpressionAttributeValues[":status"] = &types.AttributeValueMemberS{Value: string(status)}
+	}
+
+	output, err := r.client.Query(ctx, input)
+	if err != nil {
+		span.RecordError(err)
+		return nil, "", err
+	}
+
+	var changes []domain.PriceChange
+	if err := attributevalue.UnmarshalListOfMaps(output.Items, &changes); err != nil {
+		return nil, "", err
+	}
+
+	next, err := encodeCursor(output.LastEvaluatedKey)
+	return changes, next, err
+}
+
+// PriceAt walks the history of the product backwards from at and stops at
+// the first applied change.
+func (r *DynamoPriceChangeRepository) PriceAt(ctx context.Context, productID string, at time.Time) (*domain.PriceChange, error) {
+	ctx, span := tracing.NewSpan(ctx, "DynamoPriceChangeRepository#PriceAt")
+	defer span.End()
+	span.SetAttributes(
+		tracing.StringAttribute("productId", productID),
+	)
+
+	paginator := dynamodb.NewQueryPaginator(r.client, &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		IndexName:              aws.String(priceChangesByDateIndex),
+		KeyConditionExpression: aws.String("productId = :productId AND #effectiveAt <= :at"),
+		FilterExpression:       aws.String("#status = :applied"),
+		ExpressionAttributeNames: map[string]string{
+			"#effectiveAt": "effectiveAt",
+			"#status":      "status",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":productId": &types.AttributeValueMemberS{Value: productID},
+			":at":        &types.AttributeValueMemberS{Value: at.UTC().Format(time.RFC3339)},
+			":applied":   &types.AttributeValueMemberS{Value: string(domain.PriceChangeApplied)},
+		},
+		ScanIndexForward: aws.Bool(false),
+	})
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			span.RecordError(err)
+			return nil, err
+		}
+		if len(output.Items) == 0 {
+			continue
+		}
+		var change domain.PriceChange
+		err = attributevalue.UnmarshalMap(output.Items[0], &change)
+		return &change, err
+	}
+	return nil, ErrPriceChangeNotFound
+}
+
+func (r *DynamoPriceChangeRepository) ListDue(ctx context.Context, now time.Time, limit int) ([]domain.PriceChange, error) {
+	ctx, span := tracing.NewSpan(ctx, "DynamoPriceChangeRepository#ListDue")
+	defer span.End()
+
+	output, err := r.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		IndexName:              aws.String(priceChangesDueIndex),
+		KeyConditionExpression: aws.String("#status = :scheduled AND #effectiveAt <= :now"),
+		ExpressionAttributeNames: map[string]string{
+			"#status":      "status",
+			"#effectiveAt": "effectiveAt",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":scheduled": &types.AttributeValueMemberS{Value: string(domain.PriceChangeScheduled)},
+			":now":       &types.AttributeValueMemberS{Value: now.UTC().Format(time.RFC3339)},
+		},
+		Limit: aws.Int32(int32(limit)),
+	})
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	var changes []domain.PriceChange
+	err = attributevalue.UnmarshalListOfMaps(output.Items, &changes)
+	return changes, err
+}
+
+func (r *DynamoPriceChangeRepository) Settle(ctx context.Context, change *domain.PriceChange) error {
+	ctx, span := tracing.NewSpan(ctx, "DynamoPriceChangeRepository#Settle")
+	defer span.End()
+	span.SetAttributes(
+		tracing.StringAttribute("productId", change.ProductID),
+		tracing.StringAttribute("priceChangeId", change.ID),
+		tracing.StringAttribute("status", string(change.Status)),
+	)
+
+	sets := []string{"#status = :status", "price = :price"}
+	values := map[string]types.AttributeValue{
+		":status":    &types.AttributeValueMemberS{Value: string(change.Status)},
+		":price":     &types.AttributeValueMemberN{Value: strconv.FormatFloat(change.Price, 'f', -1, 64)},
+		":scheduled": &types.AttributeValueMemberS{Value: string(domain.PriceChangeScheduled)},
+	}
+	if change.PreviousPrice != nil {
+		sets = append(sets, "previousPrice = :previousPrice")
+		values[":previousPrice"] = &types.AttributeValueMemberN{Value: strconv.FormatFloat(*change.PreviousPrice, 'f', -1, 64)}
+	}
+	if change.AppliedAt != "" {
+		sets = append(sets, "appliedAt = :appliedAt")
+		values[":appliedAt"] = &types.AttributeValueMemberS{Value: change.AppliedAt}
+	}
+	if change.Note != "" {
+		sets = append(sets, "note = :note")
+		values[":note"] = &types.AttributeValueMemberS{Value: change.Note}
+	}
+
+	_, err := r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(r.tableName),
+		Key:                       priceChangeKey(change.ProductID, change.ID),
+		UpdateExpression:          aws.String("SET " + strings.Join(sets, ", ")),
+		ConditionExpression:       aws.String("#status = :scheduled"),
+		ExpressionAttributeNames:  map[string]string{"#status": "status"},
+		ExpressionAttributeValues: values,
+	})
+	var conditionErr *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionErr) {
+		return ErrPriceChangeNotScheduled
+	}
+	if err != nil {
+		span.RecordError(err)
+	}
+	return err
+}
+
+func (r *DynamoPriceChangeRepository) Revoke(ctx context.Context, change *domain.PriceChange, note string) error {
+	ctx, span := tracing.NewSpan(ctx, "DynamoPriceChangeRepository#Revoke")
+	defer span.End()
+	span.SetAttributes(
+		tracing.StringAttribute("productId", change.ProductID),
+		tracing.StringAttribute("priceChangeId", change.ID),
+	)
+
+	_, err := r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(r.tableName),
+		Key:                 priceChangeKey(change.ProductID, change.ID),
+		UpdateExpression:    aws.String("SET #status = :canceled, note = :note REMOVE previousPrice, appliedAt"),
+		ConditionExpression: aws.String("#status = :applied"),
+		ExpressionAttributeNames: map[string]string{
+			"#status": "status",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":canceled": &types.AttributeValueMemberS{Value: string(domain.PriceChangeCanceled)},
+			":applied":  &types.AttributeValueMemberS{Value: string(domain.PriceChangeApplied)},
+			":note":     &types.AttributeValueMemberS{Value: note},
+		},
+	})
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	change.Status = domain.PriceChangeCanceled
+	change.PreviousPrice = nil
+	change.AppliedAt = ""
+	change.Note = note
+	return nil
+}
+
+func priceChangeKey(productID, id string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"productId": &types.AttributeValueMemberS{Value: productID},
+		"id":        &types.AttributeValueMemberS{Value: id},
+	}
+}
@@ -3,8 +3,12 @@ package repository
 import (
 	"context"
 	"errors"
+	"fmt"
 	"products-service/internal/domain"
-	"products-service/internal/tracing"
+	"strconv"
+	"strings"
+	"telemetry/tracing"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
@@ -12,15 +16,36 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 )
 
+// batchGetSize is the most keys DynamoDB accepts in one BatchGetItem call.
+const batchGetSize = 100
+
+// maxBatchAttempts bounds how often unprocessed keys and items are retried.
+const maxBatchAttempts = 5
+
+// categoryIndexName is the GSI on categoryId used to list the products of a
+// category without scanning the whole table.
+const categoryIndexName = "categoryId-index"
+
+// versionAttribute counts the writes to a product. Every write adds one to
+// it, so a product read and written back whole can be written on the
+// condition that nothing else wrote it in between.
+const versionAttribute = "version"
+
+// skuIndexName is the GSI on the catalog SKU used to match imported rows to
+// the products they update.
+const skuIndexName = "sku-index"
+
 type DynamoProductRepository struct {
-	client    *dynamodb.Client
-	tableName string
+	client      *dynamodb.Client
+	tableName   string
+	facetsTable string
 }
 
-func NewDynamoProductRepository(client *dynamodb.Client, tableName string) *DynamoProductRepository {
+func NewDynamoProductRepository(client *dynamodb.Client, tableName string, facetsTable string) *DynamoProductRepository {
 	return &DynamoProductRepository{
-		client:    client,
-		tableName: tableName,
+		client:      client,
+		tableName:   tableName,
+		facetsTable: facetsTable,
 	}
 }
 
@@ -36,27 +61,90 @@ func (r *DynamoProductRepository) Create(ctx context.Context, product *domain.Pr
 		return err
 	}
 
-	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+	put := types.TransactWriteItem{Put: &types.Put{
 		TableName: aws.String(r.tableName),
 		Item:      item,
-	})
-	return err
+	}}
+	return r.writeWithFacets(ctx, put, product.ID, product)
 }
 
 func (r *DynamoProductRepository) GetAll(ctx context.Context) ([]domain.Product, error) {
 	ctx, span := tracing.NewSpan(ctx, "DynamoProductRepository#GetAll")
 	defer span.End()
 
-	output, err := r.client.Scan(ctx, &dynamodb.ScanInput{
+	return r.scan(ctx, &dynamodb.ScanInput{
 		TableName: aws.String(r.tableName),
 	})
-	if err != nil {
-		return nil, err
+}
+
+// Find queries the facets of the tag and attribute conditions, or the
+// category index when there are none, and checks every product found
+// against the whole filter.
+func (r *DynamoProductRepository) Find(ctx context.Context, filter domain.ProductFilter) ([]domain.Product, error) {
+	ctx, span := tracing.NewSpan(ctx, "DynamoProductRepository#Find")
+	defer span.End()
+	span.SetAttributes(
+		tracing.StringAttribute("categoryIds", strings.Join(filter.CategoryIDs, ",")),
+		tracing.StringAttribute("tags", strings.Join(filter.Tags, ",")),
+	)
+
+	var found []domain.Product
+	if conditions := filter.Facets(); len(conditions) > 0 {
+		ids, err := r.findByFacets(ctx, conditions)
+		if err != nil {
+			span.RecordError(err)
+			return nil, err
+		}
+		if found, err = r.GetByIDs(ctx, ids); err != nil {
+			return nil, err
+		}
+	} else if len(filter.CategoryIDs) > 0 {
+		for _, categoryID := range filter.CategoryIDs {
+			products, err := r.query(ctx, &dynamodb.QueryInput{
+				TableName:              aws.String(r.tableName),
+				IndexName:              aws.String(categoryIndexName),
+				KeyConditionExpression: aws.String("#categoryId = :categoryId"),
+				ExpressionAttributeNames: map[string]string{
+					"#categoryId": "categoryId",
+				},
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":categoryId": &types.AttributeValueMemberS{Value: categoryID},
+				},
+			})
+			if err != nil {
+				span.RecordError(err)
+				return nil, err
+			}
+			found = append(found, products...)
+		}
+	} else {
+		return r.GetAll(ctx)
 	}
 
-	var products []domain.Product
-	err = attributevalue.UnmarshalListOfMaps(output.Items, &products)
-	return products, err
+	products := found[:0]
+	for _, product := range found {
+		if filter.Matches(&product) {
+			products = append(products, product)
+		}
+	}
+	return products, nil
+}
+
+func (r *DynamoProductRepository) FindLowStock(ctx context.Context) ([]domain.Product, error) {
+	ctx, span := tracing.NewSpan(ctx, "DynamoProductRepository#FindLowStock")
+	defer span.End()
+
+	return r.scan(ctx, &dynamodb.ScanInput{
+		TableName:        aws.String(r.tableName),
+		FilterExpression: aws.String("#stock <= :zero OR #stock <= #reorderThreshold"),
+		ExpressionAttributeNames: map[string]string{
+			"#stock":            "stock",
+			"#reorderThreshold": "reorderThreshold",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":zero": &types.AttributeValueMemberN{Value: "0"},
+		},
+	})
 }
 
 func (r *DynamoProductRepository) GetByID(ctx context.Context, id string) (*domain.Product, error) {
@@ -66,30 +154,329 @@ func (r *DynamoProductRepository) GetByID(ctx context.Context, id string) (*doma
 		tracing.StringAttribute("productId", id),
 	)
 
+	// Consistent, since the product is often read to be updated, which
+	// fails when the version read is not the latest.
 	output, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
 		TableName: aws.String(r.tableName),
 		Key: map[string]types.AttributeValue{
 			"id": &types.AttributeValueMemberS{Value: id},
 		},
+		ConsistentRead: aws.Bool(true),
 	})
 	if err != nil {
 		return nil, err
 	}
 
 	if output.Item == nil || len(output.Item) == 0 {
-		return nil, errors.New("product not found")
+		return nil, ErrProductNotFound
 	}
 
 	var product domain.Product
-	err = attributevalue.UnmarshalMap(output.Item, &product)
-	return &product, err
+	if err := attributevalue.UnmarshalMap(output.Item, &product); err != nil {
+		return nil, err
+	}
+	product.ComputeAvailability()
+	product.ComputeRating()
+	return &product, nil
+}
+
+// GetByIDs reads the products in chunks of batchGetSize. Keys DynamoDB
+// leaves unprocessed, e.g. when throttled, are retried with backoff.
+func (r *DynamoProductRepository) GetByIDs(ctx context.Context, ids []string) ([]domain.Product, error) {
+	ctx, span := tracing.NewSpan(ctx, "DynamoProductRepository#GetByIDs")
+	defer span.End()
+	span.SetAttributes(
+		tracing.IntAttribute("ids", len(ids)),
+	)
+
+	var products []domain.Product
+	for start := 0; start < len(ids); start += batchGetSize {
+		end := min(start+batchGetSize, len(ids))
+
+		keys := make([]map[string]types.AttributeValue, 0, end-start)
+		for _, id := range ids[start:end] {
+			keys = append(keys, productKey(id))
+		}
+
+		request := map[string]types.KeysAndAttributes{
+			r.tableName: {Keys: keys},
+		}
+		for attempt := 0; len(request) > 0; attempt++ {
+			if attempt == maxBatchAttempts {
+				err := fmt.Errorf("%d product keys still unprocessed after %d attempts", len(request[r.tableName].Keys), maxBatchAttempts)
+				span.RecordError(err)
+				return nil, err
+			}
+			if err := backoff(ctx, attempt); err != nil {
+				return nil, err
+			}
+
+			output, err := r.client.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{
+				RequestItems: request,
+			})
+			if err != nil {
+				span.RecordError(err)
+				return nil, err
+			}
+
+			var page []domain.Product
+			if err := attributevalue.UnmarshalListOfMaps(output.Responses[r.tableName], &page); err != nil {
+				return nil, err
+			}
+			for i := range page {
+				page[i].ComputeAvailability()
+				page[i].ComputeRating()
+			}
+			products = append(products, page...)
+
+			request = output.UnprocessedKeys
+		}
+	}
+	return products, nil
+}
+
+func (r *DynamoProductRepository) FindBySKUs(ctx context.Context, skus []string) ([]domain.Product, error) {
+	ctx, span := tracing.NewSpan(ctx, "DynamoProductRepository#FindBySKUs")
+	defer span.End()
+	span.SetAttributes(
+		tracing.IntAttribute("skus", len(skus)),
+	)
+
+	var products []domain.Product
+	for _, sku := range skus {
+		found, err := r.query(ctx, &dynamodb.QueryInput{
+			TableName:              aws.String(r.tableName),
+			IndexName:              aws.String(skuIndexName),
+			KeyConditionExpression: aws.String("#sku = :sku"),
+			ExpressionAttributeNames: map[string]string{
+				"#sku": "sku",
+			},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":sku": &types.AttributeValueMemberS{Value: sku},
+			},
+		})
+		if err != nil {
+			span.RecordError(err)
+			return nil, err
+		}
+		products = append(products, found...)
+	}
+	return products, nil
+}
+
+// PutBatch writes the products in chunks, each chunk in one transaction
+// with the changes to the facets of its products, as many products as fit
+// in maxTransactItems. The products whose condition fails are left out and
+// the rest of the chunk is retried, with backoff.
+//
+// BatchWriteItem would take half the write capacity, but it takes no
+// conditions. An imported row is matched to its product by SKU and carries
+// the version read then; the write has to fail when the product changed
+// after the match, rather than overwrite that change, and a new product
+// must not overwrite one created meanwhile under the same id.
+func (r *DynamoProductRepository) PutBatch(ctx context.Context, products []domain.Product) ([]string, error) {
+	ctx, span := tracing.NewSpan(ctx, "DynamoProductRepository#PutBatch")
+	defer span.End()
+	span.SetAttributes(
+		tracing.IntAttribute("products", len(products)),
+	)
+
+	facets := make([][]types.TransactWriteItem, len(products))
+	for i := range products {
+		stored, err := r.storedFacets(ctx, products[i].ID)
+		if err != nil {
+			span.RecordError(err)
+			return nil, err
+		}
+		facets[i] = r.facetWrites(products[i].ID, stored, &products[i])
+		if 1+len(facets[i]) > maxTransactItems {
+			err := fmt.Errorf("product %s changes %d facets, more than one transaction takes", products[i].ID, len(facets[i]))
+			span.RecordError(err)
+			return nil, err
+		}
+	}
+
+	var changed []string
+	for start := 0; start < len(products); {
+		var pending []int
+		size := 0
+		for start < len(products) && size+1+len(facets[start]) <= maxTransactItems {
+			size += 1 + len(facets[start])
+			pending = append(pending, start)
+			start++
+		}
+
+		for attempt := 0; len(pending) > 0; attempt++ {
+			if attempt == maxBatchAttempts {
+				err := fmt.Errorf("%d products still unwritten after %d attempts", len(pending), maxBatchAttempts)
+				span.RecordError(err)
+				return changed, err
+			}
+			if err := backoff(ctx, attempt); err != nil {
+				return changed, err
+			}
+
+			// positions holds the index of the put of each pending
+			// product, which its cancellation reason shares.
+			var items []types.TransactWriteItem
+			positions := make([]int, len(pending))
+			for n, i := range pending {
+				put, err := r.versionedPut(&products[i])
+				if err != nil {
+					return changed, err
+				}
+				positions[n] = len(items)
+				items = append(items, types.TransactWriteItem{Put: put})
+				items = append(items, facets[i]...)
+			}
+
+			_, err := r.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+				TransactItems: items,
+			})
+			if err == nil {
+				for _, i := range pending {
+					products[i].Version++
+				}
+				break
+			}
+			var canceled *types.TransactionCanceledException
+			if !errors.As(err, &canceled) {
+				span.RecordError(err)
+				return changed, err
+			}
+			// Products written meanwhile are left out; a transaction that
+			// conflicted with another is retried as it was.
+			retry := pending[:0]
+			for n, i := range pending {
+				if positions[n] < len(canceled.CancellationReasons) && aws.ToString(canceled.CancellationReasons[positions[n]].Code) == "ConditionalCheckFailed" {
+					changed = append(changed, products[i].ID)
+					continue
+				}
+				retry = append(retry, i)
+			}
+			pending = retry
+		}
+	}
+	span.SetAttributes(
+		tracing.IntAttribute("changed", len(changed)),
+	)
+	return changed, nil
+}
+
+// versionedPut writes the product with the next version, on the condition
+// that the stored one still has the version read, or none.
+func (r *DynamoProductRepository) versionedPut(product *domain.Product) (*types.Put, error) {
+	next := *product
+	next.Version++
+	item, err := attributevalue.MarshalMap(&next)
+	if err != nil {
+		return nil, err
+	}
+
+	condition := "#version = :version"
+	if product.Version == 0 {
+		condition = "attribute_not_exists(#version)"
+	}
+	put := &types.Put{
+		TableName:                aws.String(r.tableName),
+		Item:                     item,
+		ConditionExpression:      aws.String(condition),
+		ExpressionAttributeNames: map[string]string{"#version": versionAttribute},
+	}
+	if product.Version != 0 {
+		put.ExpressionAttributeValues = map[string]types.AttributeValue{":version": numberValue(product.Version)}
+	}
+	return put, nil
+}
+
+func (r *DynamoProductRepository) Walk(ctx context.Context, fn func(page []domain.Product) error) error {
+	ctx, span := tracing.NewSpan(ctx, "DynamoProductRepository#Walk")
+	defer span.End()
+
+	err := r.walk(ctx, &dynamodb.ScanInput{
+		TableName: aws.String(r.tableName),
+	}, fn)
+	if err != nil {
+		span.RecordError(err)
+	}
+	return err
 }
 
 func (r *DynamoProductRepository) Update(ctx context.Context, product *domain.Product) error {
 	ctx, span := tracing.NewSpan(ctx, "DynamoProductRepository#Update")
 	defer span.End()
+	span.SetAttributes(
+		tracing.StringAttribute("productId", product.ID),
+		tracing.IntAttribute("version", product.Version),
+	)
+
+	next := *product
+	next.Version++
+	item, err := attributevalue.MarshalMap(&next)
+	if err != nil {
+		return err
+	}
+
+	put := types.TransactWriteItem{Put: &types.Put{
+		TableName:                 aws.String(r.tableName),
+		Item:                      item,
+		ConditionExpression:       aws.String(versionCondition(product.Version)),
+		ExpressionAttributeNames:  map[string]string{"#version": versionAttribute},
+		ExpressionAttributeValues: map[string]types.AttributeValue{":version": numberValue(product.Version)},
+	}}
+	err = r.writeWithFacets(ctx, put, product.ID, &next)
+	if firstConditionFailed(err) {
+		return ErrProductChanged
+	}
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	product.Version = next.Version
+	return nil
+}
+
+func (r *DynamoProductRepository) SetPrice(ctx context.Context, id string, price, current float64) (*domain.Product, error) {
+	ctx, span := tracing.NewSpan(ctx, "DynamoProductRepository#SetPrice")
+	defer span.End()
+	span.SetAttributes(
+		tracing.StringAttribute("productId", id),
+	)
+
+	names := map[string]string{"#price": "price"}
+	values := map[string]types.AttributeValue{
+		":price":        &types.AttributeValueMemberN{Value: strconv.FormatFloat(price, 'f', -1, 64)},
+		":currentPrice": &types.AttributeValueMemberN{Value: strconv.FormatFloat(current, 'f', -1, 64)},
+	}
+	output, err := r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                           aws.String(r.tableName),
+		Key:                                 productKey(id),
+		UpdateExpression:                    aws.String("SET #price = :price" + addVersion(names, values)),
+		ConditionExpression:                 aws.String("attribute_exists(id) AND #price = :currentPrice"),
+		ExpressionAttributeNames:            names,
+		ExpressionAttributeValues:           values,
+		ReturnValues:                        types.ReturnValueAllNew,
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	})
+	var conditionErr *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionErr) {
+		if len(conditionErr.Item) == 0 {
+			return nil, ErrProductNotFound
+		}
+		return nil, ErrProductChanged
+	}
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
 
-	return r.Create(ctx, product)
+	var product domain.Product
+	if err := attributevalue.UnmarshalMap(output.Attributes, &product); err != nil {
+		return nil, err
+	}
+	product.ComputeAvailability()
+	product.ComputeRating()
+	return &product, nil
 }
 
 func (r *DynamoProductRepository) Delete(ctx context.Context, id string) error {
@@ -99,11 +486,107 @@ func (r *DynamoProductRepository) Delete(ctx context.Context, id string) error {
 		tracing.StringAttribute("productId", id),
 	)
 
-	_, err := r.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+	del := types.TransactWriteItem{Delete: &types.Delete{
 		TableName: aws.String(r.tableName),
 		Key: map[string]types.AttributeValue{
 			"id": &types.AttributeValueMemberS{Value: id},
 		},
+		ConditionExpression: aws.String("attribute_exists(id)"),
+	}}
+	err := r.writeWithFacets(ctx, del, id, nil)
+	if firstConditionFailed(err) {
+		return ErrProductNotFound
+	}
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	return nil
+}
+
+func (r *DynamoProductRepository) scan(ctx context.Context, input *dynamodb.ScanInput) ([]domain.Product, error) {
+	var products []domain.Product
+	err := r.walk(ctx, input, func(page []domain.Product) error {
+		products = append(products, page...)
+		return nil
 	})
-	return err
+	if err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+func (r *DynamoProductRepository) walk(ctx context.Context, input *dynamodb.ScanInput, fn func(page []domain.Product) error) error {
+	paginator := dynamodb.NewScanPaginator(r.client, input)
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		var page []domain.Product
+		if err := attributevalue.UnmarshalListOfMaps(output.Items, &page); err != nil {
+			return err
+		}
+		for i := range page {
+			page[i].ComputeAvailability()
+			page[i].ComputeRating()
+		}
+		if err := fn(page); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *DynamoProductRepository) query(ctx context.Context, input *dynamodb.QueryInput) ([]domain.Product, error) {
+	var products []domain.Product
+	paginator := dynamodb.NewQueryPaginator(r.client, input)
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		var page []domain.Product
+		if err := attributevalue.UnmarshalListOfMaps(output.Items, &page); err != nil {
+			return nil, err
+		}
+		for i := range page {
+			page[i].ComputeAvailability()
+			page[i].ComputeRating()
+		}
+		products = append(products, page...)
+	}
+	return products, nil
+}
+
+// versionCondition checks that the product still has the version that was
+// read. Products written before versions existed have none, which reads as
+// 0.
+func versionCondition(version int) string {
+	if version == 0 {
+		return "attribute_exists(id) AND (attribute_not_exists(#version) OR #version = :version)"
+	}
+	return "#version = :version"
+}
+
+// addVersion returns the clause of an update expression that adds one to
+// the version of the product, with its names and values.
+func addVersion(names map[string]string, values map[string]types.AttributeValue) string {
+	names["#version"] = versionAttribute
+	values[":versionStep"] = numberValue(1)
+	return " ADD #version :versionStep"
+}
+
+// backoff waits before retrying unprocessed batch items. The first attempt
+// does not wait.
+func backoff(ctx context.Context, attempt int) error {
+	if attempt == 0 {
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(time.Duration(1<<attempt) * 50 * time.Millisecond):
+		return nil
+	}
 }
@@ -0,0 +1,673 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"products-service/internal/domain"
+	"slices"
+	"telemetry/tracing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	// reservationsByExpiryIndex is the GSI on (status, expiresAt) the
+	// expiry sweeper queries for held and confirmed reservations that ran
+	// out.
+	reservationsByExpiryIndex = "status-expiresAt-index"
+	// reservationsByCustomerIndex is the GSI on (customerId, status) the
+	// holds of a customer are counted with.
+	reservationsByCustomerIndex = "customerId-status-index"
+)
+
+type DynamoReservationRepository struct {
+	client           *dynamodb.Client
+	tableName        string
+	productsTable    string
+	allocationsTable string
+}
+
+func NewDynamoReservationRepository(client *dynamodb.Client, tableName string, productsTable string, allocationsTable string) *DynamoReservationRepository {
+	return &DynamoReservationRepository{
+		client:           client,
+		tableName:        tableName,
+		productsTable:    productsTable,
+		allocationsTable: allocationsTable,
+	}
+}
+
+// reservedLevels is the part of a product a reservation reads and writes.
+type reservedLevels struct {
+	Status   domain.ProductStatus `dynamodbav:"status"`
+	Stock    int                  `dynamodbav:"stock"`
+	Reserved int                  `dynamodbav:"reserved"`
+	Variants map[string]struct {
+		Stock    int `dynamodbav:"stock"`
+		Reserved int `dynamodbav:"reserved"`
+	} `dynamodbav:"variants"`
+}
+
+func (r *DynamoReservationRepository) Create(ctx context.Context, reservation *domain.Reservation) error {
+	ctx, span := tracing.NewSpan(ctx, "DynamoReservationRepository#Create")
+	defer span.End()
+	span.SetAttributes(
+		tracing.StringAttribute("reservationId", reservation.ID),
+		tracing.StringAttribute("productId", reservation.ProductID),
+		tracing.StringAttribute("sku", reservation.SKU),
+		tracing.IntAttribute("quantity", reservation.Quantity),
+	)
+
+	item, err := attributevalue.MarshalMap(reservation)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 0; attempt < maxStockWriteAttempts; attempt++ {
+		levels, err := r.getLevels(ctx, reservation.ProductID)
+		if err != nil {
+			span.RecordError(err)
+			return err
+		}
+		if levels.Status == domain.ProductArchived {
+			return ErrProductArchived
+		}
+
+		available := levels.Stock - levels.Reserved
+		if reservation.SKU != "" {
+			variant, ok := levels.Variants[reservation.SKU]
+			if !ok {
+				return ErrVariantNotFound
+			}
+			available = variant.Stock - variant.Reserved
+		}
+		if available < reservation.Quantity {
+			return ErrInsufficientStock
+		}
+
+		update := r.reservedUpdate(levels, reservation, reservation.Quantity)
+		update.ConditionExpression = aws.String(*update.ConditionExpression + " AND #stock = :stock")
+		update.ExpressionAttributeNames["#stock"] = "stock"
+		update.ExpressionAttributeValues[":stock"] = numberValue(levels.Stock)
+		if reservation.SKU != "" {
+			update.ConditionExpression = aws.String(*update.ConditionExpression + " AND #variants.#sku.#stock = :variantStock")
+			update.ExpressionAttributeValues[":variantStock"] = numberValue(levels.Variants[reservation.SKU].Stock)
+		}
+
+		_, err = r.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+			TransactItems: []types.TransactWriteItem{
+				{
+					Put: &types.Put{
+						TableName:           aws.String(r.tableName),
+						Item:                item,
+						ConditionExpression: aws.String("attribute_not_exists(id)"),
+					},
+				},
+				{Update: update},
+			},
+		})
+		if err == nil {
+			return nil
+		}
+		var canceled *types.TransactionCanceledException
+		if !errors.As(err, &canceled) || !stockConditionFailed(canceled) {
+			span.RecordError(err)
+			return err
+		}
+	}
+
+	err = fmt.Errorf("stock of product %s kept changing, gave up after %d attempts", reservation.ProductID, maxStockWriteAttempts)
+	span.RecordError(err)
+	return err
+}
+
+func (r *DynamoReservationRepository) GetByID(ctx context.Context, id string) (*domain.Reservation, error) {
+	ctx, span := tracing.NewSpan(ctx, "DynamoReservationRepository#GetByID")
+	defer span.End()
+	span.SetAttributes(
+		tracing.StringAttribute("reservationId", id),
+	)
+
+	output, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(output.Item) == 0 {
+		return nil, ErrReservationNotFound
+	}
+
+	var reservation domain.Reservation
+	err = attributevalue.UnmarshalMap(output.Item, &reservation)
+	return &reservation, err
+}
+
+func (r *DynamoReservationRepository) CountHeld(ctx context.Context, customerID string) (int, error) {
+	ctx, span := tracing.NewSpan(ctx, "DynamoReservationRepository#CountHeld")
+	defer span.End()
+	span.SetAttributes(
+		tracing.StringAttribute("customerId", customerID),
+	)
+
+	count := 0
+	for _, status := range []domain.ReservationStatus{domain.ReservationHeld, domain.ReservationConfirmed} {
+		paginator := dynamodb.NewQueryPaginator(r.client, &dynamodb.QueryInput{
+			TableName:              aws.String(r.tableName),
+			IndexName:              aws.String(reservationsByCustomerIndex),
+			KeyConditionExpression: aws.String("#customerId = :customerId AND #status = :status"),
+			ExpressionAttributeNames: map[string]string{
+				"#customerId": "customerId",
+				"#status":     "status",
+			},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":customerId": &types.AttributeValueMemberS{Value: customerID},
+				":status":     &types.AttributeValueMemberS{Value: string(status)},
+			},
+			Select: types.SelectCount,
+		})
+		for paginator.HasMorePages() {
+			output, err := paginator.NextPage(ctx)
+			if err != nil {
+				span.RecordError(err)
+				return 0, err
+			}
+			count += int(output.Count)
+		}
+	}
+	return count, nil
+}
+
+// Confirm marks the reservation confirmed and adds it to the holds of the
+// order allocation in one transaction, on the condition that the
+// products-worker has not allocated the order yet and that the holds did
+// not change since they were counted against ordered. The worker takes the
+// stock and the hold together when it allocates the order. When it already
+// has, Settle gives the hold back instead.
+func (r *DynamoReservationRepository) Confirm(ctx context.Context, id string, orderID string, ordered int, expiresAt time.Time) (*domain.Reservation, error) {
+	ctx, span := tracing.NewSpan(ctx, "DynamoReservationRepository#Confirm")
+	defer span.End()
+	span.SetAttributes(
+		tracing.StringAttribute("reservationId", id),
+		tracing.StringAttribute("orderId", orderID),
+	)
+
+	reservation, err := r.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if reservation.Status != domain.ReservationHeld {
+		return nil, ErrReservationNotHeld
+	}
+
+	reservation.Status = domain.ReservationConfirmed
+	reservation.OrderID = orderID
+	reservation.ExpiresAt = expiresAt.UTC().Format(time.RFC3339)
+	reservation.UpdatedAt = time.Now().UTC().Format(time.RFC3339Nano)
+
+	hold, err := attributevalue.Marshal(domain.AllocationHold{
+		ReservationID: reservation.ID,
+		ProductID:     reservation.ProductID,
+		SKU:           reservation.SKU,
+		Quantity:      reservation.Quantity,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; attempt < maxStockWriteAttempts; attempt++ {
+		allocation, err := r.getAllocation(ctx, orderID)
+		if err != nil {
+			span.RecordError(err)
+			return nil, err
+		}
+		if allocation.Strategy != "" {
+			// The order was allocated and its stock taken already.
+			return r.Settle(ctx, id, domain.ReservationFulfilled, orderID)
+		}
+
+		held := 0
+		for _, h := range allocation.Holds {
+			if h.ProductID == reservation.ProductID && h.SKU == reservation.SKU {
+				held += h.Quantity
+			}
+		}
+		if held+reservation.Quantity > ordered {
+			return nil, fmt.Errorf("%w: the order has %d, reservations hold %d already", ErrOrderQuantityExceeded, ordered, held)
+		}
+
+		holds := r.holdsUpdate(orderID, allocation.HoldsVersion)
+		holds.UpdateExpression = aws.String("SET #holds = list_append(if_not_exists(#holds, :noHolds), :hold) " + *holds.UpdateExpression)
+		holds.ExpressionAttributeNames["#holds"] = "holds"
+		holds.ExpressionAttributeValues[":noHolds"] = &types.AttributeValueMemberL{Value: []types.AttributeValue{}}
+		holds.ExpressionAttributeValues[":hold"] = &types.AttributeValueMemberL{Value: []types.AttributeValue{hold}}
+
+		_, err = r.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+			TransactItems: []types.TransactWriteItem{
+				{
+					Update: &types.Update{
+						TableName: aws.String(r.tableName),
+						Key: map[string]types.AttributeValue{
+							"id": &types.AttributeValueMemberS{Value: id},
+						},
+						UpdateExpression:    aws.String("SET #status = :status, #orderId = :orderId, #expiresAt = :expiresAt, #updatedAt = :updatedAt"),
+						ConditionExpression: aws.String("#status = :held"),
+						ExpressionAttributeNames: map[string]string{
+							"#status":    "status",
+							"#orderId":   "orderId",
+							"#expiresAt": "expiresAt",
+							"#updatedAt": "updatedAt",
+						},
+						ExpressionAttributeValues: map[string]types.AttributeValue{
+							":status":    &types.AttributeValueMemberS{Value: string(domain.ReservationConfirmed)},
+							":orderId":   &types.AttributeValueMemberS{Value: orderID},
+							":expiresAt": &types.AttributeValueMemberS{Value: reservation.ExpiresAt},
+							":updatedAt": &types.AttributeValueMemberS{Value: reservation.UpdatedAt},
+							":held":      &types.AttributeValueMemberS{Value: string(domain.ReservationHeld)},
+						},
+					},
+				},
+				{Update: holds},
+			},
+		})
+		if err == nil {
+			return reservation, nil
+		}
+
+		var canceled *types.TransactionCanceledException
+		if !errors.As(err, &canceled) {
+			span.RecordError(err)
+			return nil, err
+		}
+		reasons := canceled.CancellationReasons
+		if len(reasons) > 0 && aws.ToString(reasons[0].Code) == "ConditionalCheckFailed" {
+			return nil, ErrReservationNotHeld
+		}
+		if len(reasons) < 2 || aws.ToString(reasons[1].Code) != "ConditionalCheckFailed" {
+			span.RecordError(err)
+			return nil, err
+		}
+		// The order was allocated, or its holds changed, meanwhile.
+	}
+
+	err = fmt.Errorf("holds of order %s kept changing, gave up after %d attempts", orderID, maxStockWriteAttempts)
+	span.RecordError(err)
+	return nil, err
+}
+
+// ExpireConfirmed removes the hold from the order allocation, marks the
+// reservation expired and gives the held quantity back to the product in
+// one transaction, on the condition that the products-worker has not
+// allocated the order yet. Once it has, it took the stock and the hold
+// together, and released what the order did not take.
+func (r *DynamoReservationRepository) ExpireConfirmed(ctx context.Context, id string) (*domain.Reservation, error) {
+	ctx, span := tracing.NewSpan(ctx, "DynamoReservationRepository#ExpireConfirmed")
+	defer span.End()
+	span.SetAttributes(
+		tracing.StringAttribute("reservationId", id),
+	)
+
+	reservation, err := r.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if reservation.Status != domain.ReservationConfirmed {
+		return nil, ErrReservationNotHeld
+	}
+	span.SetAttributes(
+		tracing.StringAttribute("orderId", reservation.OrderID),
+	)
+	reservation.UpdatedAt = time.Now().UTC().Format(time.RFC3339Nano)
+
+	for attempt := 0; attempt < maxStockWriteAttempts; attempt++ {
+		allocation, err := r.getAllocation(ctx, reservation.OrderID)
+		if err != nil {
+			span.RecordError(err)
+			return nil, err
+		}
+		index := slices.IndexFunc(allocation.Holds, func(h domain.AllocationHold) bool {
+			return h.ReservationID == id
+		})
+		if allocation.Strategy != "" || index < 0 {
+			return r.fulfill(ctx, reservation)
+		}
+
+		reservation.Status = domain.ReservationExpired
+		holds := r.holdsUpdate(reservation.OrderID, allocation.HoldsVersion)
+		holds.UpdateExpression = aws.String(fmt.Sprintf("REMOVE #holds[%d] ", index) + *holds.UpdateExpression)
+		holds.ConditionExpression = aws.String(*holds.ConditionExpression + fmt.Sprintf(" AND #holds[%d].#reservationId = :reservationId", index))
+		holds.ExpressionAttributeNames["#holds"] = "holds"
+		holds.ExpressionAttributeNames["#reservationId"] = "reservationId"
+		holds.ExpressionAttributeValues[":reservationId"] = &types.AttributeValueMemberS{Value: id}
+
+		items := []types.TransactWriteItem{
+			{Update: r.statusUpdate(reservation, domain.ReservationConfirmed)},
+			{Update: holds},
+		}
+		levels, err := r.getLevels(ctx, reservation.ProductID)
+		switch {
+		case errors.Is(err, ErrProductNotFound):
+			// Nothing left to give the hold back to.
+		case err != nil:
+			span.RecordError(err)
+			return nil, err
+		default:
+			if _, ok := levels.Variants[reservation.SKU]; reservation.SKU == "" || ok {
+				items = append(items, types.TransactWriteItem{
+					Update: r.reservedUpdate(levels, reservation, -reservation.Quantity),
+				})
+			}
+		}
+
+		_, err = r.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+			TransactItems: items,
+		})
+		if err == nil {
+			return reservation, nil
+		}
+
+		var canceled *types.TransactionCanceledException
+		if !errors.As(err, &canceled) {
+			span.RecordError(err)
+			return nil, err
+		}
+		reasons := canceled.CancellationReasons
+		if len(reasons) > 0 && aws.ToString(reasons[0].Code) == "ConditionalCheckFailed" {
+			return nil, ErrReservationNotHeld
+		}
+		// The order was allocated, its holds changed or the stock of the
+		// product changed meanwhile.
+		changed := slices.ContainsFunc(reasons[min(1, len(reasons)):], func(reason types.CancellationReason) bool {
+			return aws.ToString(reason.Code) == "ConditionalCheckFailed"
+		})
+		if !changed {
+			span.RecordError(err)
+			return nil, err
+		}
+	}
+
+	err = fmt.Errorf("order %s or product %s kept changing, gave up after %d attempts", reservation.OrderID, reservation.ProductID, maxStockWriteAttempts)
+	span.RecordError(err)
+	return nil, err
+}
+
+// fulfill marks a confirmed reservation fulfilled. Its hold is the
+// products-worker's to release, and the worker did when it processed the
+// order.
+func (r *DynamoReservationRepository) fulfill(ctx context.Context, reservation *domain.Reservation) (*domain.Reservation, error) {
+	reservation.Status = domain.ReservationFulfilled
+	update := r.statusUpdate(reservation, domain.ReservationConfirmed)
+	_, err := r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 update.TableName,
+		Key:                       update.Key,
+		UpdateExpression:          update.UpdateExpression,
+		ConditionExpression:       update.ConditionExpression,
+		ExpressionAttributeNames:  update.ExpressionAttributeNames,
+		ExpressionAttributeValues: update.ExpressionAttributeValues,
+	})
+	var conditionErr *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionErr) {
+		return nil, ErrReservationNotHeld
+	}
+	if err != nil {
+		return nil, err
+	}
+	return reservation, nil
+}
+
+// statusUpdate moves the reservation to its status, on the condition that
+// it is still in status from.
+func (r *DynamoReservationRepository) statusUpdate(reservation *domain.Reservation, from domain.ReservationStatus) *types.Update {
+	return &types.Update{
+		TableName: aws.String(r.tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: reservation.ID},
+		},
+		UpdateExpression:    aws.String("SET #status = :status, #updatedAt = :updatedAt"),
+		ConditionExpression: aws.String("#status = :from"),
+		ExpressionAttributeNames: map[string]string{
+			"#status":    "status",
+			"#updatedAt": "updatedAt",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":status":    &types.AttributeValueMemberS{Value: string(reservation.Status)},
+			":updatedAt": &types.AttributeValueMemberS{Value: reservation.UpdatedAt},
+			":from":      &types.AttributeValueMemberS{Value: string(from)},
+		},
+	}
+}
+
+// getAllocation returns the allocation of the order, which is empty until a
+// reservation is confirmed for it or the products-worker allocates it.
+func (r *DynamoReservationRepository) getAllocation(ctx context.Context, orderID string) (*domain.Allocation, error) {
+	output, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(r.allocationsTable),
+		Key: map[string]types.AttributeValue{
+			"orderId": &types.AttributeValueMemberS{Value: orderID},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var allocation domain.Allocation
+	err = attributevalue.UnmarshalMap(output.Item, &allocation)
+	return &allocation, err
+}
+
+// holdsUpdate counts a change to the holds of the order allocation, on the
+// condition that the products-worker has not allocated the order and that
+// the holds are still at version, as read. The caller adds the change.
+func (r *DynamoReservationRepository) holdsUpdate(orderID string, version int) *types.Update {
+	condition := "attribute_not_exists(#strategy) AND "
+	values := map[string]types.AttributeValue{":holdsStep": numberValue(1)}
+	if version == 0 {
+		condition += "attribute_not_exists(#holdsVersion)"
+	} else {
+		condition += "#holdsVersion = :holdsVersion"
+		values[":holdsVersion"] = numberValue(version)
+	}
+	return &types.Update{
+		TableName: aws.String(r.allocationsTable),
+		Key: map[string]types.AttributeValue{
+			"orderId": &types.AttributeValueMemberS{Value: orderID},
+		},
+		UpdateExpression:    aws.String("ADD #holdsVersion :holdsStep"),
+		ConditionExpression: aws.String(condition),
+		ExpressionAttributeNames: map[string]string{
+			"#strategy":     "strategy",
+			"#holdsVersion": "holdsVersion",
+		},
+		ExpressionAttributeValues: values,
+	}
+}
+
+// Settle updates the reservation, conditioned on it still being held, and
+// the reserved quantity of the product in one transaction, so a reservation
+// is given back exactly once even when the sweeper and a client race.
+func (r *DynamoReservationRepository) Settle(ctx context.Context, id string, status domain.ReservationStatus, orderID string) (*domain.Reservation, error) {
+	ctx, span := tracing.NewSpan(ctx, "DynamoReservationRepository#Settle")
+	defer span.End()
+	span.SetAttributes(
+		tracing.StringAttribute("reservationId", id),
+		tracing.StringAttribute("status", string(status)),
+	)
+
+	reservation, err := r.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if reservation.Status != domain.ReservationHeld {
+		return nil, ErrReservationNotHeld
+	}
+
+	reservation.Status = status
+	reservation.OrderID = orderID
+	reservation.UpdatedAt = time.Now().UTC().Format(time.RFC3339Nano)
+
+	settle := &types.Update{
+		TableName: aws.String(r.tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+		UpdateExpression:    aws.String("SET #status = :status, #updatedAt = :updatedAt"),
+		ConditionExpression: aws.String("#status = :held"),
+		ExpressionAttributeNames: map[string]string{
+			"#status":    "status",
+			"#updatedAt": "updatedAt",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":status":    &types.AttributeValueMemberS{Value: string(status)},
+			":updatedAt": &types.AttributeValueMemberS{Value: reservation.UpdatedAt},
+			":held":      &types.AttributeValueMemberS{Value: string(domain.ReservationHeld)},
+		},
+	}
+	if orderID != "" {
+		settle.UpdateExpression = aws.String(*settle.UpdateExpression + ", #orderId = :orderId")
+		settle.ExpressionAttributeNames["#orderId"] = "orderId"
+		settle.ExpressionAttributeValues[":orderId"] = &types.AttributeValueMemberS{Value: orderID}
+	}
+
+	for attempt := 0; attempt < maxStockWriteAttempts; attempt++ {
+		items := []types.TransactWriteItem{{Update: settle}}
+
+		levels, err := r.getLevels(ctx, reservation.ProductID)
+		switch {
+		case errors.Is(err, ErrProductNotFound):
+			// Nothing left to give the hold back to.
+		case err != nil:
+			span.RecordError(err)
+			return nil, err
+		default:
+			if _, ok := levels.Variants[reservation.SKU]; reservation.SKU == "" || ok {
+				items = append(items, types.TransactWriteItem{
+					Update: r.reservedUpdate(levels, reservation, -reservation.Quantity),
+				})
+			}
+		}
+
+		_, err = r.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+			TransactItems: items,
+		})
+		if err == nil {
+			return reservation, nil
+		}
+
+		var canceled *types.TransactionCanceledException
+		if !errors.As(err, &canceled) {
+			span.RecordError(err)
+			return nil, err
+		}
+		reasons := canceled.CancellationReasons
+		if len(reasons) > 0 && aws.ToString(reasons[0].Code) == "ConditionalCheckFailed" {
+			return nil, ErrReservationNotHeld
+		}
+		if !stockConditionFailed(canceled) {
+			span.RecordError(err)
+			return nil, err
+		}
+	}
+
+	err = fmt.Errorf("stock of product %s kept changing, gave up after %d attempts", reservation.ProductID, maxStockWriteAttempts)
+	span.RecordError(err)
+	return nil, err
+}
+
+func (r *DynamoReservationRepository) ListExpired(ctx context.Context, status domain.ReservationStatus, now time.Time, limit int) ([]domain.Reservation, error) {
+	ctx, span := tracing.NewSpan(ctx, "DynamoReservationRepository#ListExpired")
+	defer span.End()
+	span.SetAttributes(
+		tracing.StringAttribute("status", string(status)),
+	)
+
+	output, err := r.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		IndexName:              aws.String(reservationsByExpiryIndex),
+		KeyConditionExpression: aws.String("#status = :status AND #expiresAt <= :now"),
+		ExpressionAttributeNames: map[string]string{
+			"#status":    "status",
+			"#expiresAt": "expiresAt",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":status": &types.AttributeValueMemberS{Value: string(status)},
+			":now":    &types.AttributeValueMemberS{Value: now.UTC().Format(time.RFC3339)},
+		},
+		Limit: aws.Int32(int32(limit)),
+	})
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	var reservations []domain.Reservation
+	err = attributevalue.UnmarshalListOfMaps(output.Items, &reservations)
+	return reservations, err
+}
+
+func (r *DynamoReservationRepository) getLevels(ctx context.Context, productID string) (*reservedLevels, error) {
+	output, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(r.productsTable),
+		Key:            productKey(productID),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(output.Item) == 0 {
+		return nil, ErrProductNotFound
+	}
+
+	var levels reservedLevels
+	err = attributevalue.UnmarshalMap(output.Item, &levels)
+	return &levels, err
+}
+
+// reservedUpdate changes the reserved quantity of the product, and of the
+// variant when the reservation is for one, by delta. Each level is written
+// on the condition that it still holds the value that was read.
+func (r *DynamoReservationRepository) reservedUpdate(levels *reservedLevels, reservation *domain.Reservation, delta int) *types.Update {
+	names := map[string]string{"#reserved": "reserved"}
+	values := map[string]types.AttributeValue{
+		":reserved": numberValue(max(levels.Reserved+delta, 0)),
+	}
+	set := "SET #reserved = :reserved"
+	condition := reservedCondition("#reserved", ":currentReserved", levels.Reserved, values)
+
+	if reservation.SKU != "" {
+		variantReserved := levels.Variants[reservation.SKU].Reserved
+		names["#variants"] = "variants"
+		names["#sku"] = reservation.SKU
+		values[":variantReserved"] = numberValue(max(variantReserved+delta, 0))
+		set += ", #variants.#sku.#reserved = :variantReserved"
+		condition += " AND " + reservedCondition("#variants.#sku.#reserved", ":currentVariantReserved", variantReserved, values)
+	}
+
+	return &types.Update{
+		TableName:                 aws.String(r.productsTable),
+		Key:                       productKey(reservation.ProductID),
+		UpdateExpression:          aws.String(set + addVersion(names, values)),
+		ConditionExpression:       aws.String(condition),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+	}
+}
+
+// reservedCondition checks that path still holds current. Products written
+// before reservations existed have no reserved attribute, which reads as 0.
+func reservedCondition(path, placeholder string, current int, values map[string]types.AttributeValue) string {
+	values[placeholder] = numberValue(current)
+	if current == 0 {
+		return "(attribute_not_exists(" + path + ") OR " + path + " = " + placeholder + ")"
+	}
+	return path + " = " + placeholder
+}
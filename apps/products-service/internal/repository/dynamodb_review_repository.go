@@ -0,0 +1,291 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"products-service/internal/domain"
+	"telemetry/tracing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	// reviewsByDateIndex is the LSI on (productId, createdAt) used to list
+	// the reviews of a product.
+	reviewsByDateIndex = "createdAt-index"
+	// reviewsByStatusIndex is the GSI on (status, createdAt) the moderation
+	// queue is read from.
+	reviewsByStatusIndex = "status-createdAt-index"
+)
+
+type DynamoReviewRepository struct {
+	client        *dynamodb.Client
+	tableName     string
+	productsTable string
+}
+
+func NewDynamoReviewRepository(client *dynamodb.Client, tableName string, productsTable string) *DynamoReviewRepository {
+	return &DynamoReviewRepository{
+		client:        client,
+		tableName:     tableName,
+		productsTable: productsTable,
+	}
+}
+
+func (r *DynamoReviewRepository) Create(ctx context.Context, review *domain.Review) error {
+	ctx, span := tracing.NewSpan(ctx, "DynamoReviewRepository#Create")
+	defer span.End()
+	span.SetAttributes(
+		tracing.StringAttribute("productId", review.ProductID),
+		tracing.StringAttribute("customerId", review.CustomerID),
+	)
+
+	item, err := attributevalue.MarshalMap(review)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(r.tableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(customerId)"),
+	})
+	var conditionErr *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionErr) {
+		return ErrReviewExists
+	}
+	if err != nil {
+		span.RecordError(err)
+	}
+	return err
+}
+
+func (r *DynamoReviewRepository) Get(ctx context.Context, productID, customerID string) (*domain.Review, error) {
+	ctx, span := tracing.NewSpan(ctx, "DynamoReviewRepository#Get")
+	defer span.End()
+	span.SetAttributes(
+		tracing.StringAttribute("productId", productID),
+		tracing.StringAttribute("customerId", customerID),
+	)
+
+	output, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(r.tableName),
+		Key:            reviewKey(productID, customerID),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	if len(output.Item) == 0 {
+		return nil, ErrReviewNotFound
+	}
+
+	var review domain.Review
+	err = attributevalue.UnmarshalMap(output.Item, &review)
+	return &review, err
+}
+
+func (r *DynamoReviewRepository) ListByProduct(ctx context.Context, productID string, status domain.ReviewStatus, limit int, cursor string) ([]domain.Review, string, error) {
+	ctx, span := tracing.NewSpan(ctx, "DynamoReviewRepository#ListByProduct")
+	defer span.End()
+	span.SetAttributes(
+		tracing.StringAttribute("productId", productID),
+		tracing.StringAttribute("status", string(status)),
+	)
+
+	startKey, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		IndexName:              aws.String(reviewsByDateIndex),
+		KeyConditionExpression: aws.String("productId = :productId"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":productId": &types.AttributeValueMemberS{Value: productID},
+		},
+		ScanIndexForward:  aws.Bool(false),
+		Limit:             aws.Int32(int32(limit)),
+		ExclusiveStartKey: startKey,
+	}
+	if status != "" {
+		input.FilterExpression = aws.String("#status = :status")
+		input.ExpressionAttributeNames = map[string]string{"#status": "status"}
+		input.ExpressionAttributeValues[":status"] = &types.AttributeValueMemberS{Value: string(status)}
+	}
+
+	return r.query(ctx, input)
+}
+
+func (r *DynamoReviewRepository) ListByStatus(ctx context.Context, status domain.ReviewStatus, limit int, cursor string) ([]domain.Review, string, error) {
+	ctx, span := tracing.NewSpan(ctx, "DynamoReviewRepository#ListByStatus")
+	defer span.End()
+	span.SetAttributes(
+		tracing.StringAttribute("status", string(status)),
+	)
+
+	startKey, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return r.query(ctx, &dynamodb.QueryInput{
+		TableName:                aws.String(r.tableName),
+		IndexName:                aws.String(reviewsByStatusIndex),
+		KeyConditionExpression:   aws.String("#status = :status"),
+		ExpressionAttributeNames: map[string]string{"#status": "status"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":status": &types.AttributeValueMemberS{Value: string(status)},
+		},
+		Limit:             aws.Int32(int32(limit)),
+		ExclusiveStartKey: startKey,
+	})
+}
+
+func (r *DynamoReviewRepository) Moderate(ctx context.Context, review *domain.Review, from domain.ReviewStatus) error {
+	ctx, span := tracing.NewSpan(ctx, "DynamoReviewRepository#Moderate")
+	defer span.End()
+	span.SetAttributes(
+		tracing.StringAttribute("productId", review.ProductID),
+		tracing.StringAttribute("customerId", review.CustomerID),
+		tracing.StringAttribute("from", string(from)),
+		tracing.StringAttribute("status", string(review.Status)),
+	)
+
+	items := []types.TransactWriteItem{
+		{
+			Update: &types.Update{
+				TableName:           aws.String(r.tableName),
+				Key:                 reviewKey(review.ProductID, review.CustomerID),
+				UpdateExpression:    aws.String("SET #status = :status, moderatedBy = :moderatedBy, moderationNote = :moderationNote, moderatedAt = :moderatedAt"),
+				ConditionExpression: aws.String("#status = :from"),
+				ExpressionAttributeNames: map[string]string{
+					"#status": "status",
+				},
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":status":         &types.AttributeValueMemberS{Value: string(review.Status)},
+					":moderatedBy":    &types.AttributeValueMemberS{Value: review.ModeratedBy},
+					":moderationNote": &types.AttributeValueMemberS{Value: review.ModerationNote},
+					":moderatedAt":    &types.AttributeValueMemberS{Value: review.ModeratedAt},
+					":from":           &types.AttributeValueMemberS{Value: string(from)},
+				},
+			},
+		},
+	}
+	switch {
+	case from != domain.ReviewApproved && review.Status == domain.ReviewApproved:
+		items = append(items, r.ratingUpdate(review.ProductID, 1, review.Rating))
+	case from == domain.ReviewApproved && review.Status != domain.ReviewApproved:
+		items = append(items, r.ratingUpdate(review.ProductID, -1, -review.Rating))
+	}
+
+	return r.write(ctx, items)
+}
+
+func (r *DynamoReviewRepository) Delete(ctx context.Context, review *domain.Review) error {
+	ctx, span := tracing.NewSpan(ctx, "DynamoReviewRepository#Delete")
+	defer span.End()
+	span.SetAttributes(
+		tracing.StringAttribute("productId", review.ProductID),
+		tracing.StringAttribute("customerId", review.CustomerID),
+	)
+
+	items := []types.TransactWriteItem{
+		{
+			Delete: &types.Delete{
+				TableName:                aws.String(r.tableName),
+				Key:                      reviewKey(review.ProductID, review.CustomerID),
+				ConditionExpression:      aws.String("#status = :status"),
+				ExpressionAttributeNames: map[string]string{"#status": "status"},
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":status": &types.AttributeValueMemberS{Value: string(review.Status)},
+				},
+			},
+		},
+	}
+	if review.Status == domain.ReviewApproved {
+		items = append(items, r.ratingUpdate(review.ProductID, -1, -review.Rating))
+	}
+
+	err := r.write(ctx, items)
+	if errors.Is(err, ErrProductNotFound) {
+		// There is no rating left to keep in step with.
+		return r.write(ctx, items[:1])
+	}
+	return err
+}
+
+// ratingUpdate adds count reviews and rating stars to the aggregate rating
+// of the product. The product must exist, or ADD would create it.
+func (r *DynamoReviewRepository) ratingUpdate(productID string, count, rating int) types.TransactWriteItem {
+	return types.TransactWriteItem{
+		Update: &types.Update{
+			TableName:           aws.String(r.productsTable),
+			Key:                 productKey(productID),
+			UpdateExpression:    aws.String("ADD reviewCount :count, ratingTotal :rating, #version :versionStep"),
+			ConditionExpression: aws.String("attribute_exists(id)"),
+			ExpressionAttributeNames: map[string]string{
+				"#version": versionAttribute,
+			},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":count":       numberValue(count),
+				":rating":      numberValue(rating),
+				":versionStep": numberValue(1),
+			},
+		},
+	}
+}
+
+// write runs a review write, and the rating update that goes with it, as
+// one transaction.
+func (r *DynamoReviewRepository) write(ctx context.Context, items []types.TransactWriteItem) error {
+	span := tracing.SpanFromContext(ctx)
+
+	_, err := r.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: items,
+	})
+	var canceled *types.TransactionCanceledException
+	if errors.As(err, &canceled) {
+		reasons := canceled.CancellationReasons
+		if len(reasons) > 0 && aws.ToString(reasons[0].Code) == "ConditionalCheckFailed" {
+			return ErrReviewModerated
+		}
+		if len(reasons) > 1 && aws.ToString(reasons[1].Code) == "ConditionalCheckFailed" {
+			return ErrProductNotFound
+		}
+	}
+	if err != nil {
+		span.RecordError(err)
+	}
+	return err
+}
+
+func (r *DynamoReviewRepository) query(ctx context.Context, input *dynamodb.QueryInput) ([]domain.Review, string, error) {
+	span := tracing.SpanFromContext(ctx)
+
+	output, err := r.client.Query(ctx, input)
+	if err != nil {
+		span.RecordError(err)
+		return nil, "", err
+	}
+
+	var reviews []domain.Review
+	if err := attributevalue.UnmarshalListOfMaps(output.Items, &reviews); err != nil {
+		return nil, "", err
+	}
+
+	next, err := encodeCursor(output.LastEvaluatedKey)
+	return reviews, next, err
+}
+
+func reviewKey(productID, customerID string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"productId":  &types.AttributeValueMemberS{Value: productID},
+		"customerId": &types.AttributeValueMemberS{Value: customerID},
+	}
+}
@@ -0,0 +1,242 @@
+package repository
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"products-service/internal/domain"
+	"telemetry/tracing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// movementsByDateIndex is the LSI on (productId, createdAt) used to list the
+// movements of a product in chronological order.
+const movementsByDateIndex = "createdAt-index"
+
+// maxStockWriteAttempts bounds the optimistic retries when the stock changes
+// between reading it and writing the movement.
+const maxStockWriteAttempts = 5
+
+type DynamoStockMovementRepository struct {
+	client        *dynamodb.Client
+	tableName     string
+	productsTable string
+}
+
+func NewDynamoStockMovementRepository(client *dynamodb.Client, tableName string, productsTable string) *DynamoStockMovementRepository {
+	return &DynamoStockMovementRepository{
+		client:        client,
+		tableName:     tableName,
+		productsTable: productsTable,
+	}
+}
+
+func (r *DynamoStockMovementRepository) Adjust(ctx context.Context, movement *domain.StockMovement) (*domain.StockChange, error) {
+	ctx, span := tracing.NewSpan(ctx, "DynamoStockMovementRepository#Adjust")
+	defer span.End()
+
+	delta := movement.Delta
+	return r.apply(ctx, movement, func(int) int { return delta })
+}
+
+func (r *DynamoStockMovementRepository) Recount(ctx context.Context, movement *domain.StockMovement, quantity int) (*domain.StockChange, error) {
+	ctx, span := tracing.NewSpan(ctx, "DynamoStockMovementRepository#Recount")
+	defer span.End()
+
+	return r.apply(ctx, movement, func(current int) int { return quantity - current })
+}
+
+// apply reads the current stock, computes the delta and writes the new stock
+// together with the movement in one transaction, returning how the product
+// stock changed. The stock update is conditioned on the value that was
+// read, so concurrent writers retry instead of overwriting each other.
+func (r *DynamoStockMovementRepository) apply(ctx context.Context, movement *domain.StockMovement, delta func(current int) int) (*domain.StockChange, error) {
+	span := tracing.SpanFromContext(ctx)
+	span.SetAttributes(
+		tracing.StringAttribute("productId", movement.ProductID),
+		tracing.StringAttribute("sku", movement.SKU),
+		tracing.StringAttribute("warehouseId", movement.WarehouseID),
+		tracing.StringAttribute("reason", string(movement.Reason)),
+	)
+
+	for attempt := 0; attempt < maxStockWriteAttempts; attempt++ {
+		output, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+			TableName:      aws.String(r.productsTable),
+			Key:            productKey(movement.ProductID),
+			ConsistentRead: aws.Bool(true),
+		})
+		if err != nil {
+			span.RecordError(err)
+			return nil, err
+		}
+		if len(output.Item) == 0 {
+			return nil, ErrProductNotFound
+		}
+
+		var product domain.Product
+		if err := attributevalue.UnmarshalMap(output.Item, &product); err != nil {
+			return nil, err
+		}
+		if product.IsArchived() {
+			return nil, ErrProductArchived
+		}
+
+		update, err := newStockUpdate(&product, movement, delta)
+		if err != nil {
+			return nil, err
+		}
+		if movement.Balance < 0 {
+			return nil, ErrInsufficientStock
+		}
+
+		item, err := attributevalue.MarshalMap(movement)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = r.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+			TransactItems: []types.TransactWriteItem{
+				{
+					Put: &types.Put{
+						TableName:           aws.String(r.tableName),
+						Item:                item,
+						ConditionExpression: aws.String("attribute_not_exists(id)"),
+					},
+				},
+				{Update: update.build(r.productsTable, productKey(movement.ProductID))},
+			},
+		})
+		if err == nil {
+			return &domain.StockChange{
+				ProductID:        product.ID,
+				Name:             product.Name,
+				Before:           product.Stock,
+				After:            product.Stock + movement.Delta,
+				ReorderThreshold: product.ReorderThreshold,
+			}, nil
+		}
+
+		var canceled *types.TransactionCanceledException
+		if !errors.As(err, &canceled) || !stockConditionFailed(canceled) {
+			span.RecordError(err)
+			return nil, err
+		}
+	}
+
+	err := fmt.Errorf("stock of product %s kept changing, gave up after %d attempts", movement.ProductID, maxStockWriteAttempts)
+	span.RecordError(err)
+	return nil, err
+}
+
+// stockConditionFailed reports whether the transaction was canceled because
+// the stock changed after it was read (the second item of the transaction).
+func stockConditionFailed(canceled *types.TransactionCanceledException) bool {
+	reasons := canceled.CancellationReasons
+	return len(reasons) > 1 && aws.ToString(reasons[1].Code) == "ConditionalCheckFailed"
+}
+
+func (r *DynamoStockMovementRepository) Record(ctx context.Context, movement *domain.StockMovement) error {
+	ctx, span := tracing.NewSpan(ctx, "DynamoStockMovementRepository#Record")
+	defer span.End()
+	span.SetAttributes(
+		tracing.StringAttribute("productId", movement.ProductID),
+		tracing.StringAttribute("reason", string(movement.Reason)),
+	)
+
+	item, err := attributevalue.MarshalMap(movement)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(r.tableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(id)"),
+	})
+	return err
+}
+
+func (r *DynamoStockMovementRepository) ListByProduct(ctx context.Context, productID string, limit int, cursor string) ([]domain.StockMovement, string, error) {
+	ctx, span := tracing.NewSpan(ctx, "DynamoStockMovementRepository#ListByProduct")
+	defer span.End()
+	span.SetAttributes(
+		tracing.StringAttribute("productId", productID),
+	)
+
+	startKey, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	output, err := r.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		IndexName:              aws.String(movementsByDateIndex),
+		KeyConditionExpression: aws.String("productId = :productId"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":productId": &types.AttributeValueMemberS{Value: productID},
+		},
+		ScanIndexForward:  aws.Bool(false),
+		Limit:             aws.Int32(int32(limit)),
+		ExclusiveStartKey: startKey,
+	})
+	if err != nil {
+		span.RecordError(err)
+		return nil, "", err
+	}
+
+	var movements []domain.StockMovement
+	if err := attributevalue.UnmarshalListOfMaps(output.Items, &movements); err != nil {
+		return nil, "", err
+	}
+
+	next, err := encodeCursor(output.LastEvaluatedKey)
+	return movements, next, err
+}
+
+func productKey(id string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"id": &types.AttributeValueMemberS{Value: id},
+	}
+}
+
+func numberValue(n int) types.AttributeValue {
+	return &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", n)}
+}
+
+// encodeCursor turns a LastEvaluatedKey made of string attributes into an
+// opaque pagination cursor.
+func encodeCursor(key map[string]types.AttributeValue) (string, error) {
+	if len(key) == 0 {
+		return "", nil
+	}
+	plain := make(map[string]string, len(key))
+	if err := attributevalue.UnmarshalMap(key, &plain); err != nil {
+		return "", err
+	}
+	raw, err := json.Marshal(plain)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func decodeCursor(cursor string) (map[string]types.AttributeValue, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+	var plain map[string]string
+	if err := json.Unmarshal(raw, &plain); err != nil {
+		return nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+	return attributevalue.MarshalMap(plain)
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"products-service/internal/domain"
+	"telemetry/tracing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+type DynamoWarehouseRepository struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+func NewDynamoWarehouseRepository(client *dynamodb.Client, tableName string) *DynamoWarehouseRepository {
+	return &DynamoWarehouseRepository{
+		client:    client,
+		tableName: tableName,
+	}
+}
+
+func (r *DynamoWarehouseRepository) Create(ctx context.Context, warehouse *domain.Warehouse) error {
+	ctx, span := tracing.NewSpan(ctx, "DynamoWarehouseRepository#Create")
+	defer span.End()
+	span.SetAttributes(
+		tracing.StringAttribute("warehouseId", warehouse.ID),
+	)
+
+	item, err := attributevalue.MarshalMap(warehouse)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item:      item,
+	})
+	return err
+}
+
+func (r *DynamoWarehouseRepository) GetAll(ctx context.Context) ([]domain.Warehouse, error) {
+	ctx, span := tracing.NewSpan(ctx, "DynamoWarehouseRepository#GetAll")
+	defer span.End()
+
+	var warehouses []domain.Warehouse
+	paginator := dynamodb.NewScanPaginator(r.client, &dynamodb.ScanInput{
+		TableName: aws.String(r.tableName),
+	})
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		var page []domain.Warehouse
+		if err := attributevalue.UnmarshalListOfMaps(output.Items, &page); err != nil {
+			return nil, err
+		}
+		warehouses = append(warehouses, page...)
+	}
+	return warehouses, nil
+}
+
+func (r *DynamoWarehouseRepository) GetByID(ctx context.Context, id string) (*domain.Warehouse, error) {
+	ctx, span := tracing.NewSpan(ctx, "DynamoWarehouseRepository#GetByID")
+	defer span.End()
+	span.SetAttributes(
+		tracing.StringAttribute("warehouseId", id),
+	)
+
+	output, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(output.Item) == 0 {
+		return nil, errors.New("warehouse not found")
+	}
+
+	var warehouse domain.Warehouse
+	err = attributevalue.UnmarshalMap(output.Item, &warehouse)
+	return &warehouse, err
+}
+
+func (r *DynamoWarehouseRepository) Update(ctx context.Context, warehouse *domain.Warehouse) error {
+	ctx, span := tracing.NewSpan(ctx, "DynamoWarehouseRepository#Update")
+	defer span.End()
+
+	return r.Create(ctx, warehouse)
+}
+
+func (r *DynamoWarehouseRepository) Delete(ctx context.Context, id string) error {
+	ctx, span := tracing.NewSpan(ctx, "DynamoWarehouseRepository#Delete")
+	defer span.End()
+	span.SetAttributes(
+		tracing.StringAttribute("warehouseId", id),
+	)
+
+	_, err := r.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+	})
+	return err
+}
@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"products-service/internal/domain"
+	"time"
+)
+
+var (
+	ErrPriceChangeNotFound     = errors.New("price change not found")
+	ErrPriceChangeNotScheduled = errors.New("price change is no longer scheduled")
+)
+
+type PriceChangeRepository interface {
+	Create(ctx context.Context, change *domain.PriceChange) error
+	GetByID(ctx context.Context, productID, id string) (*domain.PriceChange, error)
+	// ListByProduct returns the changes of a product, latest effective time
+	// first, optionally only those with status.
+	ListByProduct(ctx context.Context, productID string, status domain.PriceChangeStatus, limit int, cursor string) ([]domain.PriceChange, string, error)
+	// PriceAt returns the last change applied to the product that took
+	// effect at or before at, or ErrPriceChangeNotFound.
+	PriceAt(ctx context.Context, productID string, at time.Time) (*domain.PriceChange, error)
+	// ListDue returns scheduled changes that are effective at now, earliest
+	// first.
+	ListDue(ctx context.Context, now time.Time, limit int) ([]domain.PriceChange, error)
+	// Settle stores the new status, price, previous price, applied time and
+	// note of a scheduled change, failing with ErrPriceChangeNotScheduled when it was
+	// applied or canceled meanwhile.
+	Settle(ctx context.Context, change *domain.PriceChange) error
+	// Revoke cancels a change that was settled as applied but could not set
+	// the price after all, with a note saying why.
+	Revoke(ctx context.Context, change *domain.PriceChange, note string) error
+}
@@ -2,13 +2,45 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"products-service/internal/domain"
 )
 
+// ErrProductChanged is returned when a product is updated after something
+// else wrote it since it was read.
+var ErrProductChanged = errors.New("product was changed meanwhile")
+
 type ProductRepository interface {
 	Create(ctx context.Context, product *domain.Product) error
 	GetAll(ctx context.Context) ([]domain.Product, error)
 	GetByID(ctx context.Context, id string) (*domain.Product, error)
+	// GetByIDs returns the products that exist among ids, in no particular
+	// order.
+	GetByIDs(ctx context.Context, ids []string) ([]domain.Product, error)
+	// FindBySKUs returns the products whose catalog SKU is one of skus.
+	FindBySKUs(ctx context.Context, skus []string) ([]domain.Product, error)
+	Find(ctx context.Context, filter domain.ProductFilter) ([]domain.Product, error)
+	// FindLowStock returns the products that ran out or are at or below
+	// their reorder threshold.
+	FindLowStock(ctx context.Context) ([]domain.Product, error)
+	// Update replaces the product on the condition that its version is
+	// still the one read, and fails with ErrProductChanged otherwise.
 	Update(ctx context.Context, product *domain.Product) error
+	// SetPrice changes only the price of the product, on the condition that
+	// it is still current, and returns the product as written. It fails
+	// with ErrProductChanged when the price is no longer current.
+	SetPrice(ctx context.Context, id string, price, current float64) (*domain.Product, error)
+	// PutBatch creates or replaces all the products, in as few round trips
+	// as DynamoDB allows. Like Update, a product is only replaced when its
+	// version is unchanged; a product without a version is only written
+	// when the stored one has none either. It returns the ids of the
+	// products that were changed meanwhile and not written.
+	PutBatch(ctx context.Context, products []domain.Product) ([]string, error)
+	// Walk hands every product to fn one page at a time, so the whole
+	// catalog never has to be held in memory. It stops at the first error fn
+	// returns.
+	Walk(ctx context.Context, fn func(page []domain.Product) error) error
+	// Delete removes the product for good. It returns ErrProductNotFound
+	// when there is nothing to delete.
 	Delete(ctx context.Context, id string) error
 }
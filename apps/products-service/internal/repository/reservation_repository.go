@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"products-service/internal/domain"
+	"time"
+)
+
+var (
+	ErrReservationNotFound = errors.New("reservation not found")
+	ErrReservationNotHeld  = errors.New("reservation is no longer held")
+	// ErrOrderQuantityExceeded is returned when the reservations confirmed
+	// for an order would hold more of a product than the order has.
+	ErrOrderQuantityExceeded = errors.New("reservations exceed the ordered quantity")
+)
+
+type ReservationRepository interface {
+	// Create holds the quantity on the product, failing with
+	// ErrInsufficientStock when less than that is available.
+	Create(ctx context.Context, reservation *domain.Reservation) error
+	GetByID(ctx context.Context, id string) (*domain.Reservation, error)
+	// CountHeld returns how many reservations of the customer hold stock,
+	// held or confirmed.
+	CountHeld(ctx context.Context, customerID string) (int, error)
+	// Confirm hands a held reservation over to the order until expiresAt.
+	// The quantity stays reserved until the products-worker takes the stock
+	// for the order, so nobody else can buy it in between. ordered is how
+	// much of the reserved product and SKU the order has, which the
+	// reservations confirmed for it cannot exceed. When the order was
+	// processed already the hold is given back and the reservation is
+	// fulfilled, as its stock was taken meanwhile.
+	Confirm(ctx context.Context, id string, orderID string, ordered int, expiresAt time.Time) (*domain.Reservation, error)
+	// Settle moves a held reservation to status (released, expired or
+	// fulfilled) and gives the held quantity back to the product.
+	Settle(ctx context.Context, id string, status domain.ReservationStatus, orderID string) (*domain.Reservation, error)
+	// ExpireConfirmed takes a confirmed reservation off its order and gives
+	// the held quantity back to the product, unless the products-worker
+	// processed the order meanwhile, which fulfills the reservation.
+	ExpireConfirmed(ctx context.Context, id string) (*domain.Reservation, error)
+	// ListExpired returns reservations in status, held or confirmed, that
+	// expired before now.
+	ListExpired(ctx context.Context, status domain.ReservationStatus, now time.Time, limit int) ([]domain.Reservation, error)
+}
@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"products-service/internal/domain"
+)
+
+var (
+	ErrReviewNotFound  = errors.New("review not found")
+	ErrReviewExists    = errors.New("customer already reviewed the product")
+	ErrReviewModerated = errors.New("review was moderated meanwhile")
+)
+
+// ReviewRepository stores the reviews of the products and keeps the review
+// count and rating total of the product in step with the approved ones.
+type ReviewRepository interface {
+	// Create stores a pending review, failing with ErrReviewExists when the
+	// customer already reviewed the product.
+	Create(ctx context.Context, review *domain.Review) error
+	Get(ctx context.Context, productID, customerID string) (*domain.Review, error)
+	// ListByProduct returns the reviews of a product, newest first,
+	// optionally only those with status.
+	ListByProduct(ctx context.Context, productID string, status domain.ReviewStatus, limit int, cursor string) ([]domain.Review, string, error)
+	// ListByStatus returns the reviews of every product with status, oldest
+	// first, which is the moderation queue for pending reviews.
+	ListByStatus(ctx context.Context, status domain.ReviewStatus, limit int, cursor string) ([]domain.Review, string, error)
+	// Moderate stores the status, moderator, note and moderation time of a
+	// review that was in status from, adding it to the rating of the
+	// product when it gets approved and taking it out when it no longer is.
+	// It fails with ErrReviewModerated when the status changed meanwhile and
+	// with ErrProductNotFound when the product is gone.
+	Moderate(ctx context.Context, review *domain.Review, from domain.ReviewStatus) error
+	// Delete removes a review, and from the rating of the product when it
+	// was approved. It fails with ErrReviewModerated when the status of the
+	// review changed since it was read.
+	Delete(ctx context.Context, review *domain.Review) error
+}
@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"products-service/internal/domain"
+)
+
+// ErrProductNotFound is returned when the product does not exist.
+var ErrProductNotFound = errors.New("product not found")
+
+// ErrProductArchived is returned when stock is moved or reserved for a
+// product that was archived.
+var ErrProductArchived = errors.New("product is archived")
+
+// ErrInsufficientStock is returned when a movement would leave a negative balance.
+var ErrInsufficientStock = errors.New("insufficient stock")
+
+// ErrVariantNotFound is returned when a movement references an unknown SKU.
+var ErrVariantNotFound = errors.New("variant not found")
+
+type StockMovementRepository interface {
+	// Adjust applies movement.Delta to the product (and variant) stock and
+	// records the movement in the same transaction, filling in Balance. It
+	// returns how the product stock changed.
+	Adjust(ctx context.Context, movement *domain.StockMovement) (*domain.StockChange, error)
+	// Recount sets the stock to quantity, recording the difference as Delta.
+	Recount(ctx context.Context, movement *domain.StockMovement, quantity int) (*domain.StockChange, error)
+	// Record stores a movement whose stock change was already written, such
+	// as the opening balance of a new product.
+	Record(ctx context.Context, movement *domain.StockMovement) error
+	// ListByProduct returns the movements of a product, newest first.
+	ListByProduct(ctx context.Context, productID string, limit int, cursor string) ([]domain.StockMovement, string, error)
+}
@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"errors"
+	"products-service/internal/domain"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ErrWarehouseRequired is returned when stock that is tracked per warehouse
+// is changed without saying which warehouse.
+var ErrWarehouseRequired = errors.New("warehouse is required for stock tracked per location")
+
+// ErrSKURequired is returned when the stock of a product with variants is
+// changed without saying which variant. The product stock is the sum of its
+// variants and cannot change on its own.
+var ErrSKURequired = errors.New("sku is required for products with variants")
+
+// stockUpdate builds a conditional update of the product stock for a
+// movement. Every level touched (product, variant, warehouse) is set to its
+// new value on the condition that it still holds the value that was read.
+type stockUpdate struct {
+	sets       []string
+	conditions []string
+	names      map[string]string
+	values     map[string]types.AttributeValue
+}
+
+// newStockUpdate resolves the stock level the movement applies to, fills in
+// the movement Delta and Balance and returns the matching update.
+func newStockUpdate(product *domain.Product, movement *domain.StockMovement, delta func(current int) int) (*stockUpdate, error) {
+	u := &stockUpdate{
+		names:  map[string]string{"#stock": "stock"},
+		values: map[string]types.AttributeValue{},
+	}
+
+	current := product.Stock
+	locations := product.Locations
+	locationsPath := "#locations"
+	if movement.SKU == "" && len(product.Variants) > 0 {
+		return nil, ErrSKURequired
+	}
+	if movement.SKU != "" {
+		variant, ok := product.Variants[movement.SKU]
+		if !ok {
+			return nil, ErrVariantNotFound
+		}
+		current = variant.Stock
+		locations = variant.Locations
+		locationsPath = "#variants.#sku.#locations"
+	}
+
+	if movement.WarehouseID == "" && len(locations) > 0 {
+		return nil, ErrWarehouseRequired
+	}
+	tracked := locations != nil
+	if movement.WarehouseID != "" {
+		if !tracked {
+			// The stock counted so far moves to the default location, or
+			// summing up the locations would lose it.
+			locations = map[string]int{}
+			if current != 0 {
+				locations[domain.DefaultLocation] = current
+			}
+		}
+		current = locations[movement.WarehouseID]
+	}
+
+	movement.Delta = delta(current)
+	movement.Balance = current + movement.Delta
+
+	u.set("#stock", ":stock", ":currentStock", product.Stock, product.Stock+movement.Delta, true)
+	u.clampReserved("#reserved", ":reserved", ":currentReserved", product.Reserved, product.Stock+movement.Delta)
+
+	if movement.SKU != "" {
+		u.names["#variants"] = "variants"
+		u.names["#sku"] = movement.SKU
+		variant := product.Variants[movement.SKU]
+		u.set("#variants.#sku.#stock", ":variantStock", ":currentVariantStock", variant.Stock, variant.Stock+movement.Delta, true)
+		u.clampReserved("#variants.#sku.#reserved", ":variantReserved", ":currentVariantReserved", variant.Reserved, variant.Stock+movement.Delta)
+	}
+
+	if movement.WarehouseID != "" {
+		u.names["#locations"] = "locations"
+		if !tracked {
+			// Stock was not tracked per location yet: create the map.
+			seeded := make(map[string]types.AttributeValue, len(locations)+1)
+			for warehouseID, stock := range locations {
+				seeded[warehouseID] = numberValue(stock)
+			}
+			seeded[movement.WarehouseID] = numberValue(movement.Balance)
+			u.sets = append(u.sets, locationsPath+" = :locations")
+			u.conditions = append(u.conditions, "attribute_not_exists("+locationsPath+")")
+			u.values[":locations"] = &types.AttributeValueMemberM{Value: seeded}
+		} else {
+			u.names["#warehouse"] = movement.WarehouseID
+			_, exists := locations[movement.WarehouseID]
+			u.set(locationsPath+".#warehouse", ":warehouseStock", ":currentWarehouseStock", current, movement.Balance, exists)
+		}
+	}
+
+	return u, nil
+}
+
+// set assigns next to path on the condition that it still holds current, or
+// does not exist yet when exists is false.
+func (u *stockUpdate) set(path, nextPlaceholder, currentPlaceholder string, current, next int, exists bool) {
+	u.sets = append(u.sets, path+" = "+nextPlaceholder)
+	u.values[nextPlaceholder] = numberValue(next)
+	if exists {
+		u.conditions = append(u.conditions, path+" = "+currentPlaceholder)
+		u.values[currentPlaceholder] = numberValue(current)
+	} else {
+		u.conditions = append(u.conditions, "attribute_not_exists("+path+")")
+	}
+}
+
+// clampReserved lowers the reserved quantity at path to the new stock when
+// the stock drops below it, so more than is on hand is never reserved.
+func (u *stockUpdate) clampReserved(path, nextPlaceholder, currentPlaceholder string, reserved, stock int) {
+	if reserved <= max(stock, 0) {
+		return
+	}
+	u.names["#reserved"] = "reserved"
+	u.set(path, nextPlaceholder, currentPlaceholder, reserved, max(stock, 0), true)
+}
+
+func (u *stockUpdate) build(tableName string, key map[string]types.AttributeValue) *types.Update {
+	return &types.Update{
+		TableName:                 aws.String(tableName),
+		Key:                       key,
+		UpdateExpression:          aws.String("SET " + strings.Join(u.sets, ", ") + addVersion(u.names, u.values)),
+		ConditionExpression:       aws.String(strings.Join(u.conditions, " AND ")),
+		ExpressionAttributeNames:  u.names,
+		ExpressionAttributeValues: u.values,
+	}
+}
@@ -0,0 +1,14 @@
+package repository
+
+import (
+	"context"
+	"products-service/internal/domain"
+)
+
+type WarehouseRepository interface {
+	Create(ctx context.Context, warehouse *domain.Warehouse) error
+	GetAll(ctx context.Context) ([]domain.Warehouse, error)
+	GetByID(ctx context.Context, id string) (*domain.Warehouse, error)
+	Update(ctx context.Context, warehouse *domain.Warehouse) error
+	Delete(ctx context.Context, id string) error
+}
@@ -0,0 +1,106 @@
+package reservations
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"products-service/internal/domain"
+	"products-service/internal/repository"
+	"telemetry/tracing"
+	"time"
+)
+
+const sweepBatchSize = 100
+
+// Sweeper releases reservations once they expire, giving the stock back to
+// other customers: held ones that were never confirmed, and confirmed ones
+// whose order the products-worker did not process in time.
+type Sweeper struct {
+	repo     repository.ReservationRepository
+	interval time.Duration
+}
+
+func NewSweeper(repo repository.ReservationRepository, interval time.Duration) *Sweeper {
+	return &Sweeper{repo: repo, interval: interval}
+}
+
+// Run sweeps every interval until ctx is done.
+func (s *Sweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			expired, err := s.Sweep(ctx)
+			if err != nil {
+				slog.ErrorContext(ctx, "failed to sweep expired reservations", "error", err)
+				continue
+			}
+			if expired > 0 {
+				slog.InfoContext(ctx, "released expired reservations", "reservations", expired)
+			}
+		}
+	}
+}
+
+// Sweep releases every reservation that is expired now and returns how many
+// it released.
+func (s *Sweeper) Sweep(ctx context.Context) (int, error) {
+	ctx, span := tracing.NewSpan(ctx, "Sweeper#Sweep")
+	defer span.End()
+
+	released := 0
+	for _, status := range []domain.ReservationStatus{domain.ReservationHeld, domain.ReservationConfirmed} {
+		n, err := s.sweep(ctx, status)
+		released += n
+		if err != nil {
+			span.RecordError(err)
+			return released, err
+		}
+	}
+	span.SetAttributes(
+		tracing.IntAttribute("released", released),
+	)
+	return released, nil
+}
+
+// sweep expires the reservations in status that ran out. A confirmed one
+// whose order was processed meanwhile is fulfilled instead, which releases
+// nothing.
+func (s *Sweeper) sweep(ctx context.Context, status domain.ReservationStatus) (int, error) {
+	released := 0
+	for {
+		expired, err := s.repo.ListExpired(ctx, status, time.Now(), sweepBatchSize)
+		if err != nil {
+			return released, err
+		}
+
+		for _, reservation := range expired {
+			settled, err := s.expire(ctx, &reservation)
+			if errors.Is(err, repository.ErrReservationNotHeld) {
+				// Settled otherwise while we were sweeping.
+				continue
+			}
+			if err != nil {
+				return released, err
+			}
+			if settled.Status == domain.ReservationExpired {
+				released++
+			}
+		}
+
+		if len(expired) < sweepBatchSize {
+			return released, nil
+		}
+	}
+}
+
+func (s *Sweeper) expire(ctx context.Context, reservation *domain.Reservation) (*domain.Reservation, error) {
+	if reservation.Status == domain.ReservationConfirmed {
+		return s.repo.ExpireConfirmed(ctx, reservation.ID)
+	}
+	return s.repo.Settle(ctx, reservation.ID, domain.ReservationExpired, "")
+}
@@ -0,0 +1,463 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"products-service/internal/domain"
+	"strings"
+	"sync"
+	"telemetry/tracing"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/mapping"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+const (
+	defaultLimit = 20
+	maxLimit     = 100
+
+	categoryFacetSize = 50
+	rebuildBatchSize  = 500
+)
+
+// priceBuckets are the ranges reported in the price facet.
+var priceBuckets = []struct {
+	name     string
+	min, max *float64
+}{
+	{"0-10", nil, float(10)},
+	{"10-25", float(10), float(25)},
+	{"25-50", float(25), float(50)},
+	{"50-100", float(50), float(100)},
+	{"100+", float(100), nil},
+}
+
+// document is what gets indexed for every product. Source keeps the full
+// product so hits can be returned without going back to DynamoDB.
+type document struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Tags        []string `json:"tags"`
+	CategoryID  string   `json:"categoryId"`
+	Price       float64  `json:"price"`
+	Source      string   `json:"source"`
+}
+
+// BleveProductIndex is an embedded full-text index. With an empty path the
+// index lives in memory and has to be rebuilt on every start.
+//
+// On disk a rebuild writes the index next to the one being served and
+// switches over by updating the pointer file at path + ".current", so the
+// old index keeps serving until the new one is open.
+type BleveProductIndex struct {
+	mu    sync.RWMutex
+	index bleve.Index
+	path  string
+	dir   string
+	// pending records the writes made while a rebuild is running, by
+	// product id, to replay them on the rebuilt index. A nil product is a
+	// delete. It is nil when no rebuild runs.
+	pending   map[string]*domain.Product
+	pendingMu sync.Mutex
+	rebuildMu sync.Mutex
+}
+
+func NewBleveProductIndex(path string) (*BleveProductIndex, error) {
+	dir, err := currentDir(path)
+	if err != nil {
+		return nil, err
+	}
+	idx, err := openIndex(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &BleveProductIndex{index: idx, path: path, dir: dir}, nil
+}
+
+// currentDir returns the directory of the index served at path: the one the
+// pointer file names, or path itself before the first rebuild.
+func currentDir(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	current, err := os.ReadFile(path + ".current")
+	if errors.Is(err, os.ErrNotExist) {
+		return path, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(current)), nil
+}
+
+// setCurrentDir points path at dir. The pointer file is replaced in one
+// rename so a crash leaves either the old or the new one.
+func setCurrentDir(path, dir string) error {
+	tmp := path + ".current.tmp"
+	if err := os.WriteFile(tmp, []byte(dir+"\n"), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path+".current")
+}
+
+func openIndex(path string) (bleve.Index, error) {
+	if path == "" {
+		return bleve.NewMemOnly(newIndexMapping())
+	}
+	idx, err := bleve.Open(path)
+	if err == bleve.ErrorIndexPathDoesNotExist {
+		return bleve.New(path, newIndexMapping())
+	}
+	return idx, err
+}
+
+func newIndexMapping() mapping.IndexMapping {
+	text := bleve.NewTextFieldMapping()
+	text.Analyzer = "standard"
+
+	keyword := bleve.NewKeywordFieldMapping()
+
+	price := bleve.NewNumericFieldMapping()
+
+	source := bleve.NewTextFieldMapping()
+	source.Index = false
+	source.IncludeInAll = false
+
+	product := bleve.NewDocumentStaticMapping()
+	product.AddFieldMappingsAt("name", text)
+	product.AddFieldMappingsAt("description", text)
+	product.AddFieldMappingsAt("tags", keyword)
+	product.AddFieldMappingsAt("categoryId", keyword)
+	product.AddFieldMappingsAt("price", price)
+	product.AddFieldMappingsAt("source", source)
+
+	m := bleve.NewIndexMapping()
+	m.DefaultMapping = product
+	m.DefaultAnalyzer = "standard"
+	return m
+}
+
+func newDocument(product domain.Product) (document, error) {
+	source, err := json.Marshal(product)
+	if err != nil {
+		return document{}, err
+	}
+	return document{
+		Name:        product.Name,
+		Description: product.Description,
+		Tags:        product.Tags,
+		CategoryID:  product.CategoryID,
+		Price:       product.Price,
+		Source:      string(source),
+	}, nil
+}
+
+func (i *BleveProductIndex) Index(ctx context.Context, product domain.Product) error {
+	_, span := tracing.NewSpan(ctx, "BleveProductIndex#Index")
+	defer span.End()
+	span.SetAttributes(
+		tracing.StringAttribute("productId", product.ID),
+	)
+
+	doc, err := newDocument(product)
+	if err != nil {
+		return err
+	}
+
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	i.record(product.ID, &product)
+	return i.index.Index(product.ID, doc)
+}
+
+func (i *BleveProductIndex) Delete(ctx context.Context, id string) error {
+	_, span := tracing.NewSpan(ctx, "BleveProductIndex#Delete")
+	defer span.End()
+	span.SetAttributes(
+		tracing.StringAttribute("productId", id),
+	)
+
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	i.record(id, nil)
+	return i.index.Delete(id)
+}
+
+// record remembers a write for the rebuild that is running, if any.
+func (i *BleveProductIndex) record(id string, product *domain.Product) {
+	i.pendingMu.Lock()
+	defer i.pendingMu.Unlock()
+	if i.pending != nil {
+		i.pending[id] = product
+	}
+}
+
+func (i *BleveProductIndex) Search(ctx context.Context, q Query) (*Result, error) {
+	ctx, span := tracing.NewSpan(ctx, "BleveProductIndex#Search")
+	defer span.End()
+	span.SetAttributes(
+		tracing.StringAttribute("query", q.Text),
+	)
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+	offset := q.Offset
+	if offset < 0 {
+		offset = 0
+	}
+
+	req := bleve.NewSearchRequestOptions(buildQuery(q), limit, offset, false)
+	req.Fields = []string{"source"}
+	req.AddFacet("category", bleve.NewFacetRequest("categoryId", categoryFacetSize))
+	priceFacet := bleve.NewFacetRequest("price", len(priceBuckets))
+	for _, b := range priceBuckets {
+		priceFacet.AddNumericRange(b.name, b.min, b.max)
+	}
+	req.AddFacet("price", priceFacet)
+
+	i.mu.RLock()
+	res, err := i.index.SearchInContext(ctx, req)
+	i.mu.RUnlock()
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	result := &Result{
+		Total: res.Total,
+		Hits:  make([]Hit, 0, len(res.Hits)),
+		Facets: Facets{
+			Category: []TermFacet{},
+			Price:    []RangeFacet{},
+		},
+	}
+	for _, hit := range res.Hits {
+		source, _ := hit.Fields["source"].(string)
+		var product domain.Product
+		if err := json.Unmarshal([]byte(source), &product); err != nil {
+			return nil, fmt.Errorf("failed to decode indexed product %s: %w", hit.ID, err)
+		}
+		result.Hits = append(result.Hits, Hit{Product: product, Score: hit.Score})
+	}
+
+	if facet, ok := res.Facets["category"]; ok && facet.Terms != nil {
+		for _, term := range facet.Terms.Terms() {
+			result.Facets.Category = append(result.Facets.Category, TermFacet{Value: term.Term, Count: term.Count})
+		}
+	}
+	if facet, ok := res.Facets["price"]; ok {
+		counts := make(map[string]int, len(facet.NumericRanges))
+		for _, r := range facet.NumericRanges {
+			counts[r.Name] = r.Count
+		}
+		// Report every bucket in a stable order, including the empty ones.
+		for _, b := range priceBuckets {
+			result.Facets.Price = append(result.Facets.Price, RangeFacet{Name: b.name, Min: b.min, Max: b.max, Count: counts[b.name]})
+		}
+	}
+
+	return result, nil
+}
+
+// buildQuery combines exact, fuzzy and prefix matching over name, description
+// and tags. Name matches weigh more than description matches, and exact
+// matches more than fuzzy ones, which gives the relevance ranking.
+func buildQuery(q Query) query.Query {
+	text := strings.ToLower(strings.TrimSpace(q.Text))
+
+	var disjuncts []query.Query
+	add := func(field string, boost float64) {
+		exact := bleve.NewMatchQuery(text)
+		exact.SetField(field)
+		exact.SetBoost(boost)
+
+		fuzzy := bleve.NewMatchQuery(text)
+		fuzzy.SetField(field)
+		fuzzy.SetFuzziness(1)
+		fuzzy.SetBoost(boost / 3)
+
+		disjuncts = append(disjuncts, exact, fuzzy)
+
+		// Prefix matching on every token lets "headph" find "headphones"
+		// while the user is still typing.
+		for _, token := range strings.Fields(text) {
+			if len(token) < 2 {
+				continue
+			}
+			prefix := bleve.NewPrefixQuery(token)
+			prefix.SetField(field)
+			prefix.SetBoost(boost / 2)
+			disjuncts = append(disjuncts, prefix)
+		}
+	}
+	add("name", 3)
+	add("description", 1)
+
+	tag := bleve.NewTermQuery(text)
+	tag.SetField("tags")
+	tag.SetBoost(2)
+	disjuncts = append(disjuncts, tag)
+
+	var match query.Query = bleve.NewDisjunctionQuery(disjuncts...)
+	if len(q.CategoryIDs) == 0 {
+		return match
+	}
+
+	var categories []query.Query
+	for _, id := range q.CategoryIDs {
+		term := bleve.NewTermQuery(id)
+		term.SetField("categoryId")
+		categories = append(categories, term)
+	}
+	return bleve.NewConjunctionQuery(match, bleve.NewDisjunctionQuery(categories...))
+}
+
+// Rebuild indexes the loaded products into a new index and swaps it in.
+// Writes made meanwhile still go to the old index and are replayed on the
+// new one before the swap, so none of them is lost. When anything fails the
+// old index stays in place. Rebuilds run one at a time.
+func (i *BleveProductIndex) Rebuild(ctx context.Context, load func(ctx context.Context) ([]domain.Product, error)) error {
+	ctx, span := tracing.NewSpan(ctx, "BleveProductIndex#Rebuild")
+	defer span.End()
+
+	i.rebuildMu.Lock()
+	defer i.rebuildMu.Unlock()
+
+	i.pendingMu.Lock()
+	i.pending = map[string]*domain.Product{}
+	i.pendingMu.Unlock()
+	defer func() {
+		i.pendingMu.Lock()
+		i.pending = nil
+		i.pendingMu.Unlock()
+	}()
+
+	i.mu.RLock()
+	nextDir := ""
+	if i.path != "" {
+		nextDir = i.path
+		if i.dir == i.path {
+			nextDir = i.path + ".rebuild"
+		}
+	}
+	i.mu.RUnlock()
+
+	products, err := load(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	span.SetAttributes(
+		tracing.IntAttribute("products", len(products)),
+	)
+
+	if nextDir != "" {
+		if err := os.RemoveAll(nextDir); err != nil {
+			return err
+		}
+	}
+	next, err := openIndex(nextDir)
+	if err != nil {
+		return err
+	}
+	discard := func(err error) error {
+		next.Close()
+		if nextDir != "" {
+			os.RemoveAll(nextDir)
+		}
+		span.RecordError(err)
+		return err
+	}
+
+	batch := next.NewBatch()
+	for _, product := range products {
+		doc, err := newDocument(product)
+		if err != nil {
+			return discard(err)
+		}
+		if err := batch.Index(product.ID, doc); err != nil {
+			return discard(err)
+		}
+		if batch.Size() >= rebuildBatchSize {
+			if err := next.Batch(batch); err != nil {
+				return discard(err)
+			}
+			batch.Reset()
+		}
+	}
+	if err := next.Batch(batch); err != nil {
+		return discard(err)
+	}
+
+	// No write can start while the lock is held, so the pending writes are
+	// complete.
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.pendingMu.Lock()
+	pending := i.pending
+	i.pending = nil
+	i.pendingMu.Unlock()
+
+	batch = next.NewBatch()
+	for id, product := range pending {
+		if product == nil {
+			batch.Delete(id)
+			continue
+		}
+		doc, err := newDocument(*product)
+		if err != nil {
+			return discard(err)
+		}
+		if err := batch.Index(id, doc); err != nil {
+			return discard(err)
+		}
+	}
+	if err := next.Batch(batch); err != nil {
+		return discard(err)
+	}
+
+	if nextDir != "" {
+		if err := setCurrentDir(i.path, nextDir); err != nil {
+			return discard(err)
+		}
+	}
+
+	old, oldDir := i.index, i.dir
+	i.index, i.dir = next, nextDir
+	if err := old.Close(); err != nil {
+		slog.WarnContext(ctx, "failed to close the replaced search index", "error", err)
+	}
+	if oldDir != "" {
+		if err := os.RemoveAll(oldDir); err != nil {
+			slog.WarnContext(ctx, "failed to remove the replaced search index", "dir", oldDir, "error", err)
+		}
+	}
+	return nil
+}
+
+func (i *BleveProductIndex) Count() (uint64, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.index.DocCount()
+}
+
+func (i *BleveProductIndex) Close() error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.index.Close()
+}
+
+func float(v float64) *float64 {
+	return &v
+}
@@ -0,0 +1,84 @@
+package search
+
+import (
+	"context"
+	"products-service/internal/domain"
+	"products-service/internal/repository"
+	"telemetry/tracing"
+)
+
+// Query describes a full-text search over the catalog.
+type Query struct {
+	Text        string
+	CategoryIDs []string
+	Limit       int
+	Offset      int
+}
+
+type Hit struct {
+	Product domain.Product `json:"product"`
+	Score   float64        `json:"score"`
+}
+
+type TermFacet struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+type RangeFacet struct {
+	Name  string   `json:"name"`
+	Min   *float64 `json:"min,omitempty"`
+	Max   *float64 `json:"max,omitempty"`
+	Count int      `json:"count"`
+}
+
+type Facets struct {
+	Category []TermFacet  `json:"category"`
+	Price    []RangeFacet `json:"price"`
+}
+
+type Result struct {
+	Total  uint64 `json:"total"`
+	Hits   []Hit  `json:"hits"`
+	Facets Facets `json:"facets"`
+}
+
+type ProductIndex interface {
+	Index(ctx context.Context, product domain.Product) error
+	Delete(ctx context.Context, id string) error
+	Search(ctx context.Context, query Query) (*Result, error)
+	// Rebuild replaces the whole index with the products load returns.
+	// Writes made while it runs, including while load reads the products,
+	// are kept.
+	Rebuild(ctx context.Context, load func(ctx context.Context) ([]domain.Product, error)) error
+	Count() (uint64, error)
+	Close() error
+}
+
+// Rebuild reloads every product from the repository into the index and
+// returns how many were indexed.
+func Rebuild(ctx context.Context, repo repository.ProductRepository, index ProductIndex) (int, error) {
+	ctx, span := tracing.NewSpan(ctx, "search#Rebuild")
+	defer span.End()
+
+	indexed := 0
+	err := index.Rebuild(ctx, func(ctx context.Context) ([]domain.Product, error) {
+		all, err := repo.GetAll(ctx)
+		if err != nil {
+			return nil, err
+		}
+		products := all[:0]
+		for _, product := range all {
+			if !product.IsArchived() {
+				products = append(products, product)
+			}
+		}
+		indexed = len(products)
+		return products, nil
+	})
+	if err != nil {
+		span.RecordError(err)
+		return 0, err
+	}
+	return indexed, nil
+}
@@ -0,0 +1,145 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"products-service/internal/domain"
+	"products-service/internal/repository"
+	"slices"
+	"telemetry/tracing"
+)
+
+// Broadcaster tells the other replicas of the service which products
+// changed, so that each refreshes them in its own index.
+type Broadcaster interface {
+	Broadcast(ctx context.Context, productIDs ...string)
+}
+
+// IndexedProductRepository keeps the search index in sync with every product
+// write. DynamoDB stays the source of truth: indexing failures are recorded
+// but do not fail the write, and the index can be rebuilt from the table.
+// Archived products are taken out of the index. Every replica has an index
+// of its own, so the products changed through one are broadcast to the
+// others, which Refresh them; peers is nil when the service runs alone.
+type IndexedProductRepository struct {
+	repository.ProductRepository
+	index ProductIndex
+	peers Broadcaster
+}
+
+func NewIndexedProductRepository(repo repository.ProductRepository, index ProductIndex, peers Broadcaster) *IndexedProductRepository {
+	return &IndexedProductRepository{
+		ProductRepository: repo,
+		index:             index,
+		peers:             peers,
+	}
+}
+
+func (r *IndexedProductRepository) Create(ctx context.Context, product *domain.Product) error {
+	if err := r.ProductRepository.Create(ctx, product); err != nil {
+		return err
+	}
+	r.reindex(ctx, *product)
+	r.broadcast(ctx, product.ID)
+	return nil
+}
+
+func (r *IndexedProductRepository) Update(ctx context.Context, product *domain.Product) error {
+	if err := r.ProductRepository.Update(ctx, product); err != nil {
+		return err
+	}
+	r.reindex(ctx, *product)
+	r.broadcast(ctx, product.ID)
+	return nil
+}
+
+func (r *IndexedProductRepository) SetPrice(ctx context.Context, id string, price, current float64) (*domain.Product, error) {
+	product, err := r.ProductRepository.SetPrice(ctx, id, price, current)
+	if err != nil {
+		return nil, err
+	}
+	r.reindex(ctx, *product)
+	r.broadcast(ctx, product.ID)
+	return product, nil
+}
+
+func (r *IndexedProductRepository) PutBatch(ctx context.Context, products []domain.Product) ([]string, error) {
+	changed, err := r.ProductRepository.PutBatch(ctx, products)
+	if err != nil {
+		return changed, err
+	}
+	var written []string
+	for _, product := range products {
+		if !slices.Contains(changed, product.ID) {
+			r.reindex(ctx, product)
+			written = append(written, product.ID)
+		}
+	}
+	r.broadcast(ctx, written...)
+	return changed, nil
+}
+
+func (r *IndexedProductRepository) Delete(ctx context.Context, id string) error {
+	if err := r.ProductRepository.Delete(ctx, id); err != nil {
+		return err
+	}
+	r.unindex(ctx, id)
+	r.broadcast(ctx, id)
+	return nil
+}
+
+// Invalidate reindexes the products from the table, here and on the other
+// replicas. It is called for the writes that change a product without going
+// through this repository: stock movements, reservations and reviews, and
+// the stock the worker moves.
+func (r *IndexedProductRepository) Invalidate(ctx context.Context, productIDs ...string) {
+	r.Refresh(ctx, productIDs...)
+	r.broadcast(ctx, productIDs...)
+}
+
+// Refresh reindexes the products from the table on this replica only, for
+// the changes another replica broadcast.
+func (r *IndexedProductRepository) Refresh(ctx context.Context, productIDs ...string) {
+	for _, id := range productIDs {
+		product, err := r.ProductRepository.GetByID(ctx, id)
+		switch {
+		case errors.Is(err, repository.ErrProductNotFound):
+			r.unindex(ctx, id)
+		case err != nil:
+			tracing.SpanFromContext(ctx).RecordError(err)
+			slog.ErrorContext(ctx, "failed to read product to reindex", "productId", id, "error", err)
+		default:
+			r.reindex(ctx, *product)
+		}
+	}
+}
+
+func (r *IndexedProductRepository) unindex(ctx context.Context, id string) {
+	ctx, span := tracing.NewSpan(ctx, "IndexedProductRepository#unindex")
+	defer span.End()
+	if err := r.index.Delete(ctx, id); err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "failed to remove product from search index", "productId", id, "error", err)
+	}
+}
+
+func (r *IndexedProductRepository) reindex(ctx context.Context, product domain.Product) {
+	if product.IsArchived() {
+		r.unindex(ctx, product.ID)
+		return
+	}
+
+	ctx, span := tracing.NewSpan(ctx, "IndexedProductRepository#reindex")
+	defer span.End()
+	if err := r.index.Index(ctx, product); err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "failed to index product", "productId", product.ID, "error", err)
+	}
+}
+
+func (r *IndexedProductRepository) broadcast(ctx context.Context, productIDs ...string) {
+	if r.peers != nil {
+		r.peers.Broadcast(ctx, productIDs...)
+	}
+}
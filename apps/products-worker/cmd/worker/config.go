@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+
+	"config"
+	"products-worker/internal/allocation"
+)
+
+// Config is the configuration of the worker, loaded from the environment
+// and the optional CONFIG_FILE. Tracing, metrics and logging read their
+// OTEL_* and LOG_* variables themselves.
+type Config struct {
+	// Port serves the probes and the metrics.
+	Port string `env:"PORT" default:"8080"`
+
+	QueueURL         string `env:"SQS_QUEUE_URL" required:"true"`
+	ProductsTopicArn string `env:"PRODUCTS_TOPIC_ARN" required:"true"`
+
+	ProductsTable    string `env:"PRODUCTS_TABLE" default:"products"`
+	MovementsTable   string `env:"STOCK_MOVEMENTS_TABLE" default:"stock_movements"`
+	WarehousesTable  string `env:"WAREHOUSES_TABLE" default:"warehouses"`
+	AllocationsTable string `env:"ALLOCATIONS_TABLE" default:"allocations"`
+
+	AllocationStrategy string `env:"ALLOCATION_STRATEGY" default:"priority"`
+
+	AWS config.AWS
+}
+
+// Validate checks that the allocation strategy exists.
+func (c *Config) Validate() error {
+	if _, err := allocation.New(c.AllocationStrategy); err != nil {
+		return fmt.Errorf("ALLOCATION_STRATEGY: %w", err)
+	}
+	return nil
+}
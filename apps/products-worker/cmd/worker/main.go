@@ -1,70 +1,103 @@
 package main
 
 import (
+	"config"
 	"context"
-	"log"
+	"flag"
+	"log/slog"
+	"net/http"
 	"os"
+	"products-worker/internal/allocation"
 	"products-worker/internal/processor"
+	"products-worker/internal/publisher"
 	"products-worker/internal/repository"
 	"products-worker/internal/sqs"
-	"products-worker/internal/tracing"
-
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
-	awssqs "github.com/aws/aws-sdk-go-v2/service/sqs"
+	"telemetry/health"
+	"telemetry/logging"
+	"telemetry/metrics"
+	"telemetry/tracing"
+	"time"
 )
 
 func main() {
-	tempoEndpoint := getEnv("TEMPO_ENDPOINT", "tempo:4318")
-	tp := tracing.InitTracer("products-worker", tempoEndpoint)
+	logging.Init("products-worker")
+
+	printConfig := flag.Bool("print-config", false, "print the effective configuration, with secrets redacted, and exit")
+	flag.Parse()
+
+	var conf Config
+	if err := config.Load(&conf); err != nil {
+		logging.Fatal("failed to load configuration", "error", err)
+	}
+	if *printConfig {
+		if err := config.Print(os.Stdout, &conf); err != nil {
+			logging.Fatal("failed to print configuration", "error", err)
+		}
+		return
+	}
+
+	tp, err := tracing.InitTracer(context.Background(), "products-worker")
+	if err != nil {
+		slog.Warn("tracing is partially disabled", "error", err)
+	}
 	defer func() {
 		if err := tp.Shutdown(context.Background()); err != nil {
-			log.Printf("Error shutting down tracer provider: %v", err)
+			slog.Error("failed to shut down the tracer provider", "error", err)
 		}
 	}()
-
-	awsEndpoint := getEnv("AWS_ENDPOINT", "")
-	awsRegion := getEnv("AWS_REGION", "us-west-2")
-
-	customResolver := aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...interface{}) (aws.Endpoint, error) {
-		if awsEndpoint != "" {
-			return aws.Endpoint{
-				PartitionID:   "aws",
-				URL:           awsEndpoint,
-				SigningRegion: awsRegion,
-			}, nil
+	mp, metricsHandler, err := metrics.InitMeter(context.Background(), "products-worker")
+	if err != nil {
+		slog.Warn("metrics are partially disabled", "error", err)
+	}
+	defer func() {
+		if err := mp.Shutdown(context.Background()); err != nil {
+			slog.Error("failed to shut down the meter provider", "error", err)
 		}
+	}()
 
-		// returning EndpointNotFoundError will allow the service to fallback to it's default resolution
-		return aws.Endpoint{}, &aws.EndpointNotFoundError{}
-	})
-
-	cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithEndpointResolverWithOptions(customResolver))
+	cfg, err := conf.AWS.Load(context.Background())
 	if err != nil {
-		log.Fatalf("failed to load AWS config: %v", err)
+		logging.Fatal("failed to load AWS config", "error", err)
 	}
+	metrics.InstrumentAWS(&cfg)
 
-	queueURL := getEnv("SQS_QUEUE_URL", "")
-	if queueURL == "" {
-		log.Fatal("SQS_QUEUE_URL environment variable is required")
+	strategy, err := allocation.New(conf.AllocationStrategy)
+	if err != nil {
+		logging.Fatal("invalid ALLOCATION_STRATEGY", "error", err)
 	}
 
-	tableName := getEnv("DYNAMODB_TABLE", "products")
+	dynamoClient := conf.AWS.DynamoDB(cfg)
+	repo := repository.NewDynamoProductRepository(dynamoClient, conf.ProductsTable, conf.MovementsTable)
+	warehouses := repository.NewDynamoWarehouseRepository(dynamoClient, conf.WarehousesTable, time.Minute)
+	allocations := repository.NewDynamoAllocationRepository(dynamoClient, conf.AllocationsTable)
 
-	dynamoClient := dynamodb.NewFromConfig(cfg)
-	repo := repository.NewDynamoProductRepository(dynamoClient, tableName)
+	sqsClient := conf.AWS.SQS(cfg)
+	snsClient := conf.AWS.SNS(cfg)
+	events := publisher.NewSnsStockEventPublisher(snsClient, conf.ProductsTopicArn)
+	handler := processor.NewOrderHandler(repo, warehouses, allocations, strategy, events)
 
-	sqsClient := awssqs.NewFromConfig(cfg)
-	handler := processor.NewOrderHandler(repo)
+	readiness := health.NewChecker(health.DefaultCacheTTL, health.DefaultTimeout)
+	for _, table := range []string{conf.ProductsTable, conf.MovementsTable, conf.WarehousesTable, conf.AllocationsTable} {
+		readiness.Add("dynamodb:"+table, health.DynamoDBTable(dynamoClient, table))
+	}
+	readiness.Add("sns:"+conf.ProductsTopicArn, health.SNSTopic(snsClient, conf.ProductsTopicArn))
+	readiness.Add("sqs:"+conf.QueueURL, health.SQSQueue(sqsClient, conf.QueueURL))
+	go serveHTTP(conf.Port, readiness, metricsHandler)
 
-	log.Println("Worker started. Listening for messages...")
-	sqs.ListenAndProcess(context.Background(), sqsClient, queueURL, handler)
+	slog.Info("worker started, listening for messages", "queueUrl", conf.QueueURL)
+	sqs.ListenAndProcess(context.Background(), sqsClient, conf.QueueURL, handler)
 }
 
-func getEnv(key, fallback string) string {
-	if value, ok := os.LookupEnv(key); ok {
-		return value
+// serveHTTP serves the probes and, when enabled, the metrics; the worker
+// has no other HTTP.
+func serveHTTP(port string, readiness *health.Checker, metricsHandler http.Handler) {
+	mux := http.NewServeMux()
+	mux.Handle("GET /livez", health.Live())
+	mux.Handle("GET /readyz", readiness)
+	if metricsHandler != nil {
+		mux.Handle("GET /metrics", metricsHandler)
+	}
+	if err := http.ListenAndServe(":"+port, mux); err != nil {
+		slog.Error("health server stopped", "error", err)
 	}
-	return fallback
 }
@@ -0,0 +1,263 @@
+package allocation
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Warehouse mirrors the products-service warehouse.
+type Warehouse struct {
+	ID        string   `dynamodbav:"id"`
+	Name      string   `dynamodbav:"name"`
+	Priority  int      `dynamodbav:"priority"`
+	Latitude  *float64 `dynamodbav:"latitude,omitempty"`
+	Longitude *float64 `dynamodbav:"longitude,omitempty"`
+	Disabled  bool     `dynamodbav:"disabled,omitempty"`
+}
+
+// Location is where an order ships to.
+type Location struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// Item is an order item together with the stock held for it per warehouse.
+type Item struct {
+	Index     int
+	ProductID string
+	SKU       string
+	Quantity  int
+	Stock     map[string]int
+}
+
+// Line says how many units of the order item at position Item are picked
+// from a warehouse.
+type Line struct {
+	Item        int    `json:"item" dynamodbav:"item"`
+	ProductID   string `json:"productId" dynamodbav:"productId"`
+	SKU         string `json:"sku,omitempty" dynamodbav:"sku,omitempty"`
+	WarehouseID string `json:"warehouseId" dynamodbav:"warehouseId"`
+	Quantity    int    `json:"quantity" dynamodbav:"quantity"`
+}
+
+// Hold is a reservation products-service confirmed for the order. Its
+// quantity is released from the reserved stock when the stock is taken.
+type Hold struct {
+	ReservationID string `dynamodbav:"reservationId"`
+	ProductID     string `dynamodbav:"productId"`
+	SKU           string `dynamodbav:"sku,omitempty"`
+	Quantity      int    `dynamodbav:"quantity"`
+}
+
+// Allocation mirrors the products-service allocation.
+type Allocation struct {
+	OrderID   string `dynamodbav:"orderId"`
+	Strategy  string `dynamodbav:"strategy"`
+	Lines     []Line `dynamodbav:"lines"`
+	Holds     []Hold `dynamodbav:"holds,omitempty"`
+	CreatedAt string `dynamodbav:"createdAt"`
+}
+
+// Allocated reports whether the order was allocated. Before that the
+// allocation only holds the reservations confirmed for the order.
+func (a *Allocation) Allocated() bool {
+	return a.Strategy != ""
+}
+
+// HeldQuantities returns the quantity confirmed reservations hold for the
+// order, per product and SKU.
+func (a *Allocation) HeldQuantities() map[[2]string]int {
+	held := make(map[[2]string]int, len(a.Holds))
+	for _, hold := range a.Holds {
+		held[[2]string{hold.ProductID, hold.SKU}] += hold.Quantity
+	}
+	return held
+}
+
+// LinesFor returns the lines allocated for the order item at index.
+func (a *Allocation) LinesFor(index int) []Line {
+	var lines []Line
+	for _, line := range a.Lines {
+		if line.Item == index {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// Strategy decides which warehouses fulfill an order item. When there is
+// not enough stock anywhere the missing units are backordered at the
+// top-ranked warehouse, so an allocation always covers the full quantity.
+type Strategy interface {
+	Name() string
+	Allocate(item Item, warehouses []Warehouse, shipTo *Location) []Line
+}
+
+const (
+	StrategyPriority = "priority"
+	StrategyNearest  = "nearest"
+	StrategySplit    = "split"
+)
+
+// New returns the strategy with the given name.
+func New(name string) (Strategy, error) {
+	switch name {
+	case StrategyPriority:
+		return priority{}, nil
+	case StrategyNearest:
+		return nearest{}, nil
+	case StrategySplit:
+		return split{}, nil
+	}
+	return nil, fmt.Errorf("unknown allocation strategy %q", name)
+}
+
+// priority ships each item from the most preferred warehouse that can
+// fulfill it in full.
+type priority struct{}
+
+func (priority) Name() string { return StrategyPriority }
+
+func (priority) Allocate(item Item, warehouses []Warehouse, _ *Location) []Line {
+	return single(item, byPriority(candidates(item, warehouses)))
+}
+
+// nearest ships each item from the closest warehouse that can fulfill it in
+// full. Without a destination it behaves like priority.
+type nearest struct{}
+
+func (nearest) Name() string { return StrategyNearest }
+
+func (nearest) Allocate(item Item, warehouses []Warehouse, shipTo *Location) []Line {
+	return single(item, byDistance(candidates(item, warehouses), shipTo))
+}
+
+// split takes as much as possible from each warehouse in turn, closest
+// first when the destination is known and by priority otherwise.
+type split struct{}
+
+func (split) Name() string { return StrategySplit }
+
+func (split) Allocate(item Item, warehouses []Warehouse, shipTo *Location) []Line {
+	ranked := byDistance(candidates(item, warehouses), shipTo)
+
+	var lines []Line
+	remaining := item.Quantity
+	for _, w := range ranked {
+		if remaining == 0 {
+			break
+		}
+		take := min(item.Stock[w.ID], remaining)
+		if take <= 0 {
+			continue
+		}
+		lines = append(lines, line(item, w.ID, take))
+		remaining -= take
+	}
+	if remaining == 0 {
+		return lines
+	}
+
+	// Backorder the rest at the top-ranked warehouse.
+	for i := range lines {
+		if lines[i].WarehouseID == ranked[0].ID {
+			lines[i].Quantity += remaining
+			return lines
+		}
+	}
+	return append(lines, line(item, ranked[0].ID, remaining))
+}
+
+// Restock chooses where stock coming back without a recorded allocation is
+// put: the most preferred warehouse holding the item.
+func Restock(item Item, warehouses []Warehouse) Line {
+	return line(item, byPriority(candidates(item, warehouses))[0].ID, item.Quantity)
+}
+
+func single(item Item, ranked []Warehouse) []Line {
+	for _, w := range ranked {
+		if item.Stock[w.ID] >= item.Quantity {
+			return []Line{line(item, w.ID, item.Quantity)}
+		}
+	}
+	return []Line{line(item, ranked[0].ID, item.Quantity)}
+}
+
+func line(item Item, warehouseID string, quantity int) Line {
+	return Line{
+		Item:        item.Index,
+		ProductID:   item.ProductID,
+		SKU:         item.SKU,
+		WarehouseID: warehouseID,
+		Quantity:    quantity,
+	}
+}
+
+// candidates are the enabled warehouses the item is stocked at. Locations of
+// unknown or disabled warehouses are only used when nothing else is left.
+func candidates(item Item, warehouses []Warehouse) []Warehouse {
+	var enabled, fallback []Warehouse
+	known := make(map[string]bool, len(warehouses))
+	for _, w := range warehouses {
+		known[w.ID] = true
+		if _, stocked := item.Stock[w.ID]; !stocked {
+			continue
+		}
+		if w.Disabled {
+			fallback = append(fallback, w)
+		} else {
+			enabled = append(enabled, w)
+		}
+	}
+	if len(enabled) > 0 {
+		return enabled
+	}
+	for id := range item.Stock {
+		if !known[id] {
+			fallback = append(fallback, Warehouse{ID: id})
+		}
+	}
+	return fallback
+}
+
+func byPriority(warehouses []Warehouse) []Warehouse {
+	sort.SliceStable(warehouses, func(i, j int) bool {
+		if warehouses[i].Priority != warehouses[j].Priority {
+			return warehouses[i].Priority < warehouses[j].Priority
+		}
+		return warehouses[i].ID < warehouses[j].ID
+	})
+	return warehouses
+}
+
+// byDistance orders the warehouses by distance to shipTo. Warehouses without
+// coordinates go last, and ties are broken by priority.
+func byDistance(warehouses []Warehouse, shipTo *Location) []Warehouse {
+	warehouses = byPriority(warehouses)
+	if shipTo == nil {
+		return warehouses
+	}
+	distance := func(w Warehouse) float64 {
+		if w.Latitude == nil || w.Longitude == nil {
+			return math.Inf(1)
+		}
+		return haversine(*w.Latitude, *w.Longitude, shipTo.Latitude, shipTo.Longitude)
+	}
+	sort.SliceStable(warehouses, func(i, j int) bool {
+		return distance(warehouses[i]) < distance(warehouses[j])
+	})
+	return warehouses
+}
+
+// haversine returns the great-circle distance in kilometers.
+func haversine(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadiusKm = 6371
+	rad := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	dLat := rad(lat2 - lat1)
+	dLon := rad(lon2 - lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(rad(lat1))*math.Cos(rad(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return earthRadiusKm * 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
@@ -0,0 +1,241 @@
+package allocation
+
+import (
+	"slices"
+	"testing"
+)
+
+func coordinates(lat, lon float64) (*float64, *float64) {
+	return &lat, &lon
+}
+
+func testWarehouses() []Warehouse {
+	lisbonLat, lisbonLon := coordinates(38.72, -9.14)
+	madridLat, madridLon := coordinates(40.42, -3.70)
+	parisLat, parisLon := coordinates(48.86, 2.35)
+	berlinLat, berlinLon := coordinates(52.52, 13.40)
+	return []Warehouse{
+		{ID: "lisbon", Priority: 2, Latitude: lisbonLat, Longitude: lisbonLon},
+		{ID: "madrid", Priority: 1, Latitude: madridLat, Longitude: madridLon},
+		{ID: "paris", Priority: 3, Latitude: parisLat, Longitude: parisLon},
+		{ID: "berlin", Priority: 0, Latitude: berlinLat, Longitude: berlinLon, Disabled: true},
+		{ID: "remote", Priority: 1},
+	}
+}
+
+// porto is closest to lisbon, then madrid, then paris.
+var porto = &Location{Latitude: 41.15, Longitude: -8.61}
+
+func testItem(quantity int, stock map[string]int) Item {
+	return Item{Index: 3, ProductID: "product-1", SKU: "sku-1", Quantity: quantity, Stock: stock}
+}
+
+// pick is how many units a line takes from its warehouse.
+type pick struct {
+	warehouseID string
+	quantity    int
+}
+
+func picks(lines []Line) []pick {
+	var picked []pick
+	for _, l := range lines {
+		picked = append(picked, pick{l.WarehouseID, l.Quantity})
+	}
+	return picked
+}
+
+func TestStrategies(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy string
+		item     Item
+		shipTo   *Location
+		want     []pick
+	}{
+		{
+			name:     "priority takes the most preferred warehouse with enough stock",
+			strategy: StrategyPriority,
+			item:     testItem(5, map[string]int{"lisbon": 10, "madrid": 4, "paris": 10}),
+			shipTo:   porto,
+			want:     []pick{{"lisbon", 5}},
+		},
+		{
+			name:     "priority prefers the lower priority number",
+			strategy: StrategyPriority,
+			item:     testItem(5, map[string]int{"lisbon": 10, "madrid": 10}),
+			want:     []pick{{"madrid", 5}},
+		},
+		{
+			name:     "priority breaks ties by warehouse id",
+			strategy: StrategyPriority,
+			item:     testItem(2, map[string]int{"madrid": 10, "remote": 10}),
+			want:     []pick{{"madrid", 2}},
+		},
+		{
+			name:     "priority backorders at the most preferred warehouse when none has enough",
+			strategy: StrategyPriority,
+			item:     testItem(8, map[string]int{"lisbon": 5, "madrid": 3}),
+			want:     []pick{{"madrid", 8}},
+		},
+		{
+			name:     "priority skips disabled warehouses",
+			strategy: StrategyPriority,
+			item:     testItem(1, map[string]int{"berlin": 10, "paris": 10}),
+			want:     []pick{{"paris", 1}},
+		},
+		{
+			name:     "priority falls back to disabled warehouses when nothing else stocks the item",
+			strategy: StrategyPriority,
+			item:     testItem(1, map[string]int{"berlin": 10}),
+			want:     []pick{{"berlin", 1}},
+		},
+		{
+			name:     "priority falls back to unknown warehouses",
+			strategy: StrategyPriority,
+			item:     testItem(1, map[string]int{"gone": 10}),
+			want:     []pick{{"gone", 1}},
+		},
+		{
+			name:     "nearest takes the closest warehouse with enough stock",
+			strategy: StrategyNearest,
+			item:     testItem(5, map[string]int{"lisbon": 10, "madrid": 10, "paris": 10}),
+			shipTo:   porto,
+			want:     []pick{{"lisbon", 5}},
+		},
+		{
+			name:     "nearest passes over a closer warehouse without enough stock",
+			strategy: StrategyNearest,
+			item:     testItem(5, map[string]int{"lisbon": 4, "madrid": 1, "paris": 10}),
+			shipTo:   porto,
+			want:     []pick{{"paris", 5}},
+		},
+		{
+			name:     "nearest ranks warehouses without coordinates last",
+			strategy: StrategyNearest,
+			item:     testItem(5, map[string]int{"remote": 10, "paris": 10}),
+			shipTo:   porto,
+			want:     []pick{{"paris", 5}},
+		},
+		{
+			name:     "nearest without a destination goes by priority",
+			strategy: StrategyNearest,
+			item:     testItem(5, map[string]int{"lisbon": 10, "madrid": 10}),
+			want:     []pick{{"madrid", 5}},
+		},
+		{
+			name:     "nearest backorders at the closest warehouse",
+			strategy: StrategyNearest,
+			item:     testItem(20, map[string]int{"lisbon": 4, "paris": 10}),
+			shipTo:   porto,
+			want:     []pick{{"lisbon", 20}},
+		},
+		{
+			name:     "split takes from one warehouse when it has enough",
+			strategy: StrategySplit,
+			item:     testItem(5, map[string]int{"lisbon": 10, "madrid": 10}),
+			shipTo:   porto,
+			want:     []pick{{"lisbon", 5}},
+		},
+		{
+			name:     "split takes the rest from the next closest warehouses",
+			strategy: StrategySplit,
+			item:     testItem(12, map[string]int{"lisbon": 4, "madrid": 5, "paris": 10}),
+			shipTo:   porto,
+			want:     []pick{{"lisbon", 4}, {"madrid", 5}, {"paris", 3}},
+		},
+		{
+			name:     "split without a destination goes by priority",
+			strategy: StrategySplit,
+			item:     testItem(12, map[string]int{"lisbon": 4, "madrid": 5, "paris": 10}),
+			want:     []pick{{"madrid", 5}, {"lisbon", 4}, {"paris", 3}},
+		},
+		{
+			name:     "split skips warehouses without stock",
+			strategy: StrategySplit,
+			item:     testItem(3, map[string]int{"lisbon": 0, "madrid": 3}),
+			shipTo:   porto,
+			want:     []pick{{"madrid", 3}},
+		},
+		{
+			name:     "split backorders the rest at the top-ranked warehouse",
+			strategy: StrategySplit,
+			item:     testItem(10, map[string]int{"lisbon": 2, "madrid": 3}),
+			shipTo:   porto,
+			want:     []pick{{"lisbon", 7}, {"madrid", 3}},
+		},
+		{
+			name:     "split backorders at the top-ranked warehouse when it has no stock",
+			strategy: StrategySplit,
+			item:     testItem(10, map[string]int{"lisbon": 0, "madrid": 3}),
+			shipTo:   porto,
+			want:     []pick{{"madrid", 3}, {"lisbon", 7}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			strategy, err := New(tt.strategy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines := strategy.Allocate(tt.item, testWarehouses(), tt.shipTo)
+
+			if got := picks(lines); !slices.Equal(got, tt.want) {
+				t.Errorf("Allocate() = %v, want %v", got, tt.want)
+			}
+			total := 0
+			for _, l := range lines {
+				total += l.Quantity
+				if l.Item != tt.item.Index || l.ProductID != tt.item.ProductID || l.SKU != tt.item.SKU {
+					t.Errorf("line %+v is not for item %d of %s/%s", l, tt.item.Index, tt.item.ProductID, tt.item.SKU)
+				}
+			}
+			if total != tt.item.Quantity {
+				t.Errorf("lines cover %d units, want %d", total, tt.item.Quantity)
+			}
+		})
+	}
+}
+
+func TestRestock(t *testing.T) {
+	tests := []struct {
+		name string
+		item Item
+		want string
+	}{
+		{
+			name: "most preferred warehouse holding the item",
+			item: testItem(2, map[string]int{"lisbon": 0, "paris": 3}),
+			want: "lisbon",
+		},
+		{
+			name: "disabled warehouse when it is the only one",
+			item: testItem(2, map[string]int{"berlin": 0}),
+			want: "berlin",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Restock(tt.item, testWarehouses())
+			if got.WarehouseID != tt.want || got.Quantity != tt.item.Quantity {
+				t.Errorf("Restock() = %s x%d, want %s x%d", got.WarehouseID, got.Quantity, tt.want, tt.item.Quantity)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, name := range []string{StrategyPriority, StrategyNearest, StrategySplit} {
+		strategy, err := New(name)
+		if err != nil {
+			t.Fatalf("New(%q) error = %v", name, err)
+		}
+		if strategy.Name() != name {
+			t.Errorf("New(%q).Name() = %q", name, strategy.Name())
+		}
+	}
+	if _, err := New("random"); err == nil {
+		t.Error(`New("random") accepted an unknown strategy`)
+	}
+}
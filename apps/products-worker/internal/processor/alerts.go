@@ -0,0 +1,41 @@
+package processor
+
+import (
+	"products-worker/internal/publisher"
+	"products-worker/internal/repository"
+)
+
+type stockState int
+
+const (
+	stockAvailable stockState = iota
+	stockLow
+	stockDepleted
+)
+
+func stateOf(stock, reorderThreshold int) stockState {
+	switch {
+	case stock <= 0:
+		return stockDepleted
+	case stock <= reorderThreshold:
+		return stockLow
+	}
+	return stockAvailable
+}
+
+// stockAlertType returns the alert for a change that moved the product into
+// another stock state, or "" when the state did not change.
+func stockAlertType(change *repository.StockChange) string {
+	before := stateOf(change.Before, change.ReorderThreshold)
+	after := stateOf(change.After, change.ReorderThreshold)
+	if before == after {
+		return ""
+	}
+	switch after {
+	case stockDepleted:
+		return publisher.StockDepleted
+	case stockLow:
+		return publisher.StockLow
+	}
+	return publisher.StockReplenished
+}
@@ -3,21 +3,34 @@ package processor
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"products-worker/internal/allocation"
+	"products-worker/internal/publisher"
 	"products-worker/internal/repository"
-	"products-worker/internal/tracing"
+	"telemetry/tracing"
+	"time"
+
+	"go.opentelemetry.io/otel/metric"
 )
 
+// actor identifies the worker in the stock ledger.
+const actor = "products-worker"
+
 type OrderItem struct {
 	ProductID string `json:"productId"`
+	SKU       string `json:"sku,omitempty"`
 	Quantity  int    `json:"quantity"`
 }
 
 type OrderMessage struct {
-	Type     string      `json:"type"`
-	OrderID  string      `json:"orderId"`
-	Items    []OrderItem `json:"items"`
-	Datetime string      `json:"datetime"`
+	Type     string               `json:"type"`
+	EventID  string               `json:"eventId"`
+	OrderID  string               `json:"orderId"`
+	Items    []OrderItem          `json:"items"`
+	ShipTo   *allocation.Location `json:"shipTo,omitempty"`
+	Datetime string               `json:"datetime"`
 }
 
 type Handler interface {
@@ -25,11 +38,15 @@ type Handler interface {
 }
 
 type OrderHandler struct {
-	repo repository.ProductRepository
+	repo        repository.ProductRepository
+	warehouses  repository.WarehouseRepository
+	allocations repository.AllocationRepository
+	strategy    allocation.Strategy
+	events      publisher.StockEventPublisher
 }
 
-func NewOrderHandler(repo repository.ProductRepository) *OrderHandler {
-	return &OrderHandler{repo: repo}
+func NewOrderHandler(repo repository.ProductRepository, warehouses repository.WarehouseRepository, allocations repository.AllocationRepository, strategy allocation.Strategy, events publisher.StockEventPublisher) *OrderHandler {
+	return &OrderHandler{repo: repo, warehouses: warehouses, allocations: allocations, strategy: strategy, events: events}
 }
 
 func (h *OrderHandler) HandleMessage(ctx context.Context, message string) error {
@@ -46,20 +63,314 @@ func (h *OrderHandler) HandleMessage(ctx context.Context, message string) error
 		tracing.StringAttribute("eventType", order.Type),
 	)
 
-	for _, item := range order.Items {
-		switch order.Type {
-		case "order.created":
-			if err := h.repo.DecrementStock(ctx, item.ProductID, item.Quantity); err != nil {
-				return fmt.Errorf("failed to decrement stock for product %s: %w", item.ProductID, err)
+	var reason string
+	var sign int
+	switch order.Type {
+	case "order.created":
+		reason, sign = "sale", -1
+	case "order.canceled":
+		reason, sign = "cancel", 1
+	case "order.returned":
+		reason, sign = "return", 1
+	default:
+		return fmt.Errorf("unsupported order event type: %s", order.Type)
+	}
+
+	// Events published before eventId existed are identified by order and
+	// type, which are unique for these events as well.
+	eventID := order.EventID
+	if eventID == "" {
+		eventID = order.Type + ":" + order.OrderID
+	}
+
+	var plan *allocation.Allocation
+	var err error
+	if sign < 0 {
+		plan, err = h.allocate(ctx, &order)
+	} else {
+		plan, err = h.restock(ctx, &order)
+	}
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to allocate order %s: %w", order.OrderID, err)
+	}
+
+	// A sale takes the stock held by the reservations confirmed for the
+	// order together with the stock itself.
+	var held map[[2]string]int
+	if sign < 0 {
+		held = plan.HeldQuantities()
+	}
+
+	for i, item := range order.Items {
+		// orders-service rejects such items; a negative sale would add
+		// stock.
+		if item.ProductID == "" || item.Quantity < 1 {
+			slog.WarnContext(ctx, "skipping invalid order item", "orderId", order.OrderID, "item", i, "productId", item.ProductID, "quantity", item.Quantity)
+			continue
+		}
+		movement := repository.StockMovement{
+			ID:        fmt.Sprintf("%s#%d", eventID, i),
+			ProductID: item.ProductID,
+			SKU:       item.SKU,
+			OrderID:   order.OrderID,
+			EventID:   eventID,
+			Reason:    reason,
+			Actor:     actor,
+			Delta:     sign * item.Quantity,
+			CreatedAt: time.Now().UTC().Format(time.RFC3339Nano),
+		}
+
+		// Stock tracked per location moves once per allocated warehouse.
+		movements := []repository.StockMovement{movement}
+		if lines := plan.LinesFor(i); len(lines) > 0 {
+			movements = movements[:0]
+			for _, line := range lines {
+				m := movement
+				m.ID = fmt.Sprintf("%s#%d#%s", eventID, i, line.WarehouseID)
+				m.WarehouseID = line.WarehouseID
+				m.Delta = sign * line.Quantity
+				movements = append(movements, m)
+			}
+		}
+
+		for _, m := range movements {
+			key := [2]string{item.ProductID, item.SKU}
+			unreserve := 0
+			if held[key] > 0 {
+				unreserve = min(held[key], -m.Delta)
+				m.Unreserve = unreserve
+				held[key] -= unreserve
+			}
+
+			change, err := h.repo.ApplyStockMovement(ctx, &m)
+			if errors.Is(err, repository.ErrInsufficientStock) {
+				change, err = h.applyAvailable(ctx, &m)
+			}
+			if errors.Is(err, repository.ErrDuplicateMovement) {
+				slog.InfoContext(ctx, "skipping already applied movement", "movementId", m.ID, "productId", item.ProductID)
+				continue
 			}
-		case "order.canceled", "order.returned":
-			if err := h.repo.IncrementStock(ctx, item.ProductID, item.Quantity); err != nil {
-				return fmt.Errorf("failed to increment stock for product %s: %w", item.ProductID, err)
+			if unavailable(err) || errors.Is(err, errNothingAvailable) {
+				// What the movement would have released is still held.
+				held[key] += unreserve
+				if unavailable(err) {
+					slog.WarnContext(ctx, "refusing stock movement", "reason", reason, "movementId", m.ID, "productId", item.ProductID, "error", err)
+				}
+				continue
 			}
-		default:
-			return fmt.Errorf("unsupported order event type: %s", order.Type)
+			if err != nil {
+				return fmt.Errorf("failed to apply %s movement for product %s: %w", reason, item.ProductID, err)
+			}
+			held[key] += unreserve - m.Unreserve
+			recordMovement(ctx, reason, m.Delta)
+			h.stockChanged(ctx, &m)
+			h.alert(ctx, change, order.OrderID)
 		}
 	}
 
+	return h.releaseHeld(ctx, eventID, order.OrderID, held)
+}
+
+// releaseHeld gives back what the reservations confirmed for the order held
+// beyond what the sale took: items that ordered less than was held, were
+// backordered or were refused. Each release is recorded in the ledger, so a
+// redelivered event releases nothing twice.
+func (h *OrderHandler) releaseHeld(ctx context.Context, eventID, orderID string, held map[[2]string]int) error {
+	for key, quantity := range held {
+		if quantity <= 0 {
+			continue
+		}
+		productID, sku := key[0], key[1]
+		movement := repository.StockMovement{
+			ID:        fmt.Sprintf("%s#release#%s#%s", eventID, productID, sku),
+			ProductID: productID,
+			SKU:       sku,
+			OrderID:   orderID,
+			EventID:   eventID,
+			Reason:    "release",
+			Actor:     actor,
+			Note:      fmt.Sprintf("released %d held units the order did not take", quantity),
+			CreatedAt: time.Now().UTC().Format(time.RFC3339Nano),
+			Unreserve: quantity,
+		}
+
+		_, err := h.repo.ApplyStockMovement(ctx, &movement)
+		if errors.Is(err, repository.ErrDuplicateMovement) {
+			continue
+		}
+		if unavailable(err) {
+			slog.WarnContext(ctx, "refusing to release held stock", "movementId", movement.ID, "productId", productID, "error", err)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to release held stock of product %s: %w", productID, err)
+		}
+		slog.InfoContext(ctx, "released held stock", "orderId", orderID, "productId", productID, "sku", sku, "quantity", quantity)
+		h.stockChanged(ctx, &movement)
+	}
 	return nil
 }
+
+// errNothingAvailable is returned by applyAvailable when the variant or
+// warehouse has no stock left to take.
+var errNothingAvailable = errors.New("no stock available in warehouse")
+
+// applyAvailable takes what is left of the variant or in the warehouse when
+// the movement asks for more, which happens when the stock was short
+// everywhere and the rest was backordered, or when it was sold meanwhile.
+// The missing units are logged instead of taking the stock below zero.
+func (h *OrderHandler) applyAvailable(ctx context.Context, movement *repository.StockMovement) (*repository.StockChange, error) {
+	stock, err := h.repo.AvailableStock(ctx, movement.ProductID, movement.SKU, movement.WarehouseID)
+	if err != nil {
+		return nil, err
+	}
+	wanted := -movement.Delta
+	available := max(stock, 0)
+	slog.WarnContext(ctx, "backordering missing units", "movementId", movement.ID, "productId", movement.ProductID, "warehouseId", movement.WarehouseID, "wanted", wanted, "available", available)
+	if available == 0 {
+		return nil, errNothingAvailable
+	}
+
+	movement.Delta = -min(wanted, available)
+	movement.Unreserve = min(movement.Unreserve, available)
+	return h.repo.ApplyStockMovement(ctx, movement)
+}
+
+// stockChanged announces the movement, which among others lets
+// products-service drop the product from its cache. Like alerts, a failure
+// is only logged.
+func (h *OrderHandler) stockChanged(ctx context.Context, movement *repository.StockMovement) {
+	if err := h.events.PublishStockChanged(ctx, *movement); err != nil {
+		slog.ErrorContext(ctx, "failed to publish stock change", "movementId", movement.ID, "productId", movement.ProductID, "error", err)
+	}
+}
+
+// alert publishes a stock alert when the change crossed the reorder
+// threshold. The stock already moved, so a failure is only logged: failing
+// the message would skip the movement as a duplicate on redelivery anyway.
+func (h *OrderHandler) alert(ctx context.Context, change *repository.StockChange, orderID string) {
+	alertType := stockAlertType(change)
+	if alertType == "" {
+		return
+	}
+	stockAlerts.Add(ctx, 1, metric.WithAttributes(tracing.StringAttribute("type", alertType)))
+
+	err := h.events.PublishStockAlert(ctx, publisher.StockAlert{
+		Type:             alertType,
+		ProductID:        change.ProductID,
+		Name:             change.Name,
+		Stock:            change.After,
+		ReorderThreshold: change.ReorderThreshold,
+		OrderID:          orderID,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to publish stock alert", "alertType", alertType, "productId", change.ProductID, "error", err)
+	}
+}
+
+// allocate decides which warehouses fulfill the order and stores the
+// decision before any stock moves, so a redelivered event reuses it instead
+// of allocating again against stock it already took.
+func (h *OrderHandler) allocate(ctx context.Context, order *OrderMessage) (*allocation.Allocation, error) {
+	ctx, span := tracing.NewSpan(ctx, "OrderHandler#allocate")
+	defer span.End()
+	span.SetAttributes(
+		tracing.StringAttribute("orderId", order.OrderID),
+		tracing.StringAttribute("strategy", h.strategy.Name()),
+	)
+
+	existing, err := h.allocations.GetByOrderID(ctx, order.OrderID)
+	if err != nil || (existing != nil && existing.Allocated()) {
+		return existing, err
+	}
+
+	plan := &allocation.Allocation{
+		OrderID:   order.OrderID,
+		Strategy:  h.strategy.Name(),
+		Lines:     []allocation.Line{},
+		CreatedAt: time.Now().UTC().Format(time.RFC3339Nano),
+	}
+	err = h.forLocatedItems(ctx, order, func(item allocation.Item, warehouses []allocation.Warehouse) {
+		plan.Lines = append(plan.Lines, h.strategy.Allocate(item, warehouses, order.ShipTo)...)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = h.allocations.Create(ctx, plan)
+	if errors.Is(err, repository.ErrAllocationExists) {
+		return h.allocations.GetByOrderID(ctx, order.OrderID)
+	}
+	return plan, err
+}
+
+// restock returns where stock coming back from a canceled or returned order
+// goes: the warehouses it was allocated from, or for orders allocated before
+// their products were stocked per location, the preferred warehouse.
+func (h *OrderHandler) restock(ctx context.Context, order *OrderMessage) (*allocation.Allocation, error) {
+	ctx, span := tracing.NewSpan(ctx, "OrderHandler#restock")
+	defer span.End()
+	span.SetAttributes(
+		tracing.StringAttribute("orderId", order.OrderID),
+	)
+
+	existing, err := h.allocations.GetByOrderID(ctx, order.OrderID)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &allocation.Allocation{OrderID: order.OrderID}
+	err = h.forLocatedItems(ctx, order, func(item allocation.Item, warehouses []allocation.Warehouse) {
+		if existing != nil {
+			if lines := existing.LinesFor(item.Index); len(lines) > 0 {
+				plan.Lines = append(plan.Lines, lines...)
+				return
+			}
+		}
+		plan.Lines = append(plan.Lines, allocation.Restock(item, warehouses))
+	})
+	return plan, err
+}
+
+// forLocatedItems calls fn for every order item whose stock is tracked per
+// location. Items of missing or archived products are left out: their
+// stock is not moved.
+func (h *OrderHandler) forLocatedItems(ctx context.Context, order *OrderMessage, fn func(allocation.Item, []allocation.Warehouse)) error {
+	var warehouses []allocation.Warehouse
+	for i, item := range order.Items {
+		locations, err := h.repo.StockLocations(ctx, item.ProductID, item.SKU)
+		if unavailable(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if len(locations) == 0 {
+			continue
+		}
+		if warehouses == nil {
+			if warehouses, err = h.warehouses.GetAll(ctx); err != nil {
+				return err
+			}
+		}
+		fn(allocation.Item{
+			Index:     i,
+			ProductID: item.ProductID,
+			SKU:       item.SKU,
+			Quantity:  item.Quantity,
+			Stock:     locations,
+		}, warehouses)
+	}
+	return nil
+}
+
+// unavailable reports whether err refused a stock update because the
+// product was deleted or archived, or because the item does not say which
+// variant of the product was ordered. Retrying the message would not change
+// that, so such items are skipped instead of failing the whole order.
+func unavailable(err error) bool {
+	return errors.Is(err, repository.ErrProductNotFound) ||
+		errors.Is(err, repository.ErrProductArchived) ||
+		errors.Is(err, repository.ErrSKURequired)
+}
@@ -0,0 +1,38 @@
+package processor
+
+import (
+	"context"
+	"telemetry/metrics"
+	"telemetry/tracing"
+
+	"go.opentelemetry.io/otel/metric"
+)
+
+var (
+	meter = metrics.Meter("products-worker/internal/processor")
+
+	unitsSold, _ = meter.Int64Counter(
+		"products.units_sold",
+		metric.WithUnit("{unit}"),
+		metric.WithDescription("Units taken from stock by orders"),
+	)
+	unitsRestocked, _ = meter.Int64Counter(
+		"products.units_restocked",
+		metric.WithUnit("{unit}"),
+		metric.WithDescription("Units put back in stock by canceled and returned orders, by reason"),
+	)
+	stockAlerts, _ = meter.Int64Counter(
+		"products.stock_alerts",
+		metric.WithUnit("{alert}"),
+		metric.WithDescription("Stock alerts raised, by type"),
+	)
+)
+
+// recordMovement counts the units of an applied stock movement.
+func recordMovement(ctx context.Context, reason string, delta int) {
+	if delta < 0 {
+		unitsSold.Add(ctx, int64(-delta))
+		return
+	}
+	unitsRestocked.Add(ctx, int64(delta), metric.WithAttributes(tracing.StringAttribute("reason", reason)))
+}
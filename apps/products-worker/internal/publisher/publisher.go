@@ -0,0 +1,30 @@
+package publisher
+
+import (
+	"context"
+	"products-worker/internal/repository"
+)
+
+const (
+	StockLow         = "stock.low"
+	StockDepleted    = "stock.depleted"
+	StockReplenished = "stock.replenished"
+	// StockChanged is the event products-service publishes for its own
+	// stock movements; the worker publishes it for the movements of orders.
+	StockChanged = "product.stock_changed"
+)
+
+// StockAlert reports a product crossing its reorder threshold.
+type StockAlert struct {
+	Type             string `json:"type"`
+	ProductID        string `json:"productId"`
+	Name             string `json:"name"`
+	Stock            int    `json:"stock"`
+	ReorderThreshold int    `json:"reorderThreshold"`
+	OrderID          string `json:"orderId,omitempty"`
+}
+
+type StockEventPublisher interface {
+	PublishStockAlert(ctx context.Context, alert StockAlert) error
+	PublishStockChanged(ctx context.Context, movement repository.StockMovement) error
+}
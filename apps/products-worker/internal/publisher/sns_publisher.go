@@ -0,0 +1,114 @@
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+	"products-worker/internal/repository"
+	"telemetry/tracing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"github.com/google/uuid"
+)
+
+type SnsStockEventPublisher struct {
+	client   *sns.Client
+	topicArn string
+}
+
+func NewSnsStockEventPublisher(client *sns.Client, topicArn string) *SnsStockEventPublisher {
+	return &SnsStockEventPublisher{
+		client:   client,
+		topicArn: topicArn,
+	}
+}
+
+func (p *SnsStockEventPublisher) PublishStockAlert(ctx context.Context, alert StockAlert) error {
+	ctx, span := tracing.NewSpan(ctx, "SnsStockEventPublisher#PublishStockAlert")
+	defer span.End()
+
+	span.SetAttributes(
+		tracing.StringAttribute("productId", alert.ProductID),
+		tracing.StringAttribute("eventType", alert.Type),
+	)
+
+	payload := struct {
+		StockAlert
+		EventID  string `json:"eventId"`
+		Datetime string `json:"datetime"`
+	}{
+		StockAlert: alert,
+		EventID:    uuid.New().String(),
+		Datetime:   time.Now().UTC().Format(time.RFC3339),
+	}
+
+	err := p.publish(ctx, alert.Type, payload)
+	if err != nil {
+		span.RecordError(err)
+	}
+	return err
+}
+
+// PublishStockChanged publishes the movement in the same shape as the
+// product.stock_changed events of products-service.
+func (p *SnsStockEventPublisher) PublishStockChanged(ctx context.Context, movement repository.StockMovement) error {
+	ctx, span := tracing.NewSpan(ctx, "SnsStockEventPublisher#PublishStockChanged")
+	defer span.End()
+
+	span.SetAttributes(
+		tracing.StringAttribute("productId", movement.ProductID),
+		tracing.StringAttribute("eventType", StockChanged),
+	)
+
+	payload := map[string]interface{}{
+		"type":      StockChanged,
+		"eventId":   uuid.New().String(),
+		"productId": movement.ProductID,
+		"datetime":  time.Now().UTC().Format(time.RFC3339),
+		"movement":  movement,
+	}
+
+	err := p.publish(ctx, StockChanged, payload)
+	if err != nil {
+		span.RecordError(err)
+	}
+	return err
+}
+
+func (p *SnsStockEventPublisher) publish(ctx context.Context, eventType string, payload interface{}) error {
+	ctx, span := tracing.NewPublishSpan(ctx, tracing.MessagingSNS, tracing.TopicName(p.topicArn))
+	defer span.End()
+
+	messageAttributes := map[string]types.MessageAttributeValue{
+		"eventType": {
+			DataType:    aws.String("String"),
+			StringValue: aws.String(eventType),
+		},
+	}
+	// The trace context and baggage travel along for the consumers.
+	for key, value := range tracing.MessageHeaders(ctx) {
+		messageAttributes[key] = types.MessageAttributeValue{
+			DataType:    aws.String("String"),
+			StringValue: aws.String(value),
+		}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	output, err := p.client.Publish(ctx, &sns.PublishInput{
+		TopicArn:          aws.String(p.topicArn),
+		Message:           aws.String(string(body)),
+		MessageAttributes: messageAttributes,
+	})
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	tracing.SetMessageID(span, aws.ToString(output.MessageId))
+	return nil
+}
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"products-worker/internal/allocation"
+	"telemetry/tracing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ErrAllocationExists is returned when the order was already allocated,
+// i.e. its order.created event has been processed before.
+var ErrAllocationExists = errors.New("order already allocated")
+
+type AllocationRepository interface {
+	// Create stores the allocation unless the order was allocated already.
+	// It fills in the holds confirmed for the order before.
+	Create(ctx context.Context, a *allocation.Allocation) error
+	// GetByOrderID returns nil when the order has no allocation.
+	GetByOrderID(ctx context.Context, orderID string) (*allocation.Allocation, error)
+}
+
+type DynamoAllocationRepository struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+func NewDynamoAllocationRepository(client *dynamodb.Client, tableName string) *DynamoAllocationRepository {
+	return &DynamoAllocationRepository{client: client, tableName: tableName}
+}
+
+func (r *DynamoAllocationRepository) Create(ctx context.Context, a *allocation.Allocation) error {
+	ctx, span := tracing.NewSpan(ctx, "DynamoAllocationRepository#Create")
+	defer span.End()
+	span.SetAttributes(
+		tracing.StringAttribute("orderId", a.OrderID),
+		tracing.StringAttribute("strategy", a.Strategy),
+	)
+
+	lines, err := attributevalue.Marshal(a.Lines)
+	if err != nil {
+		return err
+	}
+
+	// The item exists already when products-service confirmed reservations
+	// for the order; their holds are kept.
+	output, err := r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: &r.tableName,
+		Key: map[string]types.AttributeValue{
+			"orderId": &types.AttributeValueMemberS{Value: a.OrderID},
+		},
+		UpdateExpression:    aws.String("SET #strategy = :strategy, #lines = :lines, #createdAt = :createdAt"),
+		ConditionExpression: aws.String("attribute_not_exists(#strategy)"),
+		ExpressionAttributeNames: map[string]string{
+			"#strategy":  "strategy",
+			"#lines":     "lines",
+			"#createdAt": "createdAt",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":strategy":  &types.AttributeValueMemberS{Value: a.Strategy},
+			":lines":     lines,
+			":createdAt": &types.AttributeValueMemberS{Value: a.CreatedAt},
+		},
+		ReturnValues: types.ReturnValueAllNew,
+	})
+	var conditionErr *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionErr) {
+		return ErrAllocationExists
+	}
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	return attributevalue.UnmarshalMap(output.Attributes, a)
+}
+
+func (r *DynamoAllocationRepository) GetByOrderID(ctx context.Context, orderID string) (*allocation.Allocation, error) {
+	ctx, span := tracing.NewSpan(ctx, "DynamoAllocationRepository#GetByOrderID")
+	defer span.End()
+	span.SetAttributes(
+		tracing.StringAttribute("orderId", orderID),
+	)
+
+	output, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: &r.tableName,
+		Key: map[string]types.AttributeValue{
+			"orderId": &types.AttributeValueMemberS{Value: orderID},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	if len(output.Item) == 0 {
+		return nil, nil
+	}
+
+	var a allocation.Allocation
+	if err := attributevalue.UnmarshalMap(output.Item, &a); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
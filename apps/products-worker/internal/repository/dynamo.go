@@ -2,61 +2,386 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"products-worker/internal/tracing"
+	"strings"
+	"telemetry/tracing"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 )
 
+// maxStockWriteAttempts bounds the optimistic retries when the stock changes
+// between reading it and writing the movement.
+const maxStockWriteAttempts = 5
+
+// ErrDuplicateMovement is returned when the movement was already recorded,
+// i.e. the event that caused it has been processed before.
+var ErrDuplicateMovement = errors.New("stock movement already recorded")
+
+// ErrProductNotFound is returned when the product of a movement does not
+// exist, e.g. because it was deleted after the order was placed.
+var ErrProductNotFound = errors.New("product not found")
+
+// ErrProductArchived is returned when the product of a movement was
+// archived. Its stock is frozen until it is restored.
+var ErrProductArchived = errors.New("product is archived")
+
+// ErrInsufficientStock is returned when a movement would take a warehouse
+// or a variant below zero. Only the stock of products without variants or
+// locations can be backordered into the negative.
+var ErrInsufficientStock = errors.New("not enough stock")
+
+// ErrSKURequired is returned when a movement on a product with variants
+// does not say which variant it applies to.
+var ErrSKURequired = errors.New("sku is required for products with variants")
+
+// productArchived is the products-service status of archived products.
+const productArchived = "archived"
+
+// StockMovement mirrors the products-service ledger entry.
+type StockMovement struct {
+	ID          string `json:"id" dynamodbav:"id"`
+	ProductID   string `json:"productId" dynamodbav:"productId"`
+	SKU         string `json:"sku,omitempty" dynamodbav:"sku,omitempty"`
+	WarehouseID string `json:"warehouseId,omitempty" dynamodbav:"warehouseId,omitempty"`
+	OrderID     string `json:"orderId,omitempty" dynamodbav:"orderId,omitempty"`
+	EventID     string `json:"eventId,omitempty" dynamodbav:"eventId,omitempty"`
+	Reason      string `json:"reason" dynamodbav:"reason"`
+	Actor       string `json:"actor" dynamodbav:"actor"`
+	Delta       int    `json:"delta" dynamodbav:"delta"`
+	Balance     int    `json:"balance" dynamodbav:"balance"`
+	CreatedAt   string `json:"createdAt" dynamodbav:"createdAt"`
+	Note        string `json:"note,omitempty" dynamodbav:"note,omitempty"`
+	// Unreserve is the part of a sale held by reservations confirmed for
+	// the order, released from the reserved stock as the stock is taken.
+	Unreserve int `json:"-" dynamodbav:"-"`
+}
+
+// StockChange is the stock of a product before and after a movement.
+type StockChange struct {
+	ProductID        string
+	Name             string
+	Before           int
+	After            int
+	ReorderThreshold int
+}
+
 type ProductRepository interface {
-	DecrementStock(ctx context.Context, id string, qty int) error
-	IncrementStock(ctx context.Context, id string, qty int) error
+	// StockLocations returns the stock per warehouse of the product, or of
+	// its variant when sku is set. It is empty when the stock is not tracked
+	// per location.
+	StockLocations(ctx context.Context, productID, sku string) (map[string]int, error)
+	// AvailableStock returns the stock of the variant, when sku is set, at
+	// the warehouse, when warehouseID is set.
+	AvailableStock(ctx context.Context, productID, sku, warehouseID string) (int, error)
+	// ApplyStockMovement changes the stock by movement.Delta and records the
+	// movement, filling in its Balance. It returns how the product stock
+	// changed.
+	ApplyStockMovement(ctx context.Context, movement *StockMovement) (*StockChange, error)
 }
 
 type DynamoProductRepository struct {
-	client    *dynamodb.Client
-	tableName string
+	client         *dynamodb.Client
+	tableName      string
+	movementsTable string
+}
+
+func NewDynamoProductRepository(client *dynamodb.Client, table string, movementsTable string) *DynamoProductRepository {
+	return &DynamoProductRepository{client: client, tableName: table, movementsTable: movementsTable}
 }
 
-func NewDynamoProductRepository(client *dynamodb.Client, table string) *DynamoProductRepository {
-	return &DynamoProductRepository{client: client, tableName: table}
+type stockLevels struct {
+	Status           string         `dynamodbav:"status"`
+	Name             string         `dynamodbav:"name"`
+	ReorderThreshold int            `dynamodbav:"reorderThreshold"`
+	Stock            int            `dynamodbav:"stock"`
+	Reserved         int            `dynamodbav:"reserved"`
+	Locations        map[string]int `dynamodbav:"locations"`
+	Variants         map[string]struct {
+		Stock     int            `dynamodbav:"stock"`
+		Reserved  int            `dynamodbav:"reserved"`
+		Locations map[string]int `dynamodbav:"locations"`
+	} `dynamodbav:"variants"`
 }
 
-func (r *DynamoProductRepository) updateStock(ctx context.Context, id string, qty int) error {
-	ctx, span := tracing.NewSpan(ctx, "DynamoProductRepository#updateStock")
+func (r *DynamoProductRepository) getStockLevels(ctx context.Context, productID string) (*stockLevels, error) {
+	output, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      &r.tableName,
+		Key:            productKey(productID),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(output.Item) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrProductNotFound, productID)
+	}
+
+	var levels stockLevels
+	if err := attributevalue.UnmarshalMap(output.Item, &levels); err != nil {
+		return nil, err
+	}
+	if levels.Status == productArchived {
+		return nil, fmt.Errorf("%w: %s", ErrProductArchived, productID)
+	}
+	return &levels, nil
+}
+
+func (r *DynamoProductRepository) StockLocations(ctx context.Context, productID, sku string) (map[string]int, error) {
+	ctx, span := tracing.NewSpan(ctx, "DynamoProductRepository#StockLocations")
 	defer span.End()
+	span.SetAttributes(
+		tracing.StringAttribute("productId", productID),
+		tracing.StringAttribute("sku", sku),
+	)
+
+	levels, err := r.getStockLevels(ctx, productID)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	if sku == "" {
+		return levels.Locations, nil
+	}
+	variant, ok := levels.Variants[sku]
+	if !ok {
+		return nil, fmt.Errorf("variant %s not found on product %s", sku, productID)
+	}
+	return variant.Locations, nil
+}
 
+func (r *DynamoProductRepository) AvailableStock(ctx context.Context, productID, sku, warehouseID string) (int, error) {
+	ctx, span := tracing.NewSpan(ctx, "DynamoProductRepository#AvailableStock")
+	defer span.End()
 	span.SetAttributes(
-		tracing.StringAttribute("productId", id),
-		tracing.IntAttribute("quantity", qty),
+		tracing.StringAttribute("productId", productID),
+		tracing.StringAttribute("sku", sku),
+		tracing.StringAttribute("warehouseId", warehouseID),
 	)
 
-	key := map[string]types.AttributeValue{
-		"id": &types.AttributeValueMemberS{Value: id},
+	levels, err := r.getStockLevels(ctx, productID)
+	if err != nil {
+		span.RecordError(err)
+		return 0, err
+	}
+	stock, locations := levels.Stock, levels.Locations
+	if sku != "" {
+		variant, ok := levels.Variants[sku]
+		if !ok {
+			return 0, fmt.Errorf("variant %s not found on product %s", sku, productID)
+		}
+		stock, locations = variant.Stock, variant.Locations
 	}
+	if warehouseID != "" {
+		stock = locations[warehouseID]
+	}
+	return stock, nil
+}
 
-	update := "SET stock = stock + :val"
+// ApplyStockMovement reads the current stock and writes the new stock and the
+// movement in one transaction. The movement put is conditioned on its id not
+// existing, which makes redelivered events a no-op, and the stock update is
+// conditioned on the value that was read so concurrent writers retry.
+func (r *DynamoProductRepository) ApplyStockMovement(ctx context.Context, movement *StockMovement) (*StockChange, error) {
+	ctx, span := tracing.NewSpan(ctx, "DynamoProductRepository#ApplyStockMovement")
+	defer span.End()
+
+	span.SetAttributes(
+		tracing.StringAttribute("productId", movement.ProductID),
+		tracing.StringAttribute("sku", movement.SKU),
+		tracing.StringAttribute("warehouseId", movement.WarehouseID),
+		tracing.StringAttribute("reason", movement.Reason),
+		tracing.IntAttribute("quantity", movement.Delta),
+	)
+
+	for attempt := 0; attempt < maxStockWriteAttempts; attempt++ {
+		levels, err := r.getStockLevels(ctx, movement.ProductID)
+		if err != nil {
+			span.RecordError(err)
+			return nil, err
+		}
+
+		update, err := r.stockUpdate(levels, movement)
+		if err != nil {
+			return nil, err
+		}
+
+		item, err := attributevalue.MarshalMap(movement)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = r.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+			TransactItems: []types.TransactWriteItem{
+				{
+					Put: &types.Put{
+						TableName:           &r.movementsTable,
+						Item:                item,
+						ConditionExpression: aws.String("attribute_not_exists(id)"),
+					},
+				},
+				{Update: update},
+			},
+		})
+		if err == nil {
+			return &StockChange{
+				ProductID:        movement.ProductID,
+				Name:             levels.Name,
+				Before:           levels.Stock,
+				After:            levels.Stock + movement.Delta,
+				ReorderThreshold: levels.ReorderThreshold,
+			}, nil
+		}
+
+		var canceled *types.TransactionCanceledException
+		if !errors.As(err, &canceled) {
+			span.RecordError(err)
+			return nil, err
+		}
+		if conditionFailed(canceled, 0) {
+			return nil, ErrDuplicateMovement
+		}
+		if !conditionFailed(canceled, 1) {
+			span.RecordError(err)
+			return nil, err
+		}
+	}
+
+	err := fmt.Errorf("stock of product %s kept changing, gave up after %d attempts", movement.ProductID, maxStockWriteAttempts)
+	span.RecordError(err)
+	return nil, err
+}
+
+// stockUpdate sets the product stock, and the variant and warehouse stock
+// the movement applies to, and releases the reserved stock it took, on the
+// condition that each still holds the value that was read, that a variant
+// or warehouse holds the units taken from it and that the product was
+// neither deleted nor archived in the meantime, so the update never
+// recreates a deleted product. It fills in the movement Balance.
+func (r *DynamoProductRepository) stockUpdate(levels *stockLevels, movement *StockMovement) (*types.Update, error) {
+	current := levels.Stock
+	locations := levels.Locations
+	locationsPath := "locations"
+	names := map[string]string{}
+	if movement.SKU == "" && len(levels.Variants) > 0 {
+		return nil, fmt.Errorf("%w: product %s", ErrSKURequired, movement.ProductID)
+	}
+	if movement.SKU != "" {
+		variant, ok := levels.Variants[movement.SKU]
+		if !ok {
+			return nil, fmt.Errorf("variant %s not found on product %s", movement.SKU, movement.ProductID)
+		}
+		current = variant.Stock
+		locations = variant.Locations
+		locationsPath = "variants.#sku.locations"
+		names["#sku"] = movement.SKU
+	}
+	// A movement that only releases reserved stock moves none, so it needs
+	// no warehouse.
+	if movement.WarehouseID == "" && len(locations) > 0 && movement.Delta != 0 {
+		return nil, fmt.Errorf("product %s is stocked per warehouse, movement has no warehouse", movement.ProductID)
+	}
+	if movement.WarehouseID != "" {
+		current = locations[movement.WarehouseID]
+	}
+	movement.Balance = current + movement.Delta
+	if (movement.SKU != "" || movement.WarehouseID != "") && movement.Balance < 0 {
+		return nil, fmt.Errorf("%w: %d left of product %s", ErrInsufficientStock, current, movement.ProductID)
+	}
+
+	sets := []string{"stock = :stock"}
+	conditions := []string{
+		"attribute_exists(id)",
+		"(attribute_not_exists(#status) OR #status <> :archived)",
+		"stock = :currentStock",
+	}
+	names["#status"] = "status"
 	values := map[string]types.AttributeValue{
-		":val": &types.AttributeValueMemberN{Value: stringInt(qty)},
+		":stock":        numberValue(levels.Stock + movement.Delta),
+		":currentStock": numberValue(levels.Stock),
+		":archived":     &types.AttributeValueMemberS{Value: productArchived},
 	}
+	// Reserved stock only ever goes down here, so it was read non-zero and
+	// the attribute exists.
+	if reserved := releaseReserved(levels.Reserved, levels.Stock+movement.Delta, movement.Unreserve); reserved != levels.Reserved {
+		names["#reserved"] = "reserved"
+		sets = append(sets, "#reserved = :reserved")
+		conditions = append(conditions, "#reserved = :currentReserved")
+		values[":reserved"] = numberValue(reserved)
+		values[":currentReserved"] = numberValue(levels.Reserved)
+	}
+	if movement.SKU != "" {
+		variant := levels.Variants[movement.SKU]
+		variantStock := variant.Stock
+		if reserved := releaseReserved(variant.Reserved, variantStock+movement.Delta, movement.Unreserve); reserved != variant.Reserved {
+			names["#reserved"] = "reserved"
+			sets = append(sets, "variants.#sku.#reserved = :variantReserved")
+			conditions = append(conditions, "variants.#sku.#reserved = :currentVariantReserved")
+			values[":variantReserved"] = numberValue(reserved)
+			values[":currentVariantReserved"] = numberValue(variant.Reserved)
+		}
+		sets = append(sets, "variants.#sku.stock = :variantStock")
+		conditions = append(conditions, "variants.#sku.stock = :currentVariantStock")
+		values[":variantStock"] = numberValue(variantStock + movement.Delta)
+		values[":currentVariantStock"] = numberValue(variantStock)
+		if movement.Delta < 0 {
+			conditions = append(conditions, "variants.#sku.stock >= :variantFloor")
+			values[":variantFloor"] = numberValue(-movement.Delta)
+		}
+	}
+	if movement.WarehouseID != "" {
+		names["#warehouse"] = movement.WarehouseID
+		path := locationsPath + ".#warehouse"
+		sets = append(sets, path+" = :warehouseStock")
+		values[":warehouseStock"] = numberValue(movement.Balance)
+		if _, exists := locations[movement.WarehouseID]; exists {
+			conditions = append(conditions, path+" = :currentWarehouseStock")
+			values[":currentWarehouseStock"] = numberValue(current)
+		} else {
+			conditions = append(conditions, "attribute_not_exists("+path+")")
+		}
+	}
+
+	// Every write to a product adds one to its version, which products-service
+	// replaces products on the condition of.
+	names["#version"] = "version"
+	values[":versionStep"] = numberValue(1)
 
-	_, err := r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+	update := &types.Update{
 		TableName:                 &r.tableName,
-		Key:                       key,
-		UpdateExpression:          &update,
+		Key:                       productKey(movement.ProductID),
+		UpdateExpression:          aws.String("SET " + strings.Join(sets, ", ") + " ADD #version :versionStep"),
+		ConditionExpression:       aws.String(strings.Join(conditions, " AND ")),
+		ExpressionAttributeNames:  names,
 		ExpressionAttributeValues: values,
-		ReturnValues:              types.ReturnValueUpdatedNew,
-	})
-	return err
+	}
+	return update, nil
+}
+
+// releaseReserved returns the reserved stock after a movement: the units
+// held for the order are released, and what is reserved is lowered further
+// to the new stock when that dropped below it, so more than is on hand is
+// never reserved.
+func releaseReserved(reserved, stock, unreserve int) int {
+	return min(max(reserved-unreserve, 0), max(stock, 0))
+}
+
+func productKey(id string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"id": &types.AttributeValueMemberS{Value: id},
+	}
 }
 
-func (r *DynamoProductRepository) DecrementStock(ctx context.Context, id string, qty int) error {
-	return r.updateStock(ctx, id, -qty)
+func numberValue(i int) types.AttributeValue {
+	return &types.AttributeValueMemberN{Value: stringInt(i)}
 }
 
-func (r *DynamoProductRepository) IncrementStock(ctx context.Context, id string, qty int) error {
-	return r.updateStock(ctx, id, qty)
+func conditionFailed(canceled *types.TransactionCanceledException, item int) bool {
+	reasons := canceled.CancellationReasons
+	return len(reasons) > item && aws.ToString(reasons[item].Code) == "ConditionalCheckFailed"
 }
 
 func stringInt(i int) string {
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"products-worker/internal/allocation"
+	"sync"
+	"telemetry/tracing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+type WarehouseRepository interface {
+	GetAll(ctx context.Context) ([]allocation.Warehouse, error)
+}
+
+// DynamoWarehouseRepository scans the warehouses table. Warehouses change
+// rarely, so the result is kept for ttl instead of scanning per message.
+type DynamoWarehouseRepository struct {
+	client    *dynamodb.Client
+	tableName string
+	ttl       time.Duration
+
+	mu         sync.Mutex
+	warehouses []allocation.Warehouse
+	loadedAt   time.Time
+}
+
+func NewDynamoWarehouseRepository(client *dynamodb.Client, tableName string, ttl time.Duration) *DynamoWarehouseRepository {
+	return &DynamoWarehouseRepository{client: client, tableName: tableName, ttl: ttl}
+}
+
+func (r *DynamoWarehouseRepository) GetAll(ctx context.Context) ([]allocation.Warehouse, error) {
+	ctx, span := tracing.NewSpan(ctx, "DynamoWarehouseRepository#GetAll")
+	defer span.End()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.warehouses != nil && time.Since(r.loadedAt) < r.ttl {
+		return copyWarehouses(r.warehouses), nil
+	}
+
+	warehouses := []allocation.Warehouse{}
+	paginator := dynamodb.NewScanPaginator(r.client, &dynamodb.ScanInput{
+		TableName: &r.tableName,
+	})
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			span.RecordError(err)
+			return nil, err
+		}
+		var page []allocation.Warehouse
+		if err := attributevalue.UnmarshalListOfMaps(output.Items, &page); err != nil {
+			return nil, err
+		}
+		warehouses = append(warehouses, page...)
+	}
+
+	r.warehouses = warehouses
+	r.loadedAt = time.Now()
+	return copyWarehouses(warehouses), nil
+}
+
+// copyWarehouses hands out a copy so callers can sort it freely.
+func copyWarehouses(warehouses []allocation.Warehouse) []allocation.Warehouse {
+	return append([]allocation.Warehouse(nil), warehouses...)
+}
@@ -3,54 +3,76 @@ package sqs
 import (
 	"context"
 	"encoding/json"
-	"log"
+	"log/slog"
 	"products-worker/internal/processor"
-	"products-worker/internal/tracing"
+	"telemetry/tracing"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
 )
 
 type SNSMessageWrapper struct {
-	Message           string                         `json:"Message"`
-	MessageAttributes map[string]SNSMessageAttribute `json:"MessageAttributes"`
-}
-
-type SNSMessageAttribute struct {
-	Type  string `json:"Type"`
-	Value string `json:"Value"`
+	Message           string                                 `json:"Message"`
+	MessageAttributes map[string]tracing.SNSMessageAttribute `json:"MessageAttributes"`
 }
 
 func ListenAndProcess(ctx context.Context, client *sqs.Client, queueURL string, handler processor.Handler) {
+	queue := tracing.QueueName(queueURL)
 	for {
+		start := time.Now()
 		output, err := client.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
 			QueueUrl:            &queueURL,
 			MaxNumberOfMessages: 10,
 			WaitTimeSeconds:     10,
+			MessageSystemAttributeNames: []types.MessageSystemAttributeName{
+				types.MessageSystemAttributeNameSentTimestamp,
+			},
 		})
 		if err != nil {
-			log.Printf("error receiving messages: %v", err)
+			slog.ErrorContext(ctx, "failed to receive messages", "error", err)
+			continue
+		}
+		recordBatch(ctx, queue, output.Messages)
+		if len(output.Messages) == 0 {
 			continue
 		}
 
-		for _, msg := range output.Messages {
-			if err := processMessage(ctx, client, queueURL, msg, handler); err != nil {
-				log.Printf("processing failed: %v", err)
+		wrappers := make([]SNSMessageWrapper, len(output.Messages))
+		headers := make([]map[string]string, len(output.Messages))
+		for i, msg := range output.Messages {
+			// An unreadable body has no headers and fails when processed.
+			_ = json.Unmarshal([]byte(aws.ToString(msg.Body)), &wrappers[i])
+			headers[i] = tracing.SNSHeaders(wrappers[i].MessageAttributes)
+		}
+
+		// Long polls that return nothing are not traced; the span of a
+		// batch starts with the poll that received it.
+		batchCtx, span := tracing.NewReceiveSpan(ctx, tracing.MessagingSQS, queue, start, headers)
+		span.End()
+
+		for i, msg := range output.Messages {
+			start := time.Now()
+			err := processMessage(batchCtx, client, queueURL, msg, headers[i], handler)
+			recordMessage(ctx, queue, start, err)
+			if err != nil {
+				slog.ErrorContext(batchCtx, "failed to process message", "messageId", aws.ToString(msg.MessageId), "error", err)
 			}
 		}
 	}
 }
 
-func processMessage(ctx context.Context, client *sqs.Client, queueURL string, msg types.Message, handler processor.Handler) error {
+func processMessage(ctx context.Context, client *sqs.Client, queueURL string, msg types.Message, headers map[string]string, handler processor.Handler) error {
+	ctx, span := tracing.NewProcessSpan(ctx, tracing.MessagingSQS, tracing.QueueName(queueURL), aws.ToString(msg.MessageId), headers)
+	defer span.End()
+
 	var sns SNSMessageWrapper
-	if err := json.Unmarshal([]byte(*msg.Body), &sns); err != nil {
+	if err := json.Unmarshal([]byte(aws.ToString(msg.Body)), &sns); err != nil {
+		span.RecordError(err)
 		return err
 	}
 
-	traceparent, _ := sns.MessageAttributes["traceparent"]
-	ctx, span := tracing.NewSpanWithTraceparent(ctx, "processMessage", traceparent.Value)
-	defer span.End()
-
 	if err := handler.HandleMessage(ctx, sns.Message); err != nil {
 		span.RecordError(err)
 		return err
@@ -0,0 +1,85 @@
+package sqs
+
+import (
+	"context"
+	"strconv"
+	"telemetry/metrics"
+	"telemetry/tracing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var (
+	meter = metrics.Meter("products-worker/internal/sqs")
+
+	messagesReceived, _ = meter.Int64Counter(
+		"messages.received",
+		metric.WithUnit("{message}"),
+		metric.WithDescription("Messages received from the queue"),
+	)
+	messagesProcessed, _ = meter.Int64Counter(
+		"messages.processed",
+		metric.WithUnit("{message}"),
+		metric.WithDescription("Messages processed and deleted from the queue"),
+	)
+	messagesFailed, _ = meter.Int64Counter(
+		"messages.failed",
+		metric.WithUnit("{message}"),
+		metric.WithDescription("Messages that failed and are left for redelivery"),
+	)
+	processingDuration, _ = meter.Float64Histogram(
+		"messages.processing.duration",
+		metric.WithUnit("s"),
+		metric.WithDescription("Time spent processing a message"),
+	)
+	oldestMessageAge, _ = meter.Float64Gauge(
+		"messages.oldest.age",
+		metric.WithUnit("s"),
+		metric.WithDescription("Age of the oldest message of the last batch received"),
+	)
+)
+
+// recordBatch counts the messages of a batch and the age of its oldest one,
+// which approximates how far the worker lags behind the queue. An empty
+// batch means the worker caught up, so the age drops back to zero.
+func recordBatch(ctx context.Context, queue string, messages []types.Message) {
+	messagesReceived.Add(ctx, int64(len(messages)), queueAttribute(queue))
+
+	age := 0.0
+	var oldest time.Time
+	for _, msg := range messages {
+		sent, err := strconv.ParseInt(msg.Attributes[string(types.MessageSystemAttributeNameSentTimestamp)], 10, 64)
+		if err != nil {
+			continue
+		}
+		if at := time.UnixMilli(sent); oldest.IsZero() || at.Before(oldest) {
+			oldest = at
+		}
+	}
+	if !oldest.IsZero() {
+		age = time.Since(oldest).Seconds()
+	}
+	oldestMessageAge.Record(ctx, age, queueAttribute(queue))
+}
+
+// recordMessage records the outcome of processing a message that started
+// at start.
+func recordMessage(ctx context.Context, queue string, start time.Time, err error) {
+	attribute := queueAttribute(queue)
+	outcome := "processed"
+	if err != nil {
+		outcome = "failed"
+		messagesFailed.Add(ctx, 1, attribute)
+	} else {
+		messagesProcessed.Add(ctx, 1, attribute)
+	}
+	processingDuration.Record(ctx, time.Since(start).Seconds(), attribute,
+		metric.WithAttributes(tracing.StringAttribute("outcome", outcome)))
+}
+
+// queueAttribute identifies the queue by name in the measurements.
+func queueAttribute(queue string) metric.MeasurementOption {
+	return metric.WithAttributes(tracing.StringAttribute("messaging.destination.name", queue))
+}
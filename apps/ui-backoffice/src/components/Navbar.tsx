@@ -2,9 +2,11 @@
 
 import Link from "next/link"
 import { usePathname } from "next/navigation"
+import { useUser } from "@/services/auth"
 
 export function Navbar() {
   const pathname = usePathname()
+  const user = useUser()
 
   const linkClass = (href: string) =>
     `px-4 py-2 rounded-md ${
@@ -26,6 +28,16 @@ export function Navbar() {
           Orders
         </Link>
       </div>
+      {user && (
+        <div className="ml-auto flex gap-4 items-center">
+          <span className="text-zinc-400">
+            {user.subject} ({user.roles.join(", ") || "no role"})
+          </span>
+          <a href="/api/auth/logout" className="text-zinc-300 hover:text-white">
+            Sign out
+          </a>
+        </div>
+      )}
     </nav>
   )
 }
\ No newline at end of file
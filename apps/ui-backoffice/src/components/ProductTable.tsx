@@ -1,6 +1,6 @@
 "use client"
 
-import { Product, deleteProduct, updateProduct } from "@/services/products"
+import { Product, deleteProduct, recountStock, updateProduct } from "@/services/products"
 import { useState } from "react"
 
 export function ProductTable({ products, reload }: { products: Product[]; reload: () => void }) {
@@ -17,8 +17,13 @@ export function ProductTable({ products, reload }: { products: Product[]; reload
     })
   }
 
-  const handleSave = async (id: string) => {
-    await updateProduct(id, editData)
+  const handleSave = async (product: Product) => {
+    // Stock changes go through the stock ledger as a recount.
+    const { stock, ...fields } = editData
+    await updateProduct(product.id, fields)
+    if (stock != null && stock !== product.stock) {
+      await recountStock(product.id, stock)
+    }
     setEditingId(null)
     reload()
   }
@@ -98,7 +103,7 @@ export function ProductTable({ products, reload }: { products: Product[]; reload
               <td className="py-2 px-4 flex gap-2">
                 {editingId === product.id ? (
                   <button
-                    onClick={() => handleSave(product.id)}
+                    onClick={() => handleSave(product)}
                     className="bg-green-600 hover:bg-green-500 transition px-3 py-1 rounded"
                   >
                     Save
@@ -115,7 +120,7 @@ export function ProductTable({ products, reload }: { products: Product[]; reload
                   onClick={() => handleDelete(product.id)}
                   className="bg-red-600 hover:bg-red-500 transition px-3 py-1 rounded"
                 >
-                  Delete
+                  Archive
                 </button>
               </td>
             </tr>
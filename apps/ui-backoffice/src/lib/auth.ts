@@ -0,0 +1,185 @@
+import { createHash, createPrivateKey, createPublicKey, randomBytes, sign } from "crypto"
+import { readFileSync } from "fs"
+import type { NextApiRequest, NextApiResponse } from "next"
+
+// Users sign in with the OpenID Connect provider (authorization code flow
+// with PKCE) and their access token is kept in an httpOnly cookie, which the
+// proxies forward to the services as a bearer token. The services verify the
+// token; the UI only reads it to show who is signed in.
+//
+// Without a provider, AUTH_DEV_SIGNING_KEY enables a local sign-in that signs
+// tokens with the key tools/token generates.
+
+// Cookies are shared by the ports of a host, so the names keep the session
+// apart from the one of ui-web when both run on localhost.
+const SESSION_COOKIE = "backoffice_session"
+const LOGIN_COOKIE = "backoffice_login"
+const LOGIN_TTL_SECONDS = 600
+const DEV_TOKEN_TTL_SECONDS = 3600
+
+export interface User {
+  subject: string
+  roles: string[]
+}
+
+interface Discovery {
+  authorization_endpoint: string
+  token_endpoint: string
+}
+
+interface PendingLogin {
+  state: string
+  verifier: string
+  returnTo: string
+}
+
+export function oidcEnabled() {
+  return !!process.env.OIDC_ISSUER && !!process.env.OIDC_CLIENT_ID
+}
+
+export function devLoginEnabled() {
+  return !!process.env.AUTH_DEV_SIGNING_KEY
+}
+
+// authHeaders returns the Authorization header for the services, or none
+// when the user is not signed in.
+export function authHeaders(req: NextApiRequest): Record<string, string> {
+  const token = req.cookies[SESSION_COOKIE]
+  return token ? { Authorization: `Bearer ${token}` } : {}
+}
+
+// currentUser reads the signed-in user from the session cookie. The token is
+// not verified here, the services do that on every call.
+export function currentUser(req: NextApiRequest): User | null {
+  const token = req.cookies[SESSION_COOKIE]
+  if (!token) {
+    return null
+  }
+  try {
+    const claims = JSON.parse(Buffer.from(token.split(".")[1], "base64url").toString())
+    if (typeof claims.exp === "number" && claims.exp * 1000 < Date.now()) {
+      return null
+    }
+    const roles = Array.isArray(claims.roles)
+      ? claims.roles
+      : typeof claims.roles === "string"
+        ? claims.roles.split(" ")
+        : []
+    return { subject: claims.sub, roles }
+  } catch {
+    return null
+  }
+}
+
+// safeReturnTo keeps the user on this site after signing in.
+export function safeReturnTo(value: unknown) {
+  return typeof value === "string" && value.startsWith("/") && !value.startsWith("//") ? value : "/"
+}
+
+async function discover(): Promise<Discovery> {
+  const issuer = (process.env.OIDC_ISSUER || "").replace(/\/$/, "")
+  const res = await fetch(`${issuer}/.well-known/openid-configuration`)
+  if (!res.ok) {
+    throw new Error(`OpenID configuration answered ${res.status}`)
+  }
+  return res.json()
+}
+
+// startLogin redirects the user to the provider.
+export async function startLogin(req: NextApiRequest, res: NextApiResponse, returnTo: string) {
+  const { authorization_endpoint } = await discover()
+  const login: PendingLogin = {
+    state: randomBytes(16).toString("base64url"),
+    verifier: randomBytes(32).toString("base64url"),
+    returnTo,
+  }
+  const params = new URLSearchParams({
+    response_type: "code",
+    client_id: process.env.OIDC_CLIENT_ID || "",
+    redirect_uri: process.env.OIDC_REDIRECT_URI || "",
+    scope: process.env.OIDC_SCOPE || "openid",
+    state: login.state,
+    code_challenge: createHash("sha256").update(login.verifier).digest("base64url"),
+    code_challenge_method: "S256",
+  })
+  setCookie(req, res, LOGIN_COOKIE, Buffer.from(JSON.stringify(login)).toString("base64url"), LOGIN_TTL_SECONDS)
+  res.redirect(302, `${authorization_endpoint}?${params}`)
+}
+
+// finishLogin exchanges the code the provider redirected back with for the
+// access token, and returns where the user started signing in.
+export async function finishLogin(req: NextApiRequest, res: NextApiResponse): Promise<string> {
+  const cookie = req.cookies[LOGIN_COOKIE]
+  if (!cookie) {
+    throw new Error("No sign-in in progress")
+  }
+  const login: PendingLogin = JSON.parse(Buffer.from(cookie, "base64url").toString())
+  if (typeof req.query.code !== "string" || req.query.state !== login.state) {
+    throw new Error("Invalid sign-in response")
+  }
+
+  const { token_endpoint } = await discover()
+  const body = new URLSearchParams({
+    grant_type: "authorization_code",
+    code: req.query.code,
+    redirect_uri: process.env.OIDC_REDIRECT_URI || "",
+    client_id: process.env.OIDC_CLIENT_ID || "",
+    code_verifier: login.verifier,
+  })
+  if (process.env.OIDC_CLIENT_SECRET) {
+    body.set("client_secret", process.env.OIDC_CLIENT_SECRET)
+  }
+  const tokenRes = await fetch(token_endpoint, {
+    method: "POST",
+    headers: { "Content-Type": "application/x-www-form-urlencoded" },
+    body,
+  })
+  if (!tokenRes.ok) {
+    throw new Error(`Token endpoint answered ${tokenRes.status}`)
+  }
+  const tokens = await tokenRes.json()
+
+  setCookie(req, res, LOGIN_COOKIE, "", 0)
+  setCookie(req, res, SESSION_COOKIE, tokens.access_token, tokens.expires_in || 300)
+  return login.returnTo
+}
+
+// devLogin signs a token for the subject and roles with the local key, as
+// the provider would.
+export function devLogin(req: NextApiRequest, res: NextApiResponse, subject: string, roles: string[]) {
+  const key = createPrivateKey(readFileSync(process.env.AUTH_DEV_SIGNING_KEY || ""))
+  const jwk = createPublicKey(key).export({ format: "jwk" })
+  // The JWK thumbprint, the key id tools/token publishes the key under.
+  const kid = createHash("sha256")
+    .update(`{"crv":"${jwk.crv}","kty":"EC","x":"${jwk.x}","y":"${jwk.y}"}`)
+    .digest("base64url")
+
+  const now = Math.floor(Date.now() / 1000)
+  const claims: Record<string, unknown> = {
+    iss: process.env.AUTH_ISSUER,
+    sub: subject,
+    iat: now,
+    exp: now + DEV_TOKEN_TTL_SECONDS,
+    roles,
+  }
+  if (process.env.AUTH_AUDIENCE) {
+    claims.aud = process.env.AUTH_AUDIENCE
+  }
+  const encode = (value: object) => Buffer.from(JSON.stringify(value)).toString("base64url")
+  const unsigned = `${encode({ alg: "ES256", typ: "JWT", kid })}.${encode(claims)}`
+  const signature = sign("sha256", Buffer.from(unsigned), { key, dsaEncoding: "ieee-p1363" })
+
+  setCookie(req, res, SESSION_COOKIE, `${unsigned}.${signature.toString("base64url")}`, DEV_TOKEN_TTL_SECONDS)
+}
+
+export function logout(req: NextApiRequest, res: NextApiResponse) {
+  setCookie(req, res, SESSION_COOKIE, "", 0)
+}
+
+function setCookie(req: NextApiRequest, res: NextApiResponse, name: string, value: string, maxAge: number) {
+  const secure = req.headers["x-forwarded-proto"] === "https" ? "; Secure" : ""
+  const cookie = `${name}=${value}; Path=/; HttpOnly; SameSite=Lax; Max-Age=${maxAge}${secure}`
+  const existing = res.getHeader("Set-Cookie")
+  const cookies = Array.isArray(existing) ? existing : existing ? [String(existing)] : []
+  res.setHeader("Set-Cookie", [...cookies, cookie])
+}
@@ -0,0 +1,17 @@
+import { NextResponse } from "next/server"
+import type { NextRequest } from "next/server"
+
+// Every page of the back office takes a signed-in user; the others are sent
+// to sign in first. The services check the token and the roles.
+export function middleware(request: NextRequest) {
+  if (request.cookies.has("backoffice_session")) {
+    return NextResponse.next()
+  }
+  const login = new URL("/api/auth/login", request.url)
+  login.searchParams.set("returnTo", request.nextUrl.pathname + request.nextUrl.search)
+  return NextResponse.redirect(login)
+}
+
+export const config = {
+  matcher: ["/((?!api/|_next/|login|favicon.ico).*)"],
+}
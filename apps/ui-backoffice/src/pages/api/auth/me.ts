@@ -0,0 +1,10 @@
+import type { NextApiRequest, NextApiResponse } from "next"
+import { currentUser } from "@/lib/auth"
+
+export default function handler(req: NextApiRequest, res: NextApiResponse) {
+  const user = currentUser(req)
+  if (!user) {
+    return res.status(401).json({ error: "Not signed in" })
+  }
+  return res.status(200).json(user)
+}
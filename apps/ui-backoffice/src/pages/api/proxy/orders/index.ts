@@ -1,6 +1,7 @@
 import "@/otel"
 import type { NextApiRequest, NextApiResponse } from "next"
 import { context, trace, propagation } from "@opentelemetry/api"
+import { authHeaders } from "@/lib/auth"
 
 export default async function handler(req: NextApiRequest, res: NextApiResponse) {
   const tracer = trace.getTracer("ui-backoffice")
@@ -10,6 +11,8 @@ export default async function handler(req: NextApiRequest, res: NextApiResponse)
     try {
       const headers: Record<string, string> = { "Content-Type": "application/json" }
       propagation.inject(context.active(), headers)
+      // The services authorize the request as made by the signed-in user.
+      Object.assign(headers, authHeaders(req))
 
       if (req.method === "GET") {
         const response = await fetch(`${baseUrl}/api/orders`, { headers })
@@ -0,0 +1,39 @@
+import "@/otel"
+import type { NextApiRequest, NextApiResponse } from "next"
+import { context, trace, propagation } from "@opentelemetry/api"
+import { authHeaders } from "@/lib/auth"
+
+export default async function handler(req: NextApiRequest, res: NextApiResponse) {
+  const tracer = trace.getTracer("ui-backoffice")
+  const { id } = req.query
+  const baseUrl = process.env.PRODUCT_API_BASE_URL || "http://localhost:8080"
+
+  return tracer.startActiveSpan("proxy_product_stock_adjustment", {}, context.active(), async (span) => {
+    try {
+      if (req.method !== "POST") {
+        span.setStatus({ code: 1, message: "Method not allowed" })
+        return res.status(405).end()
+      }
+
+      const headers: Record<string, string> = { "Content-Type": "application/json" }
+      propagation.inject(context.active(), headers)
+      // The services authorize the request as made by the signed-in user.
+      Object.assign(headers, authHeaders(req))
+
+      const response = await fetch(`${baseUrl}/api/products/${id}/stock-adjustments`, {
+        method: "POST",
+        headers,
+        body: JSON.stringify(req.body),
+      })
+      const data = await response.json()
+      span.setStatus({ code: 0 })
+      return res.status(response.status).json(data)
+    } catch (error) {
+      span.recordException(error as Error)
+      span.setStatus({ code: 2, message: "Unexpected error" })
+      return res.status(500).json({ error: "Internal Server Error" })
+    } finally {
+      span.end()
+    }
+  })
+}
@@ -1,3 +1,4 @@
+import type { GetServerSidePropsContext } from "next"
 import { fetchOrdersServerSide, Order } from "@/services/orders"
 import { OrderManager } from "@/components/OrderManager"
 
@@ -10,9 +11,9 @@ export default function OrdersPage({ initialOrders }: { initialOrders: Order[] }
   )
 }
 
-export async function getServerSideProps() {
+export async function getServerSideProps({ req }: GetServerSidePropsContext) {
   try {
-    const initialOrders = await fetchOrdersServerSide()
+    const initialOrders = await fetchOrdersServerSide(req.headers.cookie)
     return { props: { initialOrders } }
   } catch (error) {
     console.error("Failed to fetch orders:", error)
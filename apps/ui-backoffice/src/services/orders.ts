@@ -9,6 +9,15 @@ export interface Order {
   status: string
   createdAt: string
   items: OrderItem[]
+  allocation?: AllocationLine[]
+}
+
+export interface AllocationLine {
+  item: number
+  productId: string
+  sku?: string
+  warehouseId: string
+  quantity: number
 }
 
 function getBaseUrl() {
@@ -18,9 +27,13 @@ function getBaseUrl() {
   return process.env.NEXT_PUBLIC_APP_URL || "http://ui-backoffice:3000"
 }
 
-export async function fetchOrdersServerSide(): Promise<Order[]> {
+// fetchOrdersServerSide is given the cookie of the page request when it
+// runs on the server, so the orders are read as the signed-in user.
+export async function fetchOrdersServerSide(cookie?: string): Promise<Order[]> {
   const baseUrl = getBaseUrl()
-  const res = await fetch(`${baseUrl}/api/proxy/orders`)
+  const res = await fetch(`${baseUrl}/api/proxy/orders`, {
+    headers: cookie ? { cookie } : {},
+  })
   if (!res.ok) {
     throw new Error("Failed to fetch orders")
   }
@@ -1,9 +1,21 @@
 export interface Product {
     id: string
+    sku?: string
     name: string
     description: string
     price: number
     stock: number
+    reserved?: number
+    available?: number
+    categoryId?: string
+    tags?: string[]
+    attributes?: Record<string, string | number | boolean>
+    reorderThreshold?: number
+    images?: { id: string, url?: string, thumbnailUrl?: string, alt?: string }[]
+    status?: "active" | "archived"
+    archivedAt?: string
+    rating?: number
+    reviewCount?: number
 }
 
 function getBaseUrl() {
@@ -49,6 +61,18 @@ if (!res.ok) {
 }
 }
 
+export async function recountStock(id: string, quantity: number) {
+const baseUrl = getBaseUrl()
+const res = await fetch(`${baseUrl}/api/proxy/products/${id}/stock-adjustments`, {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({ reason: "recount", quantity }),
+})
+if (!res.ok) {
+    throw new Error("Failed to adjust stock")
+}
+}
+
 export async function deleteProduct(id: string) {
 const baseUrl = getBaseUrl()
 const res = await fetch(`${baseUrl}/api/proxy/products/${id}`, {
@@ -2,9 +2,11 @@
 
 import Link from "next/link"
 import { useCart } from "@/context"
+import { loginUrl, useUser } from "@/services/auth"
 
 export function Navbar() {
   const { cartItems } = useCart()
+  const user = useUser()
 
   const itemCount = cartItems.reduce((total, item) => total + item.quantity, 0)
 
@@ -13,9 +15,24 @@ export function Navbar() {
       <Link href="/" className="text-white font-bold text-xl">
         Home
       </Link>
-      <Link href="/cart" className="text-white font-semibold">
-        Cart ({itemCount})
-      </Link>
+      <div className="flex gap-6 items-center">
+        <Link href="/cart" className="text-white font-semibold">
+          Cart ({itemCount})
+        </Link>
+        {user && (
+          <>
+            <span className="text-zinc-400">{user.subject}</span>
+            <a href="/api/auth/logout" className="text-white font-semibold">
+              Sign out
+            </a>
+          </>
+        )}
+        {user === null && (
+          <a href={loginUrl("/")} className="text-white font-semibold">
+            Sign in
+          </a>
+        )}
+      </div>
     </nav>
   )
 }
\ No newline at end of file
@@ -14,12 +14,32 @@ export function ProductCard({ product }: { product: Product }) {
     dispatch({ type: "ADD_TO_CART", payload: { productId: product.id, productName: product.name, quantity: 1 } })
   }
 
+  const image = product.images?.[0]
+
   return (
     <div className="bg-zinc-800 p-4 rounded-lg flex flex-col justify-between">
       <div>
+        {image?.thumbnailUrl && (
+          <img
+            src={image.thumbnailUrl}
+            alt={image.alt || product.name}
+            className="w-full h-48 object-cover rounded mb-3"
+          />
+        )}
         <h3 className="text-xl font-bold text-white mb-2">{product.name}</h3>
         <p className="text-white text-sm mb-2">{product.description}</p>
         <p className="text-white font-bold mb-2">${product.price.toFixed(2)}</p>
+        {product.reviewCount ? (
+          <p className="text-yellow-400 text-sm mb-2">
+            {"★".repeat(Math.round(product.rating || 0))}
+            {"☆".repeat(5 - Math.round(product.rating || 0))}{" "}
+            <span className="text-white">
+              {product.rating?.toFixed(1)} ({product.reviewCount} {product.reviewCount === 1 ? "review" : "reviews"})
+            </span>
+          </p>
+        ) : (
+          <p className="text-zinc-400 text-sm mb-2">No reviews yet</p>
+        )}
         <p className="text-white text-sm mb-2">Stock: {availableStock}</p>
       </div>
       <button
@@ -0,0 +1,12 @@
+import type { NextApiRequest, NextApiResponse } from "next"
+import { finishLogin } from "@/lib/auth"
+
+export default async function handler(req: NextApiRequest, res: NextApiResponse) {
+  try {
+    const returnTo = await finishLogin(req, res)
+    return res.redirect(302, returnTo)
+  } catch (error) {
+    console.error("Failed to finish sign-in:", error)
+    return res.status(400).json({ error: "Sign-in failed" })
+  }
+}
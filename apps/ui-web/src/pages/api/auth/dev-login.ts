@@ -0,0 +1,31 @@
+import type { NextApiRequest, NextApiResponse } from "next"
+import { devLogin, devLoginEnabled, safeReturnTo } from "@/lib/auth"
+
+const ROLES = ["customer", "backoffice-operator", "admin"]
+
+// Signs in as any subject with any role. Only enabled by
+// AUTH_DEV_SIGNING_KEY, for local development.
+export default function handler(req: NextApiRequest, res: NextApiResponse) {
+  if (!devLoginEnabled()) {
+    return res.status(404).end()
+  }
+  if (req.method !== "POST") {
+    return res.status(405).end()
+  }
+
+  const subject = typeof req.body.subject === "string" ? req.body.subject.trim() : ""
+  const roles = ([] as unknown[]).concat(req.body.roles ?? []).filter((role): role is string =>
+    typeof role === "string" && ROLES.includes(role),
+  )
+  if (!subject) {
+    return res.status(400).json({ error: "Subject is required" })
+  }
+
+  try {
+    devLogin(req, res, subject, roles)
+  } catch (error) {
+    console.error("Failed to sign the development token:", error)
+    return res.status(500).json({ error: "Internal Server Error" })
+  }
+  return res.redirect(303, safeReturnTo(req.body.returnTo))
+}
@@ -0,0 +1,19 @@
+import type { NextApiRequest, NextApiResponse } from "next"
+import { devLoginEnabled, oidcEnabled, safeReturnTo, startLogin } from "@/lib/auth"
+
+export default async function handler(req: NextApiRequest, res: NextApiResponse) {
+  const returnTo = safeReturnTo(req.query.returnTo)
+
+  if (oidcEnabled()) {
+    try {
+      return await startLogin(req, res, returnTo)
+    } catch (error) {
+      console.error("Failed to start sign-in:", error)
+      return res.status(502).json({ error: "Sign-in is unavailable" })
+    }
+  }
+  if (devLoginEnabled()) {
+    return res.redirect(302, `/login?returnTo=${encodeURIComponent(returnTo)}`)
+  }
+  return res.status(503).json({ error: "Sign-in is not configured" })
+}
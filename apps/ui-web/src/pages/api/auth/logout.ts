@@ -0,0 +1,7 @@
+import type { NextApiRequest, NextApiResponse } from "next"
+import { logout } from "@/lib/auth"
+
+export default function handler(req: NextApiRequest, res: NextApiResponse) {
+  logout(req, res)
+  return res.redirect(302, "/")
+}
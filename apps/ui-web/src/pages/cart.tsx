@@ -2,10 +2,12 @@
 
 import { useCart } from "@/context"
 import { createOrder } from "@/services/orders"
+import { loginUrl, useUser } from "@/services/auth"
 import { useState } from "react"
 
 export default function CartPage() {
   const { cartItems, dispatch } = useCart()
+  const user = useUser()
   const [loading, setLoading] = useState(false)
   const [errorMessage, setErrorMessage] = useState<string | null>(null)
   const [successMessage, setSuccessMessage] = useState<string | null>(null)
@@ -51,15 +53,24 @@ export default function CartPage() {
       {errorMessage && <p className="text-red-500 mb-4">{errorMessage}</p>}
       {successMessage && <p className="text-green-500 mb-4">{successMessage}</p>}
 
-      <button
-        onClick={handleCheckout}
-        disabled={loading || cartItems.length === 0}
-        className={`w-full ${
-          loading ? "bg-gray-600" : "bg-blue-600 hover:bg-blue-500"
-        } text-white font-semibold py-2 rounded`}
-      >
-        {loading ? "Processing..." : "Checkout"}
-      </button>
+      {user === null ? (
+        <a
+          href={loginUrl("/cart")}
+          className="block w-full text-center bg-blue-600 hover:bg-blue-500 text-white font-semibold py-2 rounded"
+        >
+          Sign in to check out
+        </a>
+      ) : (
+        <button
+          onClick={handleCheckout}
+          disabled={loading || cartItems.length === 0}
+          className={`w-full ${
+            loading ? "bg-gray-600" : "bg-blue-600 hover:bg-blue-500"
+          } text-white font-semibold py-2 rounded`}
+        >
+          {loading ? "Processing..." : "Checkout"}
+        </button>
+      )}
     </div>
   )
 }
\ No newline at end of file
@@ -0,0 +1,34 @@
+import { useRouter } from "next/router"
+
+const ROLES = ["customer", "backoffice-operator", "admin"]
+
+// Development sign-in, used when AUTH_DEV_SIGNING_KEY is set instead of an
+// OpenID Connect provider.
+export default function LoginPage() {
+  const { query } = useRouter()
+  const returnTo = typeof query.returnTo === "string" ? query.returnTo : "/"
+
+  return (
+    <div className="container mx-auto p-8 max-w-md">
+      <h1 className="text-3xl font-bold mb-2 text-white">Sign in</h1>
+      <p className="text-zinc-400 mb-8">Development sign-in: choose who to act as.</p>
+
+      <form method="POST" action="/api/auth/dev-login" className="flex flex-col gap-4">
+        <input type="hidden" name="returnTo" value={returnTo} />
+        <label className="text-white flex flex-col gap-1">
+          Subject
+          <input name="subject" required defaultValue="customer-1" className="p-2 rounded bg-zinc-800 text-white" />
+        </label>
+        {ROLES.map((role) => (
+          <label key={role} className="text-white flex gap-2 items-center">
+            <input type="checkbox" name="roles" value={role} defaultChecked={role === "customer"} />
+            {role}
+          </label>
+        ))}
+        <button type="submit" className="bg-blue-600 hover:bg-blue-500 text-white font-semibold py-2 rounded">
+          Sign in
+        </button>
+      </form>
+    </div>
+  )
+}
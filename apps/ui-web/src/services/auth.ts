@@ -0,0 +1,25 @@
+import { useEffect, useState } from "react"
+
+export interface User {
+  subject: string
+  roles: string[]
+}
+
+export function loginUrl(returnTo: string) {
+  return `/api/auth/login?returnTo=${encodeURIComponent(returnTo)}`
+}
+
+// useUser returns the signed-in user, null when nobody is, and undefined
+// while it is not known yet.
+export function useUser() {
+  const [user, setUser] = useState<User | null | undefined>(undefined)
+
+  useEffect(() => {
+    fetch("/api/auth/me")
+      .then((res) => (res.ok ? res.json() : null))
+      .then(setUser)
+      .catch(() => setUser(null))
+  }, [])
+
+  return user
+}
@@ -1,5 +1,6 @@
 export interface OrderItem {
   productId: string
+  sku?: string
   quantity: number
 }
 
@@ -9,6 +10,9 @@ export async function createOrder(items: OrderItem[]) {
     headers: { "Content-Type": "application/json" },
     body: JSON.stringify({ items }),
   })
+  if (res.status === 401) {
+    throw new Error("Sign in to place your order.")
+  }
   if (!res.ok) {
     const error = await res.json()
     throw new Error(error.error || "Failed to create order")
@@ -1,9 +1,34 @@
+export interface ProductVariant {
+  sku: string
+  options?: Record<string, string>
+  price?: number
+  stock: number
+}
+
+export interface ProductImage {
+  id: string
+  url?: string
+  thumbnailUrl?: string
+  alt?: string
+  width: number
+  height: number
+}
+
 export interface Product {
   id: string
   name: string
   description: string
   price: number
   stock: number
+  reserved?: number
+  available?: number
+  categoryId?: string
+  tags?: string[]
+  attributes?: Record<string, string | number | boolean>
+  variants?: Record<string, ProductVariant>
+  images?: ProductImage[]
+  rating?: number
+  reviewCount?: number
 }
 
 function getBaseUrl() {
@@ -40,8 +40,8 @@ resource "helm_release" "products-service" {
   }
 
   set {
-    name  = "TEMPO_ENDPOINT"
-    value = "tempo.tempo.svc.cluster.local:4318"
+    name  = "OTEL_EXPORTER_OTLP_ENDPOINT"
+    value = "http://tempo.tempo.svc.cluster.local:4318"
   }
 
   set {
@@ -49,6 +49,76 @@ resource "helm_release" "products-service" {
     value = data.terraform_remote_state.eks.outputs.products_table_name
   }
 
+  set {
+    name  = "PRODUCT_FACETS_TABLE"
+    value = data.terraform_remote_state.eks.outputs.product_facets_table_name
+  }
+
+  set {
+    name  = "CATEGORIES_TABLE"
+    value = data.terraform_remote_state.eks.outputs.categories_table_name
+  }
+
+  set {
+    name  = "STOCK_MOVEMENTS_TABLE"
+    value = data.terraform_remote_state.eks.outputs.stock_movements_table_name
+  }
+
+  set {
+    name  = "WAREHOUSES_TABLE"
+    value = data.terraform_remote_state.eks.outputs.warehouses_table_name
+  }
+
+  set {
+    name  = "ALLOCATIONS_TABLE"
+    value = data.terraform_remote_state.eks.outputs.allocations_table_name
+  }
+
+  set {
+    name  = "PRODUCTS_TOPIC_ARN"
+    value = data.terraform_remote_state.eks.outputs.products_sns_arn
+  }
+
+  set {
+    name  = "RESERVATIONS_TABLE"
+    value = data.terraform_remote_state.eks.outputs.reservations_table_name
+  }
+
+  set {
+    name  = "PRICE_CHANGES_TABLE"
+    value = data.terraform_remote_state.eks.outputs.price_changes_table_name
+  }
+
+  set {
+    name  = "IMAGES_BUCKET"
+    value = data.terraform_remote_state.eks.outputs.product_images_bucket_name
+  }
+
+  set {
+    name  = "CACHE_INVALIDATION_QUEUE_URL"
+    value = data.terraform_remote_state.eks.outputs.products_cache_sqs_url
+  }
+
+  set {
+    name  = "REVIEWS_TABLE"
+    value = data.terraform_remote_state.eks.outputs.reviews_table_name
+  }
+
+  set {
+    name  = "AUTH_ISSUER"
+    value = var.auth_issuer
+  }
+
+  set {
+    name  = "AUTH_AUDIENCE"
+    value = var.auth_audience
+  }
+
+  set {
+    name  = "AUTH_JWKS_URL"
+    value = var.auth_jwks_url
+  }
+
   set {
     name  = "serviceAccountAnnotations.eks\\.amazonaws\\.com/role-arn"
     value = data.terraform_remote_state.eks.outputs.products_service_service_account_role_arn
@@ -77,8 +147,8 @@ resource "helm_release" "products-worker" {
   }
 
   set {
-    name  = "TEMPO_ENDPOINT"
-    value = "tempo.tempo.svc.cluster.local:4318"
+    name  = "OTEL_EXPORTER_OTLP_ENDPOINT"
+    value = "http://tempo.tempo.svc.cluster.local:4318"
   }
 
   set {
@@ -91,6 +161,26 @@ resource "helm_release" "products-worker" {
     value = data.terraform_remote_state.eks.outputs.products_table_name
   }
 
+  set {
+    name  = "STOCK_MOVEMENTS_TABLE"
+    value = data.terraform_remote_state.eks.outputs.stock_movements_table_name
+  }
+
+  set {
+    name  = "WAREHOUSES_TABLE"
+    value = data.terraform_remote_state.eks.outputs.warehouses_table_name
+  }
+
+  set {
+    name  = "ALLOCATIONS_TABLE"
+    value = data.terraform_remote_state.eks.outputs.allocations_table_name
+  }
+
+  set {
+    name  = "PRODUCTS_TOPIC_ARN"
+    value = data.terraform_remote_state.eks.outputs.products_sns_arn
+  }
+
   set {
     name  = "serviceAccountAnnotations.eks\\.amazonaws\\.com/role-arn"
     value = data.terraform_remote_state.eks.outputs.products_worker_service_account_role_arn
@@ -119,8 +209,8 @@ resource "helm_release" "orders-service" {
   }
 
   set {
-    name  = "TEMPO_ENDPOINT"
-    value = "tempo.tempo.svc.cluster.local:4318"
+    name  = "OTEL_EXPORTER_OTLP_ENDPOINT"
+    value = "http://tempo.tempo.svc.cluster.local:4318"
   }
 
   set {
@@ -133,6 +223,21 @@ resource "helm_release" "orders-service" {
     value = data.terraform_remote_state.eks.outputs.orders_table_name
   }
 
+  set {
+    name  = "AUTH_ISSUER"
+    value = var.auth_issuer
+  }
+
+  set {
+    name  = "AUTH_AUDIENCE"
+    value = var.auth_audience
+  }
+
+  set {
+    name  = "AUTH_JWKS_URL"
+    value = var.auth_jwks_url
+  }
+
   set {
     name  = "serviceAccountAnnotations.eks\\.amazonaws\\.com/role-arn"
     value = data.terraform_remote_state.eks.outputs.orders_service_service_account_role_arn
@@ -167,6 +272,16 @@ resource "helm_release" "ui-web" {
     name  = "PRODUCT_API_BASE_URL"
     value = "http://products-service.sample-store.svc.cluster.local:8080"
   }
+
+  set {
+    name  = "OIDC_ISSUER"
+    value = var.auth_issuer
+  }
+
+  set {
+    name  = "OIDC_REDIRECT_URI"
+    value = var.ui_web_oidc_redirect_uri
+  }
 }
 
 
@@ -196,4 +311,14 @@ resource "helm_release" "ui-backoffice" {
     value = "http://products-service.sample-store.svc.cluster.local:8080"
   }
 
+  set {
+    name  = "OIDC_ISSUER"
+    value = var.auth_issuer
+  }
+
+  set {
+    name  = "OIDC_REDIRECT_URI"
+    value = var.ui_backoffice_oidc_redirect_uri
+  }
+
 }
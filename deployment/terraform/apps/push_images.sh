@@ -15,6 +15,6 @@ productsWorker=$(echo aws_ecr_repository.products-worker.repository_url | tofu c
 ordersService=$(echo aws_ecr_repository.orders-service.repository_url | tofu console | tr -d '"' )
 
 
-docker buildx build --platform=linux/amd64,linux/arm64 -t ${productsService} ../../../apps/products-service/ --push
-docker buildx build --platform=linux/amd64,linux/arm64 -t ${productsWorker} ../../../apps/products-worker/ --push
-docker buildx build --platform=linux/amd64,linux/arm64 -t ${ordersService} ../../../apps/orders-service/ --push
\ No newline at end of file
+docker buildx build --platform=linux/amd64,linux/arm64 -t ${productsService} -f ../../../apps/products-service/Dockerfile ../../../ --push
+docker buildx build --platform=linux/amd64,linux/arm64 -t ${productsWorker} -f ../../../apps/products-worker/Dockerfile ../../../ --push
+docker buildx build --platform=linux/amd64,linux/arm64 -t ${ordersService} -f ../../../apps/orders-service/Dockerfile ../../../ --push
\ No newline at end of file
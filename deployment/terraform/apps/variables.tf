@@ -14,4 +14,33 @@ variable "environment" {
   description = "Deployment environment (dev, staging, prod)"
   type        = string
   default     = "dev"
-}
\ No newline at end of file
+}
+
+variable "auth_issuer" {
+  description = "Issuer (iss claim) of the tokens the services accept, e.g. the URL of the OpenID Connect provider"
+  type        = string
+}
+
+variable "auth_audience" {
+  description = "Audience (aud claim) the tokens must contain; empty to not check it"
+  type        = string
+  default     = ""
+}
+
+variable "auth_jwks_url" {
+  description = "URL of the key set the tokens are signed with; empty to discover it from the issuer"
+  type        = string
+  default     = ""
+}
+
+variable "ui_web_oidc_redirect_uri" {
+  description = "URL the provider redirects to after signing in to ui-web, ending in /api/auth/callback"
+  type        = string
+  default     = ""
+}
+
+variable "ui_backoffice_oidc_redirect_uri" {
+  description = "URL the provider redirects to after signing in to ui-backoffice, ending in /api/auth/callback"
+  type        = string
+  default     = ""
+}
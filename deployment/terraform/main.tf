@@ -332,6 +332,69 @@ resource "aws_dynamodb_table" "products" {
     type = "S"
   }
 
+  attribute {
+    name = "categoryId"
+    type = "S"
+  }
+
+  attribute {
+    name = "sku"
+    type = "S"
+  }
+
+  global_secondary_index {
+    name            = "categoryId-index"
+    hash_key        = "categoryId"
+    projection_type = "ALL"
+  }
+
+  global_secondary_index {
+    name            = "sku-index"
+    hash_key        = "sku"
+    projection_type = "ALL"
+  }
+
+  tags = local.tags
+}
+
+# One item per product and tag or attribute value, which the product
+# listing filters query.
+resource "aws_dynamodb_table" "product_facets" {
+  name         = format("%s-%s", local.name, "product-facets")
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "productId"
+  range_key    = "facet"
+
+  attribute {
+    name = "productId"
+    type = "S"
+  }
+
+  attribute {
+    name = "facet"
+    type = "S"
+  }
+
+  global_secondary_index {
+    name            = "facet-productId-index"
+    hash_key        = "facet"
+    range_key       = "productId"
+    projection_type = "ALL"
+  }
+
+  tags = local.tags
+}
+
+resource "aws_dynamodb_table" "categories" {
+  name         = format("%s-%s", local.name, "categories")
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "id"
+
+  attribute {
+    name = "id"
+    type = "S"
+  }
+
   tags = local.tags
 }
 
@@ -348,6 +411,213 @@ resource "aws_dynamodb_table" "orders" {
   tags = local.tags
 }
 
+resource "aws_dynamodb_table" "stock_movements" {
+  name         = format("%s-%s", local.name, "stock-movements")
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "productId"
+  range_key    = "id"
+
+  attribute {
+    name = "productId"
+    type = "S"
+  }
+
+  attribute {
+    name = "id"
+    type = "S"
+  }
+
+  attribute {
+    name = "createdAt"
+    type = "S"
+  }
+
+  local_secondary_index {
+    name            = "createdAt-index"
+    range_key       = "createdAt"
+    projection_type = "ALL"
+  }
+
+  tags = local.tags
+}
+
+resource "aws_dynamodb_table" "warehouses" {
+  name         = format("%s-%s", local.name, "warehouses")
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "id"
+
+  attribute {
+    name = "id"
+    type = "S"
+  }
+
+  tags = local.tags
+}
+
+resource "aws_dynamodb_table" "allocations" {
+  name         = format("%s-%s", local.name, "allocations")
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "orderId"
+
+  attribute {
+    name = "orderId"
+    type = "S"
+  }
+
+  tags = local.tags
+}
+
+resource "aws_dynamodb_table" "reservations" {
+  name         = format("%s-%s", local.name, "reservations")
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "id"
+
+  attribute {
+    name = "id"
+    type = "S"
+  }
+
+  attribute {
+    name = "status"
+    type = "S"
+  }
+
+  attribute {
+    name = "expiresAt"
+    type = "S"
+  }
+
+  attribute {
+    name = "customerId"
+    type = "S"
+  }
+
+  global_secondary_index {
+    name            = "status-expiresAt-index"
+    hash_key        = "status"
+    range_key       = "expiresAt"
+    projection_type = "ALL"
+  }
+
+  global_secondary_index {
+    name            = "customerId-status-index"
+    hash_key        = "customerId"
+    range_key       = "status"
+    projection_type = "ALL"
+  }
+
+  ttl {
+    attribute_name = "ttl"
+    enabled        = true
+  }
+
+  tags = local.tags
+}
+
+resource "aws_dynamodb_table" "price_changes" {
+  name         = format("%s-%s", local.name, "price-changes")
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "productId"
+  range_key    = "id"
+
+  attribute {
+    name = "productId"
+    type = "S"
+  }
+
+  attribute {
+    name = "id"
+    type = "S"
+  }
+
+  attribute {
+    name = "effectiveAt"
+    type = "S"
+  }
+
+  attribute {
+    name = "status"
+    type = "S"
+  }
+
+  local_secondary_index {
+    name            = "effectiveAt-index"
+    range_key       = "effectiveAt"
+    projection_type = "ALL"
+  }
+
+  global_secondary_index {
+    name            = "status-effectiveAt-index"
+    hash_key        = "status"
+    range_key       = "effectiveAt"
+    projection_type = "ALL"
+  }
+
+  tags = local.tags
+}
+
+resource "aws_dynamodb_table" "reviews" {
+  name         = format("%s-%s", local.name, "reviews")
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "productId"
+  range_key    = "customerId"
+
+  attribute {
+    name = "productId"
+    type = "S"
+  }
+
+  attribute {
+    name = "customerId"
+    type = "S"
+  }
+
+  attribute {
+    name = "createdAt"
+    type = "S"
+  }
+
+  attribute {
+    name = "status"
+    type = "S"
+  }
+
+  local_secondary_index {
+    name            = "createdAt-index"
+    range_key       = "createdAt"
+    projection_type = "ALL"
+  }
+
+  global_secondary_index {
+    name            = "status-createdAt-index"
+    hash_key        = "status"
+    range_key       = "createdAt"
+    projection_type = "ALL"
+  }
+
+  tags = local.tags
+}
+
+# Records the item migrations run by tools/migrate, per table.
+resource "aws_dynamodb_table" "migrations" {
+  name         = format("%s-%s", local.name, "migrations")
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "table"
+  range_key    = "id"
+
+  attribute {
+    name = "table"
+    type = "S"
+  }
+
+  attribute {
+    name = "id"
+    type = "S"
+  }
+
+  tags = local.tags
+}
+
 ################################################################################
 # APP resources SNS and SQS
 ################################################################################
@@ -355,14 +625,31 @@ resource "aws_sns_topic" "orders" {
   name = format("%s-%s", local.name, "orders-topic")
 }
 
+resource "aws_sns_topic" "products" {
+  name = format("%s-%s", local.name, "products-topic")
+}
+
+# Messages received 5 times without being processed are kept in the
+# dead-letter queues for 14 days.
+resource "aws_sqs_queue" "products_dlq" {
+  name                      = format("%s-%s", local.name, "products-queue-dlq")
+  message_retention_seconds = 1209600
+}
+
 resource "aws_sqs_queue" "products" {
   name = format("%s-%s", local.name, "products-queue")
+  redrive_policy = jsonencode({
+    deadLetterTargetArn = aws_sqs_queue.products_dlq.arn
+    maxReceiveCount     = 5
+  })
 }
 
+# The consumers read the SNS envelope, so raw delivery stays off.
 resource "aws_sns_topic_subscription" "products_subscription" {
-  topic_arn = aws_sns_topic.orders.arn
-  protocol  = "sqs"
-  endpoint  = aws_sqs_queue.products.arn
+  topic_arn            = aws_sns_topic.orders.arn
+  protocol             = "sqs"
+  endpoint             = aws_sqs_queue.products.arn
+  raw_message_delivery = false
 }
 
 resource "aws_sqs_queue_policy" "allow_sns" {
@@ -383,6 +670,47 @@ resource "aws_sqs_queue_policy" "allow_sns" {
   })
 }
 
+resource "aws_sqs_queue" "products_cache_dlq" {
+  name                      = format("%s-%s", local.name, "products-cache-queue-dlq")
+  message_retention_seconds = 1209600
+}
+
+resource "aws_sqs_queue" "products_cache" {
+  name = format("%s-%s", local.name, "products-cache-queue")
+  redrive_policy = jsonencode({
+    deadLetterTargetArn = aws_sqs_queue.products_cache_dlq.arn
+    maxReceiveCount     = 5
+  })
+}
+
+resource "aws_sns_topic_subscription" "products_cache_subscription" {
+  topic_arn            = aws_sns_topic.products.arn
+  protocol             = "sqs"
+  endpoint             = aws_sqs_queue.products_cache.arn
+  raw_message_delivery = false
+  filter_policy = jsonencode({
+    eventType = ["product.stock_changed"]
+  })
+}
+
+resource "aws_sqs_queue_policy" "products_cache_allow_sns" {
+  queue_url = aws_sqs_queue.products_cache.id
+  policy = jsonencode({
+    Version = "2012-10-17"
+    Statement = [{
+      Effect    = "Allow"
+      Principal = "*"
+      Action    = "sqs:SendMessage"
+      Resource  = aws_sqs_queue.products_cache.arn
+      Condition = {
+        ArnEquals = {
+          "aws:SourceArn" = aws_sns_topic.products.arn
+        }
+      }
+    }]
+  })
+}
+
 ################################################################################
 # Load Balancer
 ################################################################################
@@ -428,6 +756,15 @@ resource "helm_release" "aws-load-balancer-controller" {
 }
 
 
+################################################################################
+# Product images
+################################################################################
+resource "aws_s3_bucket" "product_images" {
+  bucket = "${local.name}-product-images"
+
+  tags = local.tags
+}
+
 ################################################################################
 # Tempo, loki and Thanos S3 configurations.
 ################################################################################
@@ -768,7 +1105,7 @@ resource "helm_release" "tempo" {
 ################################################################################
 resource "aws_iam_policy" "products_service" {
   name        = "products-service-policy"
-  description = "Allow products service access to DynamoDB and SQS"
+  description = "Allow products service access to DynamoDB, SNS, its cache queue and the product images bucket"
   policy = jsonencode({
     Version = "2012-10-17"
     Statement = [
@@ -778,10 +1115,52 @@ resource "aws_iam_policy" "products_service" {
           "dynamodb:PutItem",
           "dynamodb:GetItem",
           "dynamodb:UpdateItem",
+          "dynamodb:DeleteItem",
+          "dynamodb:Scan",
+          "dynamodb:Query",
+          "sns:Publish",
+          "dynamodb:BatchGetItem",
+          "dynamodb:BatchWriteItem",
+          "dynamodb:DescribeTable",
+          "sns:GetTopicAttributes",
         ]
         Resource = [
-          aws_dynamodb_table.products.arn
+          aws_dynamodb_table.products.arn,
+          "${aws_dynamodb_table.products.arn}/index/*",
+          aws_dynamodb_table.product_facets.arn,
+          "${aws_dynamodb_table.product_facets.arn}/index/*",
+          aws_dynamodb_table.categories.arn,
+          aws_dynamodb_table.stock_movements.arn,
+          "${aws_dynamodb_table.stock_movements.arn}/index/*",
+          aws_dynamodb_table.warehouses.arn,
+          aws_dynamodb_table.allocations.arn,
+          aws_sns_topic.products.arn,
+          aws_dynamodb_table.reservations.arn,
+          "${aws_dynamodb_table.reservations.arn}/index/*",
+          aws_dynamodb_table.price_changes.arn,
+          "${aws_dynamodb_table.price_changes.arn}/index/*",
+          aws_dynamodb_table.reviews.arn,
+          "${aws_dynamodb_table.reviews.arn}/index/*"
         ]
+      },
+      {
+        Effect = "Allow"
+        Action = [
+          "s3:GetObject",
+          "s3:PutObject",
+          "s3:DeleteObject",
+          "s3:ListBucket"
+        ]
+        Resource = ["${aws_s3_bucket.product_images.arn}/*", aws_s3_bucket.product_images.arn]
+      },
+      {
+        Effect = "Allow"
+        Action = [
+          "sqs:ReceiveMessage",
+          "sqs:DeleteMessage",
+          "sqs:GetQueueAttributes"
+        ]
+        Resource = [aws_sqs_queue.products_cache.arn]
       }
     ]
   })
@@ -789,7 +1168,7 @@ resource "aws_iam_policy" "products_service" {
 
 resource "aws_iam_policy" "products_worker" {
   name        = "products-worker-policy"
-  description = "Allow products worker access to DynamoDB and SQS"
+  description = "Allow products worker access to DynamoDB, SQS and SNS"
   policy = jsonencode({
     Version = "2012-10-17"
     Statement = [
@@ -801,10 +1180,21 @@ resource "aws_iam_policy" "products_worker" {
           "dynamodb:PutItem",
           "dynamodb:GetItem",
           "dynamodb:UpdateItem",
+          "dynamodb:ConditionCheckItem",
+          "dynamodb:Scan",
+          "sns:Publish",
+          "dynamodb:DescribeTable",
+          "sns:GetTopicAttributes",
+          "sqs:GetQueueAttributes",
         ]
         Resource = [
           aws_sqs_queue.products.arn,
-          aws_dynamodb_table.products.arn
+          aws_dynamodb_table.products.arn,
+          aws_dynamodb_table.stock_movements.arn,
+          "${aws_dynamodb_table.stock_movements.arn}/index/*",
+          aws_dynamodb_table.warehouses.arn,
+          aws_dynamodb_table.allocations.arn,
+          aws_sns_topic.products.arn
         ]
       }
     ]
@@ -824,6 +1214,8 @@ resource "aws_iam_policy" "orders_service" {
           "dynamodb:GetItem",
           "dynamodb:UpdateItem",
           "sns:Publish",
+          "dynamodb:DescribeTable",
+          "sns:GetTopicAttributes",
         ]
         Resource = [
           aws_dynamodb_table.orders.arn,
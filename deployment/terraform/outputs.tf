@@ -3,6 +3,51 @@ output "products_table_name" {
   value       = aws_dynamodb_table.products.name
 }
 
+output "product_facets_table_name" {
+  description = "Name of the DynamoDB table of the product filter facets"
+  value       = aws_dynamodb_table.product_facets.name
+}
+
+output "categories_table_name" {
+  description = "Name of the DynamoDB categories table"
+  value       = aws_dynamodb_table.categories.name
+}
+
+output "stock_movements_table_name" {
+  description = "Name of the DynamoDB stock movements table"
+  value       = aws_dynamodb_table.stock_movements.name
+}
+
+output "warehouses_table_name" {
+  description = "Name of the DynamoDB warehouses table"
+  value       = aws_dynamodb_table.warehouses.name
+}
+
+output "allocations_table_name" {
+  description = "Name of the DynamoDB allocations table"
+  value       = aws_dynamodb_table.allocations.name
+}
+
+output "reservations_table_name" {
+  description = "Name of the DynamoDB reservations table"
+  value       = aws_dynamodb_table.reservations.name
+}
+
+output "price_changes_table_name" {
+  description = "Name of the DynamoDB price changes table"
+  value       = aws_dynamodb_table.price_changes.name
+}
+
+output "reviews_table_name" {
+  description = "Name of the DynamoDB table for product reviews"
+  value       = aws_dynamodb_table.reviews.name
+}
+
+output "product_images_bucket_name" {
+  description = "Name of the S3 product images bucket"
+  value       = aws_s3_bucket.product_images.bucket
+}
+
 output "orders_table_name" {
   description = "Name of the DynamoDB orders table"
   value       = aws_dynamodb_table.orders.name
@@ -13,11 +58,21 @@ output "orders_sns_arn" {
   value       = aws_sns_topic.orders.arn
 }
 
+output "products_sns_arn" {
+  description = "ARN of the SNS topic for products"
+  value       = aws_sns_topic.products.arn
+}
+
 output "products_sqs_url" {
   description = "URL of the SQS queue for products"
   value       = aws_sqs_queue.products.url
 }
 
+output "products_cache_sqs_url" {
+  description = "URL of the SQS queue products-service invalidates its cache from"
+  value       = aws_sqs_queue.products_cache.url
+}
+
 output "region" {
   description = "AWS region"
   value       = var.aws_region
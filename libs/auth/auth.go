@@ -0,0 +1,65 @@
+// Package auth authenticates the requests to the services with the JWTs an
+// OpenID Connect provider issues, and authorizes them by the roles the
+// tokens carry.
+package auth
+
+import (
+	"context"
+	"slices"
+)
+
+// The roles the services authorize requests by.
+const (
+	// RoleCustomer places orders and reviews products, and only sees its
+	// own orders.
+	RoleCustomer = "customer"
+	// RoleOperator runs the back office: stock, moderation and the reads
+	// that go with them.
+	RoleOperator = "backoffice-operator"
+	// RoleAdmin changes the catalog, the prices and the orders.
+	RoleAdmin = "admin"
+)
+
+// Principal is the caller a request was authenticated as.
+type Principal struct {
+	// Subject is the sub claim of the token, which identifies the caller in
+	// the audit records and, for customers, owns their orders and reviews.
+	Subject string
+	Roles   []string
+	// token is the bearer token the request came with, which is forwarded
+	// to the services called on behalf of the caller.
+	token string
+}
+
+// HasRole reports whether the principal has any of the roles.
+func (p *Principal) HasRole(roles ...string) bool {
+	if p == nil {
+		return false
+	}
+	for _, role := range roles {
+		if slices.Contains(p.Roles, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsStaff reports whether the principal works in the back office, as an
+// operator or an admin, rather than being a customer.
+func (p *Principal) IsStaff() bool {
+	return p.HasRole(RoleOperator, RoleAdmin)
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying the principal.
+func NewContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, p)
+}
+
+// FromContext returns the principal ctx carries, if the request was
+// authenticated.
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(contextKey{}).(*Principal)
+	return p, ok
+}
@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"errors"
+	"net/url"
+	"time"
+)
+
+// Config is how the tokens are verified. It is nested in the configuration
+// of the services, which load it with the config package.
+type Config struct {
+	// Issuer is the iss claim the tokens must have.
+	Issuer string `env:"AUTH_ISSUER" required:"true"`
+	// Audience is the aud claim the tokens must contain, when set.
+	Audience string `env:"AUTH_AUDIENCE"`
+	// JWKSURL is where the keys the tokens are signed with are read, over
+	// http(s) or from a file:// for local key sets. When empty it is
+	// discovered from the OpenID configuration of the issuer.
+	JWKSURL string `env:"AUTH_JWKS_URL"`
+	// RolesClaim is the claim holding the roles, a list or a space
+	// separated string. Dots reach into objects, as in realm_access.roles.
+	RolesClaim string `env:"AUTH_ROLES_CLAIM" default:"roles"`
+	// JWKSRefreshInterval is how often the keys are read again so rotated
+	// keys are picked up; unknown keys are looked up right away.
+	JWKSRefreshInterval time.Duration `env:"AUTH_JWKS_REFRESH_INTERVAL" default:"1h"`
+	// ClockSkew is how far the clocks of the issuer and the service may
+	// drift apart when the times of a token are checked.
+	ClockSkew time.Duration `env:"AUTH_CLOCK_SKEW" default:"30s"`
+}
+
+// Validate checks the URLs and the durations.
+func (c *Config) Validate() error {
+	var errs []error
+	if c.JWKSURL != "" {
+		u, err := url.Parse(c.JWKSURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "file") {
+			errs = append(errs, errors.New("AUTH_JWKS_URL must be an http, https or file URL"))
+		}
+	} else if c.Issuer != "" {
+		u, err := url.Parse(c.Issuer)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			errs = append(errs, errors.New("AUTH_ISSUER must be an http or https URL when AUTH_JWKS_URL is not set"))
+		}
+	}
+	if c.RolesClaim == "" {
+		errs = append(errs, errors.New("AUTH_ROLES_CLAIM cannot be empty"))
+	}
+	if c.JWKSRefreshInterval <= 0 {
+		errs = append(errs, errors.New("AUTH_JWKS_REFRESH_INTERVAL must be positive"))
+	}
+	if c.ClockSkew < 0 {
+		errs = append(errs, errors.New("AUTH_CLOCK_SKEW cannot be negative"))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,317 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrUnknownKey = errors.New("unknown signing key")
+
+const (
+	// fetchTimeout bounds the requests for the OpenID configuration and
+	// the key set.
+	fetchTimeout = 5 * time.Second
+	// refetchInterval is how long a token signed with an unknown key waits
+	// before it makes the key set be read again, so such tokens cannot
+	// flood the issuer.
+	refetchInterval = time.Minute
+	// The key set is read again after a failure once a backoff is over,
+	// doubling from retryMin up to retryMax.
+	retryMin = time.Second
+	retryMax = time.Minute
+)
+
+// KeySet is the JSON Web Key Set the tokens are verified with. It is read
+// on first use and refreshed in the background once it is older than the
+// refresh interval. The keys read last are used for as long as reading them
+// again fails.
+type KeySet struct {
+	client  *http.Client
+	issuer  string
+	refresh time.Duration
+
+	mu        sync.Mutex
+	url       string
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+	// attemptedAt is when the key set was last read, successfully or not,
+	// and failures how many reads failed in a row since.
+	attemptedAt time.Time
+	failures    int
+	// fetching is closed once the read in progress, if any, is over.
+	fetching chan struct{}
+}
+
+// NewKeySet returns the key set at jwksURL, or the one the OpenID
+// configuration of issuer points to when jwksURL is empty.
+func NewKeySet(jwksURL, issuer string, refresh time.Duration) *KeySet {
+	return &KeySet{
+		client:  &http.Client{Timeout: fetchTimeout},
+		url:     jwksURL,
+		issuer:  issuer,
+		refresh: refresh,
+	}
+}
+
+// Key returns the key with the given id. A token without an id is verified
+// with the key of a set holding a single one. Only the callers that need a
+// key that was not read yet wait for the key set, and they share one read.
+func (s *KeySet) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	s.mu.Lock()
+	key, found := s.lookup(kid)
+	if found {
+		if time.Since(s.fetchedAt) > s.refresh {
+			s.startFetch(false)
+		}
+		s.mu.Unlock()
+		return key, nil
+	}
+	// The key set was never read, or the issuer may have rotated its keys
+	// since it was.
+	wait := s.startFetch(true)
+	s.mu.Unlock()
+
+	if wait != nil {
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if key, found := s.lookup(kid); found {
+		return key, nil
+	}
+	if s.keys == nil {
+		return nil, errors.New("no signing keys could be read")
+	}
+	return nil, fmt.Errorf("%w %q", ErrUnknownKey, kid)
+}
+
+func (s *KeySet) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, true
+		}
+	}
+	key, ok := s.keys[kid]
+	return key, ok
+}
+
+// startFetch reads the key set in the background, unless a read is in
+// progress already or the last one is too recent. It returns a channel
+// closed once the read in progress is over, or nil when there is none. The
+// caller holds mu.
+func (s *KeySet) startFetch(unknownKey bool) chan struct{} {
+	if s.fetching != nil {
+		return s.fetching
+	}
+	if time.Since(s.attemptedAt) < s.backoff(unknownKey) {
+		return nil
+	}
+
+	s.attemptedAt = time.Now()
+	done := make(chan struct{})
+	s.fetching = done
+	jwksURL := s.url
+	go func() {
+		defer close(done)
+		// Not bound to the request that started it, which the other
+		// callers may be waiting on too.
+		keys, jwksURL, err := s.fetch(context.Background(), jwksURL)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.fetching = nil
+		if err != nil {
+			s.failures++
+			slog.Warn("failed to read the signing keys", "url", jwksURL, "failures", s.failures, "error", err)
+			return
+		}
+		s.url = jwksURL
+		s.keys = keys
+		s.fetchedAt = time.Now()
+		s.failures = 0
+	}()
+	return done
+}
+
+// backoff is how long after the last read the key set can be read again.
+// The caller holds mu.
+func (s *KeySet) backoff(unknownKey bool) time.Duration {
+	if s.failures > 0 {
+		return min(retryMin<<min(s.failures-1, 16), retryMax)
+	}
+	if unknownKey && s.keys != nil {
+		return refetchInterval
+	}
+	return 0
+}
+
+// fetch reads the key set at jwksURL, which is discovered from the issuer
+// when empty, and returns it with its URL.
+func (s *KeySet) fetch(ctx context.Context, jwksURL string) (map[string]crypto.PublicKey, string, error) {
+	if jwksURL == "" {
+		var discovery struct {
+			JWKSURI string `json:"jwks_uri"`
+		}
+		if err := s.read(ctx, strings.TrimSuffix(s.issuer, "/")+"/.well-known/openid-configuration", &discovery); err != nil {
+			return nil, "", fmt.Errorf("discovery: %w", err)
+		}
+		if discovery.JWKSURI == "" {
+			return nil, "", errors.New("discovery: no jwks_uri")
+		}
+		jwksURL = discovery.JWKSURI
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := s.read(ctx, jwksURL, &set); err != nil {
+		return nil, jwksURL, err
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		// Encryption keys are no use for verifying signatures.
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			slog.Warn("skipping signing key", "kid", k.Kid, "error", err)
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, jwksURL, errors.New("the key set has no usable signing key")
+	}
+	return keys, jwksURL, nil
+}
+
+// read decodes the JSON document at rawURL, which is read from the disk
+// for file URLs.
+func (s *KeySet) read(ctx context.Context, rawURL string, v any) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme == "file" {
+		data, err := os.ReadFile(u.Path)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(data, v)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s answered %d", rawURL, res.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(v)
+}
+
+// jwk is a JSON Web Key, of which the RSA and elliptic curve public keys
+// are read.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	// Elliptic curves
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("n: %w", err)
+		}
+		e, err := decodeInt(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("e: %w", err)
+		}
+		if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		var point ecdh.Curve
+		switch k.Crv {
+		case "P-256":
+			curve, point = elliptic.P256(), ecdh.P256()
+		case "P-384":
+			curve, point = elliptic.P384(), ecdh.P384()
+		case "P-521":
+			curve, point = elliptic.P521(), ecdh.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("x: %w", err)
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("y: %w", err)
+		}
+		size := (curve.Params().BitSize + 7) / 8
+		if len(x) != size || len(y) != size {
+			return nil, errors.New("invalid coordinates")
+		}
+		// Parsed as an ECDH key only to check the point is on the curve.
+		uncompressed := append(append([]byte{4}, x...), y...)
+		if _, err := point.NewPublicKey(uncompressed); err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Middleware authenticates the requests that carry a bearer token and
+// records the principal on the request span, which the spans of the
+// handlers copy. A request with an invalid token is answered 401; one
+// without a token goes on anonymously, to the routes that do not Require
+// a principal. It belongs after the tracing middleware.
+func Middleware(v *Verifier) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		header := c.Get(fiber.HeaderAuthorization)
+		if header == "" {
+			return c.Next()
+		}
+
+		ctx := c.UserContext()
+		scheme, token, _ := strings.Cut(header, " ")
+		if !strings.EqualFold(scheme, "Bearer") || token == "" {
+			c.Set(fiber.HeaderWWWAuthenticate, `Bearer error="invalid_request"`)
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Authorization must be a bearer token",
+			})
+		}
+
+		principal, err := v.Verify(ctx, token)
+		if err != nil {
+			slog.WarnContext(ctx, "rejected bearer token", "error", err)
+			c.Set(fiber.HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Invalid or expired token",
+			})
+		}
+
+		trace.SpanFromContext(ctx).SetAttributes(
+			semconv.EnduserID(principal.Subject),
+			semconv.EnduserRole(strings.Join(principal.Roles, ",")),
+		)
+		c.SetUserContext(NewContext(ctx, principal))
+		return c.Next()
+	}
+}
+
+// Require lets through the requests authenticated with any of the roles,
+// or with any role at all when none is given. The others are answered 401
+// when they are anonymous and 403 otherwise.
+func Require(roles ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		principal, ok := FromContext(c.UserContext())
+		if !ok {
+			c.Set(fiber.HeaderWWWAuthenticate, "Bearer")
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Authentication required",
+			})
+		}
+		if len(roles) > 0 && !principal.HasRole(roles...) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Forbidden",
+			})
+		}
+		return c.Next()
+	}
+}
+
+// Forward sets the bearer token of the principal in ctx on req, so the
+// service it calls authorizes the request as made by the same caller.
+func Forward(ctx context.Context, req *http.Request) {
+	if principal, ok := FromContext(ctx); ok {
+		req.Header.Set("Authorization", "Bearer "+principal.token)
+	}
+}
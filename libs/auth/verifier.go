@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// signingMethods are the algorithms tokens may be signed with. Only the
+// asymmetric ones are accepted, so a token can never be verified with a
+// public key used as an HMAC secret.
+var signingMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// Verifier checks the tokens against the key set of the issuer.
+type Verifier struct {
+	keys       *KeySet
+	parser     *jwt.Parser
+	rolesClaim []string
+}
+
+// NewVerifier returns a verifier for the tokens conf describes.
+func NewVerifier(conf Config) *Verifier {
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(signingMethods),
+		jwt.WithIssuer(conf.Issuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(conf.ClockSkew),
+	}
+	if conf.Audience != "" {
+		options = append(options, jwt.WithAudience(conf.Audience))
+	}
+	return &Verifier{
+		keys:       NewKeySet(conf.JWKSURL, conf.Issuer, conf.JWKSRefreshInterval),
+		parser:     jwt.NewParser(options...),
+		rolesClaim: strings.Split(conf.RolesClaim, "."),
+	}
+}
+
+// Verify checks the signature, issuer, audience and times of the token,
+// and returns the principal it was issued to.
+func (v *Verifier) Verify(ctx context.Context, token string) (*Principal, error) {
+	claims := jwt.MapClaims{}
+	_, err := v.parser.ParseWithClaims(token, claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return v.keys.Key(ctx, kid)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	subject, err := claims.GetSubject()
+	if err != nil {
+		return nil, err
+	}
+	if subject == "" {
+		return nil, errors.New("token has no subject")
+	}
+	roles, err := v.roles(claims)
+	if err != nil {
+		return nil, err
+	}
+	return &Principal{Subject: subject, Roles: roles, token: token}, nil
+}
+
+// roles reads the roles claim. A token without it has no role.
+func (v *Verifier) roles(claims jwt.MapClaims) ([]string, error) {
+	var value any = map[string]any(claims)
+	for _, name := range v.rolesClaim {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil, nil
+		}
+		if value, ok = object[name]; !ok {
+			return nil, nil
+		}
+	}
+
+	switch value := value.(type) {
+	case string:
+		return strings.Fields(value), nil
+	case []any:
+		roles := make([]string, 0, len(value))
+		for _, role := range value {
+			s, ok := role.(string)
+			if !ok {
+				return nil, fmt.Errorf("claim %s holds a %T", strings.Join(v.rolesClaim, "."), role)
+			}
+			roles = append(roles, s)
+		}
+		return roles, nil
+	}
+	return nil, fmt.Errorf("claim %s holds a %T", strings.Join(v.rolesClaim, "."), value)
+}
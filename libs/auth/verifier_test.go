@@ -0,0 +1,416 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testIssuer = "https://issuer.test/realms/shop"
+
+// signingKey is a private key the test issuer signs tokens with.
+type signingKey struct {
+	kid    string
+	method jwt.SigningMethod
+	key    crypto.Signer
+}
+
+func newRSAKey(t *testing.T, kid string) signingKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signingKey{kid: kid, method: jwt.SigningMethodRS256, key: key}
+}
+
+func newECKey(t *testing.T, kid string) signingKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signingKey{kid: kid, method: jwt.SigningMethodES256, key: key}
+}
+
+func (k signingKey) jwk() map[string]string {
+	encode := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	switch public := k.key.Public().(type) {
+	case *rsa.PublicKey:
+		return map[string]string{
+			"kty": "RSA", "kid": k.kid, "use": "sig",
+			"n": encode(public.N.Bytes()),
+			"e": encode(big.NewInt(int64(public.E)).Bytes()),
+		}
+	case *ecdsa.PublicKey:
+		size := (public.Curve.Params().BitSize + 7) / 8
+		return map[string]string{
+			"kty": "EC", "kid": k.kid, "use": "sig", "crv": "P-256",
+			"x": encode(public.X.FillBytes(make([]byte, size))),
+			"y": encode(public.Y.FillBytes(make([]byte, size))),
+		}
+	}
+	panic("unsupported key")
+}
+
+func (k signingKey) sign(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(k.method, claims)
+	token.Header["kid"] = k.kid
+	signed, err := token.SignedString(k.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+// keyServer serves a key set that can be rotated, and counts how often it
+// was read.
+type keyServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	keys    []signingKey
+	fetches int
+}
+
+func newKeyServer(t *testing.T, keys ...signingKey) *keyServer {
+	s := &keyServer{keys: keys}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.fetches++
+		set := struct {
+			Keys []map[string]string `json:"keys"`
+		}{}
+		for _, k := range s.keys {
+			set.Keys = append(set.Keys, k.jwk())
+		}
+		json.NewEncoder(w).Encode(set)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *keyServer) rotate(keys ...signingKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = keys
+}
+
+func (s *keyServer) fetchCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fetches
+}
+
+func testConfig(jwksURL string) Config {
+	return Config{
+		Issuer:              testIssuer,
+		JWKSURL:             jwksURL,
+		RolesClaim:          "realm_access.roles",
+		JWKSRefreshInterval: time.Hour,
+		ClockSkew:           30 * time.Second,
+	}
+}
+
+func validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":          testIssuer,
+		"sub":          "customer-1",
+		"exp":          time.Now().Add(5 * time.Minute).Unix(),
+		"realm_access": map[string]any{"roles": []string{RoleCustomer}},
+	}
+}
+
+func with(claims jwt.MapClaims, name string, value any) jwt.MapClaims {
+	claims[name] = value
+	return claims
+}
+
+func without(claims jwt.MapClaims, name string) jwt.MapClaims {
+	delete(claims, name)
+	return claims
+}
+
+func TestVerify(t *testing.T) {
+	rsaKey := newRSAKey(t, "rsa-1")
+	ecKey := newECKey(t, "ec-1")
+	server := newKeyServer(t, rsaKey, ecKey)
+
+	hmacToken := func(t *testing.T) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, validClaims())
+		token.Header["kid"] = rsaKey.kid
+		signed, err := token.SignedString([]byte("shared secret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	noneToken := func(t *testing.T) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodNone, validClaims())
+		token.Header["kid"] = rsaKey.kid
+		signed, err := token.SignedString(jwt.UnsafeAllowNoneSignatureType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+
+	tests := []struct {
+		name     string
+		audience string
+		token    func(t *testing.T) string
+		wantErr  error
+		// wantAnyErr is set for rejections without an error of their own.
+		wantAnyErr bool
+		wantRoles  []string
+	}{
+		{
+			name:      "RS256",
+			token:     func(t *testing.T) string { return rsaKey.sign(t, validClaims()) },
+			wantRoles: []string{RoleCustomer},
+		},
+		{
+			name:      "ES256",
+			token:     func(t *testing.T) string { return ecKey.sign(t, validClaims()) },
+			wantRoles: []string{RoleCustomer},
+		},
+		{
+			name:    "HS256 with a shared secret",
+			token:   hmacToken,
+			wantErr: jwt.ErrTokenSignatureInvalid,
+		},
+		{
+			name:    "alg none",
+			token:   noneToken,
+			wantErr: jwt.ErrTokenSignatureInvalid,
+		},
+		{
+			name: "signed with another key under a known kid",
+			token: func(t *testing.T) string {
+				return newRSAKey(t, rsaKey.kid).sign(t, validClaims())
+			},
+			wantErr: jwt.ErrTokenSignatureInvalid,
+		},
+		{
+			name:    "other issuer",
+			token:   func(t *testing.T) string { return rsaKey.sign(t, with(validClaims(), "iss", "https://evil.test")) },
+			wantErr: jwt.ErrTokenInvalidIssuer,
+		},
+		{
+			name:    "no issuer",
+			token:   func(t *testing.T) string { return rsaKey.sign(t, without(validClaims(), "iss")) },
+			wantErr: jwt.ErrTokenRequiredClaimMissing,
+		},
+		{
+			name: "expired",
+			token: func(t *testing.T) string {
+				return rsaKey.sign(t, with(validClaims(), "exp", time.Now().Add(-time.Minute).Unix()))
+			},
+			wantErr: jwt.ErrTokenExpired,
+		},
+		{
+			name: "expired within the clock skew",
+			token: func(t *testing.T) string {
+				return rsaKey.sign(t, with(validClaims(), "exp", time.Now().Add(-10*time.Second).Unix()))
+			},
+			wantRoles: []string{RoleCustomer},
+		},
+		{
+			name:    "no expiry",
+			token:   func(t *testing.T) string { return rsaKey.sign(t, without(validClaims(), "exp")) },
+			wantErr: jwt.ErrTokenRequiredClaimMissing,
+		},
+		{
+			name: "not valid yet",
+			token: func(t *testing.T) string {
+				return rsaKey.sign(t, with(validClaims(), "nbf", time.Now().Add(time.Minute).Unix()))
+			},
+			wantErr: jwt.ErrTokenNotValidYet,
+		},
+		{
+			name:     "other audience",
+			audience: "products-service",
+			token:    func(t *testing.T) string { return rsaKey.sign(t, with(validClaims(), "aud", "orders-service")) },
+			wantErr:  jwt.ErrTokenInvalidAudience,
+		},
+		{
+			name:     "audience among others",
+			audience: "products-service",
+			token: func(t *testing.T) string {
+				return rsaKey.sign(t, with(validClaims(), "aud", []string{"orders-service", "products-service"}))
+			},
+			wantRoles: []string{RoleCustomer},
+		},
+		{
+			name:       "no subject",
+			token:      func(t *testing.T) string { return rsaKey.sign(t, without(validClaims(), "sub")) },
+			wantAnyErr: true,
+		},
+		{
+			name:  "no roles",
+			token: func(t *testing.T) string { return rsaKey.sign(t, without(validClaims(), "realm_access")) },
+		},
+		{
+			name: "roles as a string",
+			token: func(t *testing.T) string {
+				return rsaKey.sign(t, with(validClaims(), "realm_access", map[string]any{"roles": "admin customer"}))
+			},
+			wantRoles: []string{RoleAdmin, RoleCustomer},
+		},
+		{
+			name:    "unknown kid",
+			token:   func(t *testing.T) string { return newRSAKey(t, "rsa-unknown").sign(t, validClaims()) },
+			wantErr: ErrUnknownKey,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := testConfig(server.URL)
+			conf.Audience = tt.audience
+			principal, err := NewVerifier(conf).Verify(context.Background(), tt.token(t))
+
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Verify() error = %v, want %v", err, tt.wantErr)
+				}
+			case tt.wantAnyErr:
+				if err == nil {
+					t.Fatal("Verify() accepted the token")
+				}
+			default:
+				if err != nil {
+					t.Fatalf("Verify() error = %v", err)
+				}
+				if principal.Subject != "customer-1" {
+					t.Errorf("Subject = %q, want customer-1", principal.Subject)
+				}
+				if !slices.Equal(principal.Roles, tt.wantRoles) {
+					t.Errorf("Roles = %v, want %v", principal.Roles, tt.wantRoles)
+				}
+			}
+		})
+	}
+}
+
+// TestVerifyKeyRotation verifies tokens in turn against an issuer that
+// rotates its key, checking how often the key set is read.
+func TestVerifyKeyRotation(t *testing.T) {
+	oldKey := newRSAKey(t, "2026-01")
+	newKey := newECKey(t, "2026-02")
+	server := newKeyServer(t, oldKey)
+	verifier := NewVerifier(testConfig(server.URL))
+
+	steps := []struct {
+		name string
+		// rotate replaces the served keys before the token is verified.
+		rotate []signingKey
+		// refetchDue lets an unknown key read the key set again, as it
+		// would once refetchInterval passed.
+		refetchDue bool
+		// stale makes the keys read older than the refresh interval.
+		stale       bool
+		token       signingKey
+		wantErr     error
+		wantFetches int
+	}{
+		{
+			name:        "old key reads the key set",
+			token:       oldKey,
+			wantFetches: 1,
+		},
+		{
+			name:        "old key again uses the keys read",
+			token:       oldKey,
+			wantFetches: 1,
+		},
+		{
+			name:        "new key within the refetch interval",
+			rotate:      []signingKey{oldKey, newKey},
+			token:       newKey,
+			wantErr:     ErrUnknownKey,
+			wantFetches: 1,
+		},
+		{
+			name:        "new key once the key set can be read again",
+			refetchDue:  true,
+			token:       newKey,
+			wantFetches: 2,
+		},
+		{
+			name:        "old key while both are served",
+			token:       oldKey,
+			wantFetches: 2,
+		},
+		{
+			name:        "old key until the refresh after it was rotated out",
+			rotate:      []signingKey{newKey},
+			stale:       true,
+			token:       oldKey,
+			wantFetches: 3,
+		},
+		{
+			name:        "old key after the refresh",
+			token:       oldKey,
+			wantErr:     ErrUnknownKey,
+			wantFetches: 3,
+		},
+		{
+			name:        "new key after the refresh",
+			token:       newKey,
+			wantFetches: 3,
+		},
+	}
+
+	ctx := context.Background()
+	for _, step := range steps {
+		if step.rotate != nil {
+			server.rotate(step.rotate...)
+		}
+		verifier.keys.mu.Lock()
+		if step.refetchDue {
+			verifier.keys.attemptedAt = time.Time{}
+		}
+		if step.stale {
+			verifier.keys.fetchedAt = time.Time{}
+			verifier.keys.attemptedAt = time.Time{}
+		}
+		verifier.keys.mu.Unlock()
+
+		_, err := verifier.Verify(ctx, step.token.sign(t, validClaims()))
+		if step.wantErr == nil && err != nil {
+			t.Fatalf("%s: Verify() error = %v", step.name, err)
+		}
+		if step.wantErr != nil && !errors.Is(err, step.wantErr) {
+			t.Fatalf("%s: Verify() error = %v, want %v", step.name, err, step.wantErr)
+		}
+
+		// A stale key set is read again in the background.
+		verifier.keys.mu.Lock()
+		fetching := verifier.keys.fetching
+		verifier.keys.mu.Unlock()
+		if fetching != nil {
+			<-fetching
+		}
+		if got := server.fetchCount(); got != step.wantFetches {
+			t.Errorf("%s: key set read %d times, want %d", step.name, got, step.wantFetches)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+// AWS configures the AWS clients. Credentials come from the default chain
+// of the SDK. Endpoint sends every service to another endpoint, such as
+// LocalStack; the per-service endpoints override it for one service.
+type AWS struct {
+	Region           string `env:"AWS_REGION" default:"us-west-2"`
+	Endpoint         string `env:"AWS_ENDPOINT"`
+	DynamoDBEndpoint string `env:"AWS_ENDPOINT_DYNAMODB"`
+	SNSEndpoint      string `env:"AWS_ENDPOINT_SNS"`
+	SQSEndpoint      string `env:"AWS_ENDPOINT_SQS"`
+	S3Endpoint       string `env:"AWS_ENDPOINT_S3"`
+}
+
+// Validate checks that the endpoints are absolute URLs.
+func (a *AWS) Validate() error {
+	endpoints := []struct{ name, value string }{
+		{"AWS_ENDPOINT", a.Endpoint},
+		{"AWS_ENDPOINT_DYNAMODB", a.DynamoDBEndpoint},
+		{"AWS_ENDPOINT_SNS", a.SNSEndpoint},
+		{"AWS_ENDPOINT_SQS", a.SQSEndpoint},
+		{"AWS_ENDPOINT_S3", a.S3Endpoint},
+	}
+	for _, e := range endpoints {
+		if e.value == "" {
+			continue
+		}
+		if u, err := url.Parse(e.value); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("%s: %q is not an absolute URL", e.name, e.value)
+		}
+	}
+	return nil
+}
+
+// Load returns the SDK configuration shared by the clients.
+func (a *AWS) Load(ctx context.Context) (aws.Config, error) {
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(a.Region))
+	if err != nil {
+		return cfg, err
+	}
+	if a.Endpoint != "" {
+		cfg.BaseEndpoint = aws.String(a.Endpoint)
+	}
+	return cfg, nil
+}
+
+// DynamoDB returns a client of the service at its own endpoint, if set.
+func (a *AWS) DynamoDB(cfg aws.Config) *dynamodb.Client {
+	return dynamodb.NewFromConfig(cfg, func(o *dynamodb.Options) {
+		if a.DynamoDBEndpoint != "" {
+			o.BaseEndpoint = aws.String(a.DynamoDBEndpoint)
+		}
+	})
+}
+
+// SNS returns a client of the service at its own endpoint, if set.
+func (a *AWS) SNS(cfg aws.Config) *sns.Client {
+	return sns.NewFromConfig(cfg, func(o *sns.Options) {
+		if a.SNSEndpoint != "" {
+			o.BaseEndpoint = aws.String(a.SNSEndpoint)
+		}
+	})
+}
+
+// SQS returns a client of the service at its own endpoint, if set.
+func (a *AWS) SQS(cfg aws.Config) *sqs.Client {
+	return sqs.NewFromConfig(cfg, func(o *sqs.Options) {
+		if a.SQSEndpoint != "" {
+			o.BaseEndpoint = aws.String(a.SQSEndpoint)
+		}
+	})
+}
+
+// S3 returns a client of the service at its own endpoint, if set. It
+// addresses buckets by path rather than by subdomain when the endpoint
+// is overridden, which is what LocalStack and other S3 compatible stores
+// serve.
+func (a *AWS) S3(cfg aws.Config) *s3.Client {
+	return s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if a.S3Endpoint != "" {
+			o.BaseEndpoint = aws.String(a.S3Endpoint)
+		}
+		o.UsePathStyle = a.Endpoint != "" || a.S3Endpoint != ""
+	})
+}
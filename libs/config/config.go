@@ -0,0 +1,274 @@
+// Package config loads the configuration of a binary into a typed struct.
+//
+// Every field names its variable in an env tag and may give a default and
+// mark itself required or secret:
+//
+//	type Config struct {
+//		Table    string        `env:"PRODUCTS_TABLE" default:"products"`
+//		TopicArn string        `env:"PRODUCTS_TOPIC_ARN" required:"true"`
+//		CacheTTL time.Duration `env:"PRODUCT_CACHE_TTL" default:"30s"`
+//		RedisURL string        `env:"REDIS_URL" secret:"true"`
+//		AWS      config.AWS
+//	}
+//
+// Fields of nested structs are loaded the same way. Supported types are
+// strings, booleans, integers, floats, durations and comma-separated
+// string lists.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileVariable names the optional YAML file the configuration is read from.
+const FileVariable = "CONFIG_FILE"
+
+// redacted replaces the value of secrets when the configuration is printed.
+const redacted = "[REDACTED]"
+
+// Validator is implemented by configurations, or parts of them, that check
+// more than the presence and type of each value.
+type Validator interface {
+	Validate() error
+}
+
+// field is a configurable field of the struct being loaded.
+type field struct {
+	name     string
+	value    reflect.Value
+	fallback string
+	required bool
+	secret   bool
+}
+
+// Load fills cfg, a pointer to a struct, from the defaults in its tags,
+// then the YAML file named by CONFIG_FILE, which maps variable names to
+// values, then the environment, each source overriding the previous one
+// unless its value is empty.
+//
+// Every problem is collected before Load returns, so a single error lists
+// all the missing and invalid values and the unknown settings of the file.
+// The Validate methods of the configuration run once every value loaded.
+func Load(cfg any) error {
+	fields, validators, err := walk(cfg)
+	if err != nil {
+		return err
+	}
+
+	file, err := readFile(os.Getenv(FileVariable))
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	known := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		known[f.name] = true
+
+		// An empty value counts as unset, as it does in compose files and
+		// Helm values that leave a variable blank.
+		value := f.fallback
+		if v := file[f.name]; v != "" {
+			value = v
+		}
+		if v := os.Getenv(f.name); v != "" {
+			value = v
+		}
+
+		if value == "" {
+			if f.required {
+				errs = append(errs, fmt.Errorf("%s is required", f.name))
+			}
+			continue
+		}
+		if err := assign(f.value, value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.name, err))
+		}
+	}
+	for name := range file {
+		if !known[name] {
+			errs = append(errs, fmt.Errorf("%s: unknown setting in %s", name, os.Getenv(FileVariable)))
+		}
+	}
+
+	// Values that failed to load would only cause confusing follow-up
+	// errors in the validators.
+	if len(errs) == 0 {
+		for _, v := range validators {
+			if err := v.Validate(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// Print writes the effective configuration, one NAME=value line per field
+// in the order of the struct, with the values of secrets redacted.
+func Print(w io.Writer, cfg any) error {
+	fields, _, err := walk(cfg)
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		value := format(f.value)
+		if f.secret && value != "" {
+			value = redacted
+		}
+		if _, err := fmt.Fprintf(w, "%s=%s\n", f.name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walk returns the configurable fields of cfg, nested structs included,
+// and the parts of it that validate themselves, innermost first.
+func walk(cfg any) ([]field, []Validator, error) {
+	v := reflect.ValueOf(cfg)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return nil, nil, fmt.Errorf("config: %T is not a pointer to a struct", cfg)
+	}
+
+	var fields []field
+	var validators []Validator
+	var visit func(v reflect.Value)
+	visit = func(v reflect.Value) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			if !sf.IsExported() {
+				continue
+			}
+			fv := v.Field(i)
+			name := sf.Tag.Get("env")
+			if name == "" {
+				if fv.Kind() == reflect.Struct {
+					visit(fv)
+				}
+				continue
+			}
+			fields = append(fields, field{
+				name:     name,
+				value:    fv,
+				fallback: sf.Tag.Get("default"),
+				required: sf.Tag.Get("required") == "true",
+				secret:   sf.Tag.Get("secret") == "true",
+			})
+		}
+		if validator, ok := v.Addr().Interface().(Validator); ok {
+			validators = append(validators, validator)
+		}
+	}
+	visit(v.Elem())
+	return fields, validators, nil
+}
+
+func readFile(path string) (map[string]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+
+	// Values are read as YAML scalars and converted like the environment.
+	var raw map[string]yaml.Node
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("config: %s: %w", path, err)
+	}
+	values := make(map[string]string, len(raw))
+	for name, node := range raw {
+		switch node.Kind {
+		case yaml.ScalarNode:
+			values[name] = node.Value
+		case yaml.SequenceNode:
+			items := make([]string, 0, len(node.Content))
+			for _, item := range node.Content {
+				items = append(items, item.Value)
+			}
+			values[name] = strings.Join(items, ",")
+		default:
+			return nil, fmt.Errorf("config: %s: %s must be a value or a list", path, name)
+		}
+	}
+	return values, nil
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func assign(v reflect.Value, value string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", value)
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", value)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", value)
+		}
+		v.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", value)
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", v.Type())
+		}
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+func format(v reflect.Value) string {
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String()
+	}
+	if v.Kind() == reflect.Slice {
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = fmt.Sprint(v.Index(i).Interface())
+		}
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(v.Interface())
+}
@@ -0,0 +1,51 @@
+package health
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+// DynamoDBTable checks that the table exists and takes reads and writes.
+func DynamoDBTable(client *dynamodb.Client, table string) Check {
+	return func(ctx context.Context) error {
+		output, err := client.DescribeTable(ctx, &dynamodb.DescribeTableInput{
+			TableName: aws.String(table),
+		})
+		if err != nil {
+			return err
+		}
+		// A table being updated keeps serving.
+		switch status := output.Table.TableStatus; status {
+		case types.TableStatusActive, types.TableStatusUpdating:
+			return nil
+		default:
+			return fmt.Errorf("table %s is %s", table, status)
+		}
+	}
+}
+
+// SNSTopic checks that the topic exists and can be reached.
+func SNSTopic(client *sns.Client, topicArn string) Check {
+	return func(ctx context.Context) error {
+		_, err := client.GetTopicAttributes(ctx, &sns.GetTopicAttributesInput{
+			TopicArn: aws.String(topicArn),
+		})
+		return err
+	}
+}
+
+// SQSQueue checks that the queue exists and can be reached.
+func SQSQueue(client *sqs.Client, queueURL string) Check {
+	return func(ctx context.Context) error {
+		_, err := client.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+			QueueUrl: aws.String(queueURL),
+		})
+		return err
+	}
+}
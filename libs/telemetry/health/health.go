@@ -0,0 +1,158 @@
+// Package health serves the liveness and readiness of a service. Liveness
+// only says the process answers; readiness runs checks against the
+// dependencies the service cannot work without.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Check returns an error when a dependency is not usable.
+type Check func(ctx context.Context) error
+
+// Status of a check, and of the service as a whole.
+const (
+	StatusUp       = "up"
+	StatusDown     = "down"
+	StatusDraining = "draining"
+)
+
+// Defaults the services create their checker with: the readiness of the
+// dependencies is reused for DefaultCacheTTL before they are checked again,
+// and DefaultTimeout bounds the checks of a readiness probe.
+const (
+	DefaultCacheTTL = 10 * time.Second
+	DefaultTimeout  = 2 * time.Second
+)
+
+// Result is the outcome of a check.
+type Result struct {
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	CheckedAt string `json:"checkedAt"`
+}
+
+// Report is the outcome of every check; the service is up when all are.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Checker runs the readiness checks. Results are cached for ttl so that
+// frequent probes, from several kubelets or load balancers, do not turn
+// into a stream of calls to the dependencies.
+type Checker struct {
+	ttl     time.Duration
+	timeout time.Duration
+	checks  []namedCheck
+
+	mu        sync.Mutex
+	report    Report
+	checkedAt time.Time
+
+	draining atomic.Bool
+}
+
+// NewChecker returns a checker caching results for ttl and giving every
+// check timeout to answer.
+func NewChecker(ttl, timeout time.Duration) *Checker {
+	return &Checker{ttl: ttl, timeout: timeout}
+}
+
+// Add registers a check under name. Checks are meant to be added before
+// the checker serves.
+func (c *Checker) Add(name string, check Check) {
+	c.checks = append(c.checks, namedCheck{name, check})
+}
+
+// Report runs the checks concurrently, unless they ran less than ttl ago,
+// and returns their results.
+func (c *Checker) Report(ctx context.Context) Report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.checkedAt.IsZero() && time.Since(c.checkedAt) < c.ttl {
+		return c.report
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	results := make([]Result, len(c.checks))
+	var wg sync.WaitGroup
+	for i, nc := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = run(ctx, nc.check)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusUp, Checks: make(map[string]Result, len(c.checks))}
+	for i, nc := range c.checks {
+		report.Checks[nc.name] = results[i]
+		if results[i].Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+	c.report = report
+	c.checkedAt = time.Now()
+	return report
+}
+
+func run(ctx context.Context, check Check) Result {
+	result := Result{Status: StatusUp}
+	if err := check(ctx); err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	result.CheckedAt = time.Now().UTC().Format(time.RFC3339)
+	return result
+}
+
+// Drain makes the service report as not ready from now on, whatever the
+// checks say, so that it is taken out of load balancing before it stops.
+func (c *Checker) Drain() {
+	c.draining.Store(true)
+}
+
+// ServeHTTP serves the report of the readiness checks, with status 503
+// when a dependency is down or the service is draining.
+func (c *Checker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if c.draining.Load() {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": StatusDraining})
+		return
+	}
+	report := c.Report(r.Context())
+	status := http.StatusOK
+	if report.Status != StatusUp {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, report)
+}
+
+// Live serves the liveness of the process, which is up as long as it
+// answers at all.
+func Live() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": StatusUp})
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
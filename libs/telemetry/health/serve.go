@@ -0,0 +1,40 @@
+package health
+
+import (
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"telemetry/logging"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Serve answers requests on port until SIGINT or SIGTERM, then stops
+// gracefully: readiness fails first so the service is taken out of load
+// balancing, and once the drain period is over the server stops accepting
+// connections and gets at most timeout to finish the requests in flight.
+func Serve(app *fiber.App, port string, readiness *Checker, drain, timeout time.Duration) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	listenErr := make(chan error, 1)
+	go func() {
+		listenErr <- app.Listen(":" + port)
+	}()
+
+	select {
+	case err := <-listenErr:
+		logging.Fatal("server stopped", "error", err)
+	case sig := <-signals:
+		slog.Info("shutting down", "signal", sig.String(), "drain", drain.String(), "timeout", timeout.String())
+	}
+
+	readiness.Drain()
+	time.Sleep(drain)
+	if err := app.ShutdownWithTimeout(timeout); err != nil {
+		slog.Error("failed to shut down the server", "error", err)
+	}
+}
@@ -0,0 +1,66 @@
+// Package otelenv holds the OpenTelemetry setup shared by traces and
+// metrics: the resource describing the service, the error handler and the
+// standard OTEL_* variables both signals read.
+package otelenv
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// Disabled reports whether OTEL_SDK_DISABLED turns every exporter off.
+func Disabled() bool {
+	disabled, _ := strconv.ParseBool(os.Getenv("OTEL_SDK_DISABLED"))
+	return disabled
+}
+
+// Exporters returns the exporters listed in the OTEL_<SIGNAL>_EXPORTER
+// variable, such as OTEL_TRACES_EXPORTER, or defaults when it is not set.
+func Exporters(variable string, defaults ...string) []string {
+	value := os.Getenv(variable)
+	if value == "" {
+		return defaults
+	}
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Protocol returns the OTLP protocol of a signal (TRACES or METRICS),
+// http/protobuf unless OTEL_EXPORTER_OTLP_<SIGNAL>_PROTOCOL or
+// OTEL_EXPORTER_OTLP_PROTOCOL say otherwise.
+func Protocol(signal string) string {
+	if protocol := os.Getenv("OTEL_EXPORTER_OTLP_" + signal + "_PROTOCOL"); protocol != "" {
+		return protocol
+	}
+	if protocol := os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL"); protocol != "" {
+		return protocol
+	}
+	return "http/protobuf"
+}
+
+// Resource describes the service. The environment is applied last so that
+// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES win over the defaults.
+func Resource(ctx context.Context, serviceName string) (*resource.Resource, error) {
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(serviceName)),
+		resource.WithTelemetrySDK(),
+		resource.WithHost(),
+		resource.WithProcessRuntimeName(),
+		resource.WithProcessRuntimeVersion(),
+		resource.WithFromEnv(),
+	)
+	if res == nil {
+		res = resource.Default()
+	}
+	return res, err
+}
@@ -0,0 +1,48 @@
+package otelenv
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+)
+
+// errorLogInterval is how often errors of the telemetry pipeline are
+// logged while they keep happening.
+const errorLogInterval = time.Minute
+
+// errorHandler logs errors of the telemetry pipeline, such as spans and
+// metrics that could not be exported, without flooding the logs while the
+// collector is down.
+type errorHandler struct {
+	mu         sync.Mutex
+	last       time.Time
+	suppressed int
+}
+
+var handlerOnce sync.Once
+
+// SetErrorHandler installs the global error handler of OpenTelemetry.
+func SetErrorHandler() {
+	handlerOnce.Do(func() {
+		otel.SetErrorHandler(&errorHandler{})
+	})
+}
+
+func (h *errorHandler) Handle(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if time.Since(h.last) < errorLogInterval {
+		h.suppressed++
+		return
+	}
+	if h.suppressed > 0 {
+		slog.Error("opentelemetry", "error", err, "suppressed", h.suppressed, "interval", errorLogInterval)
+	} else {
+		slog.Error("opentelemetry", "error", err)
+	}
+	h.last = time.Now()
+	h.suppressed = 0
+}
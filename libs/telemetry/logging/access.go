@@ -0,0 +1,48 @@
+package logging
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AccessLog logs every request once it is answered: at error level for
+// server errors, warn for client errors and info otherwise. It belongs after
+// the tracing middleware so the record carries the ids of the request span.
+func AccessLog() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		ctx := c.UserContext()
+
+		// Errors are answered here rather than by the app, so the
+		// status they end up with is the one logged.
+		if err := c.Next(); err != nil {
+			if err := c.App().ErrorHandler(c, err); err != nil {
+				_ = c.SendStatus(fiber.StatusInternalServerError)
+			}
+		}
+
+		status := c.Response().StatusCode()
+		level := slog.LevelInfo
+		switch {
+		case status >= fiber.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= fiber.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+
+		slog.Default().LogAttrs(ctx, level, "request",
+			slog.String("method", c.Method()),
+			slog.String("path", c.Path()),
+			slog.String("route", c.Route().Path),
+			slog.Int("status", status),
+			slog.Duration("duration", time.Since(start)),
+			// -1 for streamed bodies, whose length is not known upfront.
+			slog.Int("bytes", c.Response().Header.ContentLength()),
+			slog.String("ip", c.IP()),
+			slog.String("userAgent", c.Get(fiber.HeaderUserAgent)),
+		)
+		return nil
+	}
+}
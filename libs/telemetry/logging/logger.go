@@ -0,0 +1,88 @@
+// Package logging sets up structured logging with log/slog. Records logged
+// with a context carry the trace and span ids of its span, which is how Loki
+// links a log line to its trace in Tempo.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Init installs the default logger of the service, configured with:
+//
+//   - LOG_LEVEL: debug, info (the default), warn or error.
+//   - LOG_FORMAT: json (the default) or text.
+//
+// The log package writes through the same logger, so libraries that still
+// use it end up in the same stream at info level. An invalid setting is
+// reported and replaced by its default.
+func Init(serviceName string) *slog.Logger {
+	level, levelErr := parseLevel(os.Getenv("LOG_LEVEL"))
+	options := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	format := strings.ToLower(os.Getenv("LOG_FORMAT"))
+	switch format {
+	case "text":
+		handler = slog.NewTextHandler(os.Stderr, options)
+	default:
+		handler = slog.NewJSONHandler(os.Stderr, options)
+	}
+
+	logger := slog.New(&traceHandler{handler}).With("service", serviceName)
+	slog.SetDefault(logger)
+
+	if levelErr != nil {
+		logger.Warn("ignoring LOG_LEVEL", "error", levelErr)
+	}
+	if format != "" && format != "json" && format != "text" {
+		logger.Warn("ignoring LOG_FORMAT", "error", fmt.Errorf("unknown format %q", format))
+	}
+	return logger
+}
+
+// Fatal logs msg at error level and exits, for errors the service cannot
+// start with.
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+func parseLevel(value string) (slog.Level, error) {
+	var level slog.Level
+	if value == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := level.UnmarshalText([]byte(value)); err != nil {
+		return slog.LevelInfo, err
+	}
+	return level, nil
+}
+
+// traceHandler adds the ids of the span in the context of a record.
+type traceHandler struct {
+	slog.Handler
+}
+
+func (h *traceHandler) Handle(ctx context.Context, record slog.Record) error {
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		record.AddAttrs(
+			slog.String("trace_id", spanContext.TraceID().String()),
+			slog.String("span_id", spanContext.SpanID().String()),
+		)
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *traceHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &traceHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h *traceHandler) WithGroup(name string) slog.Handler {
+	return &traceHandler{h.Handler.WithGroup(name)}
+}
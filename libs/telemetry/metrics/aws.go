@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/middleware"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+var awsCallDuration, _ = Meter("telemetry/metrics").Float64Histogram(
+	"rpc.client.duration",
+	metric.WithUnit("ms"),
+	metric.WithDescription("Duration of the calls to AWS services, retries included"),
+)
+
+// InstrumentAWS records the duration and outcome of every call the clients
+// made from cfg send, by service and operation. Failed calls carry the AWS
+// error code as error.type.
+func InstrumentAWS(cfg *aws.Config) {
+	cfg.APIOptions = append(cfg.APIOptions, func(stack *middleware.Stack) error {
+		// After the service metadata is registered, before retries.
+		return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("telemetry.metrics", recordAWSCall), middleware.After)
+	})
+}
+
+func recordAWSCall(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+	start := time.Now()
+	out, metadata, err := next.HandleInitialize(ctx, in)
+
+	attrs := []attribute.KeyValue{
+		semconv.RPCSystemKey.String("aws-api"),
+		semconv.RPCService(awsmiddleware.GetServiceID(ctx)),
+		semconv.RPCMethod(awsmiddleware.GetOperationName(ctx)),
+	}
+	if err != nil {
+		attrs = append(attrs, semconv.ErrorTypeKey.String(errorType(err)))
+	}
+	awsCallDuration.Record(ctx, float64(time.Since(start).Microseconds())/1000, metric.WithAttributes(attrs...))
+
+	return out, metadata, err
+}
+
+func errorType(err error) string {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.ErrorCode()
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return "timeout"
+	}
+	return semconv.ErrorTypeOther.Value.AsString()
+}
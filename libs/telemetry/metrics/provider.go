@@ -0,0 +1,117 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"telemetry/internal/otelenv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	otelprometheus "go.opentelemetry.io/otel/exporters/prometheus"
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// InitMeter installs the global meter provider next to the tracer provider
+// and is configured the same way, with the standard OTEL_* variables:
+//
+//   - OTEL_METRICS_EXPORTER lists the exporters: otlp and prometheus by
+//     default, console for metrics on stdout, or none.
+//   - OTEL_EXPORTER_OTLP_[METRICS_]PROTOCOL is http/protobuf (the default)
+//     or grpc, and the exporter reads the endpoint, headers and TLS setup
+//     from the matching OTEL_EXPORTER_OTLP_[METRICS_]* variables.
+//   - OTEL_METRIC_EXPORT_INTERVAL sets how often OTLP and console export.
+//
+// The returned handler serves the metrics in the Prometheus format and is
+// nil when the prometheus exporter is not enabled. Like InitTracer, it never
+// stops the service: the provider is always usable and the error only
+// reports the exporters that were left out.
+func InitMeter(ctx context.Context, serviceName string) (*sdkmetric.MeterProvider, http.Handler, error) {
+	otelenv.SetErrorHandler()
+
+	res, resErr := otelenv.Resource(ctx, serviceName)
+	options := []sdkmetric.Option{sdkmetric.WithResource(res)}
+
+	var errs []error
+	if resErr != nil {
+		errs = append(errs, fmt.Errorf("resource: %w", resErr))
+	}
+
+	var handler http.Handler
+	if !otelenv.Disabled() {
+		for _, name := range otelenv.Exporters("OTEL_METRICS_EXPORTER", "otlp", "prometheus") {
+			if name == "prometheus" {
+				reader, h, err := newPrometheus()
+				if err != nil {
+					errs = append(errs, fmt.Errorf("prometheus exporter: %w", err))
+					continue
+				}
+				options = append(options, sdkmetric.WithReader(reader))
+				handler = h
+				continue
+			}
+
+			exporter, err := newExporter(ctx, name)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s exporter: %w", name, err))
+				continue
+			}
+			if exporter != nil {
+				options = append(options, sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter)))
+			}
+		}
+	}
+
+	mp := sdkmetric.NewMeterProvider(options...)
+	otel.SetMeterProvider(mp)
+	return mp, handler, errors.Join(errs...)
+}
+
+// Meter returns a meter of the global provider. Instruments created before
+// InitMeter start recording once it runs.
+func Meter(name string) metric.Meter {
+	return otel.Meter(name)
+}
+
+func newExporter(ctx context.Context, name string) (sdkmetric.Exporter, error) {
+	switch name {
+	case "otlp":
+		switch protocol := otelenv.Protocol("METRICS"); protocol {
+		case "grpc":
+			return otlpmetricgrpc.New(ctx)
+		case "http/protobuf":
+			return otlpmetrichttp.New(ctx)
+		default:
+			return nil, fmt.Errorf("unsupported protocol %q", protocol)
+		}
+	case "console":
+		return stdoutmetric.New()
+	case "none":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown exporter")
+	}
+}
+
+// newPrometheus registers the metrics, and those of the Go runtime, in a
+// registry of their own that the returned handler serves.
+func newPrometheus() (sdkmetric.Reader, http.Handler, error) {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	exporter, err := otelprometheus.New(otelprometheus.WithRegisterer(registry))
+	if err != nil {
+		return nil, nil, err
+	}
+	return exporter, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}), nil
+}
@@ -0,0 +1,147 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// Messaging systems, as recorded in messaging.system.
+const (
+	MessagingSNS = "aws_sns"
+	MessagingSQS = "aws_sqs"
+)
+
+// SNSMessageAttribute is a message attribute as SNS delivers it in the
+// body of a message sent to a subscribed queue.
+type SNSMessageAttribute struct {
+	Type  string `json:"Type"`
+	Value string `json:"Value"`
+}
+
+// TopicName returns the name of a topic, the last part of its ARN.
+func TopicName(arn string) string {
+	return arn[strings.LastIndex(arn, ":")+1:]
+}
+
+// QueueName returns the name of a queue, the last part of its URL.
+func QueueName(queueURL string) string {
+	return queueURL[strings.LastIndex(queueURL, "/")+1:]
+}
+
+// NewPublishSpan starts the producer span of a message sent to destination.
+// The message carries the context of the span, see MessageHeaders, and the
+// id the system assigns it is recorded with SetMessageID.
+func NewPublishSpan(ctx context.Context, system, destination string) (context.Context, oteltrace.Span) {
+	return tracer.Start(ctx, "publish "+destination,
+		oteltrace.WithSpanKind(oteltrace.SpanKindProducer),
+		oteltrace.WithAttributes(
+			semconv.MessagingSystemKey.String(system),
+			semconv.MessagingDestinationName(destination),
+			semconv.MessagingOperationTypePublish,
+			semconv.MessagingOperationName("publish"),
+		),
+	)
+}
+
+// MessageHeaders returns the trace context and the baggage of ctx, keyed by
+// the header names of the W3C propagators (traceparent, tracestate and
+// baggage), to send as attributes of a message.
+func MessageHeaders(ctx context.Context) map[string]string {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	return carrier
+}
+
+// SNSHeaders returns the values of the attributes of a message received
+// from a topic, which carry the trace context and baggage of the publisher.
+func SNSHeaders(attributes map[string]SNSMessageAttribute) map[string]string {
+	headers := make(map[string]string, len(attributes))
+	for key, attribute := range attributes {
+		headers[key] = attribute.Value
+	}
+	return headers
+}
+
+// SetMessageID records the id of the message a span published or processes.
+func SetMessageID(span oteltrace.Span, id string) {
+	if id != "" {
+		span.SetAttributes(semconv.MessagingMessageID(id))
+	}
+}
+
+// NewReceiveSpan starts the consumer span of a batch of messages received
+// from destination since start, given the headers every message carried.
+// The messages come from as many producers, so rather than a parent the
+// span gets a link to each of them.
+func NewReceiveSpan(ctx context.Context, system, destination string, start time.Time, headers []map[string]string) (context.Context, oteltrace.Span) {
+	var links []oteltrace.Link
+	for _, h := range headers {
+		if link, ok := producerLink(extract(h)); ok {
+			links = append(links, link)
+		}
+	}
+	return tracer.Start(ctx, "receive "+destination,
+		oteltrace.WithSpanKind(oteltrace.SpanKindConsumer),
+		oteltrace.WithTimestamp(start),
+		oteltrace.WithLinks(links...),
+		oteltrace.WithAttributes(
+			semconv.MessagingSystemKey.String(system),
+			semconv.MessagingDestinationName(destination),
+			semconv.MessagingOperationTypeReceive,
+			semconv.MessagingOperationName("receive"),
+			semconv.MessagingBatchMessageCount(len(headers)),
+		),
+	)
+}
+
+// NewProcessSpan starts the consumer span processing a single message of a
+// batch. It is a child of the receive span in ctx, links to the producer of
+// the message and brings the baggage the message carried into the returned
+// context.
+func NewProcessSpan(ctx context.Context, system, destination, messageID string, headers map[string]string) (context.Context, oteltrace.Span) {
+	options := []oteltrace.SpanStartOption{
+		oteltrace.WithSpanKind(oteltrace.SpanKindConsumer),
+		oteltrace.WithAttributes(
+			semconv.MessagingSystemKey.String(system),
+			semconv.MessagingDestinationName(destination),
+			semconv.MessagingOperationTypeDeliver,
+			semconv.MessagingOperationName("process"),
+		),
+	}
+	producer := extract(headers)
+	if link, ok := producerLink(producer); ok {
+		options = append(options, oteltrace.WithLinks(link))
+	}
+	if bag := baggage.FromContext(producer); bag.Len() > 0 {
+		ctx = baggage.ContextWithBaggage(ctx, bag)
+	}
+
+	ctx, span := tracer.Start(ctx, "process "+destination, options...)
+	SetMessageID(span, messageID)
+	return ctx, span
+}
+
+// extract returns a context holding only what the headers of a message
+// carried: the producer span context and the baggage.
+func extract(headers map[string]string) context.Context {
+	return otel.GetTextMapPropagator().Extract(context.Background(), propagation.MapCarrier(headers))
+}
+
+func producerLink(producer context.Context) (oteltrace.Link, bool) {
+	spanContext := oteltrace.SpanContextFromContext(producer)
+	if !spanContext.IsValid() {
+		return oteltrace.Link{}, false
+	}
+	return oteltrace.Link{
+		SpanContext: spanContext,
+		Attributes:  []attribute.KeyValue{semconv.MessagingOperationTypePublish},
+	}, true
+}
@@ -0,0 +1,87 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"telemetry/internal/otelenv"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// InitTracer installs the global tracer provider and the W3C trace context
+// and baggage propagators. It is configured with the standard OTEL_*
+// variables:
+//
+//   - OTEL_SDK_DISABLED=true creates spans for propagation only and exports
+//     nothing.
+//   - OTEL_TRACES_EXPORTER lists the exporters: otlp (the default), console
+//     for pretty-printed spans on stdout, or none.
+//   - OTEL_EXPORTER_OTLP_[TRACES_]PROTOCOL is http/protobuf (the default)
+//     or grpc. The endpoint, headers, TLS certificates, compression and
+//     timeout are read by the exporter from the matching
+//     OTEL_EXPORTER_OTLP_[TRACES_]* variables.
+//   - OTEL_TRACES_SAMPLER and OTEL_TRACES_SAMPLER_ARG pick the sampler,
+//     parentbased_always_on by default.
+//   - OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES (service.version,
+//     deployment.environment, ...) describe the service; serviceName is
+//     used when OTEL_SERVICE_NAME is not set.
+//
+// Tracing never stops the service: the returned provider is always usable
+// and the error only reports the parts of the configuration that were left
+// out. Export failures, such as an unreachable collector, are logged at
+// most once a minute.
+func InitTracer(ctx context.Context, serviceName string) (*sdktrace.TracerProvider, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	otelenv.SetErrorHandler()
+
+	res, resErr := otelenv.Resource(ctx, serviceName)
+	options := []sdktrace.TracerProviderOption{sdktrace.WithResource(res)}
+
+	var errs []error
+	if resErr != nil {
+		errs = append(errs, fmt.Errorf("resource: %w", resErr))
+	}
+
+	if !otelenv.Disabled() {
+		for _, name := range otelenv.Exporters("OTEL_TRACES_EXPORTER", "otlp") {
+			exporter, err := newExporter(ctx, name)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s exporter: %w", name, err))
+				continue
+			}
+			if exporter != nil {
+				options = append(options, sdktrace.WithBatcher(exporter))
+			}
+		}
+	}
+
+	tp := sdktrace.NewTracerProvider(options...)
+	otel.SetTracerProvider(tp)
+	return tp, errors.Join(errs...)
+}
+
+func newExporter(ctx context.Context, name string) (sdktrace.SpanExporter, error) {
+	switch name {
+	case "otlp":
+		switch protocol := otelenv.Protocol("TRACES"); protocol {
+		case "grpc":
+			return otlptracegrpc.New(ctx)
+		case "http/protobuf":
+			return otlptracehttp.New(ctx)
+		default:
+			return nil, fmt.Errorf("unsupported protocol %q", protocol)
+		}
+	case "console":
+		return stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case "none":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown exporter")
+	}
+}
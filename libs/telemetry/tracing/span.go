@@ -0,0 +1,63 @@
+package tracing
+
+import (
+	"context"
+	"os"
+	"path"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer(getName())
+
+func getName() string {
+	name, err := os.Executable()
+	if err != nil {
+		return "fiber-server"
+	}
+	return path.Base(name)
+}
+
+func extractMetricsAttributesFromSpan(span oteltrace.Span) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	readOnlySpan, ok := span.(sdktrace.ReadOnlySpan)
+	if !ok {
+		return attrs
+	}
+	attrs = append(attrs, readOnlySpan.Attributes()...)
+	return attrs
+}
+
+func NewSpan(ctx context.Context, spanName string) (context.Context, oteltrace.Span) {
+	attrs := extractMetricsAttributesFromSpan(oteltrace.SpanFromContext(ctx))
+	ctx, span := tracer.Start(ctx, spanName, oteltrace.WithAttributes(
+		attrs...,
+	))
+	return ctx, span
+}
+
+func SpanFromContext(ctx context.Context) oteltrace.Span {
+	return oteltrace.SpanFromContext(ctx)
+}
+
+func StringAttribute(key, value string) attribute.KeyValue {
+	return attribute.String(key, value)
+}
+
+func IntAttribute(key string, value int) attribute.KeyValue {
+	return attribute.Int(key, value)
+}
+
+func BoolAttribute(key string, value bool) attribute.KeyValue {
+	return attribute.Bool(key, value)
+}
+
+func GetTraceParent(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	return carrier["traceparent"]
+}
@@ -0,0 +1,14 @@
+package main
+
+import (
+	"config"
+
+	"bootstrap/internal/infra"
+)
+
+// Config is the configuration of the command, loaded from the environment
+// and the optional CONFIG_FILE.
+type Config struct {
+	Names infra.Names
+	AWS   config.AWS
+}
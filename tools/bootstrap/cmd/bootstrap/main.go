@@ -0,0 +1,67 @@
+// Command bootstrap creates the AWS resources the services run on, in an
+// account or in LocalStack, and brings existing ones in line with what the
+// services expect: tables with their indexes and TTL, the images bucket,
+// topics, queues with their dead-letter queues and the subscriptions.
+//
+//	bootstrap [-check] [-print-config]
+//
+// Running it again changes nothing once everything matches. Differences it
+// cannot reconcile in place, such as the key of a table, are reported as
+// drift and make it exit with status 1. With -check it only reports, and
+// exits with status 1 if anything differs.
+package main
+
+import (
+	"config"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"bootstrap/internal/infra"
+)
+
+func main() {
+	log.SetFlags(0)
+	check := flag.Bool("check", false, "report the differences without changing anything")
+	printConfig := flag.Bool("print-config", false, "print the effective configuration, with secrets redacted, and exit")
+	flag.Parse()
+
+	var conf Config
+	if err := config.Load(&conf); err != nil {
+		log.Fatal(err)
+	}
+	if *printConfig {
+		if err := config.Print(os.Stdout, &conf); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	ctx := context.Background()
+	cfg, err := conf.AWS.Load(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	reconciler := infra.NewReconciler(conf.AWS.DynamoDB(cfg), conf.AWS.S3(cfg), conf.AWS.SNS(cfg), conf.AWS.SQS(cfg), cfg.Region, *check)
+
+	findings, err := reconciler.Reconcile(ctx, infra.Declare(conf.Names))
+	counts := make(map[infra.Action]int)
+	for _, f := range findings {
+		fmt.Println(f)
+		counts[f.Action]++
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	format := "%d created, %d updated, %d drifted\n"
+	if *check {
+		format = "check: %d to create, %d to update, %d drifted\n"
+	}
+	fmt.Printf(format, counts[infra.ActionCreate], counts[infra.ActionUpdate], counts[infra.ActionDrift])
+	if counts[infra.ActionDrift] > 0 || *check && len(findings) > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,164 @@
+package infra
+
+import "fmt"
+
+// Names are the names of the resources. The tables and the bucket are read
+// from the variables the services read them from, so one environment or
+// CONFIG_FILE configures both.
+type Names struct {
+	ProductsTable      string `env:"PRODUCTS_TABLE" default:"products"`
+	ProductFacetsTable string `env:"PRODUCT_FACETS_TABLE" default:"product_facets"`
+	CategoriesTable    string `env:"CATEGORIES_TABLE" default:"categories"`
+	OrdersTable        string `env:"ORDERS_TABLE" default:"orders"`
+	MovementsTable     string `env:"STOCK_MOVEMENTS_TABLE" default:"stock_movements"`
+	WarehousesTable    string `env:"WAREHOUSES_TABLE" default:"warehouses"`
+	AllocationsTable   string `env:"ALLOCATIONS_TABLE" default:"allocations"`
+	ReservationsTable  string `env:"RESERVATIONS_TABLE" default:"reservations"`
+	PriceChangesTable  string `env:"PRICE_CHANGES_TABLE" default:"price_changes"`
+	ReviewsTable       string `env:"REVIEWS_TABLE" default:"reviews"`
+	MigrationsTable    string `env:"MIGRATIONS_TABLE" default:"migrations"`
+
+	ImagesBucket string `env:"IMAGES_BUCKET" default:"product-images"`
+
+	OrdersTopic        string `env:"ORDERS_TOPIC_NAME" default:"orders-topic"`
+	ProductsTopic      string `env:"PRODUCTS_TOPIC_NAME" default:"products-topic"`
+	ProductsQueue      string `env:"PRODUCTS_QUEUE_NAME" default:"products-queue"`
+	ProductsCacheQueue string `env:"PRODUCTS_CACHE_QUEUE_NAME" default:"products-cache-queue"`
+
+	// MaxReceiveCount is how many times a message is received before it is
+	// moved to the dead-letter queue of its queue.
+	MaxReceiveCount int `env:"DEAD_LETTER_MAX_RECEIVE_COUNT" default:"5"`
+}
+
+// Validate checks the receive count, which SQS takes from 1 to 1000.
+func (n *Names) Validate() error {
+	if n.MaxReceiveCount < 1 || n.MaxReceiveCount > 1000 {
+		return fmt.Errorf("DEAD_LETTER_MAX_RECEIVE_COUNT must be between 1 and 1000")
+	}
+	return nil
+}
+
+// deadLetterRetention keeps dead letters for 14 days, the longest SQS
+// allows, so there is time to look at them.
+const deadLetterRetention = "1209600"
+
+// Declare returns the resources the services expect. The indexes are the
+// ones the repositories query by name.
+func Declare(n Names) Spec {
+	return Spec{
+		Tables: []Table{
+			{
+				Name: n.ProductsTable,
+				Key:  Key{Hash: "id"},
+				GlobalIndexes: []Index{
+					{Name: "categoryId-index", Key: Key{Hash: "categoryId"}},
+					{Name: "sku-index", Key: Key{Hash: "sku"}},
+				},
+			},
+			// One item per product and tag or attribute value, which the
+			// product listing filters query.
+			{
+				Name: n.ProductFacetsTable,
+				Key:  Key{Hash: "productId", Range: "facet"},
+				GlobalIndexes: []Index{
+					{Name: "facet-productId-index", Key: Key{Hash: "facet", Range: "productId"}},
+				},
+			},
+			{
+				Name: n.CategoriesTable,
+				Key:  Key{Hash: "id"},
+			},
+			{
+				Name: n.OrdersTable,
+				Key:  Key{Hash: "id"},
+			},
+			{
+				Name: n.MovementsTable,
+				Key:  Key{Hash: "productId", Range: "id"},
+				LocalIndexes: []Index{
+					{Name: "createdAt-index", Key: Key{Hash: "productId", Range: "createdAt"}},
+				},
+			},
+			{
+				Name: n.WarehousesTable,
+				Key:  Key{Hash: "id"},
+			},
+			{
+				Name: n.AllocationsTable,
+				Key:  Key{Hash: "orderId"},
+			},
+			{
+				Name: n.ReservationsTable,
+				Key:  Key{Hash: "id"},
+				GlobalIndexes: []Index{
+					{Name: "status-expiresAt-index", Key: Key{Hash: "status", Range: "expiresAt"}},
+					{Name: "customerId-status-index", Key: Key{Hash: "customerId", Range: "status"}},
+				},
+				TTLAttribute: "ttl",
+			},
+			{
+				Name: n.PriceChangesTable,
+				Key:  Key{Hash: "productId", Range: "id"},
+				LocalIndexes: []Index{
+					{Name: "effectiveAt-index", Key: Key{Hash: "productId", Range: "effectiveAt"}},
+				},
+				GlobalIndexes: []Index{
+					{Name: "status-effectiveAt-index", Key: Key{Hash: "status", Range: "effectiveAt"}},
+				},
+			},
+			{
+				Name: n.ReviewsTable,
+				Key:  Key{Hash: "productId", Range: "customerId"},
+				LocalIndexes: []Index{
+					{Name: "createdAt-index", Key: Key{Hash: "productId", Range: "createdAt"}},
+				},
+				GlobalIndexes: []Index{
+					{Name: "status-createdAt-index", Key: Key{Hash: "status", Range: "createdAt"}},
+				},
+			},
+			// Records the item migrations run by tools/migrate, per table.
+			{
+				Name: n.MigrationsTable,
+				Key:  Key{Hash: "table", Range: "id"},
+			},
+		},
+		Buckets: []Bucket{
+			{Name: n.ImagesBucket},
+		},
+		Topics: []Topic{
+			{Name: n.OrdersTopic},
+			{Name: n.ProductsTopic},
+		},
+		Queues: []Queue{
+			{
+				Name:       n.ProductsQueue + "-dlq",
+				Attributes: map[string]string{"MessageRetentionPeriod": deadLetterRetention},
+			},
+			{
+				Name:            n.ProductsQueue,
+				DeadLetterQueue: n.ProductsQueue + "-dlq",
+				MaxReceiveCount: n.MaxReceiveCount,
+			},
+			{
+				Name:       n.ProductsCacheQueue + "-dlq",
+				Attributes: map[string]string{"MessageRetentionPeriod": deadLetterRetention},
+			},
+			{
+				Name:            n.ProductsCacheQueue,
+				DeadLetterQueue: n.ProductsCacheQueue + "-dlq",
+				MaxReceiveCount: n.MaxReceiveCount,
+			},
+		},
+		Subscriptions: []Subscription{
+			// The worker takes the orders to allocate them.
+			{Topic: n.OrdersTopic, Queue: n.ProductsQueue},
+			// products-service only drops the products whose stock the
+			// worker changed from its cache.
+			{
+				Topic:        n.ProductsTopic,
+				Queue:        n.ProductsCacheQueue,
+				FilterPolicy: `{"eventType":["product.stock_changed"]}`,
+			},
+		},
+	}
+}
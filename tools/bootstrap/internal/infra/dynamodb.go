@@ -0,0 +1,273 @@
+package infra
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// indexPollInterval is how often a new global index is checked while it
+// is backfilled.
+const indexPollInterval = 2 * time.Second
+
+func (k Key) String() string {
+	if k.Range == "" {
+		return "(" + k.Hash + ")"
+	}
+	return "(" + k.Hash + ", " + k.Range + ")"
+}
+
+func (r *Reconciler) table(ctx context.Context, t Table) ([]Finding, error) {
+	resource := "table " + t.Name
+
+	output, err := r.dynamo.DescribeTable(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(t.Name),
+	})
+	var notFound *types.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		if !r.check {
+			if err := r.createTable(ctx, t); err != nil {
+				return nil, err
+			}
+		}
+		return []Finding{{Action: ActionCreate, Resource: resource}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	desc := output.Table
+
+	var findings []Finding
+	drift := func(format string, args ...any) {
+		findings = append(findings, Finding{Action: ActionDrift, Resource: resource, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	if got := keyOf(desc.KeySchema); got != t.Key {
+		drift("key is %s, declared %s", got, t.Key)
+	}
+
+	local := make(map[string]Key)
+	for _, idx := range desc.LocalSecondaryIndexes {
+		local[aws.ToString(idx.IndexName)] = keyOf(idx.KeySchema)
+	}
+	for _, idx := range t.LocalIndexes {
+		got, ok := local[idx.Name]
+		delete(local, idx.Name)
+		if !ok {
+			drift("local index %s is missing; local indexes can only be created with the table", idx.Name)
+		} else if got != idx.Key {
+			drift("local index %s is keyed on %s, declared %s", idx.Name, got, idx.Key)
+		}
+	}
+	for _, name := range sortedKeys(local) {
+		drift("local index %s is not declared", name)
+	}
+
+	global := make(map[string]Key)
+	for _, idx := range desc.GlobalSecondaryIndexes {
+		global[aws.ToString(idx.IndexName)] = keyOf(idx.KeySchema)
+	}
+	for _, idx := range t.GlobalIndexes {
+		got, ok := global[idx.Name]
+		delete(global, idx.Name)
+		if !ok {
+			if !r.check {
+				if err := r.createGlobalIndex(ctx, t.Name, idx); err != nil {
+					return findings, err
+				}
+			}
+			findings = append(findings, Finding{Action: ActionUpdate, Resource: resource, Detail: "create global index " + idx.Name})
+		} else if got != idx.Key {
+			drift("global index %s is keyed on %s, declared %s", idx.Name, got, idx.Key)
+		}
+	}
+	for _, name := range sortedKeys(global) {
+		drift("global index %s is not declared", name)
+	}
+
+	ttl, err := r.timeToLive(ctx, t, resource)
+	return append(findings, ttl...), err
+}
+
+func (r *Reconciler) createTable(ctx context.Context, t Table) error {
+	keys := []Key{t.Key}
+	input := &dynamodb.CreateTableInput{
+		TableName:   aws.String(t.Name),
+		KeySchema:   keySchema(t.Key),
+		BillingMode: types.BillingModePayPerRequest,
+	}
+	for _, idx := range t.LocalIndexes {
+		keys = append(keys, idx.Key)
+		input.LocalSecondaryIndexes = append(input.LocalSecondaryIndexes, types.LocalSecondaryIndex{
+			IndexName:  aws.String(idx.Name),
+			KeySchema:  keySchema(idx.Key),
+			Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+		})
+	}
+	for _, idx := range t.GlobalIndexes {
+		keys = append(keys, idx.Key)
+		input.GlobalSecondaryIndexes = append(input.GlobalSecondaryIndexes, types.GlobalSecondaryIndex{
+			IndexName:  aws.String(idx.Name),
+			KeySchema:  keySchema(idx.Key),
+			Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+		})
+	}
+	input.AttributeDefinitions = attributeDefinitions(keys...)
+
+	if _, err := r.dynamo.CreateTable(ctx, input); err != nil {
+		return err
+	}
+	err := dynamodb.NewTableExistsWaiter(r.dynamo).Wait(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(t.Name),
+	}, tableWaitTimeout)
+	if err != nil {
+		return err
+	}
+	if t.TTLAttribute != "" {
+		return r.enableTTL(ctx, t)
+	}
+	return nil
+}
+
+// createGlobalIndex adds the index and waits for its backfill, since a
+// table takes one index creation at a time.
+func (r *Reconciler) createGlobalIndex(ctx context.Context, table string, idx Index) error {
+	_, err := r.dynamo.UpdateTable(ctx, &dynamodb.UpdateTableInput{
+		TableName:            aws.String(table),
+		AttributeDefinitions: attributeDefinitions(idx.Key),
+		GlobalSecondaryIndexUpdates: []types.GlobalSecondaryIndexUpdate{{
+			Create: &types.CreateGlobalSecondaryIndexAction{
+				IndexName:  aws.String(idx.Name),
+				KeySchema:  keySchema(idx.Key),
+				Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+			},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, tableWaitTimeout)
+	defer cancel()
+	ticker := time.NewTicker(indexPollInterval)
+	defer ticker.Stop()
+	for {
+		output, err := r.dynamo.DescribeTable(ctx, &dynamodb.DescribeTableInput{
+			TableName: aws.String(table),
+		})
+		if err != nil {
+			return err
+		}
+		for _, gsi := range output.Table.GlobalSecondaryIndexes {
+			if aws.ToString(gsi.IndexName) == idx.Name && gsi.IndexStatus == types.IndexStatusActive {
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("global index %s did not become active: %w", idx.Name, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Reconciler) timeToLive(ctx context.Context, t Table, resource string) ([]Finding, error) {
+	output, err := r.dynamo.DescribeTimeToLive(ctx, &dynamodb.DescribeTimeToLiveInput{
+		TableName: aws.String(t.Name),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	enabled, attribute := false, ""
+	if desc := output.TimeToLiveDescription; desc != nil {
+		enabled = desc.TimeToLiveStatus == types.TimeToLiveStatusEnabled || desc.TimeToLiveStatus == types.TimeToLiveStatusEnabling
+		attribute = aws.ToString(desc.AttributeName)
+	}
+
+	switch {
+	case t.TTLAttribute == "" && enabled:
+		return []Finding{{Action: ActionDrift, Resource: resource, Detail: "TTL is enabled on " + attribute + " but not declared"}}, nil
+	case t.TTLAttribute == "" || enabled && attribute == t.TTLAttribute:
+		return nil, nil
+	case enabled:
+		// DynamoDB takes one TTL change an hour, so this is left to a person.
+		return []Finding{{Action: ActionDrift, Resource: resource, Detail: fmt.Sprintf("TTL is enabled on %s, declared on %s", attribute, t.TTLAttribute)}}, nil
+	}
+
+	if !r.check {
+		if err := r.enableTTL(ctx, t); err != nil {
+			return nil, err
+		}
+	}
+	return []Finding{{Action: ActionUpdate, Resource: resource, Detail: "enable TTL on " + t.TTLAttribute}}, nil
+}
+
+func (r *Reconciler) enableTTL(ctx context.Context, t Table) error {
+	_, err := r.dynamo.UpdateTimeToLive(ctx, &dynamodb.UpdateTimeToLiveInput{
+		TableName: aws.String(t.Name),
+		TimeToLiveSpecification: &types.TimeToLiveSpecification{
+			AttributeName: aws.String(t.TTLAttribute),
+			Enabled:       aws.Bool(true),
+		},
+	})
+	return err
+}
+
+func keyOf(schema []types.KeySchemaElement) Key {
+	var k Key
+	for _, e := range schema {
+		switch e.KeyType {
+		case types.KeyTypeHash:
+			k.Hash = aws.ToString(e.AttributeName)
+		case types.KeyTypeRange:
+			k.Range = aws.ToString(e.AttributeName)
+		}
+	}
+	return k
+}
+
+func keySchema(k Key) []types.KeySchemaElement {
+	schema := []types.KeySchemaElement{
+		{AttributeName: aws.String(k.Hash), KeyType: types.KeyTypeHash},
+	}
+	if k.Range != "" {
+		schema = append(schema, types.KeySchemaElement{AttributeName: aws.String(k.Range), KeyType: types.KeyTypeRange})
+	}
+	return schema
+}
+
+// attributeDefinitions defines every key attribute once, as a string.
+func attributeDefinitions(keys ...Key) []types.AttributeDefinition {
+	var definitions []types.AttributeDefinition
+	seen := make(map[string]bool)
+	for _, k := range keys {
+		for _, name := range []string{k.Hash, k.Range} {
+			if name == "" || seen[name] {
+				continue
+			}
+			seen[name] = true
+			definitions = append(definitions, types.AttributeDefinition{
+				AttributeName: aws.String(name),
+				AttributeType: types.ScalarAttributeTypeS,
+			})
+		}
+	}
+	return definitions
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
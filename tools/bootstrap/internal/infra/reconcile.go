@@ -0,0 +1,131 @@
+package infra
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+// Action is what reconciling a resource did, or would do.
+type Action string
+
+const (
+	ActionCreate Action = "create"
+	ActionUpdate Action = "update"
+	// ActionDrift is a difference that cannot be reconciled in place, such
+	// as the key schema of a table, or that is not removed because it may
+	// be in use, such as an index that is not declared.
+	ActionDrift Action = "drift"
+)
+
+// Finding is a difference between the declaration and what exists.
+type Finding struct {
+	Action Action
+	// Resource is the kind and name of the resource, such as
+	// "table products".
+	Resource string
+	Detail   string
+}
+
+func (f Finding) String() string {
+	if f.Detail == "" {
+		return fmt.Sprintf("%-6s %s", f.Action, f.Resource)
+	}
+	return fmt.Sprintf("%-6s %s: %s", f.Action, f.Resource, f.Detail)
+}
+
+// tableWaitTimeout bounds the wait for a new table, or index, to become
+// active.
+const tableWaitTimeout = 5 * time.Minute
+
+// Reconciler creates the missing resources and brings the existing ones
+// in line with the declaration. Nothing is ever deleted.
+type Reconciler struct {
+	dynamo *dynamodb.Client
+	s3     *s3.Client
+	sns    *sns.Client
+	sqs    *sqs.Client
+	region string
+	// check only reports the differences.
+	check bool
+
+	topicARNs map[string]string
+	queueURLs map[string]string
+	queueARNs map[string]string
+}
+
+func NewReconciler(dynamo *dynamodb.Client, s3Client *s3.Client, snsClient *sns.Client, sqsClient *sqs.Client, region string, check bool) *Reconciler {
+	return &Reconciler{
+		dynamo:    dynamo,
+		s3:        s3Client,
+		sns:       snsClient,
+		sqs:       sqsClient,
+		region:    region,
+		check:     check,
+		topicARNs: make(map[string]string),
+		queueURLs: make(map[string]string),
+		queueARNs: make(map[string]string),
+	}
+}
+
+// Reconcile goes through the resources of spec in order and returns the
+// differences it found. In check mode nothing is changed; otherwise every
+// create and update finding has been applied when Reconcile returns, and
+// only the drift is left.
+func (r *Reconciler) Reconcile(ctx context.Context, spec Spec) ([]Finding, error) {
+	var findings []Finding
+	report := func(f []Finding, err error) error {
+		findings = append(findings, f...)
+		return err
+	}
+
+	for _, t := range spec.Tables {
+		if err := report(r.table(ctx, t)); err != nil {
+			return findings, fmt.Errorf("table %s: %w", t.Name, err)
+		}
+	}
+	for _, b := range spec.Buckets {
+		if err := report(r.bucket(ctx, b)); err != nil {
+			return findings, fmt.Errorf("bucket %s: %w", b.Name, err)
+		}
+	}
+	if err := r.listTopics(ctx); err != nil {
+		return findings, err
+	}
+	for _, t := range spec.Topics {
+		if err := report(r.topic(ctx, t)); err != nil {
+			return findings, fmt.Errorf("topic %s: %w", t.Name, err)
+		}
+	}
+	for _, q := range spec.Queues {
+		if err := report(r.queue(ctx, q, spec.Subscriptions)); err != nil {
+			return findings, fmt.Errorf("queue %s: %w", q.Name, err)
+		}
+	}
+	for _, s := range spec.Subscriptions {
+		if err := report(r.subscription(ctx, s)); err != nil {
+			return findings, fmt.Errorf("subscription %s to %s: %w", s.Queue, s.Topic, err)
+		}
+	}
+	return findings, nil
+}
+
+// sameJSON reports whether two JSON documents hold the same values. The
+// services return some numbers as strings, so values are compared as they
+// print: fmt sorts the keys of maps and prints 5 and "5" alike.
+func sameJSON(a, b string) bool {
+	if a == "" || b == "" {
+		return a == b
+	}
+	var va, vb any
+	if json.Unmarshal([]byte(a), &va) != nil || json.Unmarshal([]byte(b), &vb) != nil {
+		return a == b
+	}
+	return fmt.Sprint(va) == fmt.Sprint(vb)
+}
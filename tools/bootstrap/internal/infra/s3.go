@@ -0,0 +1,36 @@
+package infra
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func (r *Reconciler) bucket(ctx context.Context, b Bucket) ([]Finding, error) {
+	_, err := r.s3.HeadBucket(ctx, &s3.HeadBucketInput{
+		Bucket: aws.String(b.Name),
+	})
+	var notFound *types.NotFound
+	if !errors.As(err, &notFound) {
+		return nil, err
+	}
+
+	if !r.check {
+		input := &s3.CreateBucketInput{
+			Bucket: aws.String(b.Name),
+		}
+		// us-east-1 is the default location and is refused as a constraint.
+		if r.region != "us-east-1" {
+			input.CreateBucketConfiguration = &types.CreateBucketConfiguration{
+				LocationConstraint: types.BucketLocationConstraint(r.region),
+			}
+		}
+		if _, err := r.s3.CreateBucket(ctx, input); err != nil {
+			return nil, err
+		}
+	}
+	return []Finding{{Action: ActionCreate, Resource: "bucket " + b.Name}}, nil
+}
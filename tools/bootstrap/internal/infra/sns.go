@@ -0,0 +1,133 @@
+package infra
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+)
+
+// listTopics records the ARNs of the topics that exist, which SNS only
+// lists; there is no lookup by name.
+func (r *Reconciler) listTopics(ctx context.Context) error {
+	paginator := sns.NewListTopicsPaginator(r.sns, &sns.ListTopicsInput{})
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, topic := range output.Topics {
+			arn := aws.ToString(topic.TopicArn)
+			r.topicARNs[arn[strings.LastIndex(arn, ":")+1:]] = arn
+		}
+	}
+	return nil
+}
+
+func (r *Reconciler) topic(ctx context.Context, t Topic) ([]Finding, error) {
+	if r.topicARNs[t.Name] != "" {
+		return nil, nil
+	}
+
+	if !r.check {
+		output, err := r.sns.CreateTopic(ctx, &sns.CreateTopicInput{
+			Name: aws.String(t.Name),
+		})
+		if err != nil {
+			return nil, err
+		}
+		r.topicARNs[t.Name] = aws.ToString(output.TopicArn)
+	}
+	return []Finding{{Action: ActionCreate, Resource: "topic " + t.Name}}, nil
+}
+
+func (r *Reconciler) subscription(ctx context.Context, s Subscription) ([]Finding, error) {
+	resource := "subscription " + s.Queue + " to " + s.Topic
+
+	desired := map[string]string{
+		"RawMessageDelivery": strconv.FormatBool(s.RawMessageDelivery),
+	}
+	if s.FilterPolicy != "" {
+		desired["FilterPolicy"] = s.FilterPolicy
+	}
+
+	topicARN, queueARN := r.topicARNs[s.Topic], r.queueARNs[s.Queue]
+	if topicARN == "" || queueARN == "" {
+		// The topic or the queue is yet to be created, which only happens
+		// in check mode.
+		return []Finding{{Action: ActionCreate, Resource: resource}}, nil
+	}
+
+	subscriptionARN, err := r.findSubscription(ctx, topicARN, queueARN)
+	if err != nil {
+		return nil, err
+	}
+	if subscriptionARN == "" {
+		if !r.check {
+			_, err := r.sns.Subscribe(ctx, &sns.SubscribeInput{
+				TopicArn:              aws.String(topicARN),
+				Protocol:              aws.String("sqs"),
+				Endpoint:              aws.String(queueARN),
+				Attributes:            desired,
+				ReturnSubscriptionArn: true,
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+		return []Finding{{Action: ActionCreate, Resource: resource}}, nil
+	}
+
+	output, err := r.sns.GetSubscriptionAttributes(ctx, &sns.GetSubscriptionAttributesInput{
+		SubscriptionArn: aws.String(subscriptionARN),
+	})
+	if err != nil {
+		return nil, err
+	}
+	current := output.Attributes
+	if current["RawMessageDelivery"] == "" {
+		current["RawMessageDelivery"] = "false"
+	}
+
+	var findings []Finding
+	for _, name := range sortedKeys(desired) {
+		if sameJSON(current[name], desired[name]) {
+			continue
+		}
+		if !r.check {
+			_, err := r.sns.SetSubscriptionAttributes(ctx, &sns.SetSubscriptionAttributesInput{
+				SubscriptionArn: aws.String(subscriptionARN),
+				AttributeName:   aws.String(name),
+				AttributeValue:  aws.String(desired[name]),
+			})
+			if err != nil {
+				return findings, err
+			}
+		}
+		findings = append(findings, Finding{Action: ActionUpdate, Resource: resource, Detail: "set " + name + " to " + desired[name]})
+	}
+	if s.FilterPolicy == "" && current["FilterPolicy"] != "" {
+		findings = append(findings, Finding{Action: ActionDrift, Resource: resource, Detail: "FilterPolicy " + current["FilterPolicy"] + " is not declared"})
+	}
+	return findings, nil
+}
+
+func (r *Reconciler) findSubscription(ctx context.Context, topicARN, queueARN string) (string, error) {
+	paginator := sns.NewListSubscriptionsByTopicPaginator(r.sns, &sns.ListSubscriptionsByTopicInput{
+		TopicArn: aws.String(topicARN),
+	})
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return "", err
+		}
+		for _, s := range output.Subscriptions {
+			if aws.ToString(s.Protocol) == "sqs" && aws.ToString(s.Endpoint) == queueARN {
+				return aws.ToString(s.SubscriptionArn), nil
+			}
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,73 @@
+// Package infra declares the AWS resources the services run on and
+// reconciles an account, or LocalStack, against the declaration.
+package infra
+
+// Spec is every resource the services expect, in the order they are
+// reconciled: a queue comes after its dead-letter queue and a subscription
+// after its topic and queue.
+type Spec struct {
+	Tables        []Table
+	Buckets       []Bucket
+	Topics        []Topic
+	Queues        []Queue
+	Subscriptions []Subscription
+}
+
+// Key is the key schema of a table or an index. Every key attribute of the
+// tables is a string.
+type Key struct {
+	Hash  string
+	Range string
+}
+
+// Index is a secondary index. Indexes project every attribute.
+type Index struct {
+	Name string
+	Key  Key
+}
+
+// Table is an on-demand DynamoDB table.
+type Table struct {
+	Name          string
+	Key           Key
+	LocalIndexes  []Index
+	GlobalIndexes []Index
+	// TTLAttribute, when set, is the attribute items expire on.
+	TTLAttribute string
+}
+
+// Bucket is an S3 bucket.
+type Bucket struct {
+	Name string
+}
+
+// Topic is an SNS topic.
+type Topic struct {
+	Name string
+}
+
+// Queue is an SQS queue.
+type Queue struct {
+	Name string
+	// DeadLetterQueue, when set, receives the messages that were received
+	// MaxReceiveCount times without being deleted.
+	DeadLetterQueue string
+	MaxReceiveCount int
+	// Attributes are the other queue attributes that are managed, such as
+	// MessageRetentionPeriod. Attributes that are not listed are left as
+	// they are.
+	Attributes map[string]string
+}
+
+// Subscription delivers the messages of a topic to a queue. The queue
+// policy lets the topic send to it.
+type Subscription struct {
+	Topic string
+	Queue string
+	// FilterPolicy, when set, is the JSON policy on the message attributes
+	// that selects what is delivered.
+	FilterPolicy string
+	// RawMessageDelivery sends the bare message instead of the SNS
+	// envelope. The consumers read the envelope, so it is off everywhere.
+	RawMessageDelivery bool
+}
@@ -0,0 +1,147 @@
+package infra
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+type redrivePolicy struct {
+	DeadLetterTargetArn string `json:"deadLetterTargetArn"`
+	MaxReceiveCount     int    `json:"maxReceiveCount"`
+}
+
+type queuePolicy struct {
+	Version   string
+	Statement []policyStatement
+}
+
+type policyStatement struct {
+	Sid       string
+	Effect    string
+	Principal map[string]string
+	Action    string
+	Resource  string
+	Condition map[string]map[string]string
+}
+
+func (r *Reconciler) queue(ctx context.Context, q Queue, subscriptions []Subscription) ([]Finding, error) {
+	resource := "queue " + q.Name
+
+	var findings []Finding
+	created := false
+	output, err := r.sqs.GetQueueUrl(ctx, &sqs.GetQueueUrlInput{
+		QueueName: aws.String(q.Name),
+	})
+	var notFound *types.QueueDoesNotExist
+	switch {
+	case errors.As(err, &notFound):
+		findings = append(findings, Finding{Action: ActionCreate, Resource: resource})
+		if r.check {
+			return findings, nil
+		}
+		// The policy names the queue, so it is set once the queue exists.
+		attributes, _ := r.queueAttributes(q, subscriptions, "")
+		output, err := r.sqs.CreateQueue(ctx, &sqs.CreateQueueInput{
+			QueueName:  aws.String(q.Name),
+			Attributes: attributes,
+		})
+		if err != nil {
+			return nil, err
+		}
+		r.queueURLs[q.Name] = aws.ToString(output.QueueUrl)
+		created = true
+	case err != nil:
+		return nil, err
+	default:
+		r.queueURLs[q.Name] = aws.ToString(output.QueueUrl)
+	}
+
+	current, err := r.sqs.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl:       aws.String(r.queueURLs[q.Name]),
+		AttributeNames: []types.QueueAttributeName{types.QueueAttributeNameAll},
+	})
+	if err != nil {
+		return findings, err
+	}
+	r.queueARNs[q.Name] = current.Attributes["QueueArn"]
+
+	desired, pending := r.queueAttributes(q, subscriptions, r.queueARNs[q.Name])
+	for _, name := range sortedKeys(desired) {
+		if sameJSON(current.Attributes[name], desired[name]) {
+			continue
+		}
+		if !r.check {
+			_, err := r.sqs.SetQueueAttributes(ctx, &sqs.SetQueueAttributesInput{
+				QueueUrl:   aws.String(r.queueURLs[q.Name]),
+				Attributes: map[string]string{name: desired[name]},
+			})
+			if err != nil {
+				return findings, err
+			}
+		}
+		// What is set right after the creation is part of it.
+		if !created {
+			findings = append(findings, Finding{Action: ActionUpdate, Resource: resource, Detail: "set " + name})
+		}
+	}
+	for _, name := range pending {
+		findings = append(findings, Finding{Action: ActionUpdate, Resource: resource, Detail: "set " + name + " once the resources it names exist"})
+	}
+	return findings, nil
+}
+
+// queueAttributes returns the declared attributes of q, with the policy
+// that lets the topics subscribed to it send to it when queueARN is known.
+// The attributes that name a resource that does not exist yet, which only
+// happens in check mode, are returned as pending.
+func (r *Reconciler) queueAttributes(q Queue, subscriptions []Subscription, queueARN string) (map[string]string, []string) {
+	attributes := make(map[string]string, len(q.Attributes)+2)
+	for name, value := range q.Attributes {
+		attributes[name] = value
+	}
+	var pending []string
+
+	if q.DeadLetterQueue != "" {
+		if arn := r.queueARNs[q.DeadLetterQueue]; arn != "" {
+			policy, _ := json.Marshal(redrivePolicy{DeadLetterTargetArn: arn, MaxReceiveCount: q.MaxReceiveCount})
+			attributes["RedrivePolicy"] = string(policy)
+		} else {
+			pending = append(pending, "RedrivePolicy")
+		}
+	}
+
+	if queueARN == "" {
+		return attributes, pending
+	}
+	policy := queuePolicy{Version: "2012-10-17"}
+	for _, s := range subscriptions {
+		if s.Queue != q.Name {
+			continue
+		}
+		topicARN := r.topicARNs[s.Topic]
+		if topicARN == "" {
+			pending = append(pending, "Policy")
+			return attributes, pending
+		}
+		policy.Statement = append(policy.Statement, policyStatement{
+			Sid:       "topic-" + s.Topic,
+			Effect:    "Allow",
+			Principal: map[string]string{"Service": "sns.amazonaws.com"},
+			Action:    "sqs:SendMessage",
+			Resource:  queueARN,
+			Condition: map[string]map[string]string{
+				"ArnEquals": {"aws:SourceArn": topicARN},
+			},
+		})
+	}
+	if len(policy.Statement) > 0 {
+		document, _ := json.Marshal(policy)
+		attributes["Policy"] = string(document)
+	}
+	return attributes, pending
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"config"
+	"fmt"
+
+	"migrate/internal/migrations"
+)
+
+// Config is the configuration of the command, loaded from the environment
+// and the optional CONFIG_FILE. The table names are read from the
+// variables the services read them from.
+type Config struct {
+	MigrationsTable    string `env:"MIGRATIONS_TABLE" default:"migrations"`
+	ProductsTable      string `env:"PRODUCTS_TABLE" default:"products"`
+	ProductFacetsTable string `env:"PRODUCT_FACETS_TABLE" default:"product_facets"`
+	OrdersTable        string `env:"ORDERS_TABLE" default:"orders"`
+
+	AWS config.AWS
+}
+
+// table returns the name of a logical table of the migrations.
+func (c *Config) table(name string) (string, error) {
+	tables := map[string]string{
+		"products":       c.ProductsTable,
+		"product_facets": c.ProductFacetsTable,
+		"orders":         c.OrdersTable,
+	}
+	if table, ok := tables[name]; ok {
+		return table, nil
+	}
+	return "", fmt.Errorf("no table is configured for %q", name)
+}
+
+// into returns the name of the table the migration puts the items it
+// derives into, if it derives any.
+func (c *Config) into(m migrations.Migration) (string, error) {
+	if m.Into == "" {
+		return "", nil
+	}
+	return c.table(m.Into)
+}
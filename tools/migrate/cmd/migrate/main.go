@@ -0,0 +1,201 @@
+// Command migrate runs the DynamoDB item migrations and shows where they
+// stand.
+//
+//	migrate status
+//	migrate up [-dry-run] [-segments N]
+//	migrate run [-dry-run] [-segments N] [-table NAME] ID
+//
+// up runs the migrations that have not completed, in order, and stops at
+// the first that fails. run runs a single one, against the table it is
+// written for or any other. An interrupted migration resumes from its
+// checkpoints when it is run again. With -dry-run the items are only
+// counted, nothing is written or recorded.
+package main
+
+import (
+	"config"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"migrate/internal/migrations"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	var conf Config
+	if err := config.Load(&conf); err != nil {
+		log.Fatal(err)
+	}
+
+	// Stopping between pages leaves the checkpoints in place for the
+	// next run.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var err error
+	switch os.Args[1] {
+	case "status":
+		err = runStatus(ctx, &conf)
+	case "up":
+		err = runUp(ctx, &conf, os.Args[2:])
+	case "run":
+		err = runOne(ctx, &conf, os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: migrate status")
+	fmt.Fprintln(os.Stderr, "       migrate up [-dry-run] [-segments N]")
+	fmt.Fprintln(os.Stderr, "       migrate run [-dry-run] [-segments N] [-table NAME] ID")
+	os.Exit(2)
+}
+
+func runStatus(ctx context.Context, conf *Config) error {
+	store, _, err := connect(ctx, conf)
+	if err != nil {
+		return err
+	}
+	records, err := store.List(ctx)
+	if err != nil {
+		return err
+	}
+	recorded := make(map[string]migrations.Record, len(records))
+	for _, r := range records {
+		recorded[r.Table+"/"+r.ID] = r
+	}
+
+	for _, m := range migrations.All {
+		table, err := conf.table(m.Table)
+		if err != nil {
+			return err
+		}
+		r, ok := recorded[table+"/"+m.ID]
+		delete(recorded, table+"/"+m.ID)
+		if !ok {
+			fmt.Printf("%-30s %-20s pending    %s\n", m.ID, table, m.Description)
+			continue
+		}
+		printRecord(r)
+	}
+	// Runs against other tables.
+	for _, r := range records {
+		if _, ok := recorded[r.Table+"/"+r.ID]; ok {
+			printRecord(r)
+		}
+	}
+	return nil
+}
+
+func printRecord(r migrations.Record) {
+	fmt.Printf("%-30s %-20s %-10s %d scanned, %d updated, %d/%d segments done\n",
+		r.ID, r.Table, r.Status, r.Scanned, r.Updated, len(r.Done), r.Segments)
+}
+
+func runUp(ctx context.Context, conf *Config, args []string) error {
+	flags := flag.NewFlagSet("up", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "count the items that would change without writing them")
+	segments := flags.Int("segments", 4, "segments each table is scanned in, in parallel")
+	flags.Parse(args)
+	if flags.NArg() != 0 || *segments < 1 {
+		usage()
+	}
+
+	store, client, err := connect(ctx, conf)
+	if err != nil {
+		return err
+	}
+	runner := migrations.NewRunner(client, store, *segments, *dryRun, printProgress)
+	for _, m := range migrations.All {
+		table, err := conf.table(m.Table)
+		if err != nil {
+			return err
+		}
+		record, err := store.Get(ctx, table, m.ID)
+		if err == nil && record.Status == migrations.StatusCompleted {
+			continue
+		}
+		if err != nil && !errors.Is(err, migrations.ErrRecordNotFound) {
+			return err
+		}
+		into, err := conf.into(m)
+		if err != nil {
+			return err
+		}
+		if err := runner.Run(ctx, m, table, into); err != nil {
+			return fmt.Errorf("%s on %s: %w", m.ID, table, err)
+		}
+	}
+	return nil
+}
+
+func runOne(ctx context.Context, conf *Config, args []string) error {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "count the items that would change without writing them")
+	segments := flags.Int("segments", 4, "segments the table is scanned in, in parallel")
+	table := flags.String("table", "", "table to migrate (default: the table the migration is written for)")
+	flags.Parse(args)
+	if flags.NArg() != 1 || *segments < 1 {
+		usage()
+	}
+
+	m, err := migrations.Lookup(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *table == "" {
+		if *table, err = conf.table(m.Table); err != nil {
+			return err
+		}
+	}
+
+	into, err := conf.into(m)
+	if err != nil {
+		return err
+	}
+
+	store, client, err := connect(ctx, conf)
+	if err != nil {
+		return err
+	}
+	runner := migrations.NewRunner(client, store, *segments, *dryRun, printProgress)
+	if err := runner.Run(ctx, m, *table, into); err != nil {
+		return fmt.Errorf("%s on %s: %w", m.ID, *table, err)
+	}
+	return nil
+}
+
+// connect returns the store of the records and the client of the tables.
+func connect(ctx context.Context, conf *Config) (*migrations.DynamoStore, *dynamodb.Client, error) {
+	cfg, err := conf.AWS.Load(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	client := conf.AWS.DynamoDB(cfg)
+	return migrations.NewDynamoStore(client, conf.MigrationsTable), client, nil
+}
+
+func printProgress(p migrations.Progress) {
+	prefix, updated := "", "updated"
+	if p.DryRun {
+		prefix, updated = "dry run: ", "to update"
+	}
+	fmt.Printf("%s%s on %s: %d scanned, %d %s, %d/%d segments done\n",
+		prefix, p.Migration, p.Table, p.Scanned, p.Updated, updated, p.SegmentsDone, p.Segments)
+}
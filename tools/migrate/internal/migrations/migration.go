@@ -0,0 +1,53 @@
+// Package migrations brings the items stored before a change of schema in
+// line with it, for example by filling in an attribute that new items always
+// have. Migrations run in order, each once per table, and are recorded in
+// the migrations table together with their progress so that an interrupted
+// run resumes where it stopped.
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Item is a stored item as DynamoDB returns it.
+type Item = map[string]types.AttributeValue
+
+// Migration transforms the items of one table.
+type Migration struct {
+	// ID orders the migrations and names their records. It is never
+	// changed once the migration has run anywhere.
+	ID          string
+	Description string
+	// Table is the logical table the migration applies to, such as
+	// "products"; the CLI maps it to the configured table name.
+	Table string
+	// Transform returns the attributes to set on item, or nil when the item
+	// needs no change. It must return nil for an item it already migrated,
+	// since items are seen again when a run is resumed or retried.
+	Transform func(item Item) (Item, error)
+	// Derive, in place of Transform, returns the items to put into the
+	// logical table Into for item, for migrations that fill a table from
+	// the items of another. Items are put again when a run is resumed or
+	// retried, so they must not depend on anything but item.
+	Derive func(item Item) ([]Item, error)
+	Into   string
+}
+
+// All is every migration, in the order they run. New migrations are
+// appended.
+var All = []Migration{
+	productStatus,
+	productFacets,
+}
+
+// Lookup returns the migration with the given id.
+func Lookup(id string) (Migration, error) {
+	for _, m := range All {
+		if m.ID == id {
+			return m, nil
+		}
+	}
+	return Migration{}, fmt.Errorf("unknown migration %q", id)
+}
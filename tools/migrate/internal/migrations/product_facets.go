@@ -0,0 +1,62 @@
+package migrations
+
+import (
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// productFacets fills the facets table the product listing filters query
+// with the tags and attribute values of the products stored before it
+// existed. The facets are named as products-service names them.
+var productFacets = Migration{
+	ID:          "0002-product-facets",
+	Description: "write the filter facets of every product",
+	Table:       "products",
+	Into:        "product_facets",
+	Derive: func(item Item) ([]Item, error) {
+		id, ok := item["id"].(*types.AttributeValueMemberS)
+		if !ok {
+			return nil, nil
+		}
+		var facets []string
+		if tags, ok := item["tags"].(*types.AttributeValueMemberL); ok {
+			for _, tag := range tags.Value {
+				if tag, ok := tag.(*types.AttributeValueMemberS); ok {
+					facets = append(facets, "tag:"+tag.Value)
+				}
+			}
+		}
+		if attributes, ok := item["attributes"].(*types.AttributeValueMemberM); ok {
+			for name, value := range attributes.Value {
+				switch v := value.(type) {
+				case *types.AttributeValueMemberS:
+					facets = append(facets, "attribute:"+name+"="+v.Value)
+				case *types.AttributeValueMemberN:
+					n, err := strconv.ParseFloat(v.Value, 64)
+					if err != nil {
+						return nil, err
+					}
+					facets = append(facets, "attribute:"+name+"="+strconv.FormatFloat(n, 'f', -1, 64))
+				case *types.AttributeValueMemberBOOL:
+					facets = append(facets, "attribute:"+name+"="+strconv.FormatBool(v.Value))
+				}
+			}
+		}
+
+		items := make([]Item, 0, len(facets))
+		seen := make(map[string]bool, len(facets))
+		for _, facet := range facets {
+			// A batch write fails on duplicate keys.
+			if seen[facet] {
+				continue
+			}
+			seen[facet] = true
+			items = append(items, Item{
+				"productId": id,
+				"facet":     &types.AttributeValueMemberS{Value: facet},
+			})
+		}
+		return items, nil
+	},
+}
@@ -0,0 +1,20 @@
+package migrations
+
+import "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+// productStatus gives the products stored before the product lifecycle the
+// active status they are treated as having, so that every product can be
+// filtered on it.
+var productStatus = Migration{
+	ID:          "0001-product-status",
+	Description: "set the status of products stored without one to active",
+	Table:       "products",
+	Transform: func(item Item) (Item, error) {
+		if _, ok := item["status"]; ok {
+			return nil, nil
+		}
+		return Item{
+			"status": &types.AttributeValueMemberS{Value: "active"},
+		}, nil
+	},
+}
@@ -0,0 +1,413 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+var ErrAlreadyCompleted = errors.New("migration already completed")
+
+const (
+	// pageSize is how many items a scan page holds; the checkpoint of a
+	// segment moves after every page.
+	pageSize = 100
+	// maxAttempts bounds how often an item that keeps changing while it is
+	// migrated is read again, and how often the derived items DynamoDB
+	// leaves unprocessed are written again.
+	maxAttempts = 3
+	// progressInterval is how often the progress is reported during a run.
+	progressInterval = 5 * time.Second
+	// batchWriteSize is the most items DynamoDB accepts in one
+	// BatchWriteItem call.
+	batchWriteSize = 25
+)
+
+// Progress is how far a run got. The counters include what earlier,
+// interrupted runs of the migration recorded.
+type Progress struct {
+	Migration    string
+	Table        string
+	DryRun       bool
+	Segments     int
+	SegmentsDone int
+	Scanned      int64
+	Updated      int64
+}
+
+// Runner runs migrations with parallel scans, one goroutine per segment of
+// the table.
+type Runner struct {
+	client   *dynamodb.Client
+	store    Store
+	segments int
+	// dryRun transforms the items without writing them or recording
+	// anything, to count what would change.
+	dryRun bool
+	report func(Progress)
+}
+
+// NewRunner returns a runner scanning tables in segments, which new runs
+// use; resumed runs keep the segments they started with. report is given
+// the progress every few seconds and once the run is over.
+func NewRunner(client *dynamodb.Client, store Store, segments int, dryRun bool, report func(Progress)) *Runner {
+	return &Runner{
+		client:   client,
+		store:    store,
+		segments: segments,
+		dryRun:   dryRun,
+		report:   report,
+	}
+}
+
+// run is the state of one migration run.
+type run struct {
+	migration    Migration
+	table        string
+	into         string
+	keyNames     []string
+	segments     int
+	scanned      atomic.Int64
+	updated      atomic.Int64
+	segmentsDone atomic.Int64
+}
+
+// Run applies m to table, resuming from the checkpoints if an earlier run
+// was interrupted, and puts the items m derives into the table into. It
+// returns ErrAlreadyCompleted if m completed on table before.
+func (r *Runner) Run(ctx context.Context, m Migration, table, into string) error {
+	record, err := r.store.Get(ctx, table, m.ID)
+	if errors.Is(err, ErrRecordNotFound) {
+		record = nil
+	} else if err != nil {
+		return err
+	}
+	if record != nil && record.Status == StatusCompleted {
+		return ErrAlreadyCompleted
+	}
+
+	keyNames, err := r.keyNames(ctx, table)
+	if err != nil {
+		return err
+	}
+
+	if record == nil && !r.dryRun {
+		record = &Record{
+			Table:       table,
+			ID:          m.ID,
+			Description: m.Description,
+			Status:      StatusRunning,
+			Segments:    r.segments,
+			StartedAt:   time.Now().UTC().Format(time.RFC3339),
+		}
+		if err := r.store.Start(ctx, record); err != nil {
+			return err
+		}
+	}
+
+	state := &run{migration: m, table: table, into: into, keyNames: keyNames, segments: r.segments}
+	if record != nil && !r.dryRun {
+		state.segments = record.Segments
+		state.scanned.Store(record.Scanned)
+		state.updated.Store(record.Updated)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errs := make([]error, state.segments)
+	var wg sync.WaitGroup
+	for segment := 0; segment < state.segments; segment++ {
+		var startKey Item
+		if record != nil && !r.dryRun {
+			if record.IsDone(segment) {
+				state.segmentsDone.Add(1)
+				continue
+			}
+			startKey = record.Checkpoints[segment]
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := r.scanSegment(ctx, state, segment, startKey); err != nil {
+				errs[segment] = fmt.Errorf("segment %d: %w", segment, err)
+				cancel()
+			}
+		}()
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	for waiting := true; waiting; {
+		select {
+		case <-finished:
+			waiting = false
+		case <-ticker.C:
+			r.report(r.progress(state))
+		}
+	}
+	r.report(r.progress(state))
+
+	if err := firstError(errs); err != nil {
+		return err
+	}
+	if r.dryRun {
+		return nil
+	}
+	return r.store.Complete(ctx, table, m.ID, time.Now().UTC().Format(time.RFC3339))
+}
+
+func (r *Runner) scanSegment(ctx context.Context, state *run, segment int, startKey Item) error {
+	for {
+		output, err := r.client.Scan(ctx, &dynamodb.ScanInput{
+			TableName:         aws.String(state.table),
+			Segment:           aws.Int32(int32(segment)),
+			TotalSegments:     aws.Int32(int32(state.segments)),
+			Limit:             aws.Int32(pageSize),
+			ExclusiveStartKey: startKey,
+		})
+		if err != nil {
+			return err
+		}
+
+		var updated int64
+		for _, item := range output.Items {
+			changed, err := r.migrateItem(ctx, state, item)
+			if err != nil {
+				return err
+			}
+			if changed {
+				updated++
+			}
+		}
+		state.scanned.Add(int64(len(output.Items)))
+		state.updated.Add(updated)
+
+		var lastKey Item
+		if len(output.LastEvaluatedKey) > 0 {
+			lastKey = output.LastEvaluatedKey
+		}
+		if !r.dryRun {
+			err := r.store.Checkpoint(ctx, state.table, state.migration.ID, segment, lastKey, int64(len(output.Items)), updated)
+			if err != nil {
+				return err
+			}
+		}
+		if lastKey == nil {
+			state.segmentsDone.Add(1)
+			return nil
+		}
+		startKey = lastKey
+	}
+}
+
+// migrateItem sets the attributes the migration returns for item, on the
+// condition that none of them changed since the item was read. When one
+// did, the item is read again and transformed anew.
+func (r *Runner) migrateItem(ctx context.Context, state *run, item Item) (bool, error) {
+	key := make(Item, len(state.keyNames))
+	for _, name := range state.keyNames {
+		key[name] = item[name]
+	}
+	if state.migration.Derive != nil {
+		return r.deriveItem(ctx, state, key, item)
+	}
+
+	for attempt := 1; ; attempt++ {
+		changes, err := state.migration.Transform(item)
+		if err != nil {
+			return false, fmt.Errorf("item %s: %w", formatKey(key), err)
+		}
+		if len(changes) == 0 {
+			return false, nil
+		}
+		if r.dryRun {
+			return true, nil
+		}
+
+		err = r.update(ctx, state, key, item, changes)
+		var conditionErr *types.ConditionalCheckFailedException
+		if !errors.As(err, &conditionErr) || attempt == maxAttempts {
+			if err != nil {
+				return false, fmt.Errorf("item %s: %w", formatKey(key), err)
+			}
+			return true, nil
+		}
+
+		output, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+			TableName:      aws.String(state.table),
+			Key:            key,
+			ConsistentRead: aws.Bool(true),
+		})
+		if err != nil {
+			return false, err
+		}
+		if len(output.Item) == 0 {
+			// Deleted in the meantime.
+			return false, nil
+		}
+		item = output.Item
+	}
+}
+
+// deriveItem puts the items the migration derives from item into the
+// target table, retrying the ones DynamoDB leaves unprocessed, e.g. when
+// throttled.
+func (r *Runner) deriveItem(ctx context.Context, state *run, key, item Item) (bool, error) {
+	derived, err := state.migration.Derive(item)
+	if err != nil {
+		return false, fmt.Errorf("item %s: %w", formatKey(key), err)
+	}
+	if len(derived) == 0 {
+		return false, nil
+	}
+	if r.dryRun {
+		return true, nil
+	}
+
+	for start := 0; start < len(derived); start += batchWriteSize {
+		end := min(start+batchWriteSize, len(derived))
+		requests := make([]types.WriteRequest, 0, end-start)
+		for _, d := range derived[start:end] {
+			requests = append(requests, types.WriteRequest{PutRequest: &types.PutRequest{Item: d}})
+		}
+
+		request := map[string][]types.WriteRequest{state.into: requests}
+		for attempt := 1; len(request) > 0; attempt++ {
+			if attempt > maxAttempts {
+				return false, fmt.Errorf("item %s: %d derived items still unwritten after %d attempts", formatKey(key), len(request[state.into]), maxAttempts)
+			}
+			if attempt > 1 {
+				select {
+				case <-ctx.Done():
+					return false, ctx.Err()
+				case <-time.After(time.Duration(attempt) * 100 * time.Millisecond):
+				}
+			}
+			output, err := r.client.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
+				RequestItems: request,
+			})
+			if err != nil {
+				return false, fmt.Errorf("item %s: %w", formatKey(key), err)
+			}
+			request = output.UnprocessedItems
+		}
+	}
+	return true, nil
+}
+
+func (r *Runner) update(ctx context.Context, state *run, key, item, changes Item) error {
+	names := map[string]string{"#key": state.keyNames[0]}
+	values := make(map[string]types.AttributeValue)
+	sets := make([]string, 0, len(changes))
+	conditions := []string{"attribute_exists(#key)"}
+
+	for i, name := range sortedNames(changes) {
+		n := strconv.Itoa(i)
+		names["#a"+n] = name
+		values[":v"+n] = changes[name]
+		sets = append(sets, "#a"+n+" = :v"+n)
+		if old, ok := item[name]; ok {
+			values[":o"+n] = old
+			conditions = append(conditions, "#a"+n+" = :o"+n)
+		} else {
+			conditions = append(conditions, "attribute_not_exists(#a"+n+")")
+		}
+	}
+
+	_, err := r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(state.table),
+		Key:                       key,
+		UpdateExpression:          aws.String("SET " + strings.Join(sets, ", ")),
+		ConditionExpression:       aws.String(strings.Join(conditions, " AND ")),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+	})
+	return err
+}
+
+// keyNames returns the key attributes of table, the partition key first.
+func (r *Runner) keyNames(ctx context.Context, table string) ([]string, error) {
+	output, err := r.client.DescribeTable(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(table),
+	})
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, 2)
+	for _, e := range output.Table.KeySchema {
+		if e.KeyType == types.KeyTypeHash {
+			names = append([]string{aws.ToString(e.AttributeName)}, names...)
+		} else {
+			names = append(names, aws.ToString(e.AttributeName))
+		}
+	}
+	return names, nil
+}
+
+func (r *Runner) progress(state *run) Progress {
+	return Progress{
+		Migration:    state.migration.ID,
+		Table:        state.table,
+		DryRun:       r.dryRun,
+		Segments:     state.segments,
+		SegmentsDone: int(state.segmentsDone.Load()),
+		Scanned:      state.scanned.Load(),
+		Updated:      state.updated.Load(),
+	}
+}
+
+// firstError returns the error that stopped the run, rather than the
+// cancellations it caused in the other segments.
+func firstError(errs []error) error {
+	var canceled error
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, context.Canceled) {
+			return err
+		}
+		canceled = err
+	}
+	return canceled
+}
+
+func formatKey(key Item) string {
+	parts := make([]string, 0, len(key))
+	for _, name := range sortedNames(key) {
+		switch v := key[name].(type) {
+		case *types.AttributeValueMemberS:
+			parts = append(parts, name+"="+v.Value)
+		case *types.AttributeValueMemberN:
+			parts = append(parts, name+"="+v.Value)
+		default:
+			parts = append(parts, name+"=?")
+		}
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
+func sortedNames(item Item) []string {
+	names := make([]string, 0, len(item))
+	for name := range item {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,229 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+var (
+	ErrRecordNotFound = errors.New("migration record not found")
+	ErrAlreadyStarted = errors.New("migration already started")
+)
+
+type Status string
+
+const (
+	StatusRunning   Status = "running"
+	StatusCompleted Status = "completed"
+)
+
+// Record is the progress of a migration on a table.
+type Record struct {
+	Table       string `dynamodbav:"table"`
+	ID          string `dynamodbav:"id"`
+	Description string `dynamodbav:"description"`
+	Status      Status `dynamodbav:"status"`
+	// Segments is how many segments the table is scanned in. It is set when
+	// the migration starts and kept when it resumes, since the checkpoints
+	// only hold for the same segments.
+	Segments int `dynamodbav:"segments"`
+	// Checkpoints holds, per segment that is still scanned, the key the scan
+	// resumes after. It is stored as a map of maps and read separately.
+	Checkpoints map[int]Item `dynamodbav:"-"`
+	// Done lists the segments that were scanned to the end.
+	Done []int `dynamodbav:"done,numberset,omitempty"`
+	// Scanned and Updated count the items. Items scanned after the last
+	// checkpoint of an interrupted run are counted again when it resumes.
+	Scanned     int64  `dynamodbav:"scanned"`
+	Updated     int64  `dynamodbav:"updated"`
+	StartedAt   string `dynamodbav:"startedAt"`
+	CompletedAt string `dynamodbav:"completedAt,omitempty"`
+}
+
+// IsDone reports whether the segment was scanned to the end.
+func (r *Record) IsDone(segment int) bool {
+	for _, s := range r.Done {
+		if s == segment {
+			return true
+		}
+	}
+	return false
+}
+
+// Store keeps the records of the migrations, keyed by table and id.
+type Store interface {
+	Get(ctx context.Context, table, id string) (*Record, error)
+	List(ctx context.Context) ([]Record, error)
+	// Start records a migration that never ran on the table, or returns
+	// ErrAlreadyStarted.
+	Start(ctx context.Context, record *Record) error
+	// Checkpoint adds to the counters and moves the checkpoint of the
+	// segment to lastKey; a nil lastKey marks the segment done.
+	Checkpoint(ctx context.Context, table, id string, segment int, lastKey Item, scanned, updated int64) error
+	Complete(ctx context.Context, table, id, completedAt string) error
+}
+
+type DynamoStore struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+func NewDynamoStore(client *dynamodb.Client, tableName string) *DynamoStore {
+	return &DynamoStore{
+		client:    client,
+		tableName: tableName,
+	}
+}
+
+func (s *DynamoStore) Get(ctx context.Context, table, id string) (*Record, error) {
+	output, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(s.tableName),
+		Key:            recordKey(table, id),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(output.Item) == 0 {
+		return nil, ErrRecordNotFound
+	}
+	return unmarshalRecord(output.Item)
+}
+
+func (s *DynamoStore) List(ctx context.Context) ([]Record, error) {
+	var records []Record
+	paginator := dynamodb.NewScanPaginator(s.client, &dynamodb.ScanInput{
+		TableName: aws.String(s.tableName),
+	})
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range output.Items {
+			record, err := unmarshalRecord(item)
+			if err != nil {
+				return nil, err
+			}
+			records = append(records, *record)
+		}
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Table != records[j].Table {
+			return records[i].Table < records[j].Table
+		}
+		return records[i].ID < records[j].ID
+	})
+	return records, nil
+}
+
+func (s *DynamoStore) Start(ctx context.Context, record *Record) error {
+	item, err := attributevalue.MarshalMap(record)
+	if err != nil {
+		return err
+	}
+	// The checkpoints of the segments are set inside this map.
+	item["checkpoints"] = &types.AttributeValueMemberM{Value: Item{}}
+
+	_, err = s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:                aws.String(s.tableName),
+		Item:                     item,
+		ConditionExpression:      aws.String("attribute_not_exists(#id)"),
+		ExpressionAttributeNames: map[string]string{"#id": "id"},
+	})
+	var conditionErr *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionErr) {
+		return ErrAlreadyStarted
+	}
+	return err
+}
+
+func (s *DynamoStore) Checkpoint(ctx context.Context, table, id string, segment int, lastKey Item, scanned, updated int64) error {
+	segmentName := strconv.Itoa(segment)
+	names := map[string]string{
+		"#checkpoints": "checkpoints",
+		"#segment":     segmentName,
+		"#scanned":     "scanned",
+		"#updated":     "updated",
+		"#status":      "status",
+	}
+	values := map[string]types.AttributeValue{
+		":scanned": &types.AttributeValueMemberN{Value: strconv.FormatInt(scanned, 10)},
+		":updated": &types.AttributeValueMemberN{Value: strconv.FormatInt(updated, 10)},
+		":running": &types.AttributeValueMemberS{Value: string(StatusRunning)},
+	}
+
+	var update string
+	if lastKey != nil {
+		update = "SET #checkpoints.#segment = :key ADD #scanned :scanned, #updated :updated"
+		values[":key"] = &types.AttributeValueMemberM{Value: lastKey}
+	} else {
+		update = "REMOVE #checkpoints.#segment ADD #done :segment, #scanned :scanned, #updated :updated"
+		names["#done"] = "done"
+		values[":segment"] = &types.AttributeValueMemberNS{Value: []string{segmentName}}
+	}
+
+	_, err := s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(s.tableName),
+		Key:                       recordKey(table, id),
+		UpdateExpression:          aws.String(update),
+		ConditionExpression:       aws.String("#status = :running"),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+	})
+	return err
+}
+
+func (s *DynamoStore) Complete(ctx context.Context, table, id, completedAt string) error {
+	_, err := s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:        aws.String(s.tableName),
+		Key:              recordKey(table, id),
+		UpdateExpression: aws.String("SET #status = :completed, #completedAt = :completedAt REMOVE #checkpoints"),
+		ExpressionAttributeNames: map[string]string{
+			"#status":      "status",
+			"#completedAt": "completedAt",
+			"#checkpoints": "checkpoints",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":completed":   &types.AttributeValueMemberS{Value: string(StatusCompleted)},
+			":completedAt": &types.AttributeValueMemberS{Value: completedAt},
+		},
+	})
+	return err
+}
+
+func unmarshalRecord(item Item) (*Record, error) {
+	var record Record
+	if err := attributevalue.UnmarshalMap(item, &record); err != nil {
+		return nil, err
+	}
+
+	record.Checkpoints = make(map[int]Item)
+	if checkpoints, ok := item["checkpoints"].(*types.AttributeValueMemberM); ok {
+		for name, value := range checkpoints.Value {
+			segment, err := strconv.Atoi(name)
+			if err != nil {
+				continue
+			}
+			if key, ok := value.(*types.AttributeValueMemberM); ok {
+				record.Checkpoints[segment] = key.Value
+			}
+		}
+	}
+	return &record, nil
+}
+
+func recordKey(table, id string) Item {
+	return Item{
+		"table": &types.AttributeValueMemberS{Value: table},
+		"id":    &types.AttributeValueMemberS{Value: id},
+	}
+}
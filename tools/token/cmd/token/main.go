@@ -0,0 +1,208 @@
+// Command token makes a local key set and signs tokens with it, so the
+// services can be called with every role without an identity provider.
+//
+//	token keygen [-dir DIR] [-force]
+//	token sign [-key FILE] [-iss ISSUER] [-aud AUDIENCE] [-roles R1,R2] [-ttl D] -sub SUBJECT
+//
+// keygen writes an ECDSA P-256 private key to DIR/private.pem and its key
+// set to DIR/jwks.json, which the services read through
+// AUTH_JWKS_URL=file://DIR/jwks.json. An existing key is kept unless -force
+// is given, so the tokens signed before stay valid. sign prints a token for
+// the subject with the roles.
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "keygen":
+		err = keygen(os.Args[2:])
+	case "sign":
+		err = sign(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: token keygen [-dir DIR] [-force]")
+	fmt.Fprintln(os.Stderr, "       token sign [-key FILE] [-iss ISSUER] [-aud AUDIENCE] [-roles R1,R2] [-ttl D] -sub SUBJECT")
+	os.Exit(2)
+}
+
+func keygen(args []string) error {
+	flags := flag.NewFlagSet("keygen", flag.ExitOnError)
+	dir := flags.String("dir", ".", "directory to write private.pem and jwks.json to")
+	force := flags.Bool("force", false, "replace an existing key")
+	flags.Parse(args)
+	if flags.NArg() != 0 {
+		usage()
+	}
+
+	keyPath := filepath.Join(*dir, "private.pem")
+	key, err := readKey(keyPath)
+	if *force || errors.Is(err, os.ErrNotExist) {
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return err
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(*dir, 0o755); err != nil {
+			return err
+		}
+		// Readable by all, since the development sign-in of the UIs reads
+		// it as another user. The key is only for local use.
+		if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o644); err != nil {
+			return err
+		}
+		fmt.Printf("wrote %s\n", keyPath)
+	} else if err != nil {
+		return err
+	}
+
+	set, err := json.MarshalIndent(map[string]any{"keys": []any{publicJWK(&key.PublicKey)}}, "", "  ")
+	if err != nil {
+		return err
+	}
+	jwksPath := filepath.Join(*dir, "jwks.json")
+	if err := os.WriteFile(jwksPath, append(set, '\n'), 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("wrote %s (kid %s)\n", jwksPath, keyID(&key.PublicKey))
+	return nil
+}
+
+func sign(args []string) error {
+	flags := flag.NewFlagSet("sign", flag.ExitOnError)
+	keyPath := flags.String("key", "private.pem", "private key written by keygen")
+	issuer := flags.String("iss", envOr("AUTH_ISSUER", "https://auth.local"), "issuer of the token")
+	audience := flags.String("aud", os.Getenv("AUTH_AUDIENCE"), "audience of the token, if any")
+	subject := flags.String("sub", "", "subject the token is issued to")
+	roles := flags.String("roles", "", "comma separated roles: customer, backoffice-operator, admin")
+	ttl := flags.Duration("ttl", time.Hour, "how long the token is valid")
+	flags.Parse(args)
+	if flags.NArg() != 0 || *subject == "" || *ttl <= 0 {
+		usage()
+	}
+
+	key, err := readKey(*keyPath)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":   *issuer,
+		"sub":   *subject,
+		"iat":   now.Unix(),
+		"exp":   now.Add(*ttl).Unix(),
+		"roles": splitRoles(*roles),
+	}
+	if *audience != "" {
+		claims["aud"] = *audience
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["kid"] = keyID(&key.PublicKey)
+	signed, err := token.SignedString(key)
+	if err != nil {
+		return err
+	}
+	fmt.Println(signed)
+	return nil
+}
+
+func readKey(path string) (*ecdsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data", path)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	key, ok := parsed.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an ECDSA key", path)
+	}
+	return key, nil
+}
+
+// publicJWK returns the public key as a JSON Web Key.
+func publicJWK(key *ecdsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "EC",
+		"crv": "P-256",
+		"use": "sig",
+		"alg": "ES256",
+		"kid": keyID(key),
+		"x":   coordinate(key.X.Bytes()),
+		"y":   coordinate(key.Y.Bytes()),
+	}
+}
+
+// keyID is the JWK thumbprint of the key (RFC 7638), so a new key gets a
+// new id.
+func keyID(key *ecdsa.PublicKey) string {
+	thumbprint := fmt.Sprintf(`{"crv":"P-256","kty":"EC","x":"%s","y":"%s"}`, coordinate(key.X.Bytes()), coordinate(key.Y.Bytes()))
+	sum := sha256.Sum256([]byte(thumbprint))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// coordinate encodes a P-256 coordinate padded to its 32 bytes.
+func coordinate(b []byte) string {
+	padded := make([]byte, 32)
+	copy(padded[32-len(b):], b)
+	return base64.RawURLEncoding.EncodeToString(padded)
+}
+
+func splitRoles(s string) []string {
+	roles := []string{}
+	for _, role := range strings.Split(s, ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}